@@ -27,8 +27,8 @@ type AFM struct {
 	CapHeight          uint16
 	XHeight            uint16
 	Ascender           uint16
-	Descender          uint16
-	UnderlinePosition  uint16
+	Descender          uint16 // negative values are stored in two's complement
+	UnderlinePosition  uint16 // negative values are stored in two's complement
 	UnderlineThickness uint16
 	ItalicAngle        float64
 	CharWidth          [2]uint16
@@ -203,6 +203,7 @@ func (afm *AFM) parseCharMetrics(scanner *bufio.Scanner, j *int, n int) error {
 		} else if r, ok := glyphList[name]; !ok {
 			return fmt.Errorf("afm: invalid char metrics at line %v: unknown character name: %v", *j, name)
 		} else {
+			charMetrics.Name = name
 			afm.names[name] = uint16(i)
 			afm.unicode[r] = uint16(i)
 		}
@@ -339,22 +340,22 @@ Scanner:
 			}
 		case "CapHeight":
 			f, _ = afmParseNumber(line, pos)
-			afm.CapHeight = uint16(f + 0.5)
+			afm.CapHeight = uint16(int16(math.Round(f)))
 		case "XHeight":
 			f, _ = afmParseNumber(line, pos)
-			afm.XHeight = uint16(f + 0.5)
+			afm.XHeight = uint16(int16(math.Round(f)))
 		case "Ascender":
 			f, _ = afmParseNumber(line, pos)
-			afm.Ascender = uint16(f + 0.5)
+			afm.Ascender = uint16(int16(math.Round(f)))
 		case "Descender":
 			f, _ = afmParseNumber(line, pos)
-			afm.Descender = uint16(f + 0.5)
+			afm.Descender = uint16(int16(math.Round(f)))
 		case "UnderlinePosition":
 			f, _ = afmParseNumber(line, pos)
-			afm.UnderlinePosition = uint16(f + 0.5)
+			afm.UnderlinePosition = uint16(int16(math.Round(f)))
 		case "UnderlineThickness":
 			f, _ = afmParseNumber(line, pos)
-			afm.UnderlineThickness = uint16(f + 0.5)
+			afm.UnderlineThickness = uint16(int16(math.Round(f)))
 		case "ItalicAngle":
 			afm.ItalicAngle, _ = afmParseNumber(line, pos)
 		case "StartCharMetrics":
@@ -17,6 +17,8 @@ func TestParseAFM(t *testing.T) {
 	test.T(t, afm.NumGlyphs(), uint16(315))
 	test.T(t, afm.GlyphIndex('A'), uint16(33))
 	test.T(t, afm.GlyphAdvance(33), uint16(667))
+	test.T(t, afm.GlyphName(33), "A")
+	test.T(t, int16(afm.Descender), int16(-207))
 
 	test.T(t, afm.GlyphIndex('V'), uint16(54))
 	test.T(t, afm.Kerning(33, 54), int16(-70))
@@ -0,0 +1,427 @@
+package font
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"sort"
+)
+
+// AtlasMode is the type of glyph images packed into an atlas.
+type AtlasMode int
+
+// see AtlasMode
+const (
+	AtlasCoverage AtlasMode = iota // antialiased coverage as returned by RasterizeGlyph
+	AtlasSDF                       // signed distance field as returned by GlyphSDF
+	AtlasMSDF                      // multi-channel signed distance field as returned by GlyphMSDF
+)
+
+func (mode AtlasMode) String() string {
+	switch mode {
+	case AtlasCoverage:
+		return "coverage"
+	case AtlasSDF:
+		return "sdf"
+	case AtlasMSDF:
+		return "msdf"
+	}
+	return fmt.Sprintf("AtlasMode(%d)", int(mode))
+}
+
+// AtlasOptions are the options for packing glyphs into an atlas.
+type AtlasOptions struct {
+	PPEM                  uint16 // pixels-per-EM, default is 32
+	Mode                  AtlasMode
+	Hinting               Hinting // only used for AtlasCoverage
+	Range                 float64 // distance range in pixels for AtlasSDF and AtlasMSDF, default is 4
+	PageWidth, PageHeight int     // default is 512x512
+	Padding               int     // empty pixels around each glyph, default is 1
+}
+
+// AtlasGlyph is a glyph packed into an atlas page. Positions and sizes are in pixels, and the UV coordinates are normalized to the page size with the origin at the top-left corner.
+type AtlasGlyph struct {
+	GlyphID            uint16
+	Unicode            []rune
+	Page               int
+	X, Y               int
+	Width, Height      int
+	U0, V0, U1, V1     float64
+	BearingX, BearingY int // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Advance            float64
+}
+
+// AtlasKerning is the advance correction in pixels for a glyph pair.
+type AtlasKerning struct {
+	Left    uint16  `json:"left"`
+	Right   uint16  `json:"right"`
+	Advance float64 `json:"advance"`
+}
+
+// Atlas is a set of glyph images packed into one or more texture pages together with their metrics. Pages are *image.Alpha for AtlasCoverage, *image.Gray for AtlasSDF, and *image.RGBA for AtlasMSDF.
+type Atlas struct {
+	PPEM                            uint16
+	Mode                            AtlasMode
+	Range                           float64
+	Padding                         int     // empty pixels around each glyph
+	Ascender, Descender, LineHeight float64 // in pixels
+	Pages                           []draw.Image
+	Glyphs                          []AtlasGlyph
+	Kerning                         []AtlasKerning
+}
+
+// Atlas rasterizes the glyphs and packs them into texture pages using a skyline bin packer. Kerning pairs between the glyphs are taken from the kern table and the kern feature of the GPOS table.
+func (sfnt *SFNT) Atlas(glyphIDs []uint16, options AtlasOptions) (*Atlas, error) {
+	if options.PPEM == 0 {
+		options.PPEM = 32
+	}
+	if options.Range == 0.0 {
+		options.Range = 4.0
+	}
+	if options.PageWidth == 0 {
+		options.PageWidth = 512
+	}
+	if options.PageHeight == 0 {
+		options.PageHeight = 512
+	}
+	if options.Padding == 0 {
+		options.Padding = 1
+	} else if options.Padding < 0 {
+		options.Padding = 0
+	}
+
+	f := float64(options.PPEM) / float64(sfnt.Head.UnitsPerEm)
+	ascender, descender, lineGap := sfnt.VerticalMetrics()
+	atlas := &Atlas{
+		PPEM:       options.PPEM,
+		Mode:       options.Mode,
+		Range:      options.Range,
+		Padding:    options.Padding,
+		Ascender:   f * float64(ascender),
+		Descender:  f * float64(descender),
+		LineHeight: f * float64(ascender+descender+lineGap),
+	}
+
+	// render glyphs, remove duplicates
+	images := []image.Image{}
+	seen := map[uint16]bool{}
+	for _, glyphID := range glyphIDs {
+		if seen[glyphID] {
+			continue
+		} else if sfnt.NumGlyphs() <= glyphID {
+			return nil, fmt.Errorf("glyph ID %d out of range", glyphID)
+		}
+		seen[glyphID] = true
+
+		glyph := AtlasGlyph{
+			GlyphID: glyphID,
+			Unicode: sfnt.GlyphToUnicode(glyphID),
+			Advance: f * float64(sfnt.GlyphAdvance(glyphID)),
+		}
+		var img image.Image
+		switch options.Mode {
+		case AtlasCoverage:
+			raster, err := sfnt.RasterizeGlyph(glyphID, options.PPEM, options.Hinting, 0.0)
+			if err != nil {
+				return nil, err
+			}
+			img, glyph.BearingX, glyph.BearingY = raster.Alpha, raster.BearingX, raster.BearingY
+		case AtlasSDF:
+			sdf, err := sfnt.GlyphSDF(glyphID, options.PPEM, options.Range)
+			if err != nil {
+				return nil, err
+			}
+			img, glyph.BearingX, glyph.BearingY = sdf.Gray, sdf.BearingX, sdf.BearingY
+		case AtlasMSDF:
+			msdf, err := sfnt.GlyphMSDF(glyphID, options.PPEM, options.Range)
+			if err != nil {
+				return nil, err
+			}
+			img, glyph.BearingX, glyph.BearingY = msdf.RGBA, msdf.BearingX, msdf.BearingY
+		default:
+			return nil, fmt.Errorf("unknown atlas mode %v", options.Mode)
+		}
+		glyph.Width, glyph.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		if options.PageWidth < glyph.Width+2*options.Padding || options.PageHeight < glyph.Height+2*options.Padding {
+			return nil, fmt.Errorf("glyph ID %d does not fit in a page of %dx%d", glyphID, options.PageWidth, options.PageHeight)
+		}
+		atlas.Glyphs = append(atlas.Glyphs, glyph)
+		images = append(images, img)
+	}
+
+	// pack the tallest glyphs first
+	order := make([]int, len(atlas.Glyphs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		gi, gj := atlas.Glyphs[order[i]], atlas.Glyphs[order[j]]
+		if gi.Height != gj.Height {
+			return gj.Height < gi.Height
+		}
+		return gj.Width < gi.Width
+	})
+
+	packers := []*atlasSkyline{}
+	for _, i := range order {
+		glyph := &atlas.Glyphs[i]
+		if glyph.Width == 0 || glyph.Height == 0 {
+			continue
+		}
+
+		w, h := glyph.Width+2*options.Padding, glyph.Height+2*options.Padding
+		page, x, y := -1, 0, 0
+		for j, packer := range packers {
+			var ok bool
+			if x, y, ok = packer.insert(w, h); ok {
+				page = j
+				break
+			}
+		}
+		if page == -1 {
+			page = len(packers)
+			packers = append(packers, newAtlasSkyline(options.PageWidth, options.PageHeight))
+			x, y, _ = packers[page].insert(w, h)
+
+			rect := image.Rect(0, 0, options.PageWidth, options.PageHeight)
+			switch options.Mode {
+			case AtlasCoverage:
+				atlas.Pages = append(atlas.Pages, image.NewAlpha(rect))
+			case AtlasSDF:
+				atlas.Pages = append(atlas.Pages, image.NewGray(rect))
+			case AtlasMSDF:
+				atlas.Pages = append(atlas.Pages, image.NewRGBA(rect))
+			}
+		}
+
+		glyph.Page = page
+		glyph.X, glyph.Y = x+options.Padding, y+options.Padding
+		glyph.U0 = float64(glyph.X) / float64(options.PageWidth)
+		glyph.V0 = float64(glyph.Y) / float64(options.PageHeight)
+		glyph.U1 = float64(glyph.X+glyph.Width) / float64(options.PageWidth)
+		glyph.V1 = float64(glyph.Y+glyph.Height) / float64(options.PageHeight)
+		dst := image.Rect(glyph.X, glyph.Y, glyph.X+glyph.Width, glyph.Y+glyph.Height)
+		draw.Draw(atlas.Pages[page], dst, images[i], image.Point{}, draw.Src)
+	}
+
+	// kerning pairs
+	if _, ok := sfnt.Tables["GPOS"]; ok && sfnt.Gpos == nil {
+		if err := sfnt.parseGPOS(); err != nil {
+			sfnt.Gpos = nil
+		}
+	}
+	for _, left := range atlas.Glyphs {
+		for _, right := range atlas.Glyphs {
+			kerning := sfnt.Kerning(left.GlyphID, right.GlyphID)
+			if kerning == 0 && sfnt.Gpos != nil {
+				kerning, _ = sfnt.Gpos.kerning(left.GlyphID, right.GlyphID)
+			}
+			if kerning != 0 {
+				atlas.Kerning = append(atlas.Kerning, AtlasKerning{
+					Left:    left.GlyphID,
+					Right:   right.GlyphID,
+					Advance: f * float64(kerning),
+				})
+			}
+		}
+	}
+	return atlas, nil
+}
+
+// WriteJSON writes the atlas metrics as JSON, where pages are the filenames of the page images.
+func (atlas *Atlas) WriteJSON(w io.Writer, pages []string) error {
+	if len(pages) != len(atlas.Pages) {
+		return fmt.Errorf("expected %d page filenames", len(atlas.Pages))
+	}
+
+	type jsonGlyph struct {
+		ID      uint16     `json:"id"`
+		Unicode []int      `json:"unicode,omitempty"`
+		Page    int        `json:"page"`
+		Rect    [4]int     `json:"rect"`
+		UV      [4]float64 `json:"uv"`
+		Bearing [2]int     `json:"bearing"`
+		Advance float64    `json:"advance"`
+	}
+	type jsonAtlas struct {
+		PPEM       uint16         `json:"ppem"`
+		Mode       string         `json:"mode"`
+		Range      float64        `json:"range,omitempty"`
+		Ascender   float64        `json:"ascender"`
+		Descender  float64        `json:"descender"`
+		LineHeight float64        `json:"lineHeight"`
+		Pages      []string       `json:"pages"`
+		Glyphs     []jsonGlyph    `json:"glyphs"`
+		Kerning    []AtlasKerning `json:"kerning"`
+	}
+
+	v := jsonAtlas{
+		PPEM:       atlas.PPEM,
+		Mode:       atlas.Mode.String(),
+		Ascender:   atlas.Ascender,
+		Descender:  atlas.Descender,
+		LineHeight: atlas.LineHeight,
+		Pages:      pages,
+		Glyphs:     make([]jsonGlyph, len(atlas.Glyphs)),
+		Kerning:    atlas.Kerning,
+	}
+	if atlas.Mode != AtlasCoverage {
+		v.Range = atlas.Range
+	}
+	if v.Kerning == nil {
+		v.Kerning = []AtlasKerning{}
+	}
+	for i, glyph := range atlas.Glyphs {
+		unicode := make([]int, len(glyph.Unicode))
+		for j, r := range glyph.Unicode {
+			unicode[j] = int(r)
+		}
+		v.Glyphs[i] = jsonGlyph{
+			ID:      glyph.GlyphID,
+			Unicode: unicode,
+			Page:    glyph.Page,
+			Rect:    [4]int{glyph.X, glyph.Y, glyph.Width, glyph.Height},
+			UV:      [4]float64{glyph.U0, glyph.V0, glyph.U1, glyph.V1},
+			Bearing: [2]int{glyph.BearingX, glyph.BearingY},
+			Advance: glyph.Advance,
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteBMFont writes the atlas metrics in the text format of AngelCode's BMFont, where pages are the filenames of the page images. Glyphs without a Unicode mapping are omitted since characters are identified by their code point.
+func (atlas *Atlas) WriteBMFont(w io.Writer, face string, pages []string) error {
+	if len(pages) != len(atlas.Pages) {
+		return fmt.Errorf("expected %d page filenames", len(atlas.Pages))
+	}
+
+	width, height := 0, 0
+	if 0 < len(atlas.Pages) {
+		width, height = atlas.Pages[0].Bounds().Dx(), atlas.Pages[0].Bounds().Dy()
+	}
+	base := int(math.Round(atlas.Ascender))
+	p := atlas.Padding
+	fmt.Fprintf(w, "info face=%q size=%d bold=0 italic=0 charset=\"\" unicode=1 stretchH=100 smooth=1 aa=1 padding=%d,%d,%d,%d spacing=0,0\n", face, atlas.PPEM, p, p, p, p)
+	fmt.Fprintf(w, "common lineHeight=%d base=%d scaleW=%d scaleH=%d pages=%d packed=0\n", int(math.Round(atlas.LineHeight)), base, width, height, len(atlas.Pages))
+	for i, page := range pages {
+		fmt.Fprintf(w, "page id=%d file=%q\n", i, page)
+	}
+
+	chars := map[uint16]rune{}
+	for _, glyph := range atlas.Glyphs {
+		if 0 < len(glyph.Unicode) {
+			chars[glyph.GlyphID] = glyph.Unicode[0]
+		}
+	}
+	fmt.Fprintf(w, "chars count=%d\n", len(chars))
+	for _, glyph := range atlas.Glyphs {
+		if r, ok := chars[glyph.GlyphID]; ok {
+			// like BMFont, the character rectangle includes the padding of packed glyphs
+			x, y, width, height, xoffset, yoffset := glyph.X, glyph.Y, glyph.Width, glyph.Height, glyph.BearingX, base-glyph.BearingY
+			if width != 0 && height != 0 {
+				x, y, width, height, xoffset, yoffset = x-p, y-p, width+2*p, height+2*p, xoffset-p, yoffset-p
+			}
+			fmt.Fprintf(w, "char id=%d x=%d y=%d width=%d height=%d xoffset=%d yoffset=%d xadvance=%d page=%d chnl=15\n", r, x, y, width, height, xoffset, yoffset, int(math.Round(glyph.Advance)), glyph.Page)
+		}
+	}
+
+	kerning := []AtlasKerning{}
+	for _, kern := range atlas.Kerning {
+		if _, ok := chars[kern.Left]; !ok {
+			continue
+		} else if _, ok := chars[kern.Right]; !ok {
+			continue
+		} else if math.Round(kern.Advance) != 0.0 {
+			kerning = append(kerning, kern)
+		}
+	}
+	fmt.Fprintf(w, "kernings count=%d\n", len(kerning))
+	for _, kern := range kerning {
+		if _, err := fmt.Fprintf(w, "kerning first=%d second=%d amount=%d\n", chars[kern.Left], chars[kern.Right], int(math.Round(kern.Advance))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type atlasSkylineNode struct {
+	x, y, width int
+}
+
+// atlasSkyline is a rectangle bin packer that keeps track of the bottom edge (the skyline) of the packed rectangles, where each rectangle is placed at the position that keeps the skyline lowest.
+type atlasSkyline struct {
+	width, height int
+	nodes         []atlasSkylineNode
+}
+
+func newAtlasSkyline(width, height int) *atlasSkyline {
+	return &atlasSkyline{
+		width:  width,
+		height: height,
+		nodes:  []atlasSkylineNode{{0, 0, width}},
+	}
+}
+
+// fit returns the top position of a rectangle of width w placed at node i.
+func (s *atlasSkyline) fit(i, w, h int) (int, bool) {
+	if s.width < s.nodes[i].x+w {
+		return 0, false
+	}
+	y := 0
+	for remaining := w; 0 < remaining; i++ {
+		y = max(y, s.nodes[i].y)
+		if s.height < y+h {
+			return 0, false
+		}
+		remaining -= s.nodes[i].width
+	}
+	return y, true
+}
+
+// insert returns the position of a rectangle of size w x h, or false if it does not fit.
+func (s *atlasSkyline) insert(w, h int) (int, int, bool) {
+	best, bestY, bestWidth := -1, math.MaxInt, math.MaxInt
+	for i := range s.nodes {
+		if y, ok := s.fit(i, w, h); ok && (y+h < bestY || y+h == bestY && s.nodes[i].width < bestWidth) {
+			best, bestY, bestWidth = i, y+h, s.nodes[i].width
+		}
+	}
+	if best == -1 {
+		return 0, 0, false
+	}
+
+	x, y := s.nodes[best].x, bestY-h
+	s.nodes = append(s.nodes[:best], append([]atlasSkylineNode{{x, y + h, w}}, s.nodes[best:]...)...)
+	for i := best + 1; i < len(s.nodes); i++ {
+		prev := s.nodes[i-1]
+		if s.nodes[i].x < prev.x+prev.width {
+			shrink := prev.x + prev.width - s.nodes[i].x
+			s.nodes[i].x += shrink
+			s.nodes[i].width -= shrink
+			if s.nodes[i].width <= 0 {
+				s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+				i--
+				continue
+			}
+		}
+		break
+	}
+
+	// merge nodes at the same height
+	for i := 0; i+1 < len(s.nodes); i++ {
+		if s.nodes[i].y == s.nodes[i+1].y {
+			s.nodes[i].width += s.nodes[i+1].width
+			s.nodes = append(s.nodes[:i+1], s.nodes[i+2:]...)
+			i--
+		}
+	}
+	return x, y, true
+}
@@ -0,0 +1,121 @@
+package font
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestAtlasSkyline(t *testing.T) {
+	s := newAtlasSkyline(10, 10)
+	rects := []image.Rectangle{}
+	for _, size := range [][2]int{{6, 4}, {4, 6}, {5, 3}, {5, 3}, {3, 3}} {
+		x, y, ok := s.insert(size[0], size[1])
+		test.That(t, ok)
+		rects = append(rects, image.Rect(x, y, x+size[0], y+size[1]))
+	}
+	for i := range rects {
+		test.That(t, rects[i].In(image.Rect(0, 0, 10, 10)), rects[i])
+		for j := i + 1; j < len(rects); j++ {
+			test.That(t, !rects[i].Overlaps(rects[j]), rects[i], rects[j])
+		}
+	}
+
+	_, _, ok := s.insert(8, 8)
+	test.That(t, !ok)
+}
+
+func TestAtlas(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	glyphIDs := []uint16{}
+	for _, r := range "AVToa A" {
+		glyphIDs = append(glyphIDs, sfnt.GlyphIndex(r))
+	}
+
+	// small pages to force multiple pages
+	atlas, err := sfnt.Atlas(glyphIDs, AtlasOptions{PPEM: 24, PageWidth: 32, PageHeight: 32})
+	test.Error(t, err)
+	test.T(t, len(atlas.Glyphs), 6)
+	test.That(t, 1 < len(atlas.Pages))
+	for i, g1 := range atlas.Glyphs {
+		r1 := image.Rect(g1.X, g1.Y, g1.X+g1.Width, g1.Y+g1.Height)
+		test.That(t, r1.In(atlas.Pages[g1.Page].Bounds()))
+		for _, g2 := range atlas.Glyphs[i+1:] {
+			r2 := image.Rect(g2.X, g2.Y, g2.X+g2.Width, g2.Y+g2.Height)
+			test.That(t, g1.Page != g2.Page || !r1.Overlaps(r2), r1, r2)
+		}
+	}
+
+	glyph := atlas.Glyphs[0]
+	raster, err := sfnt.RasterizeGlyph(glyph.GlyphID, 24, NoHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, glyph.Unicode, []rune{'A'})
+	test.T(t, glyph.BearingY, raster.BearingY)
+	test.Float(t, glyph.Advance, float64(sfnt.GlyphAdvance(glyph.GlyphID))*24.0/float64(sfnt.Head.UnitsPerEm))
+	test.Float(t, glyph.U0, float64(glyph.X)/32.0)
+	page := atlas.Pages[glyph.Page].(*image.Alpha)
+	test.T(t, page.SubImage(image.Rect(glyph.X, glyph.Y, glyph.X+glyph.Width, glyph.Y+glyph.Height)).(*image.Alpha).Pix[0], raster.Pix[0])
+
+	// A-V is kerned
+	kerned := false
+	for _, kern := range atlas.Kerning {
+		if kern.Left == sfnt.GlyphIndex('A') && kern.Right == sfnt.GlyphIndex('V') {
+			test.That(t, kern.Advance < 0.0)
+			kerned = true
+		}
+	}
+	test.That(t, kerned)
+
+	pages := make([]string, len(atlas.Pages))
+	for i := range pages {
+		pages[i] = "page.png"
+	}
+	buf := &bytes.Buffer{}
+	test.Error(t, atlas.WriteJSON(buf, pages))
+	var manifest struct {
+		PPEM   uint16 `json:"ppem"`
+		Glyphs []struct {
+			ID uint16     `json:"id"`
+			UV [4]float64 `json:"uv"`
+		} `json:"glyphs"`
+	}
+	test.Error(t, json.Unmarshal(buf.Bytes(), &manifest))
+	test.T(t, manifest.PPEM, uint16(24))
+	test.T(t, manifest.Glyphs[0].UV, [4]float64{glyph.U0, glyph.V0, glyph.U1, glyph.V1})
+
+	buf.Reset()
+	test.Error(t, atlas.WriteBMFont(buf, "DejaVu Serif", pages))
+	test.That(t, strings.Contains(buf.String(), "chars count=6\n"), buf.String())
+	test.That(t, strings.Contains(buf.String(), " padding=1,1,1,1 "), buf.String())
+	charA := fmt.Sprintf("char id=65 x=%d y=%d width=%d height=%d xoffset=%d ", glyph.X-1, glyph.Y-1, glyph.Width+2, glyph.Height+2, glyph.BearingX-1)
+	test.That(t, strings.Contains(buf.String(), charA), buf.String())
+	test.That(t, strings.Contains(buf.String(), "kerning first=65 second=86 amount=-"), buf.String())
+
+	// SDF pages
+	atlas, err = sfnt.Atlas(glyphIDs[:1], AtlasOptions{Mode: AtlasMSDF})
+	test.Error(t, err)
+	_, ok := atlas.Pages[0].(*image.RGBA)
+	test.That(t, ok)
+
+	// kerning from GPOS
+	b, err = ioutil.ReadFile("resources/EBGaramond12-Regular.otf")
+	test.Error(t, err)
+	sfnt, err = ParseSFNT(b, 0)
+	test.Error(t, err)
+	test.That(t, sfnt.Kern == nil)
+
+	atlas, err = sfnt.Atlas([]uint16{sfnt.GlyphIndex('T'), sfnt.GlyphIndex('o')}, AtlasOptions{PPEM: 10})
+	test.Error(t, err)
+	test.T(t, atlas.Kerning[0], AtlasKerning{sfnt.GlyphIndex('T'), sfnt.GlyphIndex('o'), -1.05})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/font"
+	"github.com/tdewolff/prompt"
+)
+
+type Atlas struct {
+	Quiet         bool     `short:"q" desc:"Suppress output except for errors."`
+	Force         bool     `short:"f" desc:"Force overwriting existing files."`
+	Glyphs        []string `short:"g" name:"glyph" desc:"List of glyph IDs to include, eg. 1-100."`
+	Chars         []string `short:"c" name:"char" desc:"List of literal characters to include, eg. a-z. The same escape sequences are supported as for Go strings, where , and - also need escaping. Defaults to printable ASCII if no glyphs are selected."`
+	Names         []string `short:"n" name:"name" desc:"List of glyph names to include, eg. space."`
+	Unicodes      []string `short:"u" name:"unicode" desc:"List of unicode IDs to include, eg. f0fc-f0ff."`
+	UnicodeRanges []string `short:"r" name:"range" desc:"List of unicode categories or scripts to include, eg. L (for Letters) or Latin (latin script). See https://pkg.go.dev/unicode for all supported values."`
+	Index         int      `short:"i" desc:"Index into font collection (used with TTC or OTC)."`
+	PPEM          uint16   `default:"32" desc:"Pixels per em-square."`
+	Mode          string   `short:"m" default:"coverage" desc:"Glyph image type, either coverage, sdf, or msdf."`
+	Range         float64  `name:"distance" default:"4" desc:"Distance range in pixels for sdf and msdf."`
+	Hinting       bool     `desc:"Hint glyph outlines for coverage images."`
+	Width         int      `default:"512" desc:"Page width."`
+	Height        int      `default:"512" desc:"Page height."`
+	Padding       int      `default:"1" desc:"Empty pixels around each glyph."`
+	Format        string   `desc:"Manifest format, either json or fnt (BMFont). Defaults to the output extension."`
+	Output        string   `short:"o" desc:"Output manifest file, page images are written next to it as PNG files with the page number appended."`
+	Input         string   `index:"0" desc:"Input font file."`
+}
+
+func (cmd *Atlas) Run() error {
+	if cmd.Quiet {
+		Warning = log.New(ioutil.Discard, "", 0)
+	}
+
+	if cmd.Output == "" {
+		cmd.Output = strings.TrimSuffix(filepath.Base(cmd.Input), filepath.Ext(cmd.Input)) + ".json"
+	}
+	if cmd.Format == "" {
+		cmd.Format = strings.TrimPrefix(filepath.Ext(cmd.Output), ".")
+	}
+	if cmd.Format != "json" && cmd.Format != "fnt" {
+		return fmt.Errorf("unsupported manifest format: %v", cmd.Format)
+	}
+
+	options := font.AtlasOptions{
+		PPEM:       cmd.PPEM,
+		Range:      cmd.Range,
+		PageWidth:  cmd.Width,
+		PageHeight: cmd.Height,
+		Padding:    cmd.Padding,
+	}
+	if cmd.Padding == 0 {
+		options.Padding = -1
+	}
+	switch cmd.Mode {
+	case "coverage":
+		options.Mode = font.AtlasCoverage
+	case "sdf":
+		options.Mode = font.AtlasSDF
+	case "msdf":
+		options.Mode = font.AtlasMSDF
+	default:
+		return fmt.Errorf("unsupported mode: %v", cmd.Mode)
+	}
+	if cmd.Hinting {
+		options.Hinting = font.AutoHinting
+	}
+
+	// read from file and parse font
+	sfnt, _, _, err := readFont(cmd.Input, cmd.Index)
+	if err != nil {
+		if cmd.Input == "-" {
+			return err
+		}
+		return fmt.Errorf("%v: %v", cmd.Input, err)
+	}
+
+	if len(cmd.Glyphs) == 0 && len(cmd.Chars) == 0 && len(cmd.Names) == 0 && len(cmd.Unicodes) == 0 && len(cmd.UnicodeRanges) == 0 {
+		cmd.Chars = []string{" -~"}
+	}
+	glyphMap, err := selectGlyphs(sfnt, cmd.Glyphs, cmd.Chars, cmd.Names, cmd.Unicodes, cmd.UnicodeRanges)
+	if err != nil {
+		return err
+	}
+	glyphIDs := make([]uint16, 0, len(glyphMap))
+	for glyphID := range glyphMap {
+		glyphIDs = append(glyphIDs, glyphID)
+	}
+	sort.Slice(glyphIDs, func(i, j int) bool { return glyphIDs[i] < glyphIDs[j] })
+
+	atlas, err := sfnt.Atlas(glyphIDs, options)
+	if err != nil {
+		return err
+	}
+
+	// write pages
+	base := strings.TrimSuffix(cmd.Output, filepath.Ext(cmd.Output))
+	pages := make([]string, len(atlas.Pages))
+	for i, page := range atlas.Pages {
+		filename := fmt.Sprintf("%s_%d.png", base, i)
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, page); err != nil {
+			return err
+		} else if err := writeFile(filename, buf.Bytes(), cmd.Force); err != nil {
+			return err
+		}
+		pages[i] = filepath.Base(filename)
+	}
+
+	// write manifest
+	buf := &bytes.Buffer{}
+	if cmd.Format == "json" {
+		err = atlas.WriteJSON(buf, pages)
+	} else {
+		face := ""
+		if records := sfnt.Name.Get(font.NameFontFamily); 0 < len(records) {
+			face = records[0].String()
+		}
+		err = atlas.WriteBMFont(buf, face, pages)
+	}
+	if err != nil {
+		return err
+	} else if err := writeFile(cmd.Output, buf.Bytes(), cmd.Force); err != nil {
+		return err
+	}
+
+	if !cmd.Quiet {
+		fmt.Printf("Packed %d glyphs into %d page(s) with %d kerning pairs\n", len(atlas.Glyphs), len(atlas.Pages), len(atlas.Kerning))
+	}
+	return nil
+}
+
+func writeFile(filename string, b []byte, force bool) error {
+	if _, err := os.Stat(filename); err == nil {
+		if !force && !prompt.YesNo(fmt.Sprintf("%s already exists, overwrite?", filename), false) {
+			return fmt.Errorf("file already exists")
+		}
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+	"github.com/tdewolff/font"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/xml"
+)
+
+// drawColorGlyph draws a color glyph from the COLR, SVG, sbix, or CBDT tables onto img, in that order of preference. The matrix m maps font units to pixels. It returns false if the glyph has no color representation.
+func drawColorGlyph(img *image.RGBA, sfnt *font.SFNT, glyphID, ppem uint16, palette int, m canvas.Matrix) (bool, error) {
+	if paint, err := sfnt.GlyphPaint(glyphID); err != nil {
+		return false, err
+	} else if paint != nil {
+		colors := sfnt.Palette(palette)
+		if colors == nil && 0 < sfnt.NumPalettes() {
+			return false, fmt.Errorf("palette must be in [0,%d]", sfnt.NumPalettes()-1)
+		}
+		r := &colorRenderer{
+			sfnt:       sfnt,
+			ppem:       ppem,
+			palette:    colors,
+			foreground: color.NRGBA{0, 0, 0, 255},
+		}
+		dst := newColorLayer(img.Rect.Dx(), img.Rect.Dy())
+		if err := r.draw(dst, paint, m); err != nil {
+			return false, err
+		}
+		dst.drawOver(img)
+		return true, nil
+	}
+
+	if doc, err := sfnt.GlyphSVG(glyphID); err != nil {
+		return false, err
+	} else if doc != nil {
+		return true, drawSVGGlyph(img, doc, glyphID, m)
+	}
+
+	if glyphImage, err := sfnt.GlyphImage(glyphID, ppem); err != nil {
+		return false, err
+	} else if glyphImage != nil {
+		return true, drawImageGlyph(img, glyphImage, ppem, m)
+	}
+	return false, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// colorLayer is an image with premultiplied RGBA channels in [0,1].
+type colorLayer struct {
+	w, h int
+	pix  []float64
+}
+
+func newColorLayer(w, h int) *colorLayer {
+	return &colorLayer{w, h, make([]float64, 4*w*h)}
+}
+
+// over composites a premultiplied color onto the pixel at index i using source-over.
+func (l *colorLayer) over(i int, r, g, b, a float64) {
+	l.pix[i+0] = r + l.pix[i+0]*(1.0-a)
+	l.pix[i+1] = g + l.pix[i+1]*(1.0-a)
+	l.pix[i+2] = b + l.pix[i+2]*(1.0-a)
+	l.pix[i+3] = a + l.pix[i+3]*(1.0-a)
+}
+
+// drawOver composites the layer onto img using source-over.
+func (l *colorLayer) drawOver(img *image.RGBA) {
+	for y := 0; y < l.h; y++ {
+		for x := 0; x < l.w; x++ {
+			i, j := 4*(y*l.w+x), img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			a := l.pix[i+3]
+			for k := 0; k < 3; k++ {
+				c := l.pix[i+k] + float64(img.Pix[j+k])/255.0*(1.0-a)
+				img.Pix[j+k] = uint8(math.Max(0.0, math.Min(1.0, c))*255.0 + 0.5)
+			}
+			c := a + float64(img.Pix[j+3])/255.0*(1.0-a)
+			img.Pix[j+3] = uint8(math.Max(0.0, math.Min(1.0, c))*255.0 + 0.5)
+		}
+	}
+}
+
+// rasterPather rasterizes a glyph outline in font units, transformed to pixels by m.
+type rasterPather struct {
+	ras *vector.Rasterizer
+	m   canvas.Matrix
+}
+
+func (p rasterPather) point(x, y float64) (float32, float32) {
+	q := p.m.Dot(canvas.Point{X: x, Y: y})
+	return float32(q.X), float32(q.Y)
+}
+
+func (p rasterPather) MoveTo(x, y float64) {
+	p.ras.MoveTo(p.point(x, y))
+}
+
+func (p rasterPather) LineTo(x, y float64) {
+	p.ras.LineTo(p.point(x, y))
+}
+
+func (p rasterPather) QuadTo(cpx, cpy, x, y float64) {
+	cx, cy := p.point(cpx, cpy)
+	ex, ey := p.point(x, y)
+	p.ras.QuadTo(cx, cy, ex, ey)
+}
+
+func (p rasterPather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	c1x, c1y := p.point(cpx1, cpy1)
+	c2x, c2y := p.point(cpx2, cpy2)
+	ex, ey := p.point(x, y)
+	p.ras.CubeTo(c1x, c1y, c2x, c2y, ex, ey)
+}
+
+func (p rasterPather) Close() {
+	p.ras.ClosePath()
+}
+
+// colorRenderer renders the paint graph of COLR color glyphs.
+type colorRenderer struct {
+	sfnt       *font.SFNT
+	ppem       uint16
+	palette    []color.NRGBA
+	foreground color.NRGBA
+}
+
+// color returns the premultiplied color of a palette entry, where 0xFFFF is the foreground color.
+func (r *colorRenderer) color(paletteIndex uint16, alpha float64) [4]float64 {
+	c := r.foreground
+	if paletteIndex != 0xFFFF && int(paletteIndex) < len(r.palette) {
+		c = r.palette[paletteIndex]
+	}
+	a := float64(c.A) / 255.0 * math.Max(0.0, math.Min(1.0, alpha))
+	return [4]float64{float64(c.R) / 255.0 * a, float64(c.G) / 255.0 * a, float64(c.B) / 255.0 * a, a}
+}
+
+// colorAt returns the premultiplied color of the color line at t.
+func (r *colorRenderer) colorAt(colorLine font.ColorLine, t float64) [4]float64 {
+	stops := colorLine.Stops
+	if len(stops) == 0 {
+		return [4]float64{}
+	}
+
+	switch colorLine.Extend {
+	case font.ExtendRepeat:
+		t -= math.Floor(t)
+	case font.ExtendReflect:
+		t = math.Mod(math.Abs(t), 2.0)
+		if 1.0 < t {
+			t = 2.0 - t
+		}
+	}
+
+	if t <= stops[0].Offset {
+		return r.color(stops[0].PaletteIndex, stops[0].Alpha)
+	}
+	for i := 1; i < len(stops); i++ {
+		if t <= stops[i].Offset {
+			c0 := r.color(stops[i-1].PaletteIndex, stops[i-1].Alpha)
+			c1 := r.color(stops[i].PaletteIndex, stops[i].Alpha)
+			f := 0.0
+			if stops[i-1].Offset < stops[i].Offset {
+				f = (t - stops[i-1].Offset) / (stops[i].Offset - stops[i-1].Offset)
+			}
+			return [4]float64{
+				c0[0] + f*(c1[0]-c0[0]),
+				c0[1] + f*(c1[1]-c0[1]),
+				c0[2] + f*(c1[2]-c0[2]),
+				c0[3] + f*(c1[3]-c0[3]),
+			}
+		}
+	}
+	return r.color(stops[len(stops)-1].PaletteIndex, stops[len(stops)-1].Alpha)
+}
+
+// fill composites a gradient onto dst, where the gradient function returns the position on the color line for a point in font units, or false if the point is not painted.
+func (r *colorRenderer) fill(dst *colorLayer, m canvas.Matrix, colorLine font.ColorLine, gradient func(canvas.Point) (float64, bool)) {
+	inv := m.Inv()
+	for y := 0; y < dst.h; y++ {
+		for x := 0; x < dst.w; x++ {
+			if t, ok := gradient(inv.Dot(canvas.Point{X: float64(x) + 0.5, Y: float64(y) + 0.5})); ok {
+				c := r.colorAt(colorLine, t)
+				dst.over(4*(y*dst.w+x), c[0], c[1], c[2], c[3])
+			}
+		}
+	}
+}
+
+// draw composites the paint onto dst, where m maps font units to pixels.
+func (r *colorRenderer) draw(dst *colorLayer, paint font.Paint, m canvas.Matrix) error {
+	switch p := paint.(type) {
+	case *font.PaintLayers:
+		for _, layer := range p.Layers {
+			if err := r.draw(dst, layer, m); err != nil {
+				return err
+			}
+		}
+	case *font.PaintSolid:
+		c := r.color(p.PaletteIndex, p.Alpha)
+		for i := 0; i < len(dst.pix); i += 4 {
+			dst.over(i, c[0], c[1], c[2], c[3])
+		}
+	case *font.PaintLinearGradient:
+		// project P1 onto the line through P0 perpendicular to P0-P2
+		p0 := canvas.Point{X: p.X0, Y: p.Y0}
+		d := canvas.Point{X: p.X1 - p.X0, Y: p.Y1 - p.Y0}
+		n := canvas.Point{X: p.Y2 - p.Y0, Y: p.X0 - p.X2}
+		if nn := n.Dot(n); nn != 0.0 {
+			d = n.Mul(d.Dot(n) / nn)
+		}
+		dd := d.Dot(d)
+		r.fill(dst, m, p.ColorLine, func(q canvas.Point) (float64, bool) {
+			if dd == 0.0 {
+				return 0.0, false
+			}
+			return q.Sub(p0).Dot(d) / dd, true
+		})
+	case *font.PaintRadialGradient:
+		// find the largest t for which the point lies on the circle interpolated between both circles
+		c0 := canvas.Point{X: p.X0, Y: p.Y0}
+		cd := canvas.Point{X: p.X1 - p.X0, Y: p.Y1 - p.Y0}
+		dr := p.R1 - p.R0
+		a := cd.Dot(cd) - dr*dr
+		r.fill(dst, m, p.ColorLine, func(q canvas.Point) (float64, bool) {
+			pd := q.Sub(c0)
+			b := pd.Dot(cd) + p.R0*dr
+			c := pd.Dot(pd) - p.R0*p.R0
+			if math.Abs(a) < 1e-9 {
+				if b == 0.0 {
+					return 0.0, false
+				}
+				t := c / (2.0 * b)
+				return t, 0.0 <= p.R0+t*dr
+			}
+			discriminant := b*b - a*c
+			if discriminant < 0.0 {
+				return 0.0, false
+			}
+			t0, t1 := (b+math.Sqrt(discriminant))/a, (b-math.Sqrt(discriminant))/a
+			if t0 < t1 {
+				t0, t1 = t1, t0
+			}
+			if 0.0 <= p.R0+t0*dr {
+				return t0, true
+			} else if 0.0 <= p.R0+t1*dr {
+				return t1, true
+			}
+			return 0.0, false
+		})
+	case *font.PaintSweepGradient:
+		r.fill(dst, m, p.ColorLine, func(q canvas.Point) (float64, bool) {
+			if p.StartAngle == p.EndAngle {
+				return 0.0, false
+			}
+			angle := math.Atan2(q.Y-p.Y, q.X-p.X) * 180.0 / math.Pi
+			if angle < 0.0 {
+				angle += 360.0
+			}
+			return (angle - p.StartAngle) / (p.EndAngle - p.StartAngle), true
+		})
+	case *font.PaintGlyph:
+		src := newColorLayer(dst.w, dst.h)
+		if err := r.draw(src, p.Paint, m); err != nil {
+			return err
+		}
+
+		ras := vector.NewRasterizer(dst.w, dst.h)
+		if err := r.sfnt.GlyphPath(rasterPather{ras, m}, p.GlyphID, r.ppem, 0.0, 0.0, 1.0, font.NoHinting); err != nil {
+			return err
+		}
+		mask := image.NewAlpha(image.Rect(0, 0, dst.w, dst.h))
+		ras.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+		for i, alpha := range mask.Pix {
+			if alpha != 0 {
+				f := float64(alpha) / 255.0
+				dst.over(4*i, f*src.pix[4*i+0], f*src.pix[4*i+1], f*src.pix[4*i+2], f*src.pix[4*i+3])
+			}
+		}
+	case *font.PaintTransform:
+		return r.draw(dst, p.Paint, m.Mul(canvas.Matrix{{p.XX, p.XY, p.DX}, {p.YX, p.YY, p.DY}}))
+	case *font.PaintComposite:
+		src := newColorLayer(dst.w, dst.h)
+		if err := r.draw(src, p.Source, m); err != nil {
+			return err
+		}
+		backdrop := newColorLayer(dst.w, dst.h)
+		if err := r.draw(backdrop, p.Backdrop, m); err != nil {
+			return err
+		}
+		for i := 0; i < len(dst.pix); i += 4 {
+			var s, b [4]float64
+			copy(s[:], src.pix[i:i+4])
+			copy(b[:], backdrop.pix[i:i+4])
+			c := composite(p.Mode, s, b)
+			dst.over(i, c[0], c[1], c[2], c[3])
+		}
+	default:
+		return fmt.Errorf("unsupported paint %T", paint)
+	}
+	return nil
+}
+
+// composite composites the premultiplied source color onto the premultiplied backdrop color using the COLR composite mode.
+func composite(mode uint8, s, b [4]float64) [4]float64 {
+	as, ab := s[3], b[3]
+	var fs, fb float64 // Porter-Duff factors
+	switch mode {
+	case 0: // clear
+		return [4]float64{}
+	case 1: // source
+		return s
+	case 2: // destination
+		return b
+	case 4: // destination-over
+		fs, fb = 1.0-ab, 1.0
+	case 5: // source-in
+		fs, fb = ab, 0.0
+	case 6: // destination-in
+		fs, fb = 0.0, as
+	case 7: // source-out
+		fs, fb = 1.0-ab, 0.0
+	case 8: // destination-out
+		fs, fb = 0.0, 1.0-as
+	case 9: // source-atop
+		fs, fb = ab, 1.0-as
+	case 10: // destination-atop
+		fs, fb = 1.0-ab, as
+	case 11: // xor
+		fs, fb = 1.0-ab, 1.0-as
+	case 12: // plus
+		return [4]float64{
+			math.Min(1.0, s[0]+b[0]),
+			math.Min(1.0, s[1]+b[1]),
+			math.Min(1.0, s[2]+b[2]),
+			math.Min(1.0, s[3]+b[3]),
+		}
+	case 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27:
+		// blend modes, see https://www.w3.org/TR/compositing-1/
+		var cs, cb [3]float64 // unpremultiplied
+		for k := 0; k < 3; k++ {
+			if as != 0.0 {
+				cs[k] = s[k] / as
+			}
+			if ab != 0.0 {
+				cb[k] = b[k] / ab
+			}
+		}
+		blended := blend(mode, cs, cb)
+		c := [4]float64{0.0, 0.0, 0.0, as + ab - as*ab}
+		for k := 0; k < 3; k++ {
+			c[k] = (1.0-ab)*s[k] + (1.0-as)*b[k] + as*ab*blended[k]
+		}
+		return c
+	default: // source-over
+		fs, fb = 1.0, 1.0-as
+	}
+	return [4]float64{
+		fs*s[0] + fb*b[0],
+		fs*s[1] + fb*b[1],
+		fs*s[2] + fb*b[2],
+		fs*s[3] + fb*b[3],
+	}
+}
+
+// blend returns the blended unpremultiplied color of the source and backdrop for a COLR blend mode.
+func blend(mode uint8, cs, cb [3]float64) [3]float64 {
+	switch mode {
+	case 24: // hue
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case 25: // saturation
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case 26: // color
+		return setLum(cs, lum(cb))
+	case 27: // luminosity
+		return setLum(cb, lum(cs))
+	}
+
+	var c [3]float64
+	for k := 0; k < 3; k++ {
+		s, b := cs[k], cb[k]
+		switch mode {
+		case 13: // screen
+			c[k] = b + s - b*s
+		case 14: // overlay
+			c[k] = hardLight(b, s)
+		case 15: // darken
+			c[k] = math.Min(s, b)
+		case 16: // lighten
+			c[k] = math.Max(s, b)
+		case 17: // color-dodge
+			if b == 0.0 {
+				c[k] = 0.0
+			} else if s == 1.0 {
+				c[k] = 1.0
+			} else {
+				c[k] = math.Min(1.0, b/(1.0-s))
+			}
+		case 18: // color-burn
+			if b == 1.0 {
+				c[k] = 1.0
+			} else if s == 0.0 {
+				c[k] = 0.0
+			} else {
+				c[k] = 1.0 - math.Min(1.0, (1.0-b)/s)
+			}
+		case 19: // hard-light
+			c[k] = hardLight(s, b)
+		case 20: // soft-light
+			if s <= 0.5 {
+				c[k] = b - (1.0-2.0*s)*b*(1.0-b)
+			} else {
+				d := math.Sqrt(b)
+				if b <= 0.25 {
+					d = ((16.0*b-12.0)*b + 4.0) * b
+				}
+				c[k] = b + (2.0*s-1.0)*(d-b)
+			}
+		case 21: // difference
+			c[k] = math.Abs(b - s)
+		case 22: // exclusion
+			c[k] = b + s - 2.0*b*s
+		case 23: // multiply
+			c[k] = b * s
+		}
+	}
+	return c
+}
+
+func hardLight(s, b float64) float64 {
+	if s <= 0.5 {
+		return b * 2.0 * s
+	}
+	s = 2.0*s - 1.0
+	return b + s - b*s
+}
+
+func lum(c [3]float64) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+func sat(c [3]float64) float64 {
+	return math.Max(c[0], math.Max(c[1], c[2])) - math.Min(c[0], math.Min(c[1], c[2]))
+}
+
+func setLum(c [3]float64, l float64) [3]float64 {
+	d := l - lum(c)
+	c = [3]float64{c[0] + d, c[1] + d, c[2] + d}
+
+	// clip color
+	l = lum(c)
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+	for k := 0; k < 3; k++ {
+		if n < 0.0 {
+			c[k] = l + (c[k]-l)*l/(l-n)
+		}
+		if 1.0 < x {
+			c[k] = l + (c[k]-l)*(1.0-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func setSat(c [3]float64, s float64) [3]float64 {
+	imin, imid, imax := 0, 1, 2
+	if c[imid] < c[imin] {
+		imin, imid = imid, imin
+	}
+	if c[imax] < c[imid] {
+		imid, imax = imax, imid
+	}
+	if c[imid] < c[imin] {
+		imin, imid = imid, imin
+	}
+
+	var r [3]float64
+	if c[imin] < c[imax] {
+		r[imid] = (c[imid] - c[imin]) * s / (c[imax] - c[imin])
+		r[imax] = s
+	}
+	return r
+}
+
+////////////////////////////////////////////////////////////////
+
+// drawSVGGlyph renders the glyph from an SVG document onto img, where m maps font units to pixels.
+func drawSVGGlyph(img *image.RGBA, doc []byte, glyphID uint16, m canvas.Matrix) error {
+	// the SVG glyph is in font units with the y-axis pointing downwards, map the image area to the viewBox
+	inv := m.Inv()
+	topLeft := inv.Dot(canvas.Point{X: 0.0, Y: 0.0})
+	bottomRight := inv.Dot(canvas.Point{X: float64(img.Rect.Dx()), Y: float64(img.Rect.Dy())})
+	viewBox := fmt.Sprintf("%g %g %g %g", topLeft.X, -topLeft.Y, bottomRight.X-topLeft.X, topLeft.Y-bottomRight.Y)
+
+	doc = svgGlyphDocument(doc, glyphID, viewBox, img.Rect.Dx(), img.Rect.Dy())
+	c, err := canvas.ParseSVG(bytes.NewReader(doc))
+	if err != nil {
+		return fmt.Errorf("SVG: %v", err)
+	}
+	src := rasterizer.Draw(c, canvas.DPMM(float64(img.Rect.Dx())/c.W), canvas.DefaultColorSpace)
+	draw.Draw(img, img.Rect, src, image.Point{}, draw.Over)
+	return nil
+}
+
+// svgGlyphDocument returns an SVG document with only the glyph element and definitions, that is the element with ID "glyph" followed by the glyph ID. The root element's viewBox and dimensions are replaced. If the glyph is not a child of the root element, all elements are kept.
+func svgGlyphDocument(doc []byte, glyphID uint16, viewBox string, width, height int) []byte {
+	id := fmt.Sprintf("glyph%d", glyphID)
+	filter := func(keepAll bool) ([]byte, bool) {
+		var buf, start bytes.Buffer // start buffers the start tag of the root's children
+		found, isGlyph := false, false
+		depth, skip := 0, 0 // skip is the depth of the skipped element, or zero
+		var tag string
+		l := xml.NewLexer(parse.NewInputBytes(doc))
+		for {
+			tt, data := l.Next()
+			if tt == xml.ErrorToken {
+				break
+			} else if skip != 0 {
+				if tt == xml.StartTagToken {
+					depth++
+				} else if tt == xml.StartTagCloseVoidToken || tt == xml.EndTagToken {
+					if depth == skip {
+						skip = 0
+					}
+					depth--
+				}
+				continue
+			}
+
+			switch tt {
+			case xml.StartTagToken:
+				depth++
+				if depth == 2 {
+					tag, isGlyph = string(l.Text()), false
+					start.Reset()
+					start.Write(data)
+					continue
+				}
+			case xml.AttributeToken:
+				if depth == 1 {
+					if name := string(l.Text()); name == "viewBox" || name == "width" || name == "height" {
+						continue
+					}
+				} else if depth == 2 {
+					if string(l.Text()) == "id" && strings.Trim(string(l.AttrVal()), `"'`) == id {
+						isGlyph = true
+					}
+					start.Write(data)
+					continue
+				}
+			case xml.StartTagCloseToken, xml.StartTagCloseVoidToken:
+				if depth == 1 {
+					fmt.Fprintf(&buf, ` viewBox="%s" width="%d" height="%d"`, viewBox, width, height)
+				} else if depth == 2 {
+					found = found || isGlyph
+					if !keepAll && !isGlyph && tag != "defs" && tag != "style" {
+						if tt == xml.StartTagCloseToken {
+							skip = depth
+						} else {
+							depth--
+						}
+						continue
+					}
+					buf.Write(start.Bytes())
+				}
+				if tt == xml.StartTagCloseVoidToken {
+					depth--
+				}
+			case xml.EndTagToken:
+				depth--
+			}
+			buf.Write(data)
+		}
+		return buf.Bytes(), found
+	}
+
+	if filtered, found := filter(false); found {
+		return filtered
+	}
+	filtered, _ := filter(true)
+	return filtered
+}
+
+////////////////////////////////////////////////////////////////
+
+// drawImageGlyph draws an embedded image of a glyph onto img, scaled from the strike's ppem to ppem. The matrix m maps font units to pixels.
+func drawImageGlyph(img *image.RGBA, glyphImage *font.GlyphImage, ppem uint16, m canvas.Matrix) error {
+	src, _, err := image.Decode(bytes.NewReader(glyphImage.Data))
+	if err != nil {
+		return fmt.Errorf("%s: %v", strings.TrimSpace(glyphImage.Format), err)
+	}
+
+	f := float64(ppem) / float64(glyphImage.PPEM)
+	origin := m.Dot(canvas.Point{})
+	size := src.Bounds().Size()
+	x0 := origin.X + float64(glyphImage.X)*f
+	y1 := origin.Y - float64(glyphImage.Y)*f
+	rect := image.Rect(
+		int(math.Round(x0)),
+		int(math.Round(y1-float64(size.Y)*f)),
+		int(math.Round(x0+float64(size.X)*f)),
+		int(math.Round(y1)),
+	)
+	draw.ApproxBiLinear.Scale(img, rect, src, src.Bounds(), draw.Over, nil)
+	return nil
+}
@@ -28,6 +28,7 @@ type Show struct {
 	PPEM    uint16  `default:"40" desc:"Pixels per em-square"`
 	Scale   int     `default:"4" desc:"Image scale"`
 	Ratio   float64 `desc:"Image width/height ratio"`
+	Palette int     `desc:"Color palette index for color glyphs"`
 	Output  string  `short:"o" desc:"Output filename, supports jpg, png, gif, and tiff"`
 	Input   string  `index:"0" desc:"Input file"`
 }
@@ -95,21 +96,32 @@ func (cmd *Show) Run() error {
 
 	f := float64(cmd.PPEM) / float64(sfnt.Head.UnitsPerEm)
 
-	p := &canvas.Path{}
-	err = sfnt.GlyphPath(p, cmd.GlyphID, cmd.PPEM, 0.0, float64(descent), 1.0, font.NoHinting)
-	if err != nil {
-		return err
-	}
-
 	rect := image.Rect(0, 0, width+2*xpadding, height+2*ypadding)
 	glyphRect := image.Rect(xpadding, ypadding, width+xpadding, height+ypadding)
 
 	img := image.NewRGBA(rect)
 	draw.Draw(img, rect, image.NewUniform(canvas.White), image.ZP, draw.Over)
 
-	ras := vector.NewRasterizer(width, height)
-	p.ToVectorRasterizer(ras, canvas.DPMM(f))
-	ras.Draw(img, glyphRect, image.NewUniform(canvas.Black), image.ZP)
+	// color glyphs are only drawn to image files
+	colored := false
+	if !terminal {
+		m := canvas.Identity.Translate(float64(xpadding), float64(ypadding+height)-float64(descent)*f).Scale(f, -f)
+		if colored, err = drawColorGlyph(img, sfnt, cmd.GlyphID, cmd.PPEM, cmd.Palette, m); err != nil {
+			return err
+		}
+	}
+
+	if !colored {
+		p := &canvas.Path{}
+		err = sfnt.GlyphPath(p, cmd.GlyphID, cmd.PPEM, 0.0, float64(descent), 1.0, font.NoHinting)
+		if err != nil {
+			return err
+		}
+
+		ras := vector.NewRasterizer(width, height)
+		p.ToVectorRasterizer(ras, canvas.DPMM(f))
+		ras.Draw(img, glyphRect, image.NewUniform(canvas.Black), image.ZP)
+	}
 
 	if cmd.Ratio == 0.0 {
 		if terminal {
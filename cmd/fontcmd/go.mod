@@ -10,6 +10,7 @@ require (
 	github.com/tdewolff/font v0.0.0-20260420205100-f6940a171d9c
 	github.com/tdewolff/parse/v2 v2.8.12
 	github.com/tdewolff/prompt v0.0.0-20260129133615-dc83e89202db
+	github.com/tdewolff/test v1.0.12
 	golang.org/x/image v0.39.0
 )
 
@@ -22,5 +22,6 @@ func main() {
 	cmd.AddCmd(&Subset{}, "subset", "Subset fonts")
 	cmd.AddCmd(&CSS{}, "css", "Create CSS file")
 	cmd.AddCmd(&Merge{}, "merge", "Merge fonts")
+	cmd.AddCmd(&Atlas{}, "atlas", "Pack glyphs into texture atlas")
 	cmd.Parse()
 }
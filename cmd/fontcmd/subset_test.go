@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tdewolff/font"
+	"github.com/tdewolff/test"
+)
+
+func TestSubsetOutputs(t *testing.T) {
+	dir := t.TempDir()
+	cmd := &Subset{
+		Quiet:   true,
+		Force:   true,
+		Glyphs:  []string{"0-50"},
+		Outputs: []string{filepath.Join(dir, "a.otf"), filepath.Join(dir, "a.woff2")},
+		Input:   "../../resources/DejaVuSerif.ttf",
+	}
+	test.Error(t, cmd.Run())
+
+	// the OpenType output is converted to CFF, which must not affect the WOFF2 output
+	for _, tt := range []struct {
+		output string
+		isCFF  bool
+	}{
+		{"a.otf", true},
+		{"a.woff2", false},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, tt.output))
+		test.Error(t, err)
+		b, err = font.ToSFNT(b)
+		test.Error(t, err)
+		sfnt, err := font.ParseSFNT(b, 0)
+		test.Error(t, err)
+		_, hasGlyf := sfnt.Tables["glyf"]
+		test.T(t, sfnt.IsCFF, tt.isCFF, tt.output)
+		test.T(t, hasGlyf, !tt.isCFF, tt.output)
+		test.T(t, sfnt.NumGlyphs(), uint16(51), tt.output)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -128,21 +129,35 @@ func readFont(filename string, index int) (*font.SFNT, string, int, error) {
 func writeFont(filename, mimetype, encoding string, force bool, sfnt *font.SFNT) (int, error) {
 	var b []byte
 	var err error
+	if mimetype == "font/truetype" || mimetype == "font/opentype" {
+		// convert a copy, the same font may be written to other outputs
+		if sfnt, err = font.ParseSFNT(sfnt.Write(), 0); err != nil {
+			return 0, err
+		}
+	}
 	switch mimetype {
 	case "font/truetype":
-		if sfnt.IsCFF {
-			return 0, fmt.Errorf("cannot convert CFF to TrueType glyph outlines")
+		if err := sfnt.ToTrueType(font.ConvertOptions{}); err != nil {
+			return 0, err
 		}
 		b = sfnt.Write()
 	case "font/opentype":
-		if sfnt.IsTrueType {
-			return 0, fmt.Errorf("cannot convert TrueType to CFF glyph outlines")
+		if err := sfnt.ToCFF(font.ConvertOptions{Subroutinize: true}); err != nil {
+			return 0, err
 		}
 		b = sfnt.Write()
+	case "font/woff":
+		if b, err = sfnt.WriteWOFF(); err != nil {
+			return 0, err
+		}
 	case "font/woff2":
 		if b, err = sfnt.WriteWOFF2(); err != nil {
 			return 0, err
 		}
+	case "application/vnd.ms-fontobject":
+		if b, err = sfnt.WriteEOT(font.EOTOptions{}); err != nil {
+			return 0, err
+		}
 	default:
 		if mimetype == "" {
 			return 0, fmt.Errorf("mimetype not set")
@@ -200,3 +215,233 @@ func parseHexRunes(rs []rune) (uint64, bool) {
 	}
 	return sum, true
 }
+
+// selectGlyphs returns the glyph IDs selected by glyph ID ranges, literal characters, glyph names, unicode ranges, and unicode categories or scripts.
+func selectGlyphs(sfnt *font.SFNT, glyphs, chars, names, unicodes, unicodeRanges []string) (map[uint16]bool, error) {
+	glyphMap := map[uint16]bool{}
+
+	// append glyphs
+	for _, glyph := range glyphs {
+		if dash := strings.IndexByte(glyph, '-'); dash != -1 {
+			first, err := strconv.ParseInt(glyph[:dash], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glyph ID: %v", err)
+			}
+			last, err := strconv.ParseInt(glyph[dash+1:], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glyph ID: %v", err)
+			}
+			if last < first || first < 0 || 65535 < last {
+				return nil, fmt.Errorf("invalid glyph ID range: %d-%d\n", first, last)
+			}
+			for first != last+1 {
+				glyphMap[uint16(first)] = true
+				first++
+			}
+		} else {
+			glyphID, err := strconv.ParseInt(glyph, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glyph ID: %v", err)
+			}
+			if glyphID < 0 || 65535 < glyphID {
+				return nil, fmt.Errorf("invalid glyph ID: %v", glyphID)
+			}
+			glyphMap[uint16(glyphID)] = true
+		}
+	}
+
+	// append characters
+	for _, s := range chars {
+		prev := rune(-1)
+		rangeChars := false
+		runes := []rune(s)
+		for i := 0; i < len(runes); i++ {
+			r := runes[i]
+			escaped := false
+			if r == '\\' && i+1 < len(runes) {
+				switch runes[i+1] {
+				case 'a':
+					r = '\u0007'
+					i++
+				case 'b':
+					r = '\u0008'
+					i++
+				case 't':
+					r = '\u0009'
+					i++
+				case 'n':
+					r = '\u000A'
+					i++
+				case 'v':
+					r = '\u000B'
+					i++
+				case 'f':
+					r = '\u000C'
+					i++
+				case 'r':
+					r = '\u000D'
+					i++
+				case '"':
+					r = '\u0022'
+					i++
+				case '\'':
+					r = '\u0027'
+					i++
+				case ',':
+					r = '\u002C'
+					i++
+				case '-':
+					escaped = true
+					r = '\u002D'
+					i++
+				case '\\':
+					r = '\u005C'
+					i++
+				case 'x':
+					if i+3 < len(runes) {
+						if h, ok := parseHexRunes(runes[i+2 : i+4]); ok {
+							r = rune(h)
+							i += 3
+						} else {
+							Warning.Println("invalid escape sequence:", string(runes[i:i+4]))
+						}
+					} else {
+						Warning.Println("invalid escape sequence:", string(runes[i:i+4]))
+					}
+				case 'u':
+					if i+5 < len(runes) {
+						if h, ok := parseHexRunes(runes[i+2 : i+6]); ok {
+							r = rune(h)
+							i += 5
+						} else {
+							Warning.Println("invalid escape sequence:", string(runes[i:i+6]))
+						}
+					} else {
+						Warning.Println("invalid escape sequence:", string(runes[i:i+6]))
+					}
+				case 'U':
+					if i+9 < len(runes) {
+						if h, ok := parseHexRunes(runes[i+2 : i+10]); ok {
+							r = rune(h)
+							i += 9
+						} else {
+							Warning.Println("invalid escape sequence:", string(runes[i:i+10]))
+						}
+					} else {
+						Warning.Println("invalid escape sequence:", string(runes[i:i+10]))
+					}
+				default:
+					Warning.Println("invalid escape sequence:", string(runes[i:i+2]))
+				}
+			}
+			if prev != -1 && r == '-' && !escaped {
+				rangeChars = true
+			} else if rangeChars {
+				for i := prev + 1; i <= r; i++ {
+					glyphID := sfnt.GlyphIndex(i)
+					if glyphID == 0 {
+						Warning.Println("glyph not found:", printableRune(i))
+					} else {
+						glyphMap[glyphID] = true
+					}
+				}
+				rangeChars = false
+				prev = -1
+			} else {
+				glyphID := sfnt.GlyphIndex(r)
+				if glyphID == 0 {
+					Warning.Println("glyph not found:", printableRune(r))
+				} else {
+					glyphMap[glyphID] = true
+				}
+				prev = r
+			}
+		}
+		if rangeChars {
+			glyphID := sfnt.GlyphIndex('-')
+			if glyphID == 0 {
+				Warning.Println("glyph not found: -")
+			} else {
+				glyphMap[glyphID] = true
+			}
+		}
+	}
+
+	// append glyph names
+	for _, name := range names {
+		glyphID := sfnt.FindGlyphName(name)
+		if glyphID == 0 {
+			Warning.Println("glyph name not found:", name)
+		} else {
+			glyphMap[glyphID] = true
+		}
+	}
+
+	// append unicode
+	for _, code := range unicodes {
+		if dash := strings.IndexByte(code, '-'); dash != -1 {
+			first, err := strconv.ParseInt(code[:dash], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unicode codepoint: %v", err)
+			}
+			last, err := strconv.ParseInt(code[dash+1:], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unicode codepoint: %v", err)
+			}
+			if last < first || first < 0 {
+				return nil, fmt.Errorf("invalid unicode range: U+%4X-U+%4X\n", first, last)
+			}
+			for first != last+1 {
+				glyphID := sfnt.GlyphIndex(rune(first))
+				if glyphID == 0 {
+					Warning.Printf("glyph not found for U+%4X\n", first)
+				} else {
+					glyphMap[glyphID] = true
+				}
+				first++
+			}
+		} else {
+			codepoint, err := strconv.ParseInt(code, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unicode codepoint: %v", err)
+			} else if codepoint < 0 {
+				return nil, fmt.Errorf("invalid unicode codepoint: U+%4X\n", codepoint)
+			}
+			glyphID := sfnt.GlyphIndex(rune(codepoint))
+			if glyphID == 0 {
+				Warning.Printf("glyph not found for U+%4X\n", codepoint)
+			} else {
+				glyphMap[glyphID] = true
+			}
+		}
+	}
+
+	// append unicode ranges
+	for _, unicodeRange := range unicodeRanges {
+		var ok bool
+		var table *unicode.RangeTable
+		if table, ok = unicode.Categories[unicodeRange]; !ok {
+			if table, ok = unicode.Scripts[unicodeRange]; !ok {
+				return nil, fmt.Errorf("invalid unicode range: %v", unicodeRange)
+			}
+		}
+		for _, ran := range table.R16 {
+			for r := ran.Lo; r <= ran.Hi; r += ran.Stride {
+				glyphID := sfnt.GlyphIndex(rune(r))
+				if glyphID != 0 {
+					glyphMap[glyphID] = true
+				}
+
+			}
+		}
+		for _, ran := range table.R32 {
+			for r := ran.Lo; r <= ran.Hi; r += ran.Stride {
+				glyphID := sfnt.GlyphIndex(rune(r))
+				if glyphID != 0 {
+					glyphMap[glyphID] = true
+				}
+			}
+		}
+	}
+	return glyphMap, nil
+}
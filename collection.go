@@ -0,0 +1,263 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// NumFonts returns the number of fonts in a TTF, OTF, TTC, WOFF, WOFF2, or EOT font file. Only font collections (TTC or WOFF2) can contain more than one font.
+func NumFonts(b []byte) (int, error) {
+	mediatype, err := MediaType(b)
+	if err != nil {
+		return 0, err
+	} else if mediatype == "font/woff2" {
+		_, numFonts, err := woff2Directory(b)
+		if err != nil {
+			return 0, fmt.Errorf("WOFF2: %w", err)
+		}
+		return numFonts, nil
+	} else if string(b[:4]) != "ttcf" {
+		return 1, nil
+	}
+
+	r := newBinaryReader(b)
+	_ = r.ReadString(4) // ttcTag
+	majorVersion := r.ReadUint16()
+	minorVersion := r.ReadUint16()
+	numFonts := r.ReadUint32()
+	if r.Err() != nil {
+		return 0, ErrInvalidFontData
+	} else if majorVersion != 1 && majorVersion != 2 || minorVersion != 0 {
+		return 0, fmt.Errorf("bad TTC version")
+	} else if uint64(len(b)-12) < 4*uint64(numFonts) {
+		return 0, ErrInvalidFontData
+	}
+	return int(numFonts), nil
+}
+
+// fontOffset returns the offset of the table directory of the font at the given index. It is non-zero only for font collections.
+func fontOffset(b []byte, index int) (uint32, error) {
+	if len(b) < 12 || uint(math.MaxUint32) < uint(len(b)) {
+		return 0, ErrInvalidFontData
+	} else if string(b[:4]) != "ttcf" {
+		if index != 0 {
+			return 0, fmt.Errorf("bad font index %d", index)
+		}
+		return 0, nil
+	}
+
+	numFonts, err := NumFonts(b)
+	if err != nil {
+		return 0, err
+	} else if index < 0 || numFonts <= index {
+		return 0, fmt.Errorf("bad font index %d", index)
+	}
+	offset := binary.BigEndian.Uint32(b[12+4*index:])
+	if uint32(len(b))-12 < offset {
+		return 0, ErrInvalidFontData
+	}
+	return offset, nil
+}
+
+// FontInfo is a summary of a font in a font file.
+type FontInfo struct {
+	Index          int    // index of the font in a font collection
+	Version        string // SFNT version, either "OTTO" for CFF fonts or "\x00\x01\x00\x00" or "true" for TrueType fonts
+	NumTables      int
+	Family         string
+	Subfamily      string
+	FullName       string
+	PostScriptName string
+}
+
+// ListFonts returns a summary of each font in a TTF, OTF, TTC, WOFF, WOFF2, or EOT font file. Only the table directory and the name table of each font are parsed.
+func ListFonts(b []byte) ([]FontInfo, error) {
+	b, err := ToSFNT(b)
+	if err != nil {
+		return nil, err
+	}
+	numFonts, err := NumFonts(b)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]FontInfo, 0, numFonts)
+	for index := 0; index < numFonts; index++ {
+		offset, err := fontOffset(b, index)
+		if err != nil {
+			return nil, err
+		}
+
+		r := newBinaryReader(b[offset:])
+		info := FontInfo{
+			Index:   index,
+			Version: r.ReadString(4),
+		}
+		numTables := r.ReadUint16()
+		_ = r.ReadBytes(6) // searchRange, entrySelector, and rangeShift
+		if r.Err() != nil {
+			return nil, ErrInvalidFontData
+		} else if info.Version != "OTTO" && info.Version != "true" && binary.BigEndian.Uint32([]byte(info.Version)) != 0x00010000 {
+			return nil, fmt.Errorf("bad SFNT version")
+		}
+		info.NumTables = int(numTables)
+
+		sfnt := &SFNT{Tables: map[string][]byte{}}
+		for i := 0; i < int(numTables); i++ {
+			tag := r.ReadString(4)
+			_ = r.ReadUint32() // checksum
+			offset := r.ReadUint32()
+			length := r.ReadUint32()
+			if r.Err() != nil || uint32(len(b)) < offset || uint32(len(b))-offset < length {
+				return nil, ErrInvalidFontData
+			} else if tag == "name" {
+				sfnt.Tables[tag] = b[offset : offset+length : offset+length]
+			}
+		}
+		if _, ok := sfnt.Tables["name"]; ok {
+			if err := sfnt.parseName(); err != nil {
+				return nil, err
+			}
+			info.Family = sfnt.Name.Find(NameFontFamily)
+			info.Subfamily = sfnt.Name.Find(NameFontSubfamily)
+			info.FullName = sfnt.Name.Find(NameFull)
+			info.PostScriptName = sfnt.Name.Find(NamePostScript)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// WriteCollection writes the fonts to a font collection (TTC). Tables that are identical between fonts, such as shared glyf and loca tables, are written only once.
+func WriteCollection(sfnts []*SFNT) ([]byte, error) {
+	if len(sfnts) == 0 {
+		return nil, fmt.Errorf("collection: must have at least one font")
+	}
+
+	// list tables of all fonts, identical tables are found by their checksum and then compared
+	modified := int64(time.Now().UTC().Sub(time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)) / 1e9)
+	tables := [][]byte{}
+	lengths := []uint32{}
+	checksums := []uint32{}
+	checksumTables := map[uint32][]int{}
+	fontTags := make([][]string, len(sfnts))
+	fontTables := make([][]int, len(sfnts))
+	for i, sfnt := range sfnts {
+		if !sfnt.IsTrueType && !sfnt.IsCFF {
+			return nil, fmt.Errorf("collection: font %d must have TrueType or CFF outlines", i)
+		} else if math.MaxUint16 < len(sfnt.Tables) {
+			return nil, fmt.Errorf("collection: font %d has too many tables", i)
+		}
+		for tag := range sfnt.Tables {
+			fontTags[i] = append(fontTags[i], tag)
+		}
+		sort.Strings(fontTags[i])
+
+	NextTable:
+		for _, tag := range fontTags[i] {
+			table := sfnt.Tables[tag]
+			length := uint32(len(table))
+			if padding := (4 - length&3) & 3; padding != 0 || tag == "head" {
+				table = append(make([]byte, 0, length+padding), table...)
+				table = append(table, make([]byte, padding)...)
+			}
+			if tag == "head" {
+				if length < 36 {
+					return nil, fmt.Errorf("head: bad table")
+				}
+				binary.BigEndian.PutUint32(table[8:], 0)                 // checkSumAdjustment
+				binary.BigEndian.PutUint64(table[28:], uint64(modified)) // modified
+			}
+
+			checksum := calcChecksum(table)
+			for _, j := range checksumTables[checksum] {
+				if lengths[j] == length && bytes.Equal(tables[j], table) {
+					fontTables[i] = append(fontTables[i], j)
+					continue NextTable
+				}
+			}
+			checksumTables[checksum] = append(checksumTables[checksum], len(tables))
+			fontTables[i] = append(fontTables[i], len(tables))
+			tables = append(tables, table)
+			lengths = append(lengths, length)
+			checksums = append(checksums, checksum)
+		}
+	}
+
+	// find offsets of table directories and tables
+	offset := 12 + 4*uint64(len(sfnts))
+	fontOffsets := make([]uint32, len(sfnts))
+	for i := range sfnts {
+		fontOffsets[i] = uint32(offset)
+		offset += 12 + 16*uint64(len(fontTags[i]))
+	}
+	tableOffsets := make([]uint32, len(tables))
+	for i, table := range tables {
+		tableOffsets[i] = uint32(offset)
+		offset += uint64(len(table))
+	}
+	if math.MaxUint32 < offset {
+		return nil, fmt.Errorf("collection: too large")
+	}
+
+	// write header
+	w := parse.NewBinaryWriter(make([]byte, 0, offset))
+	w.WriteString("ttcf")             // ttcTag
+	w.WriteUint16(1)                  // majorVersion
+	w.WriteUint16(0)                  // minorVersion
+	w.WriteUint32(uint32(len(sfnts))) // numFonts
+	for _, fontOffset := range fontOffsets {
+		w.WriteUint32(fontOffset) // tableDirectoryOffsets
+	}
+
+	// write table directories
+	checkSumAdjustments := make([]uint32, len(sfnts))
+	for i, sfnt := range sfnts {
+		start := w.Len()
+		if sfnt.IsTrueType {
+			w.WriteUint32(0x00010000) // sfntVersion
+		} else {
+			w.WriteString("OTTO") // sfntVersion
+		}
+		numTables := uint16(len(fontTags[i]))
+		entrySelector := uint16(math.Log2(float64(numTables)))
+		searchRange := uint16(1 << (entrySelector + 4))
+		w.WriteUint16(numTables)                  // numTables
+		w.WriteUint16(searchRange)                // searchRange
+		w.WriteUint16(entrySelector)              // entrySelector
+		w.WriteUint16(numTables<<4 - searchRange) // rangeShift
+
+		var checksum uint32
+		for j, tag := range fontTags[i] {
+			k := fontTables[i][j]
+			w.WriteString(tag)
+			w.WriteUint32(checksums[k])
+			w.WriteUint32(tableOffsets[k])
+			w.WriteUint32(lengths[k])
+			checksum += checksums[k]
+		}
+		checkSumAdjustments[i] = 0xB1B0AFBA - checksum - calcChecksum(w.Bytes()[start:])
+	}
+
+	// write tables
+	for _, table := range tables {
+		w.WriteBytes(table)
+	}
+
+	// set checkSumAdjustment, for a head table shared between fonts this uses the first font
+	buf := w.Bytes()
+	for i := len(sfnts) - 1; 0 <= i; i-- {
+		for j, tag := range fontTags[i] {
+			if tag == "head" {
+				binary.BigEndian.PutUint32(buf[tableOffsets[fontTables[i][j]]+8:], checkSumAdjustments[i])
+			}
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,124 @@
+package font
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestNumFonts(t *testing.T) {
+	var tts = []struct {
+		filename string
+		numFonts int
+	}{
+		{"resources/DejaVuSerif.ttf", 1},
+		{"resources/DejaVuSerif.woff2", 1},
+		{"resources/EBGaramond12-Regular.otf", 1},
+		{"testdata/woff2_decoder/roundtrip-collection-order-001.ttf", 3},
+		{"testdata/woff2_decoder/roundtrip-collection-order-001.woff2", 3},
+	}
+	for _, tt := range tts {
+		t.Run(tt.filename, func(t *testing.T) {
+			b, err := ioutil.ReadFile(tt.filename)
+			test.Error(t, err)
+			numFonts, err := NumFonts(b)
+			test.Error(t, err)
+			test.T(t, numFonts, tt.numFonts)
+		})
+	}
+}
+
+func TestNumFontsError(t *testing.T) {
+	var tts = []struct {
+		data string
+		err  string
+	}{
+		{"ttcf\x00\x01\x00\x00\x00\x00", "invalid font data"},
+		{"ttcf\x00\x03\x00\x00\x00\x00\x00\x01", "bad TTC version"},
+		{"ttcf\x00\x01\x00\x00\x00\x00\x00\x02\x00\x00\x00\x14", "invalid font data"},
+	}
+	for i, tt := range tts {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			_, err := NumFonts([]byte(tt.data))
+			test.T(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestListFonts(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/woff2_decoder/roundtrip-collection-order-001.woff2")
+	test.Error(t, err)
+	infos, err := ListFonts(b)
+	test.Error(t, err)
+	test.T(t, len(infos), 3)
+	for i, info := range infos {
+		// fonts are in reverse order in the test file
+		test.T(t, info.Index, i)
+		test.T(t, info.Version, "\x00\x01\x00\x00")
+		test.T(t, info.NumTables, 11)
+		test.T(t, info.Family, fmt.Sprintf("WOFF Test TTF %d", 2-i))
+		test.T(t, info.Subfamily, "Regular")
+		test.T(t, info.FullName, fmt.Sprintf("WOFF Test TTF-%d Regular", 2-i))
+		test.T(t, info.PostScriptName, fmt.Sprintf("WOFFTestTTF%d-Regular", 2-i))
+	}
+
+	b, err = ioutil.ReadFile("resources/EBGaramond12-Regular.otf")
+	test.Error(t, err)
+	infos, err = ListFonts(b)
+	test.Error(t, err)
+	test.T(t, len(infos), 1)
+	test.T(t, infos[0].Version, "OTTO")
+	test.T(t, infos[0].FullName, "EB Garamond 12 Regular")
+}
+
+func TestWriteCollection(t *testing.T) {
+	sfnts := []*SFNT{}
+	b, err := ioutil.ReadFile("testdata/woff2_decoder/roundtrip-collection-order-001.ttf")
+	test.Error(t, err)
+	for index := 0; index < 3; index++ {
+		sfnt, err := ParseSFNT(b, index)
+		test.Error(t, err)
+		sfnts = append(sfnts, sfnt)
+	}
+	b, err = ioutil.ReadFile("resources/EBGaramond12-Regular.otf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+	sfnts = append(sfnts, sfnt)
+
+	ttc, err := WriteCollection(sfnts)
+	test.Error(t, err)
+	numFonts, err := NumFonts(ttc)
+	test.Error(t, err)
+	test.T(t, numFonts, 4)
+
+	// the fonts in the test file only have different name tables
+	size := 12 + 4*4 + (12+16*11)*3 + 12 + 16*len(sfnt.Tables)
+	for _, table := range sfnts[0].Tables {
+		size += (len(table) + 3) &^ 3
+	}
+	for _, sfnt := range sfnts[1:3] {
+		size += (len(sfnt.Tables["name"]) + 3) &^ 3
+	}
+	for _, table := range sfnt.Tables {
+		size += (len(table) + 3) &^ 3
+	}
+	test.T(t, len(ttc), size)
+
+	for index, sfnt := range sfnts {
+		sfnt2, err := ParseSFNT(ttc, index)
+		test.Error(t, err)
+		test.T(t, len(sfnt2.Tables), len(sfnt.Tables))
+		for tag, table := range sfnt.Tables {
+			if tag == "head" {
+				test.T(t, sfnt2.Tables[tag][:8], table[:8]) // checkSumAdjustment and modified change
+				test.T(t, sfnt2.Tables[tag][12:28], table[12:28])
+				test.T(t, sfnt2.Tables[tag][36:], table[36:])
+			} else {
+				test.T(t, sfnt2.Tables[tag], table, tag)
+			}
+		}
+	}
+}
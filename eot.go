@@ -3,14 +3,21 @@ package font
 import (
 	"encoding/binary"
 	"fmt"
-	"io"
+	"unicode/utf16"
 
 	"github.com/tdewolff/parse/v2"
 )
 
+const (
+	eotSubset     = 0x00000001 // TTEMBED_SUBSET
+	eotCompressed = 0x00000004 // TTEMBED_TTCOMPRESSED
+	eotEUDC       = 0x00000020 // TTEMBED_EMBEDEUDC
+	eotXOR        = 0x10000000 // TTEMBED_XORENCRYPTDATA
+)
+
 // ParseEOT parses the EOT font format and returns its contained SFNT font format (TTF or OTF). See https://www.w3.org/Submission/EOT/
 func ParseEOT(b []byte) ([]byte, error) {
-	r := parse.NewBinaryReaderBytes(b)
+	r := newBinaryReader(b)
 	r.ByteOrder = binary.LittleEndian
 	_ = r.ReadUint32()             // EOTSize
 	fontDataSize := r.ReadUint32() // FontDataSize
@@ -65,22 +72,22 @@ func ParseEOT(b []byte) ([]byte, error) {
 	}
 
 	fontData := r.ReadBytes(int64(fontDataSize))
-	if r.Err() == io.EOF {
+	if r.Err() != nil {
 		return nil, ErrInvalidFontData
 	}
 
-	isCompressed := (flags & 0x00000004) != 0
-	isXORed := (flags & 0x10000000) != 0
+	isCompressed := (flags & eotCompressed) != 0
+	isXORed := (flags & eotXOR) != 0
 
 	if isXORed {
-		for i := 0; i < len(fontData); i++ {
-			fontData[i] ^= 0x50
-		}
+		fontData = eotXOR50(fontData) // don't overwrite the input
 	}
 
 	if isCompressed {
-		// TODO: (EOT) see https://www.w3.org/Submission/MTX/
-		return nil, fmt.Errorf("EOT compression not supported")
+		var err error
+		if fontData, err = decompressMTX(fontData); err != nil {
+			return nil, err
+		}
 	}
 
 	_ = checkSumAdjustment
@@ -95,3 +102,169 @@ func ParseEOT(b []byte) ([]byte, error) {
 	//binary.BigEndian.PutUint32(buf[iCheckSumAdjustment:], checkSumAdjustment)
 	return fontData, nil
 }
+
+// EOTOptions are the options for writing EOT fonts.
+type EOTOptions struct {
+	Version      uint32   // either 0x00010000, 0x00020001, or 0x00020002, defaults to 0x00020002
+	RootStrings  []string // URLs of the pages allowed to use the font, requires version 0x00020001 or later
+	EUDCCodePage uint32   // code page of the EUDC font, requires version 0x00020002
+	EUDCFontData []byte   // font with end-user-defined characters, requires version 0x00020002
+	Subset       bool     // font has been subsetted
+	Compress     bool     // compress the font data using MicroType Express, requires TrueType outlines
+	XOR          bool     // obfuscate the font data by XOR-ing with 0x50
+}
+
+// WriteEOT writes the font to the EOT format. The header is filled from the name, OS/2, and head tables. The font data is compressed only if requested. See https://www.w3.org/Submission/EOT/
+func (sfnt *SFNT) WriteEOT(options EOTOptions) ([]byte, error) {
+	version := options.Version
+	if version == 0 {
+		version = 0x00020002
+	} else if version != 0x00010000 && version != 0x00020001 && version != 0x00020002 {
+		return nil, fmt.Errorf("unsupported version")
+	}
+	if version == 0x00010000 && 0 < len(options.RootStrings) {
+		return nil, fmt.Errorf("root strings require version 0x00020001 or later")
+	} else if version != 0x00020002 && 0 < len(options.EUDCFontData) {
+		return nil, fmt.Errorf("EUDC font data requires version 0x00020002")
+	}
+
+	fontData := sfnt.Write()
+
+	// the master checksum is the checkSumAdjustment field of the head table in the written font
+	var checkSumAdjustment uint32
+	numTables := int(binary.BigEndian.Uint16(fontData[4:]))
+	for i := 0; i < numTables; i++ {
+		entry := fontData[12+16*i:]
+		if string(entry[:4]) == "head" {
+			offset := binary.BigEndian.Uint32(entry[8:])
+			checkSumAdjustment = binary.BigEndian.Uint32(fontData[offset+8:])
+			break
+		}
+	}
+
+	eudcFontData := options.EUDCFontData
+	var flags uint32
+	if options.Subset {
+		flags |= eotSubset
+	}
+	if 0 < len(eudcFontData) {
+		flags |= eotEUDC
+	}
+	if options.Compress {
+		var err error
+		if fontData, err = compressMTX(sfnt); err != nil {
+			return nil, err
+		}
+		flags |= eotCompressed
+	}
+	if options.XOR {
+		flags |= eotXOR
+		fontData = eotXOR50(fontData)
+		eudcFontData = eotXOR50(eudcFontData)
+	}
+
+	w := parse.NewBinaryWriter(make([]byte, 0, len(fontData)+512))
+	w.ByteOrder = binary.LittleEndian
+	w.WriteUint32(0)                     // EOTSize (set later)
+	w.WriteUint32(uint32(len(fontData))) // FontDataSize
+	w.WriteUint32(version)               // Version
+	w.WriteUint32(flags)                 // Flags
+	if os2 := sfnt.OS2; os2 != nil {
+		w.WriteBytes([]byte{os2.BFamilyType, os2.BSerifStyle, os2.BWeight, os2.BProportion, os2.BContrast, os2.BStrokeVariation, os2.BArmStyle, os2.BLetterform, os2.BMidline, os2.BXHeight}) // FontPANOSE
+		w.WriteUint8(1)                                                                                                                                                                       // Charset, DEFAULT_CHARSET
+		w.WriteUint8(uint8(os2.FsSelection & 0x0001))                                                                                                                                         // Italic
+		w.WriteUint32(uint32(os2.UsWeightClass))                                                                                                                                              // Weight
+		w.WriteUint16(os2.FsType)                                                                                                                                                             // fsType
+		w.WriteUint16(0x504C)                                                                                                                                                                 // MagicNumber
+		w.WriteUint32(os2.UlUnicodeRange1)                                                                                                                                                    // UnicodeRange1
+		w.WriteUint32(os2.UlUnicodeRange2)                                                                                                                                                    // UnicodeRange2
+		w.WriteUint32(os2.UlUnicodeRange3)                                                                                                                                                    // UnicodeRange3
+		w.WriteUint32(os2.UlUnicodeRange4)                                                                                                                                                    // UnicodeRange4
+		w.WriteUint32(os2.UlCodePageRange1)                                                                                                                                                   // CodePageRange1
+		w.WriteUint32(os2.UlCodePageRange2)                                                                                                                                                   // CodePageRange2
+	} else {
+		w.WriteBytes(make([]byte, 10)) // FontPANOSE
+		w.WriteUint8(1)                // Charset, DEFAULT_CHARSET
+		if sfnt.Head.MacStyle[1] {
+			w.WriteUint8(1) // Italic
+		} else {
+			w.WriteUint8(0) // Italic
+		}
+		if sfnt.Head.MacStyle[0] {
+			w.WriteUint32(700) // Weight
+		} else {
+			w.WriteUint32(400) // Weight
+		}
+		w.WriteUint16(0)               // fsType
+		w.WriteUint16(0x504C)          // MagicNumber
+		w.WriteBytes(make([]byte, 24)) // Unicode and CodePage ranges
+	}
+	w.WriteUint32(checkSumAdjustment) // CheckSumAdjustment
+	w.WriteBytes(make([]byte, 16))    // Reserved
+	w.WriteUint16(0)                  // Padding1
+
+	for i, nameID := range []NameID{NameFontFamily, NameFontSubfamily, NameVersion, NameFull} {
+		if 0 < i {
+			w.WriteUint16(0) // Padding
+		}
+		name := sfnt.eotName(nameID)
+		w.WriteUint16(uint16(len(name))) // NameSize
+		w.WriteBytes(name)               // Name
+	}
+
+	if version == 0x00020001 || version == 0x00020002 {
+		var rootString []byte
+		for _, s := range options.RootStrings {
+			rootString = append(rootString, eotUTF16(s)...)
+			rootString = append(rootString, 0, 0) // null terminated
+		}
+		w.WriteUint16(0)                       // Padding5
+		w.WriteUint16(uint16(len(rootString))) // RootStringSize
+		w.WriteBytes(rootString)               // RootString
+
+		if version == 0x00020002 {
+			var rootStringCheckSum uint32
+			for _, c := range rootString {
+				rootStringCheckSum += uint32(c)
+			}
+			w.WriteUint32(rootStringCheckSum ^ 0x50475342) // RootStringCheckSum
+			w.WriteUint32(options.EUDCCodePage)            // EUDCCodePage
+			w.WriteUint16(0)                               // Padding6
+			w.WriteUint16(0)                               // SignatureSize
+			w.WriteUint32(0)                               // EUDCFlags
+			w.WriteUint32(uint32(len(eudcFontData)))       // EUDCFontSize
+			w.WriteBytes(eudcFontData)                     // EUDCFontData
+		}
+	}
+	w.WriteBytes(fontData) // FontData
+
+	b := w.Bytes()
+	binary.LittleEndian.PutUint32(b, uint32(len(b))) // EOTSize
+	return b, nil
+}
+
+// eotXOR50 returns a copy of b with every byte XOR-ed with 0x50.
+func eotXOR50(b []byte) []byte {
+	c := make([]byte, len(b))
+	for i := range b {
+		c[i] = b[i] ^ 0x50
+	}
+	return c
+}
+
+// eotUTF16 encodes a string as UTF-16 little-endian.
+func eotUTF16(s string) []byte {
+	b := []byte{}
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+// eotName returns the name record as UTF-16 little-endian, preferring the Windows English name.
+func (sfnt *SFNT) eotName(nameID NameID) []byte {
+	if sfnt.Name == nil {
+		return nil
+	}
+	return eotUTF16(sfnt.Name.Find(nameID))
+}
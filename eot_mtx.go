@@ -0,0 +1,1248 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// MicroType Express (MTX) is the compression format used by compressed EOT fonts. The font is split into three blocks that are each compressed by LZCOMP: the font in Compact Table Format (CTF) with transformed glyf, loca, cvt, hdmx, and VDMX tables, the push data stream of the glyph instructions, and the code stream of the glyph instructions. See https://www.w3.org/Submission/MTX/
+
+const mtxPreloadSize = 2*32*96 + 4*256
+
+// mtxPreload returns the bytes that precede the data in the LZCOMP history buffer, so that copies can refer to common byte sequences from the start.
+func mtxPreload() []byte {
+	b := make([]byte, 0, mtxPreloadSize)
+	for i := 0; i < 32; i++ {
+		for j := 0; j < 96; j++ {
+			b = append(b, byte(i), byte(j))
+		}
+	}
+	for i := 0; i < 256; i++ {
+		b = append(b, byte(i), byte(i), byte(i), byte(i))
+	}
+	return b
+}
+
+// mtxNumDistRanges returns the number of 3-bit ranges needed to encode copy distances for the given data size.
+func mtxNumDistRanges(size int) int {
+	n := 1
+	for 1<<(3*n) < size+mtxPreloadSize {
+		n++
+	}
+	return n
+}
+
+type mtxBitReader struct {
+	b   []byte
+	pos uint64
+	eof bool
+}
+
+func (r *mtxBitReader) ReadBits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if uint64(len(r.b)) <= r.pos>>3 {
+			r.eof = true
+			return 0
+		}
+		v = v<<1 | uint32(r.b[r.pos>>3]>>(7-r.pos&7))&1
+		r.pos++
+	}
+	return v
+}
+
+type mtxBitWriter struct {
+	b   []byte
+	pos uint64
+}
+
+func (w *mtxBitWriter) WriteBits(v uint32, n int) {
+	for i := n - 1; 0 <= i; i-- {
+		if w.pos&7 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v&(1<<i) != 0 {
+			w.b[w.pos>>3] |= 0x80 >> (w.pos & 7)
+		}
+		w.pos++
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// mtxAHUFF is an adaptive Huffman tree stored as a heap. Nodes are numbered from the root at 1, internal nodes are below numSymbols and leaves from numSymbols onwards.
+type mtxAHUFF struct {
+	up          []int
+	left, right []int
+	code        []int // -1 for internal nodes
+	weight      []int
+	symbolIndex []int
+}
+
+func newMTXAHUFF(numSymbols int) *mtxAHUFF {
+	n := 2 * numSymbols
+	h := &mtxAHUFF{
+		up:          make([]int, n),
+		left:        make([]int, n),
+		right:       make([]int, n),
+		code:        make([]int, n),
+		weight:      make([]int, n),
+		symbolIndex: make([]int, numSymbols),
+	}
+	for i := 2; i < n; i++ {
+		h.up[i] = i / 2
+		h.weight[i] = 1
+	}
+	for i := 1; i < numSymbols; i++ {
+		h.left[i] = 2 * i
+		h.right[i] = 2*i + 1
+		h.code[i] = -1
+	}
+	for i := 0; i < numSymbols; i++ {
+		h.code[numSymbols+i] = i
+		h.left[numSymbols+i] = -1
+		h.right[numSymbols+i] = -1
+		h.symbolIndex[i] = numSymbols + i
+	}
+	for i := numSymbols - 1; 1 <= i; i-- {
+		h.weight[i] = h.weight[h.left[i]] + h.weight[h.right[i]]
+	}
+
+	// initial symbol frequencies
+	if 256 < numSymbols && numSymbols < 512 {
+		h.update(h.symbolIndex[256])
+		h.update(h.symbolIndex[257])
+		for i := 0; i < 12; i++ {
+			h.update(h.symbolIndex[numSymbols-3])
+		}
+		for i := 0; i < 6; i++ {
+			h.update(h.symbolIndex[numSymbols-2])
+		}
+	} else {
+		for j := 0; j < 2; j++ {
+			for i := 0; i < numSymbols; i++ {
+				h.update(h.symbolIndex[i])
+			}
+		}
+	}
+	return h
+}
+
+func (h *mtxAHUFF) swap(a, b int) {
+	h.left[a], h.left[b] = h.left[b], h.left[a]
+	h.right[a], h.right[b] = h.right[b], h.right[a]
+	h.code[a], h.code[b] = h.code[b], h.code[a]
+	h.weight[a], h.weight[b] = h.weight[b], h.weight[a]
+	for _, i := range []int{a, b} {
+		if h.code[i] < 0 {
+			h.up[h.left[i]] = i
+			h.up[h.right[i]] = i
+		} else {
+			h.symbolIndex[h.code[i]] = i
+		}
+	}
+}
+
+// update increments the weight of the leaf and its ancestors, keeping the nodes ordered by weight.
+func (h *mtxAHUFF) update(a int) {
+	for a != 1 {
+		weight := h.weight[a]
+		if b := a - 1; h.weight[b] == weight {
+			for h.weight[b] == weight {
+				b--
+			}
+			b++
+			if 1 < b {
+				h.swap(a, b)
+				a = b
+			}
+		}
+		h.weight[a] = weight + 1
+		a = h.up[a]
+	}
+	h.weight[1]++
+}
+
+func (h *mtxAHUFF) Read(r *mtxBitReader) int {
+	a := 1
+	for h.code[a] < 0 {
+		if r.ReadBits(1) == 1 {
+			a = h.right[a]
+		} else {
+			a = h.left[a]
+		}
+	}
+	symbol := h.code[a]
+	h.update(a)
+	return symbol
+}
+
+func (h *mtxAHUFF) Write(w *mtxBitWriter, symbol int) {
+	var bits uint32
+	n := 0
+	leaf := h.symbolIndex[symbol]
+	for a := leaf; a != 1; a = h.up[a] {
+		if h.right[h.up[a]] == a {
+			bits |= 1 << n
+		}
+		n++
+	}
+	w.WriteBits(bits, n)
+	h.update(leaf)
+}
+
+////////////////////////////////////////////////////////////////
+
+// decompressLZCOMP decompresses an LZCOMP block, which is an LZ77 variant with adaptive Huffman coding of the literals, copy lengths, and copy distances.
+func decompressLZCOMP(b []byte) ([]byte, error) {
+	r := &mtxBitReader{b: b}
+	if r.ReadBits(1) == 1 {
+		return nil, fmt.Errorf("MTX: LZCOMP run-length encoding not supported")
+	}
+	size := int(r.ReadBits(24))
+	if r.eof {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	} else if MaxMemory < uint32(size) {
+		return nil, ErrExceedsMemory
+	}
+
+	numDistRanges := mtxNumDistRanges(size)
+	dup2 := 256 + 8*numDistRanges
+	dist := newMTXAHUFF(8)
+	length := newMTXAHUFF(8)
+	symbol := newMTXAHUFF(dup2 + 3)
+
+	buf := make([]byte, mtxPreloadSize, mtxPreloadSize+size)
+	copy(buf, mtxPreload())
+	for len(buf) < mtxPreloadSize+size {
+		sym := symbol.Read(r)
+		if r.eof {
+			return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+		}
+		if sym < 256 {
+			buf = append(buf, byte(sym))
+		} else if dup2 <= sym {
+			// single byte copied from 2, 4, or 6 bytes back
+			buf = append(buf, buf[len(buf)-2*(sym-dup2+1)])
+		} else {
+			sym -= 256
+			numDistChunks := sym/8 + 1
+
+			// copy length in 2-bit chunks, where the third bit indicates more chunks follow
+			var n int
+			chunk := sym % 8
+			for {
+				n = n<<2 | chunk&3
+				if chunk&4 == 0 {
+					break
+				}
+				chunk = length.Read(r)
+			}
+			n += 2
+
+			// copy distance in 3-bit chunks
+			var d int
+			for i := 0; i < numDistChunks; i++ {
+				d = d<<3 | dist.Read(r)
+			}
+			d++
+			if 512 <= d {
+				n++
+			}
+			d += n - 1
+			if r.eof || len(buf) < d || mtxPreloadSize+size < len(buf)+n {
+				return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+			}
+			for i := 0; i < n; i++ {
+				buf = append(buf, buf[len(buf)-d])
+			}
+		}
+	}
+	return buf[mtxPreloadSize:], nil
+}
+
+// compressLZCOMP compresses data into an LZCOMP block using greedy matching.
+func compressLZCOMP(b []byte) []byte {
+	const hashBits = 15
+	const maxChain = 64
+	const maxLength = 1 << 16
+
+	numDistRanges := mtxNumDistRanges(len(b))
+	dup2 := 256 + 8*numDistRanges
+	dist := newMTXAHUFF(8)
+	length := newMTXAHUFF(8)
+	symbol := newMTXAHUFF(dup2 + 3)
+
+	w := &mtxBitWriter{}
+	w.WriteBits(0, 1) // no run-length encoding
+	w.WriteBits(uint32(len(b)), 24)
+
+	buf := append(mtxPreload(), b...)
+	head := make([]int, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int, len(buf))
+	hash := func(i int) int {
+		return int((uint32(buf[i])<<16|uint32(buf[i+1])<<8|uint32(buf[i+2]))*2654435761) >> (32 - hashBits) & (1<<hashBits - 1)
+	}
+	insert := func(i int) {
+		if i+3 <= len(buf) {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = i
+		}
+	}
+	for i := 0; i < mtxPreloadSize; i++ {
+		insert(i)
+	}
+
+	for pos := mtxPreloadSize; pos < len(buf); {
+		// find the longest match, the copy distance must be at least the copy length
+		bestLength, bestDist := 0, 0
+		if pos+3 <= len(buf) {
+			for i, chain := head[hash(pos)], 0; 0 <= i && chain < maxChain; i, chain = prev[i], chain+1 {
+				d := pos - i
+				maxN := min(d, len(buf)-pos, maxLength)
+				n := 0
+				for n < maxN && buf[i+n] == buf[pos+n] {
+					n++
+				}
+				if bestLength < n {
+					bestLength, bestDist = n, d
+				}
+			}
+		}
+
+		if 3 <= bestLength {
+			n := bestLength - 2
+			d := bestDist - bestLength + 1
+			if 512 <= d {
+				n--
+			}
+
+			var lengthChunks []int
+			for {
+				lengthChunks = append(lengthChunks, n&3)
+				n >>= 2
+				if n == 0 {
+					break
+				}
+			}
+			numDistChunks := 1
+			for 1<<(3*numDistChunks) < d {
+				numDistChunks++
+			}
+
+			for i := len(lengthChunks) - 1; 0 <= i; i-- {
+				chunk := lengthChunks[i]
+				if 0 < i {
+					chunk |= 4
+				}
+				if i == len(lengthChunks)-1 {
+					symbol.Write(w, 256+8*(numDistChunks-1)+chunk)
+				} else {
+					length.Write(w, chunk)
+				}
+			}
+			for i := numDistChunks - 1; 0 <= i; i-- {
+				dist.Write(w, (d-1)>>(3*i)&7)
+			}
+			for i := 0; i < bestLength; i++ {
+				insert(pos + i)
+			}
+			pos += bestLength
+			continue
+		}
+
+		if buf[pos] == buf[pos-2] {
+			symbol.Write(w, dup2)
+		} else if buf[pos] == buf[pos-4] {
+			symbol.Write(w, dup2+1)
+		} else if buf[pos] == buf[pos-6] {
+			symbol.Write(w, dup2+2)
+		} else {
+			symbol.Write(w, int(buf[pos]))
+		}
+		insert(pos)
+		pos++
+	}
+	return w.b
+}
+
+////////////////////////////////////////////////////////////////
+
+// decompressMTX decompresses the MTX font data and returns the reconstructed SFNT font.
+func decompressMTX(b []byte) ([]byte, error) {
+	if len(b) < 10 {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	}
+	r := newBinaryReader(b)
+	numBlocks := r.ReadUint8()
+	_ = r.ReadUint24() // copyLimit
+	offset2 := r.ReadUint24()
+	offset3 := r.ReadUint24()
+	if numBlocks != 3 {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	} else if offset3 < offset2 || offset2 < 10 || uint32(len(b)) < offset3 {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	}
+
+	font, err := decompressLZCOMP(b[10:offset2])
+	if err != nil {
+		return nil, err
+	}
+	push, err := decompressLZCOMP(b[offset2:offset3])
+	if err != nil {
+		return nil, err
+	}
+	code, err := decompressLZCOMP(b[offset3:])
+	if err != nil {
+		return nil, err
+	}
+	return reconstructCTF(font, push, code)
+}
+
+// compressMTX compresses a TrueType font to the MTX format.
+func compressMTX(sfnt *SFNT) ([]byte, error) {
+	if !sfnt.IsTrueType || sfnt.Glyf == nil {
+		return nil, fmt.Errorf("MTX: font must have TrueType outlines")
+	}
+
+	glyf, push, code, err := transformCTFGlyf(sfnt.NumGlyphs(), sfnt.Glyf)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := map[string][]byte{}
+	for tag, table := range sfnt.Tables {
+		if tag != "DSIG" {
+			tables[tag] = table
+		}
+	}
+	tables["glyf"] = glyf
+	tables["loca"] = []byte{}
+	if sfnt.Hdmx != nil {
+		tables["hdmx"] = transformCTFHdmx(sfnt)
+	}
+	if sfnt.Vdmx != nil {
+		tables["VDMX"] = transformCTFVDMX(sfnt)
+	}
+	if cvt, ok := tables["cvt "]; ok {
+		if tables["cvt "], err = transformCTFCvt(cvt); err != nil {
+			return nil, err
+		}
+	}
+	head := make([]byte, len(tables["head"]))
+	copy(head, tables["head"])
+	if 12 <= len(head) {
+		binary.BigEndian.PutUint32(head[8:], 0) // checkSumAdjustment
+	}
+	tables["head"] = head
+
+	// the font in CTF has all table checksums set to zero
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	numTables := uint16(len(tags))
+	entrySelector := uint16(math.Log2(float64(numTables)))
+	searchRange := uint16(1 << (entrySelector + 4))
+	w := parse.NewBinaryWriter([]byte{})
+	w.WriteUint32(0x00010000) // sfntVersion
+	w.WriteUint16(numTables)
+	w.WriteUint16(searchRange)
+	w.WriteUint16(entrySelector)
+	w.WriteUint16(numTables<<4 - searchRange)
+	offset := 12 + 16*uint32(numTables)
+	for _, tag := range tags {
+		length := uint32(len(tables[tag]))
+		w.WriteString(tag)
+		w.WriteUint32(0) // checksum
+		if length == 0 {
+			w.WriteUint32(0)
+		} else {
+			w.WriteUint32(offset)
+		}
+		w.WriteUint32(length)
+		offset += (length + 3) &^ 3
+	}
+	for _, tag := range tags {
+		w.WriteBytes(tables[tag])
+		w.WriteBytes(make([]byte, (4-len(tables[tag])&3)&3))
+	}
+
+	blocks := [][]byte{w.Bytes(), push, code}
+	copyLimit := 0
+	for i, block := range blocks {
+		if 1<<24 <= len(block) {
+			return nil, fmt.Errorf("MTX: font too large")
+		}
+		copyLimit = max(copyLimit, len(block)+mtxPreloadSize)
+		blocks[i] = compressLZCOMP(block)
+	}
+	offset2 := 10 + len(blocks[0])
+	offset3 := offset2 + len(blocks[1])
+	if 1<<24 <= offset3 || 1<<24 <= copyLimit {
+		return nil, fmt.Errorf("MTX: font too large")
+	}
+
+	w = parse.NewBinaryWriter(make([]byte, 0, offset3+len(blocks[2])))
+	w.WriteUint8(3) // numBlocks
+	w.WriteUint24(uint32(copyLimit))
+	w.WriteUint24(uint32(offset2))
+	w.WriteUint24(uint32(offset3))
+	for _, block := range blocks {
+		w.WriteBytes(block)
+	}
+	return w.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// reconstructCTF rebuilds the SFNT font from the font in Compact Table Format and the push and code streams of the glyph instructions.
+func reconstructCTF(font, push, code []byte) ([]byte, error) {
+	if len(font) < 12 {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	}
+	r := newBinaryReader(font)
+	sfntVersion := r.ReadUint32()
+	numTables := r.ReadUint16()
+	_ = r.ReadBytes(6) // searchRange, entrySelector, rangeShift
+	if r.Len() < 16*int64(numTables) {
+		return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+	}
+
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < int(numTables); i++ {
+		tag := string(r.ReadBytes(4))
+		_ = r.ReadUint32() // checksum
+		offset := r.ReadUint32()
+		length := r.ReadUint32()
+		if uint32(len(font)) < offset || uint32(len(font))-offset < length {
+			return nil, fmt.Errorf("MTX: %w", ErrInvalidFontData)
+		} else if _, ok := tables[tag]; ok {
+			return nil, fmt.Errorf("MTX: duplicate table %s", tag)
+		}
+		tables[tag] = font[offset : offset+length : offset+length]
+	}
+	head, ok := tables["head"]
+	if !ok || len(head) < 54 {
+		return nil, fmt.Errorf("head: must be present")
+	}
+	// don't overwrite the input and clear checkSumAdjustment
+	head = append([]byte{}, head...)
+	binary.BigEndian.PutUint32(head[8:], 0x00000000)
+	tables["head"] = head
+
+	if glyf, ok := tables["glyf"]; ok {
+		maxp, ok := tables["maxp"]
+		if !ok || len(maxp) < 6 {
+			return nil, fmt.Errorf("maxp: must be present")
+		}
+		numGlyphs := binary.BigEndian.Uint16(maxp[4:])
+		indexFormat := int16(binary.BigEndian.Uint16(head[50:]))
+
+		var loca []byte
+		var err error
+		glyf, loca, indexFormat, err = reconstructCTFGlyf(glyf, push, code, numGlyphs, indexFormat)
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(head[50:], uint16(indexFormat))
+		tables["glyf"] = glyf
+		tables["loca"] = loca
+	}
+	if cvt, ok := tables["cvt "]; ok {
+		var err error
+		if tables["cvt "], err = reconstructCTFCvt(cvt); err != nil {
+			return nil, err
+		}
+	}
+	if hdmx, ok := tables["hdmx"]; ok {
+		var err error
+		if tables["hdmx"], err = reconstructCTFHdmx(hdmx, head, tables["hhea"], tables["hmtx"], tables["maxp"]); err != nil {
+			return nil, err
+		}
+	}
+	if vdmx, ok := tables["VDMX"]; ok {
+		var err error
+		if tables["VDMX"], err = reconstructCTFVDMX(vdmx, head); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	numTables = uint16(len(tags))
+	entrySelector := uint16(math.Log2(float64(numTables)))
+	searchRange := uint16(1 << (entrySelector + 4))
+	size := 12 + 16*uint64(numTables)
+	for _, tag := range tags {
+		size += (uint64(len(tables[tag])) + 3) &^ 3
+	}
+	if uint64(MaxMemory) < size {
+		return nil, ErrExceedsMemory
+	}
+
+	w := parse.NewBinaryWriter(make([]byte, 0, size))
+	w.WriteUint32(sfntVersion)
+	w.WriteUint16(numTables)
+	w.WriteUint16(searchRange)
+	w.WriteUint16(entrySelector)
+	w.WriteUint16(numTables<<4 - searchRange)
+	offset := 12 + 16*uint32(numTables)
+	for _, tag := range tags {
+		table := tables[tag]
+		padded := append(table[:len(table):len(table)], make([]byte, (4-len(table)&3)&3)...)
+		w.WriteString(tag)
+		w.WriteUint32(calcChecksum(padded))
+		w.WriteUint32(offset)
+		w.WriteUint32(uint32(len(table)))
+		offset += uint32(len(padded))
+	}
+	var iCheckSumAdjustment uint32
+	for _, tag := range tags {
+		if tag == "head" {
+			iCheckSumAdjustment = uint32(w.Len()) + 8
+		}
+		w.WriteBytes(tables[tag])
+		w.WriteBytes(make([]byte, (4-len(tables[tag])&3)&3))
+	}
+
+	buf := w.Bytes()
+	binary.BigEndian.PutUint32(buf[iCheckSumAdjustment:], 0xB1B0AFBA-calcChecksum(buf))
+	return buf, nil
+}
+
+// reconstructCTFGlyf rebuilds the glyf and loca tables from the glyph stream and the push and code streams. The index format of loca is changed to long offsets if the glyf table grows too big for short offsets.
+func reconstructCTFGlyf(b, push, code []byte, numGlyphs uint16, indexFormat int16) ([]byte, []byte, int16, error) {
+	r := newBinaryReader(b)
+	pushStream := newBinaryReader(push)
+	codeStream := newBinaryReader(code)
+
+	w := parse.NewBinaryWriter([]byte{}) // size unknown
+	offsets := make([]uint32, numGlyphs+1)
+	for iGlyph := uint16(0); iGlyph < numGlyphs; iGlyph++ {
+		offsets[iGlyph] = uint32(w.Len())
+
+		nContours := r.ReadInt16()
+		if r.Err() != nil {
+			return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+		} else if nContours == 0 { // empty glyph
+			continue
+		} else if 0 < nContours { // simple glyph
+			contour := &glyfContour{}
+			contour.EndPoints = make([]uint16, nContours)
+			var endPoint uint16
+			for iContour := int16(0); iContour < nContours; iContour++ {
+				delta := read255Uint16(r)
+				if r.Err() != nil || math.MaxUint16-endPoint < delta {
+					return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+				}
+				endPoint += delta
+				contour.EndPoints[iContour] = endPoint
+			}
+
+			nPoints := int(endPoint) + 1
+			flags := r.ReadBytes(int64(nPoints))
+			if r.Err() != nil {
+				return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+			}
+			contour.OnCurve = make([]bool, nPoints)
+			contour.OverlapSimple = make([]bool, nPoints)
+			contour.XCoordinates = make([]int16, nPoints)
+			contour.YCoordinates = make([]int16, nPoints)
+			var x, y int32
+			for iPoint, flag := range flags {
+				dx, dy := readTriplet(r, flag&0x7f)
+				x += int32(dx)
+				y += int32(dy)
+				if x < math.MinInt16 || math.MaxInt16 < x || y < math.MinInt16 || math.MaxInt16 < y {
+					return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+				}
+				contour.OnCurve[iPoint] = flag&0x80 == 0
+				contour.XCoordinates[iPoint] = int16(x)
+				contour.YCoordinates[iPoint] = int16(y)
+				if iPoint == 0 {
+					contour.XMin, contour.XMax = int16(x), int16(x)
+					contour.YMin, contour.YMax = int16(y), int16(y)
+				} else {
+					contour.XMin = min(contour.XMin, int16(x))
+					contour.XMax = max(contour.XMax, int16(x))
+					contour.YMin = min(contour.YMin, int16(y))
+					contour.YMax = max(contour.YMax, int16(y))
+				}
+			}
+			if r.Err() != nil {
+				return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+			}
+
+			var err error
+			if contour.Instructions, err = readCTFInstructions(r, pushStream, codeStream, push); err != nil {
+				return nil, nil, 0, err
+			}
+			writeGlyfContour(w, contour)
+		} else { // composite glyph
+			w.WriteInt16(nContours)      // numberOfContours
+			w.WriteBytes(r.ReadBytes(8)) // xMin, yMin, xMax, yMax
+
+			hasInstructions := false
+			for {
+				flags := r.ReadUint16()
+				length, more := glyfCompositeLength(flags)
+				w.WriteUint16(flags)
+				w.WriteBytes(r.ReadBytes(int64(length) - 2))
+				if r.Err() != nil {
+					return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+				}
+				if flags&0x0100 != 0 { // WE_HAVE_INSTRUCTIONS
+					hasInstructions = true
+				}
+				if !more {
+					break
+				}
+			}
+			if hasInstructions {
+				instructions, err := readCTFInstructions(r, pushStream, codeStream, push)
+				if err != nil {
+					return nil, nil, 0, err
+				}
+				w.WriteUint16(uint16(len(instructions)))
+				w.WriteBytes(instructions)
+			}
+		}
+
+		// offsets for loca table should be 4-byte aligned
+		for w.Len()%4 != 0 {
+			w.WriteByte(0x00)
+		}
+	}
+	offsets[numGlyphs] = uint32(w.Len())
+	if r.Len() != 0 || pushStream.Len() != 0 || codeStream.Len() != 0 {
+		return nil, nil, 0, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+	}
+
+	if indexFormat == 0 && 2*math.MaxUint16 < offsets[numGlyphs] {
+		indexFormat = 1
+	}
+	loca := parse.NewBinaryWriter(make([]byte, 0, 4*(int(numGlyphs)+1)))
+	for _, offset := range offsets {
+		if indexFormat == 0 {
+			loca.WriteUint16(uint16(offset >> 1))
+		} else {
+			loca.WriteUint32(offset)
+		}
+	}
+	return w.Bytes(), loca.Bytes(), indexFormat, nil
+}
+
+// readCTFInstructions reads the number of push values and the code size from the glyph stream, and rebuilds the glyph instructions from the push and code streams.
+func readCTFInstructions(r, pushStream, codeStream *parse.BinaryReader, push []byte) ([]byte, error) {
+	pushCount := read255Uint16(r)
+	codeSize := read255Uint16(r)
+	if r.Err() != nil {
+		return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+	}
+
+	// push values are encoded as 255Short, where hop codes repeat the value from two positions back
+	values := make([]int16, 0, pushCount)
+	for len(values) < int(pushCount) {
+		if pushStream.Len() == 0 {
+			return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+		} else if code := push[pushStream.Pos()]; code == 251 || code == 252 { // Hop3Code: A X A, Hop4Code: A X A Y A
+			_ = pushStream.ReadUint8()
+			if len(values) < 2 {
+				return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+			}
+			a := values[len(values)-2]
+			values = append(values, a, read255Int16(pushStream), a)
+			if code == 252 {
+				values = append(values, read255Int16(pushStream), a)
+			}
+		} else {
+			values = append(values, read255Int16(pushStream))
+		}
+		if pushStream.Err() != nil {
+			return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+		}
+	}
+	code := codeStream.ReadBytes(int64(codeSize))
+	if codeStream.Err() != nil || int(pushCount) < len(values) {
+		return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+	}
+
+	w := parse.NewBinaryWriter(make([]byte, 0, 2*len(values)+len(code)+8))
+	for i := 0; i < len(values); {
+		// push a run of values that fit into a byte or a run that doesn't
+		isByte := 0 <= values[i] && values[i] <= 255
+		n := 1
+		for i+n < len(values) && n < 255 && (0 <= values[i+n] && values[i+n] <= 255) == isByte {
+			n++
+		}
+		if isByte {
+			if n <= 8 {
+				w.WriteByte(0xB0 + byte(n-1)) // PUSHB[n]
+			} else {
+				w.WriteByte(0x40) // NPUSHB
+				w.WriteByte(byte(n))
+			}
+			for _, value := range values[i : i+n] {
+				w.WriteByte(byte(value))
+			}
+		} else {
+			if n <= 8 {
+				w.WriteByte(0xB8 + byte(n-1)) // PUSHW[n]
+			} else {
+				w.WriteByte(0x41) // NPUSHW
+				w.WriteByte(byte(n))
+			}
+			for _, value := range values[i : i+n] {
+				w.WriteInt16(value)
+			}
+		}
+		i += n
+	}
+	w.WriteBytes(code)
+	if math.MaxUint16 < w.Len() {
+		return nil, fmt.Errorf("glyf: %w", ErrInvalidFontData)
+	}
+	return w.Bytes(), nil
+}
+
+// transformCTFGlyf transforms the glyf table to the glyph stream of the Compact Table Format, and the push and code streams of the glyph instructions.
+func transformCTFGlyf(numGlyphs uint16, glyf *glyfTable) ([]byte, []byte, []byte, error) {
+	w := parse.NewBinaryWriter([]byte{})
+	pushStream := parse.NewBinaryWriter([]byte{})
+	codeStream := parse.NewBinaryWriter([]byte{})
+	for glyphID := 0; glyphID < int(numGlyphs); glyphID++ {
+		if !glyf.IsComposite(uint16(glyphID)) {
+			// simple glyph
+			contour, err := glyf.Contour(uint16(glyphID))
+			if err != nil {
+				return nil, nil, nil, err
+			} else if len(contour.EndPoints) == 0 {
+				// empty glyph
+				w.WriteInt16(0)
+				continue
+			}
+
+			w.WriteInt16(int16(len(contour.EndPoints)))
+			for i, endPoint := range contour.EndPoints {
+				if 0 < i {
+					endPoint -= contour.EndPoints[i-1]
+				}
+				write255Uint16(w, endPoint)
+			}
+
+			flags := make([]byte, len(contour.XCoordinates))
+			glyphStream := parse.NewBinaryWriter([]byte{})
+			for i := range contour.XCoordinates {
+				dx, dy := contour.XCoordinates[i], contour.YCoordinates[i]
+				if 0 < i {
+					dx -= contour.XCoordinates[i-1]
+					dy -= contour.YCoordinates[i-1]
+				}
+				flags[i] = writeTriplet(glyphStream, dx, dy)
+				if !contour.OnCurve[i] {
+					flags[i] |= 0x80
+				}
+			}
+			w.WriteBytes(flags)
+			w.WriteBytes(glyphStream.Bytes())
+			writeCTFInstructions(w, pushStream, codeStream, contour.Instructions)
+		} else {
+			// composite glyph
+			r := newBinaryReader(glyf.Get(uint16(glyphID)))
+			w.WriteInt16(r.ReadInt16())  // numberOfContours
+			w.WriteBytes(r.ReadBytes(8)) // xMin, yMin, xMax, yMax
+
+			hasInstructions := false
+			for {
+				flags := r.ReadUint16()
+				length, more := glyfCompositeLength(flags)
+				if flags&0x0100 != 0 {
+					hasInstructions = true
+				}
+
+				w.WriteUint16(flags)
+				w.WriteBytes(r.ReadBytes(int64(length) - 2))
+				if !more || r.Err() != nil {
+					break
+				}
+			}
+			if hasInstructions {
+				instructionLength := r.ReadUint16()
+				writeCTFInstructions(w, pushStream, codeStream, r.ReadBytes(int64(instructionLength)))
+			}
+			if r.Err() != nil {
+				return nil, nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+		}
+	}
+	return w.Bytes(), pushStream.Bytes(), codeStream.Bytes(), nil
+}
+
+// writeCTFInstructions splits the glyph instructions into the values of the leading push instructions and the remaining code.
+func writeCTFInstructions(w, pushStream, codeStream *parse.BinaryWriter, instructions []byte) {
+	var values []int16
+	i := 0
+	for i < len(instructions) {
+		op := instructions[i]
+		header, n, words := 1, 0, false
+		if op == 0x40 || op == 0x41 { // NPUSHB, NPUSHW
+			if i+1 < len(instructions) {
+				header, n = 2, int(instructions[i+1])
+			}
+			words = op == 0x41
+		} else if 0xB0 <= op && op <= 0xBF { // PUSHB[n], PUSHW[n]
+			n = int(op&0x07) + 1
+			words = 0xB8 <= op
+		}
+		size := n
+		if words {
+			size *= 2
+		}
+		if n == 0 || len(instructions) < i+header+size {
+			break // not a push instruction or malformed, keep the remainder as code
+		}
+
+		i += header
+		for j := 0; j < n; j++ {
+			if words {
+				values = append(values, int16(binary.BigEndian.Uint16(instructions[i:])))
+				i += 2
+			} else {
+				values = append(values, int16(instructions[i]))
+				i++
+			}
+		}
+	}
+	code := instructions[i:]
+
+	write255Uint16(w, uint16(len(values)))
+	write255Uint16(w, uint16(len(code)))
+	for i := 0; i < len(values); {
+		// values that equal the one two positions back are encoded with hop codes: A X A Y A
+		if 2 <= i && i+2 < len(values) && values[i] == values[i-2] && values[i+2] == values[i] {
+			if i+4 < len(values) && values[i+4] == values[i] {
+				pushStream.WriteByte(252) // Hop4Code
+				write255Int16(pushStream, values[i+1])
+				write255Int16(pushStream, values[i+3])
+				i += 5
+			} else {
+				pushStream.WriteByte(251) // Hop3Code
+				write255Int16(pushStream, values[i+1])
+				i += 3
+			}
+			continue
+		}
+		write255Int16(pushStream, values[i])
+		i++
+	}
+	codeStream.WriteBytes(code)
+}
+
+// reconstructCTFCvt rebuilds the cvt table from its CTF encoding, which stores the differences between consecutive values.
+func reconstructCTFCvt(b []byte) ([]byte, error) {
+	r := newBinaryReader(b)
+	numValues := r.ReadUint16()
+	w := parse.NewBinaryWriter(make([]byte, 0, 2*int(numValues)))
+	var value int16
+	for i := 0; i < int(numValues); i++ {
+		var delta int16
+		switch code := int16(r.ReadUint8()); {
+		case code < 238:
+			delta = code
+		case code == 238:
+			delta = r.ReadInt16()
+		case code < 247:
+			delta = -(238*(code-239) + int16(r.ReadUint8()))
+		default:
+			delta = 238*(code-247) + int16(r.ReadUint8())
+		}
+		if r.Err() != nil {
+			return nil, fmt.Errorf("cvt: %w", ErrInvalidFontData)
+		}
+		value += delta
+		w.WriteInt16(value)
+	}
+	return w.Bytes(), nil
+}
+
+// transformCTFCvt transforms the cvt table to its CTF encoding.
+func transformCTFCvt(b []byte) ([]byte, error) {
+	if len(b)%2 != 0 || 2*math.MaxUint16 < len(b) {
+		return nil, fmt.Errorf("cvt: bad table")
+	}
+	w := parse.NewBinaryWriter(make([]byte, 0, 2+len(b)))
+	w.WriteUint16(uint16(len(b) / 2))
+	var prev int16
+	for i := 0; i < len(b); i += 2 {
+		value := int16(binary.BigEndian.Uint16(b[i:]))
+		delta := value - prev
+		prev = value
+		if 0 <= delta && delta < 238 {
+			w.WriteByte(byte(delta))
+		} else if 238 <= delta && delta < 238*9 {
+			w.WriteByte(byte(247 + delta/238))
+			w.WriteByte(byte(delta % 238))
+		} else if -238*8 < delta && delta < 0 {
+			w.WriteByte(byte(239 + -delta/238))
+			w.WriteByte(byte(-delta % 238))
+		} else {
+			w.WriteByte(238)
+			w.WriteInt16(delta)
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// ctfPredictedWidth returns the width in pixels of a glyph at the given ppem as predicted by its advance width, which is the rounded linearly scaled advance width.
+func ctfPredictedWidth(advance, ppem, unitsPerEm uint16) int {
+	return ((64*int(ppem)*int(advance)+int(unitsPerEm)/2)/int(unitsPerEm) + 32) / 64
+}
+
+// readCTFMagnitude reads a value using the magnitude dependent variable length encoding, where the magnitude is encoded in unary by one bits terminated by a zero bit, followed by a sign bit for nonzero values.
+func readCTFMagnitude(r *mtxBitReader) int {
+	v := 0
+	for r.ReadBits(1) == 1 {
+		v++
+	}
+	if v != 0 && r.ReadBits(1) == 1 {
+		v = -v
+	}
+	return v
+}
+
+func writeCTFMagnitude(w *mtxBitWriter, v int) {
+	sign := uint32(0)
+	if v < 0 {
+		sign = 1
+		v = -v
+	}
+	for i := 0; i < v; i++ {
+		w.WriteBits(1, 1)
+	}
+	w.WriteBits(0, 1)
+	if v != 0 {
+		w.WriteBits(sign, 1)
+	}
+}
+
+// reconstructCTFHdmx rebuilds the hdmx table from its CTF encoding, which stores the differences between the widths and the widths predicted by the advance widths of the hmtx table.
+func reconstructCTFHdmx(b, head, hhea, hmtx, maxp []byte) ([]byte, error) {
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, fmt.Errorf("hdmx: head, hhea, and maxp must be present")
+	}
+	unitsPerEm := binary.BigEndian.Uint16(head[18:])
+	numberOfHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	if unitsPerEm == 0 || numberOfHMetrics == 0 || len(hmtx) < 4*numberOfHMetrics {
+		return nil, fmt.Errorf("hdmx: %w", ErrInvalidFontData)
+	}
+
+	r := newBinaryReader(b)
+	version := r.ReadUint16()
+	numRecords := r.ReadInt16()
+	sizeDeviceRecord := r.ReadInt32()
+	if r.Err() != nil || version != 0 || numRecords < 0 || sizeDeviceRecord < 2+int32(numGlyphs) {
+		return nil, fmt.Errorf("hdmx: %w", ErrInvalidFontData)
+	}
+	hdmx := &hdmxTable{
+		Records: make([]hdmxRecord, numRecords),
+	}
+	for i := range hdmx.Records {
+		hdmx.Records[i].PixelSize = r.ReadUint8()
+		hdmx.Records[i].MaxWidth = r.ReadUint8()
+	}
+	if r.Err() != nil || int(MaxMemory) < int(numRecords)*int(sizeDeviceRecord) {
+		return nil, fmt.Errorf("hdmx: %w", ErrInvalidFontData)
+	}
+
+	bits := &mtxBitReader{b: r.ReadBytes(r.Len())}
+	for i := range hdmx.Records {
+		record := &hdmx.Records[i]
+		record.Widths = make([]uint8, numGlyphs)
+		for glyphID := range record.Widths {
+			advance := binary.BigEndian.Uint16(hmtx[4*min(glyphID, numberOfHMetrics-1):])
+			width := ctfPredictedWidth(advance, uint16(record.PixelSize), unitsPerEm) + readCTFMagnitude(bits)
+			if bits.eof || width < 0 || math.MaxUint8 < width {
+				return nil, fmt.Errorf("hdmx: %w", ErrInvalidFontData)
+			}
+			record.Widths[glyphID] = uint8(width)
+		}
+	}
+	if uint64(len(bits.b)) != (bits.pos+7)/8 {
+		return nil, fmt.Errorf("hdmx: %w", ErrInvalidFontData)
+	}
+	return hdmx.Write(), nil
+}
+
+// transformCTFHdmx transforms the hdmx table to its CTF encoding.
+func transformCTFHdmx(sfnt *SFNT) []byte {
+	numGlyphs := sfnt.NumGlyphs()
+	w := parse.NewBinaryWriter(make([]byte, 0, 8+2*len(sfnt.Hdmx.Records)))
+	w.WriteUint16(0) // version
+	w.WriteInt16(int16(len(sfnt.Hdmx.Records)))
+	w.WriteInt32(int32((2 + int(numGlyphs) + 3) &^ 3)) // sizeDeviceRecord
+	for _, record := range sfnt.Hdmx.Records {
+		w.WriteUint8(record.PixelSize)
+		w.WriteUint8(record.MaxWidth)
+	}
+
+	bits := &mtxBitWriter{}
+	for _, record := range sfnt.Hdmx.Records {
+		for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+			predicted := ctfPredictedWidth(sfnt.Hmtx.Advance(glyphID), uint16(record.PixelSize), sfnt.Head.UnitsPerEm)
+			writeCTFMagnitude(bits, int(record.Widths[glyphID])-predicted)
+		}
+	}
+	w.WriteBytes(bits.b)
+	return w.Bytes()
+}
+
+// ctfPredictedExtent returns the maximum and minimum y-values in pixels at the given ppem as predicted by the bounding box of the head table.
+func ctfPredictedExtent(yMax, yMin int16, ppem, unitsPerEm uint16) (int16, int16) {
+	f := float64(ppem) / float64(unitsPerEm)
+	return int16(math.Ceil(f * float64(yMax))), int16(math.Floor(f * float64(yMin)))
+}
+
+// reconstructCTFVDMX rebuilds the VDMX table from its CTF encoding. It is equal to the VDMX table, except that the maximum and minimum y-values of the records are stored as 255Short differences from the values predicted by the bounding box of the head table.
+func reconstructCTFVDMX(b, head []byte) ([]byte, error) {
+	if len(head) < 54 {
+		return nil, fmt.Errorf("VDMX: head must be present")
+	}
+	unitsPerEm := binary.BigEndian.Uint16(head[18:])
+	yMin := int16(binary.BigEndian.Uint16(head[38:]))
+	yMax := int16(binary.BigEndian.Uint16(head[42:]))
+	if unitsPerEm == 0 {
+		return nil, fmt.Errorf("VDMX: %w", ErrInvalidFontData)
+	}
+
+	r := newBinaryReader(b)
+	vdmx := &vdmxTable{}
+	vdmx.Version = r.ReadUint16()
+	numRecs := r.ReadUint16()
+	numRatios := r.ReadUint16()
+	if r.Err() != nil || 1 < vdmx.Version || r.Len() < 6*int64(numRatios) {
+		return nil, fmt.Errorf("VDMX: %w", ErrInvalidFontData)
+	}
+	vdmx.Ratios = make([]vdmxRatio, numRatios)
+	for i := range vdmx.Ratios {
+		vdmx.Ratios[i].CharSet = r.ReadUint8()
+		vdmx.Ratios[i].XRatio = r.ReadUint8()
+		vdmx.Ratios[i].YStartRatio = r.ReadUint8()
+		vdmx.Ratios[i].YEndRatio = r.ReadUint8()
+	}
+	offsets := make([]uint16, numRatios)
+	for i := range offsets {
+		offsets[i] = r.ReadUint16()
+	}
+
+	// groups are stored in order, offsets refer to the groups in the VDMX table
+	groups := map[uint16]int{}
+	offset := 6 + 6*int(numRatios)
+	for i := 0; i < int(numRecs); i++ {
+		group := vdmxGroup{}
+		numRecords := r.ReadUint16()
+		group.StartSize = r.ReadUint8()
+		group.EndSize = r.ReadUint8()
+		if r.Err() != nil || r.Len() < 4*int64(numRecords) {
+			return nil, fmt.Errorf("VDMX: %w", ErrInvalidFontData)
+		}
+		group.Records = make([]vdmxRecord, numRecords)
+		for j := range group.Records {
+			record := &group.Records[j]
+			record.YPelHeight = r.ReadUint16()
+			predictedMax, predictedMin := ctfPredictedExtent(yMax, yMin, record.YPelHeight, unitsPerEm)
+			record.YMax = predictedMax + read255Int16(r)
+			record.YMin = predictedMin + read255Int16(r)
+		}
+		if r.Err() != nil || math.MaxUint16 < offset {
+			return nil, fmt.Errorf("VDMX: %w", ErrInvalidFontData)
+		}
+		groups[uint16(offset)] = len(vdmx.Groups)
+		vdmx.Groups = append(vdmx.Groups, group)
+		offset += 4 + 6*int(numRecords)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("VDMX: %w", ErrInvalidFontData)
+	}
+	for i, offset := range offsets {
+		var ok bool
+		if vdmx.Ratios[i].Group, ok = groups[offset]; !ok {
+			return nil, fmt.Errorf("VDMX: bad offset")
+		}
+	}
+	return vdmx.Write(), nil
+}
+
+// transformCTFVDMX transforms the VDMX table to its CTF encoding. Groups are written in the order in which they are first referenced by the ratios, as by vdmxTable.Write.
+func transformCTFVDMX(sfnt *SFNT) []byte {
+	vdmx := sfnt.Vdmx
+	table := vdmx.Write()
+	w := parse.NewBinaryWriter(make([]byte, 0, len(table)))
+	w.WriteBytes(table[:6+6*len(vdmx.Ratios)]) // header, ratios, and offsets
+	for _, group := range vdmx.Groups {
+		w.WriteUint16(uint16(len(group.Records)))
+		w.WriteUint8(group.StartSize)
+		w.WriteUint8(group.EndSize)
+		for _, record := range group.Records {
+			predictedMax, predictedMin := ctfPredictedExtent(sfnt.Head.YMax, sfnt.Head.YMin, record.YPelHeight, sfnt.Head.UnitsPerEm)
+			w.WriteUint16(record.YPelHeight)
+			write255Int16(w, record.YMax-predictedMax)
+			write255Int16(w, record.YMin-predictedMin)
+		}
+	}
+	return w.Bytes()
+}
+
+func read255Int16(r *parse.BinaryReader) int16 {
+	// see https://www.w3.org/Submission/MTX/#id_255SHORT
+	code := r.ReadUint8()
+	if code == 253 {
+		return r.ReadInt16()
+	}
+	sign := int16(1)
+	if code == 250 {
+		sign = -1
+		code = r.ReadUint8()
+	}
+	if code == 255 {
+		return sign * (int16(r.ReadUint8()) + 250)
+	} else if code == 254 {
+		return sign * (int16(r.ReadUint8()) + 250*2)
+	}
+	return sign * int16(code)
+}
+
+func write255Int16(w *parse.BinaryWriter, val int16) {
+	// see https://www.w3.org/Submission/MTX/#id_255SHORT
+	if val < -(256+250*2-1) || 256+250*2-1 < val {
+		w.WriteByte(253)
+		w.WriteInt16(val)
+		return
+	} else if val < 0 {
+		w.WriteByte(250)
+		val = -val
+	}
+	if val < 250 {
+		w.WriteByte(byte(val))
+	} else if val < 250*2 {
+		w.WriteByte(255)
+		w.WriteByte(byte(val - 250))
+	} else {
+		w.WriteByte(254)
+		w.WriteByte(byte(val - 250*2))
+	}
+}
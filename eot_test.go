@@ -0,0 +1,155 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestEOTWrite(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	var tts = []EOTOptions{
+		{Version: 0x00010000},
+		{Version: 0x00020001, RootStrings: []string{"http://intranet/"}},
+		{RootStrings: []string{"http://intranet/", "https://intranet/"}, EUDCCodePage: 1252, EUDCFontData: []byte{1, 2, 3}},
+		{XOR: true},
+	}
+	for i, tt := range tts {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			eot, err := sfnt.WriteEOT(tt)
+			test.Error(t, err)
+			test.T(t, binary.LittleEndian.Uint32(eot), uint32(len(eot)))
+			test.T(t, binary.LittleEndian.Uint32(eot[28:]), uint32(sfnt.OS2.UsWeightClass))
+			test.T(t, eot[16:26], []byte{2, 6, 6, 3, 5, 6, 5, 2, 2, 4})     // PANOSE
+			test.T(t, binary.LittleEndian.Uint16(eot[34:]), uint16(0x504C)) // MagicNumber
+
+			// FamilyName
+			familyNameSize := int(binary.LittleEndian.Uint16(eot[82:]))
+			test.T(t, string(eot[84:84+familyNameSize]), "D\x00e\x00j\x00a\x00V\x00u\x00 \x00S\x00e\x00r\x00i\x00f\x00")
+
+			b2, err := ParseEOT(eot)
+			test.Error(t, err)
+			sfnt2, err := ParseSFNT(b2, 0)
+			test.Error(t, err)
+			test.T(t, sfnt2.NumGlyphs(), sfnt.NumGlyphs())
+			test.T(t, sfnt2.GlyphIndex('A'), sfnt.GlyphIndex('A'))
+			test.T(t, binary.LittleEndian.Uint32(eot[60:]), binary.BigEndian.Uint32(sfnt2.Tables["head"][8:])) // CheckSumAdjustment
+		})
+	}
+
+	_, err = sfnt.WriteEOT(EOTOptions{Version: 0x00010000, RootStrings: []string{"http://intranet/"}})
+	test.T(t, err.Error(), "root strings require version 0x00020001 or later")
+}
+
+func TestEOTTruncated(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// the font data is at the end of the file
+	eot, err := sfnt.WriteEOT(EOTOptions{})
+	test.Error(t, err)
+	_, err = ParseEOT(eot)
+	test.Error(t, err)
+	_, err = ParseEOT(eot[:len(eot)-1])
+	test.T(t, err, ErrInvalidFontData)
+
+	for _, n := range []int{0, 4, 60, 82, 84} {
+		_, err = ParseEOT(eot[:n])
+		test.That(t, err != nil, n)
+	}
+}
+
+func TestEOTCompressed(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.eot")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// compressed by WEFT from the same font but a different build
+	b, err = ioutil.ReadFile("resources/DejaVuSerif_compressed.eot")
+	test.Error(t, err)
+	b, err = ParseEOT(b)
+	test.Error(t, err)
+	sfntCompressed, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	eot, err := sfnt.WriteEOT(EOTOptions{Compress: true})
+	test.Error(t, err)
+	test.T(t, binary.LittleEndian.Uint32(eot[12:])&eotCompressed, uint32(eotCompressed))
+	b, err = ParseEOT(eot)
+	test.Error(t, err)
+	sfntRoundtrip, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	for _, sfnt2 := range []*SFNT{sfntCompressed, sfntRoundtrip} {
+		test.T(t, sfnt2.NumGlyphs(), sfnt.NumGlyphs())
+		test.T(t, sfnt2.Tables["cvt "], sfnt.Tables["cvt "])
+		test.T(t, sfnt2.Tables["hmtx"], sfnt.Tables["hmtx"])
+		for glyphID := uint16(0); glyphID < sfnt.NumGlyphs(); glyphID++ {
+			contour, err := sfnt.Glyf.Contour(glyphID)
+			test.Error(t, err)
+			contour2, err := sfnt2.Glyf.Contour(glyphID)
+			test.Error(t, err)
+			// instructions are equivalent but push instructions may be encoded differently
+			contour.Instructions, contour2.Instructions = nil, nil
+			test.T(t, contour2, contour, "glyph", glyphID)
+		}
+	}
+}
+
+func TestEOTCompressedDeviceMetrics(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// the hinted advances differ from the predicted linear advances for some glyphs
+	hdmx := &hdmxTable{}
+	for _, ppem := range []uint8{9, 12, 16} {
+		record := hdmxRecord{
+			PixelSize: ppem,
+			Widths:    make([]uint8, sfnt.NumGlyphs()),
+		}
+		for glyphID := range record.Widths {
+			record.Widths[glyphID] = uint8(sfnt.GlyphAdvancePPEM(uint16(glyphID), uint16(ppem)))
+			record.MaxWidth = max(record.MaxWidth, record.Widths[glyphID])
+		}
+		hdmx.Records = append(hdmx.Records, record)
+	}
+	vdmx := &vdmxTable{
+		Version: 1,
+		Ratios: []vdmxRatio{
+			{CharSet: 1, XRatio: 2, YStartRatio: 1, YEndRatio: 1, Group: 0},
+			{CharSet: 1, Group: 1},
+		},
+		Groups: []vdmxGroup{
+			{StartSize: 8, EndSize: 8, Records: []vdmxRecord{{8, 200, -200}}},
+			{StartSize: 12, EndSize: 13, Records: []vdmxRecord{{12, 12, -4}, {13, 13, -4}}},
+		},
+	}
+	sfnt.Tables["hdmx"] = hdmx.Write()
+	sfnt.Tables["VDMX"] = vdmx.Write()
+	sfnt, err = ParseSFNT(sfnt.Write(), 0)
+	test.Error(t, err)
+	test.That(t, sfnt.Hdmx != nil && sfnt.Vdmx != nil)
+
+	eot, err := sfnt.WriteEOT(EOTOptions{Compress: true})
+	test.Error(t, err)
+	b, err = ParseEOT(eot)
+	test.Error(t, err)
+	sfntRoundtrip, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+	test.T(t, sfntRoundtrip.Tables["hdmx"], sfnt.Tables["hdmx"])
+	test.T(t, sfntRoundtrip.Tables["VDMX"], sfnt.Tables["VDMX"])
+}
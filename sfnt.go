@@ -28,13 +28,16 @@ type Pather interface {
 	Close()
 }
 
-// Hinting specifies the type of hinting to use (none supported yes).
+// Hinting specifies the type of hinting to use. For TrueType outlines, hinting runs the glyph instructions.
 type Hinting int
 
 // see Hinting
 const (
-	NoHinting Hinting = iota
-	VerticalHinting
+	NoHinting         Hinting = iota
+	VerticalHinting           // hint vertically only, as the ClearType-style v40 interpreter of FreeType
+	FullHinting               // hint horizontally and vertically, as the v35 interpreter of FreeType
+	AutoHinting               // choose NoHinting, VerticalHinting, or FullHinting per ppem from the gasp table using the font's hints, see SFNT.Hinting
+	AutohinterHinting         // ignore the font's hints and use the automatic hinter, for fonts without (good) hints; unlike AutoHinting it does not consult the gasp table
 )
 
 // SFNT is a parsed OpenType font.
@@ -62,20 +65,38 @@ type SFNT struct {
 	CFF *cffTable
 
 	// optional
+	Gasp *gaspTable
+	Hdmx *hdmxTable
 	Kern *kernTable
+	Ltsh *ltshTable
+	Vdmx *vdmxTable
 	Vhea *vheaTable
 	Vmtx *vmtxTable
 
+	// bitmap
+	Ebdt *ebdtTable
+	Eblc *eblcTable
+	Ebsc *ebscTable
+
+	// color
+	Cbdt *ebdtTable
+	Cblc *eblcTable
+	Colr *colrTable
+	Cpal *cpalTable
+	Sbix *sbixTable
+	Svg  *svgTable
+
 	// TODO: SFNT tables
-	//Hdmx *hdmxTable
 	Gpos *gposgsubTable
 	Gsub *gposgsubTable
 	Jsft *jsftTable
-	//Gasp *gaspTable
 	//Base *baseTable
 	//Prep *baseTable
 	//Fpgm *baseTable
 	//Cvt *baseTable
+
+	autohinterOnce sync.Once
+	autohinter     *autohinter
 }
 
 // NumGlyphs returns the number of glyphs the font contains.
@@ -171,8 +192,58 @@ func (sfnt *SFNT) VerticalMetrics() (uint16, uint16, uint16) {
 	return ascender, descender, lineGap
 }
 
-// GlyphPath draws the glyph's contour as a path to the pather interface. It will use the specified ppem (pixels-per-EM) for hinting purposes. The path is draws to the (x,y) coordinate and scaled using the given scale factor.
+// VerticalMetricsPPEM returns the ascender and descender in pixels at the given ppem, as used by Windows GDI for pixel-exact layout. It uses the VDMX table if available, otherwise it rounds the scaled "win" values, or the "hhea" values if those are not set.
+func (sfnt *SFNT) VerticalMetricsPPEM(ppem uint16) (uint16, uint16) {
+	if sfnt.Vdmx != nil {
+		if yMax, yMin, ok := sfnt.Vdmx.Get(ppem, 1, 1); ok {
+			return uint16(max(0, yMax)), uint16(max(0, -yMin))
+		}
+	}
+
+	var ascender, descender uint16
+	if 0 < sfnt.Hhea.Ascender {
+		ascender = uint16(sfnt.Hhea.Ascender)
+	}
+	if sfnt.Hhea.Descender < 0 {
+		descender = uint16(-sfnt.Hhea.Descender)
+	}
+	if sfnt.OS2 != nil && sfnt.OS2.UsWinAscent != 0 && sfnt.OS2.UsWinDescent != 0 {
+		ascender, descender = sfnt.OS2.UsWinAscent, sfnt.OS2.UsWinDescent
+	}
+	f := float64(ppem) / float64(sfnt.Head.UnitsPerEm)
+	return uint16(math.Round(f * float64(ascender))), uint16(math.Round(f * float64(descender)))
+}
+
+// GaspBehavior returns the rasterization behavior for the given ppem from the gasp table. Without a gasp table it returns grid-fitting and grayscale rendering for all sizes.
+func (sfnt *SFNT) GaspBehavior(ppem uint16) GaspBehavior {
+	if sfnt.Gasp == nil {
+		return GaspGridFit | GaspDoGray
+	}
+	return sfnt.Gasp.Get(ppem)
+}
+
+// Hinting returns the hinting to use at the given ppem according to the gasp table. It returns NoHinting if grid-fitting is disabled, VerticalHinting if grid-fitting is enabled only for ClearType, and FullHinting otherwise.
+func (sfnt *SFNT) Hinting(ppem uint16) Hinting {
+	behavior := sfnt.GaspBehavior(ppem)
+	if behavior&GaspGridFit != 0 {
+		return FullHinting
+	} else if behavior&GaspSymmetricGridFit != 0 {
+		return VerticalHinting
+	}
+	return NoHinting
+}
+
+// GlyphPath draws the glyph's contour as a path to the pather interface. It will use the specified ppem (pixels-per-EM) for hinting purposes, with AutoHinting the hinting is chosen from the gasp table. The path is draws to the (x,y) coordinate and scaled using the given scale factor.
 func (sfnt *SFNT) GlyphPath(p Pather, glyphID, ppem uint16, x, y, scale float64, hinting Hinting) error {
+	if hinting == AutoHinting {
+		hinting = sfnt.Hinting(ppem)
+	} else if hinting == AutohinterHinting {
+		if ppem == 0 {
+			hinting = NoHinting
+		} else {
+			return sfnt.autohint(p, glyphID, ppem, x, y, scale, false)
+		}
+	}
 	if sfnt.IsTrueType {
 		return sfnt.Glyf.ToPath(p, glyphID, ppem, x, y, scale, hinting)
 	} else if sfnt.IsCFF {
@@ -186,6 +257,25 @@ func (sfnt *SFNT) GlyphAdvance(glyphID uint16) uint16 {
 	return sfnt.Hmtx.Advance(glyphID)
 }
 
+// GlyphAdvancePPEM returns the (horizontal) advance width in pixels of the glyph at the given ppem, as used by Windows GDI for pixel-exact layout of hinted text. It uses the hdmx table if available. Otherwise, TrueType glyphs are hinted to obtain their advance unless the LTSH table specifies that the glyph scales linearly. For other glyphs it returns the rounded scaled advance.
+func (sfnt *SFNT) GlyphAdvancePPEM(glyphID, ppem uint16) uint16 {
+	if sfnt.Hdmx != nil {
+		if width, ok := sfnt.Hdmx.Get(glyphID, ppem); ok {
+			return uint16(width)
+		}
+	}
+	if sfnt.IsTrueType && sfnt.Glyf.hinter != nil && (sfnt.Ltsh == nil || !sfnt.Ltsh.IsLinear(glyphID, ppem)) {
+		if zone, err := sfnt.Glyf.hinter.Hint(glyphID, ppem, FullHinting); err == nil {
+			// advance between the hinted phantom points
+			n := len(zone.cur) - 4
+			if advance := zone.cur[n+1].X - zone.cur[n].X; 0 <= advance {
+				return uint16((advance + 32) >> 6)
+			}
+		}
+	}
+	return uint16(math.Round(float64(sfnt.GlyphAdvance(glyphID)) * float64(ppem) / float64(sfnt.Head.UnitsPerEm)))
+}
+
 // GlyphVerticalAdvance returns the vertical advance width of the glyph.
 func (sfnt *SFNT) GlyphVerticalAdvance(glyphID uint16) uint16 {
 	if sfnt.Vmtx == nil {
@@ -281,41 +371,14 @@ func parseSFNT(b []byte, index int, embedded bool) (*SFNT, error) {
 		return nil, ErrInvalidFontData
 	}
 
+	offset, err := fontOffset(b, index)
+	if err != nil {
+		return nil, err
+	}
+
 	r := parse.NewBinaryReaderBytes(b)
+	r.Seek(int64(offset), 0)
 	sfntVersion := r.ReadString(4)
-	isCollection := sfntVersion == "ttcf"
-	if isCollection {
-		majorVersion := r.ReadUint16()
-		minorVersion := r.ReadUint16()
-		if majorVersion != 1 && majorVersion != 2 || minorVersion != 0 {
-			return nil, fmt.Errorf("bad TTC version")
-		}
-
-		numFonts := r.ReadUint32()
-		if index < 0 || numFonts <= uint32(index) {
-			return nil, fmt.Errorf("bad font index %d", index)
-		}
-		if r.Len() < 4*int64(numFonts) {
-			return nil, ErrInvalidFontData
-		}
-
-		_ = r.ReadBytes(int64(4 * index))
-		offset := r.ReadUint32()
-		var length uint32
-		if uint32(index)+1 == numFonts {
-			length = uint32(len(b)) - offset
-		} else {
-			length = r.ReadUint32() - offset
-		}
-		if uint32(len(b))-8 < offset || uint32(len(b))-8-offset < length {
-			return nil, ErrInvalidFontData
-		}
-
-		r.Seek(int64(offset), 0)
-		sfntVersion = r.ReadString(4)
-	} else if index != 0 {
-		return nil, fmt.Errorf("bad font index %d", index)
-	}
 	if sfntVersion != "OTTO" && sfntVersion != "true" && binary.BigEndian.Uint32([]byte(sfntVersion)) != 0x00010000 {
 		return nil, fmt.Errorf("bad SFNT version")
 	}
@@ -366,9 +429,6 @@ func parseSFNT(b []byte, index int, embedded bool) (*SFNT, error) {
 	sfnt.IsCFF = sfntVersion == "OTTO"
 	sfnt.IsTrueType = sfntVersion == "true" || binary.BigEndian.Uint32([]byte(sfntVersion)) == 0x00010000
 	sfnt.Tables = tables
-	//if isCollection {
-	//	sfnt.Data = sfnt.Write() // TODO: what is this?
-	//}
 
 	var requiredTables []string
 	if embedded {
@@ -431,26 +491,52 @@ func parseSFNT(b []byte, index int, embedded bool) (*SFNT, error) {
 			err = sfnt.parseCFF()
 		case "CFF2":
 			err = sfnt.parseCFF2()
+		case "CBDT":
+			err = sfnt.parseCBDT()
+		case "CBLC":
+			err = sfnt.parseCBLC()
 		case "cmap":
 			err = sfnt.parseCmap()
+		case "COLR":
+			err = sfnt.parseCOLR()
+		case "CPAL":
+			err = sfnt.parseCPAL()
+		case "EBDT":
+			err = sfnt.parseEBDT()
+		case "EBLC":
+			err = sfnt.parseEBLC()
+		case "EBSC":
+			err = sfnt.parseEBSC()
+		case "gasp":
+			err = sfnt.parseGasp()
 		case "glyf":
 			err = sfnt.parseGlyf()
 		//case "GPOS":
 		//	err = sfnt.parseGPOS()
 		//case "GSUB":
 		//	err = sfnt.parseGSUB()
+		case "hdmx":
+			err = sfnt.parseHdmx()
 		case "hhea":
 			err = sfnt.parseHhea()
 		case "hmtx":
 			err = sfnt.parseHmtx()
 		case "kern":
 			err = sfnt.parseKern()
+		case "LTSH":
+			err = sfnt.parseLTSH()
 		case "name":
 			err = sfnt.parseName()
 		case "OS/2":
 			err = sfnt.parseOS2()
 		case "post":
 			err = sfnt.parsePost()
+		case "sbix":
+			err = sfnt.parseSbix()
+		case "SVG ":
+			err = sfnt.parseSVG()
+		case "VDMX":
+			err = sfnt.parseVDMX()
 		case "vhea":
 			err = sfnt.parseVhea()
 		case "vmtx":
@@ -590,6 +676,38 @@ func (sfnt *SFNT) parseHead() error {
 	return nil
 }
 
+// headTimestamp returns the number of seconds since 1904-01-01, which is the epoch used by the head table.
+func headTimestamp(t time.Time) int64 {
+	epoch := time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	if t.Before(epoch) {
+		return 0
+	}
+	return t.Unix() - epoch.Unix()
+}
+
+func (head *headTable) Write() []byte {
+	w := parse.NewBinaryWriter(make([]byte, 0, 54))
+	w.WriteUint16(1) // majorVersion
+	w.WriteUint16(0) // minorVersion
+	w.WriteUint32(head.FontRevision)
+	w.WriteUint32(0)          // checksumAdjustment, set by SFNT.Write
+	w.WriteUint32(0x5F0F3CF5) // magicNumber
+	w.WriteUint16(flagsToUint16(head.Flags))
+	w.WriteUint16(head.UnitsPerEm)
+	w.WriteInt64(headTimestamp(head.Created))
+	w.WriteInt64(headTimestamp(head.Modified))
+	w.WriteInt16(head.XMin)
+	w.WriteInt16(head.YMin)
+	w.WriteInt16(head.XMax)
+	w.WriteInt16(head.YMax)
+	w.WriteUint16(flagsToUint16(head.MacStyle))
+	w.WriteUint16(head.LowestRecPPEM)
+	w.WriteInt16(head.FontDirectionHint)
+	w.WriteInt16(head.IndexToLocFormat)
+	w.WriteInt16(head.GlyphDataFormat)
+	return w.Bytes()
+}
+
 ////////////////////////////////////////////////////////////////
 
 type hheaTable struct {
@@ -648,6 +766,26 @@ func (sfnt *SFNT) parseHhea() error {
 	return nil
 }
 
+func (hhea *hheaTable) Write() []byte {
+	w := parse.NewBinaryWriter(make([]byte, 0, 36))
+	w.WriteUint16(1) // majorVersion
+	w.WriteUint16(0) // minorVersion
+	w.WriteInt16(hhea.Ascender)
+	w.WriteInt16(hhea.Descender)
+	w.WriteInt16(hhea.LineGap)
+	w.WriteUint16(hhea.AdvanceWidthMax)
+	w.WriteInt16(hhea.MinLeftSideBearing)
+	w.WriteInt16(hhea.MinRightSideBearing)
+	w.WriteInt16(hhea.XMaxExtent)
+	w.WriteInt16(hhea.CaretSlopeRise)
+	w.WriteInt16(hhea.CaretSlopeRun)
+	w.WriteInt16(hhea.CaretOffset)
+	w.WriteInt64(0) // reserved
+	w.WriteInt16(hhea.MetricDataFormat)
+	w.WriteUint16(hhea.NumberOfHMetrics)
+	return w.Bytes()
+}
+
 ////////////////////////////////////////////////////////////////
 
 type vheaTable struct {
@@ -764,6 +902,35 @@ func (sfnt *SFNT) parseHmtx() error {
 	return nil
 }
 
+// newHmtxTable returns the horizontal metrics given the advance widths and left side bearings of all glyphs. Trailing glyphs with the same advance width only store their left side bearing.
+func newHmtxTable(advances []uint16, lsbs []int16) *hmtxTable {
+	numberOfHMetrics := len(advances)
+	for 1 < numberOfHMetrics && advances[numberOfHMetrics-1] == advances[numberOfHMetrics-2] {
+		numberOfHMetrics--
+	}
+
+	hmtx := &hmtxTable{}
+	hmtx.HMetrics = make([]hmtxLongHorMetric, numberOfHMetrics)
+	for i := range hmtx.HMetrics {
+		hmtx.HMetrics[i].AdvanceWidth = advances[i]
+		hmtx.HMetrics[i].LeftSideBearing = lsbs[i]
+	}
+	hmtx.LeftSideBearings = append([]int16{}, lsbs[numberOfHMetrics:]...)
+	return hmtx
+}
+
+func (hmtx *hmtxTable) Write() []byte {
+	w := parse.NewBinaryWriter(make([]byte, 0, 4*len(hmtx.HMetrics)+2*len(hmtx.LeftSideBearings)))
+	for _, metric := range hmtx.HMetrics {
+		w.WriteUint16(metric.AdvanceWidth)
+		w.WriteInt16(metric.LeftSideBearing)
+	}
+	for _, lsb := range hmtx.LeftSideBearings {
+		w.WriteInt16(lsb)
+	}
+	return w.Bytes()
+}
+
 ////////////////////////////////////////////////////////////////
 
 type vmtxLongVerMetric struct {
@@ -947,6 +1114,22 @@ func (t *nameTable) Get(name NameID) []nameRecord {
 	return records
 }
 
+// Find returns the name as a string, preferring the Windows English name record. It returns an empty string if the name is not present.
+func (t *nameTable) Find(name NameID) string {
+	records := t.Get(name)
+	if len(records) == 0 {
+		return ""
+	}
+	record := records[0]
+	for _, r := range records {
+		if r.Platform == PlatformWindows && r.Language == 0x0409 {
+			record = r
+			break
+		}
+	}
+	return record.String()
+}
+
 func (sfnt *SFNT) parseName() error {
 	// TODO: lazy parse
 	b, ok := sfnt.Tables["name"]
@@ -1005,6 +1188,75 @@ func (sfnt *SFNT) parseName() error {
 	return nil
 }
 
+// Add adds a name record for the Windows platform in US English, which is the name record that is most widely supported.
+func (t *nameTable) Add(name NameID, value string) {
+	encoder := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+	b, _, err := transform.Bytes(encoder, []byte(value))
+	if err != nil {
+		return
+	}
+	t.NameRecord = append(t.NameRecord, nameRecord{
+		Platform: PlatformWindows,
+		Encoding: EncodingWindowsUnicodeBMP,
+		Language: 0x0409,
+		Name:     name,
+		Value:    b,
+	})
+}
+
+func (t *nameTable) Write() ([]byte, error) {
+	records := append([]nameRecord{}, t.NameRecord...)
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		} else if a.Encoding != b.Encoding {
+			return a.Encoding < b.Encoding
+		} else if a.Language != b.Language {
+			return a.Language < b.Language
+		}
+		return a.Name < b.Name
+	})
+
+	version := uint16(0)
+	storageOffset := 6 + 12*len(records)
+	if 0 < len(t.LangTag) {
+		version = 1
+		storageOffset += 2 + 4*len(t.LangTag)
+	}
+	if math.MaxUint16 < len(records) || math.MaxUint16 < storageOffset {
+		return nil, fmt.Errorf("name: too many records")
+	}
+
+	storage := []byte{}
+	w := parse.NewBinaryWriter(make([]byte, 0, storageOffset))
+	w.WriteUint16(version)
+	w.WriteUint16(uint16(len(records)))
+	w.WriteUint16(uint16(storageOffset))
+	for _, record := range records {
+		w.WriteUint16(uint16(record.Platform))
+		w.WriteUint16(uint16(record.Encoding))
+		w.WriteUint16(record.Language)
+		w.WriteUint16(uint16(record.Name))
+		w.WriteUint16(uint16(len(record.Value)))
+		w.WriteUint16(uint16(len(storage)))
+		storage = append(storage, record.Value...)
+	}
+	if version == 1 {
+		w.WriteUint16(uint16(len(t.LangTag)))
+		for _, record := range t.LangTag {
+			w.WriteUint16(uint16(len(record.Value)))
+			w.WriteUint16(uint16(len(storage)))
+			storage = append(storage, record.Value...)
+		}
+	}
+	if math.MaxUint16 < storageOffset+len(storage) {
+		return nil, fmt.Errorf("name: table too large")
+	}
+	w.WriteBytes(storage)
+	return w.Bytes(), nil
+}
+
 ////////////////////////////////////////////////////////////////
 
 type postTable struct {
@@ -1128,7 +1380,7 @@ func (post *postTable) Write() ([]byte, error) {
 
 	w := parse.NewBinaryWriter(make([]byte, 0, 32))
 	w.WriteUint32(uint32(version))
-	w.WriteUint32(uint32(post.ItalicAngle*(1<<16) + 0.5))
+	w.WriteUint32(uint32(int32(math.Round(post.ItalicAngle * (1 << 16)))))
 	w.WriteInt16(post.UnderlinePosition)
 	w.WriteInt16(post.UnderlineThickness)
 	w.WriteUint32(post.IsFixedPitch)
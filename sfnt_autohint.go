@@ -0,0 +1,373 @@
+package font
+
+import (
+	"math"
+	"sort"
+)
+
+// autohintContours returns the points of each contour of the outline, including the control points.
+func autohintContours(outline *outlinePather) [][][2]float64 {
+	contours := make([][][2]float64, 0, len(outline.contours))
+	for _, contour := range outline.contours {
+		points := contour.points
+		if 1 < len(points) && points[0] == points[len(points)-1] {
+			points = points[:len(points)-1]
+		}
+		if 1 < len(points) {
+			contours = append(contours, points)
+		}
+	}
+	return contours
+}
+
+type autohintSegment struct {
+	pos      float64 // y-coordinate for horizontal segments, x-coordinate for vertical segments
+	min, max float64 // extent along the segment
+	first    bool    // bottom edge for horizontal segments, left edge for vertical segments
+}
+
+// autohintSegments returns the horizontal (or vertical) segments of the contours. The orientation of the outer contours is given by ccw.
+func autohintSegments(contours [][][2]float64, vertical, ccw bool) []autohintSegment {
+	u, v := 0, 1 // u is along the segment, v is the position
+	if vertical {
+		u, v = 1, 0
+	}
+
+	segments := []autohintSegment{}
+	for _, contour := range contours {
+		n := len(contour)
+		dirs := make([]int, n) // direction along u of each edge, or zero
+		for i := 0; i < n; i++ {
+			p, q := contour[i], contour[(i+1)%n]
+			du, dv := q[u]-p[u], q[v]-p[v]
+			if du != 0.0 && math.Abs(dv)*12.0 <= math.Abs(du) {
+				if 0.0 < du {
+					dirs[i] = 1
+				} else {
+					dirs[i] = -1
+				}
+			}
+		}
+
+		// local extrema on curves that are not part of a run form their own segments
+		for i := 0; i < n; i++ {
+			if dirs[i] != 0 || dirs[(i+n-1)%n] != 0 {
+				continue
+			}
+			prev, p, next := contour[(i+n-1)%n], contour[i], contour[(i+1)%n]
+			if (prev[v] < p[v]) != (next[v] < p[v]) || prev[v] == p[v] || next[v] == p[v] {
+				continue
+			}
+			segment := autohintSegment{
+				pos: p[v],
+				min: math.Min(p[u], (prev[u]+next[u])/2.0),
+				max: math.Max(p[u], (prev[u]+next[u])/2.0),
+			}
+			if vertical {
+				segment.first = (next[u] < prev[u]) == ccw
+			} else {
+				segment.first = (prev[u] < next[u]) == ccw
+			}
+			segments = append(segments, segment)
+		}
+
+		// start at an edge that begins a run
+		start := -1
+		for i := 0; i < n; i++ {
+			if dirs[i] != 0 && dirs[i] != dirs[(i+n-1)%n] {
+				start = i
+				break
+			}
+		}
+		if start == -1 {
+			continue
+		}
+		for k := 0; k < n; {
+			i := (start + k) % n
+			dir := dirs[i]
+			if dir == 0 {
+				k++
+				continue
+			}
+
+			// run of edges in the same direction
+			segment := autohintSegment{min: math.Inf(1), max: math.Inf(-1)}
+			sum, count := 0.0, 0
+			for ; k < n && dirs[(start+k)%n] == dir; k++ {
+				for _, p := range [][2]float64{contour[(start+k)%n], contour[(start+k+1)%n]} {
+					segment.min = math.Min(segment.min, p[u])
+					segment.max = math.Max(segment.max, p[u])
+					sum += p[v]
+					count++
+				}
+			}
+			segment.pos = sum / float64(count)
+
+			// for counter-clockwise outer contours, bottom edges run towards +x and left edges towards -y
+			if vertical {
+				segment.first = (dir == -1) == ccw
+			} else {
+				segment.first = (dir == 1) == ccw
+			}
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+type autohintEdge struct {
+	pos, hinted float64 // hinted is in pixels
+	first       bool
+	length      float64 // of the longest segment
+	fixed       bool
+}
+
+// autohintMap merges the segments into edges and links them into stems. It aligns the edges in blue zones and the stems to the pixel grid, and returns the hinted edges sorted by their original position.
+func autohintMap(h *cffHinter, segments []autohintSegment, threshold, maxWidth, lenScore float64, blues bool) []cffHintEdge {
+	// merge segments of the same kind at nearby positions into edges
+	edges := []autohintEdge{}
+	edgeOf := make([]int, len(segments))
+	for i, s := range segments {
+		edgeOf[i] = -1
+		for j, e := range edges {
+			if e.first == s.first && math.Abs(e.pos-s.pos) <= threshold {
+				if e.length < s.max-s.min {
+					edges[j].pos, edges[j].length = s.pos, s.max-s.min
+				}
+				edgeOf[i] = j
+				break
+			}
+		}
+		if edgeOf[i] == -1 {
+			edgeOf[i] = len(edges)
+			edges = append(edges, autohintEdge{pos: s.pos, first: s.first, length: s.max - s.min})
+		}
+	}
+
+	if blues {
+		for i, e := range edges {
+			if hinted, ok := h.capture(e.pos, !e.first); ok {
+				edges[i].hinted, edges[i].fixed = hinted, true
+			}
+		}
+	}
+
+	// find the best matching opposite segment for each segment, where the first segment is below or left of the second segment
+	best := make([]int, len(segments))
+	scores := make([]float64, len(segments))
+	for i := range segments {
+		best[i] = -1
+		scores[i] = math.Inf(1)
+	}
+	for i, s1 := range segments {
+		if !s1.first {
+			continue
+		}
+		for j, s2 := range segments {
+			if s2.first || s2.pos <= s1.pos || maxWidth < s2.pos-s1.pos {
+				continue
+			}
+			overlap := math.Min(s1.max, s2.max) - math.Max(s1.min, s2.min)
+			if overlap <= 0.0 {
+				continue
+			}
+			score := s2.pos - s1.pos + lenScore/overlap
+			if score < scores[i] {
+				best[i], scores[i] = j, score
+			}
+			if score < scores[j] {
+				best[j], scores[j] = i, score
+			}
+		}
+	}
+
+	type stem struct {
+		lo, hi int // edge indices
+	}
+	stems := []stem{}
+	for i, s := range segments {
+		if j := best[i]; s.first && j != -1 && best[j] == i {
+			st := stem{edgeOf[i], edgeOf[j]}
+			if edges[st.lo].pos < edges[st.hi].pos {
+				stems = append(stems, st)
+			}
+		}
+	}
+
+	// align stems with an edge in a blue zone first, and prefer narrow stems
+	sort.SliceStable(stems, func(i, j int) bool {
+		fixedI := edges[stems[i].lo].fixed || edges[stems[i].hi].fixed
+		fixedJ := edges[stems[j].lo].fixed || edges[stems[j].hi].fixed
+		if fixedI != fixedJ {
+			return fixedI
+		}
+		return edges[stems[i].hi].pos-edges[stems[i].lo].pos < edges[stems[j].hi].pos-edges[stems[j].lo].pos
+	})
+	for _, s := range stems {
+		lo, hi := &edges[s.lo], &edges[s.hi]
+		width := math.Max(1.0, math.Round((hi.pos-lo.pos)*h.scale))
+		if lo.fixed && hi.fixed {
+			continue
+		} else if lo.fixed {
+			hi.hinted = lo.hinted + width
+		} else if hi.fixed {
+			lo.hinted = hi.hinted - width
+		} else {
+			lo.hinted = math.Round((lo.pos+hi.pos)/2.0*h.scale - width/2.0)
+			hi.hinted = lo.hinted + width
+		}
+		lo.fixed, hi.fixed = true, true
+	}
+
+	// skip edges that would reverse the order
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].pos < edges[j].pos
+	})
+	hinted := []cffHintEdge{}
+	for _, e := range edges {
+		if !e.fixed {
+			continue
+		} else if 0 < len(hinted) && e.hinted/h.scale < hinted[len(hinted)-1].hinted {
+			continue
+		}
+		hinted = append(hinted, cffHintEdge{orig: e.pos, hinted: e.hinted / h.scale})
+	}
+	return hinted
+}
+
+// autohinter holds the global metrics of the font used by the automatic hinter, in font units.
+type autohinter struct {
+	blues      []cffBlueZone
+	overshoot  float64 // maximum overshoot
+	unitsPerEm float64
+}
+
+// newAutohinter estimates the blue zones from the bounds of reference glyphs, similar to estimateOS2.
+func (sfnt *SFNT) newAutohinter() *autohinter {
+	a := &autohinter{
+		unitsPerEm: float64(sfnt.Head.UnitsPerEm),
+	}
+	bounds := func(r rune) (*bboxPather, bool) {
+		glyphID := sfnt.GlyphIndex(r)
+		if glyphID == 0 {
+			return nil, false
+		}
+		p := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+		if err := sfnt.GlyphPath(p, glyphID, 0, 0, 0, 1.0, NoHinting); err != nil || math.IsInf(p.YMin, 0) {
+			return nil, false
+		}
+		return p, true
+	}
+	addZone := func(flat, overshoot float64, isTop bool) {
+		zone := cffBlueZone{bottom: flat, top: flat, flat: flat, isTop: isTop}
+		if isTop && flat < overshoot {
+			zone.top = overshoot
+		} else if !isTop && overshoot < flat {
+			zone.bottom = overshoot
+		}
+		a.overshoot = math.Max(a.overshoot, zone.top-zone.bottom)
+		a.blues = append(a.blues, zone)
+	}
+
+	x, okX := bounds('x')
+	H, okH := bounds('H')
+	o, okO := bounds('o')
+	O, okOO := bounds('O')
+	if okX || okH {
+		// baseline
+		flat, overshoot := math.Inf(-1), math.Inf(1)
+		if okX {
+			flat = math.Max(flat, x.YMin)
+		}
+		if okH {
+			flat = math.Max(flat, H.YMin)
+		}
+		if okO {
+			overshoot = math.Min(overshoot, o.YMin)
+		}
+		if okOO {
+			overshoot = math.Min(overshoot, O.YMin)
+		}
+		addZone(flat, overshoot, false)
+	}
+	if okX {
+		// x-height
+		overshoot := x.YMax
+		if okO {
+			overshoot = o.YMax
+		}
+		addZone(x.YMax, overshoot, true)
+	}
+	if okH {
+		// cap-height
+		overshoot := H.YMax
+		if okOO {
+			overshoot = O.YMax
+		}
+		addZone(H.YMax, overshoot, true)
+	}
+	if d, ok := bounds('d'); ok {
+		// ascender
+		addZone(d.YMax, d.YMax, true)
+	}
+	if p, ok := bounds('p'); ok {
+		// descender
+		addZone(p.YMin, p.YMin, false)
+	}
+	return a
+}
+
+// hint returns the hinter with the hinted edges for a glyph outline at the given ppem.
+func (a *autohinter) hint(contours [][][2]float64, ppem uint16) *cffHinter {
+	scale := float64(ppem) / a.unitsPerEm
+	h := &cffHinter{
+		scale:             scale,
+		hinting:           FullHinting,
+		blues:             a.blues,
+		blueFuzz:          0.25 / scale,
+		blueShift:         math.Inf(1),
+		suppressOvershoot: a.overshoot*scale < 0.5,
+	}
+
+	// orientation of the outer contours from the signed area of the outline
+	area := 0.0
+	for _, contour := range contours {
+		for i := range contour {
+			p, q := contour[i], contour[(i+1)%len(contour)]
+			area += p[0]*q[1] - q[0]*p[1]
+		}
+	}
+	ccw := 0.0 <= area
+
+	threshold := math.Min(0.25/scale, a.unitsPerEm/100.0)
+	maxWidth := 0.3 * a.unitsPerEm
+	lenScore := 6000.0 * a.unitsPerEm / 2048.0
+	h.ymap = autohintMap(h, autohintSegments(contours, false, ccw), threshold, maxWidth, lenScore, true)
+	h.xmap = autohintMap(h, autohintSegments(contours, true, ccw), threshold, maxWidth, lenScore, false)
+	return h
+}
+
+// autohint draws the glyph hinted by the automatic hinter to the pather. The automatic hinter is similar to FreeType's autofit module. It ignores the font's hints and instead detects horizontal and vertical segments in the outline, links them into stems, and snaps the stems to the pixel grid. Global blue zones are estimated from the reference glyphs 'x', 'H', 'o', 'O', 'd', and 'p'. The snapping and interpolation of the CFF stem hinter is reused but with coordinates in font units. If vertical is set, only the y coordinates are hinted.
+func (sfnt *SFNT) autohint(p Pather, glyphID, ppem uint16, x, y, f float64, vertical bool) error {
+	outline := &outlinePather{}
+	if err := sfnt.GlyphPath(outline, glyphID, ppem, 0.0, 0.0, 1.0, NoHinting); err != nil {
+		return err
+	}
+
+	sfnt.autohinterOnce.Do(func() {
+		sfnt.autohinter = sfnt.newAutohinter()
+	})
+	h := sfnt.autohinter.hint(autohintContours(outline), ppem)
+	if vertical {
+		h.xmap = nil
+	}
+	for _, contour := range outline.contours {
+		points := make([][2]float64, len(contour.points))
+		for i, pt := range contour.points {
+			points[i] = [2]float64{x + f*cffMapHint(h.xmap, pt[0]), y + f*cffMapHint(h.ymap, pt[1])}
+		}
+		hinted := outlineContour{ops: contour.ops, points: points}
+		hinted.draw(p)
+	}
+	return nil
+}
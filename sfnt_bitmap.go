@@ -0,0 +1,838 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// maxBitmapComponentDepth is the maximum nesting depth of component bitmap glyphs.
+const maxBitmapComponentDepth = 8
+
+// GlyphBitmap is an embedded bitmap image of a glyph at a specific ppem (pixels-per-EM). The image holds the glyph's coverage, where 0 is background and 255 is foreground. The origin of the image is at its top-left corner.
+type GlyphBitmap struct {
+	*image.Alpha
+	BearingX, BearingY         int // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Advance                    int // horizontal advance in pixels
+	VertBearingX, VertBearingY int // vertical offset from the origin to the left edge, and from the origin to the top edge (downwards) in pixels
+	VertAdvance                int // vertical advance in pixels
+}
+
+type sbitLineMetrics struct {
+	Ascender              int8
+	Descender             int8
+	WidthMax              uint8
+	CaretSlopeNumerator   int8
+	CaretSlopeDenominator int8
+	CaretOffset           int8
+	MinOriginSB           int8
+	MinAdvanceSB          int8
+	MaxBeforeBL           int8
+	MinAfterBL            int8
+}
+
+func readSbitLineMetrics(r *parse.BinaryReader) sbitLineMetrics {
+	metrics := sbitLineMetrics{}
+	metrics.Ascender = r.ReadInt8()
+	metrics.Descender = r.ReadInt8()
+	metrics.WidthMax = r.ReadUint8()
+	metrics.CaretSlopeNumerator = r.ReadInt8()
+	metrics.CaretSlopeDenominator = r.ReadInt8()
+	metrics.CaretOffset = r.ReadInt8()
+	metrics.MinOriginSB = r.ReadInt8()
+	metrics.MinAdvanceSB = r.ReadInt8()
+	metrics.MaxBeforeBL = r.ReadInt8()
+	metrics.MinAfterBL = r.ReadInt8()
+	_ = r.ReadUint16() // pad1 and pad2
+	return metrics
+}
+
+type bitmapGlyphMetrics struct {
+	Height       uint8
+	Width        uint8
+	HoriBearingX int8
+	HoriBearingY int8
+	HoriAdvance  uint8
+	VertBearingX int8
+	VertBearingY int8
+	VertAdvance  uint8
+}
+
+func readBigGlyphMetrics(r *parse.BinaryReader) bitmapGlyphMetrics {
+	metrics := bitmapGlyphMetrics{}
+	metrics.Height = r.ReadUint8()
+	metrics.Width = r.ReadUint8()
+	metrics.HoriBearingX = r.ReadInt8()
+	metrics.HoriBearingY = r.ReadInt8()
+	metrics.HoriAdvance = r.ReadUint8()
+	metrics.VertBearingX = r.ReadInt8()
+	metrics.VertBearingY = r.ReadInt8()
+	metrics.VertAdvance = r.ReadUint8()
+	return metrics
+}
+
+// readSmallGlyphMetrics reads small glyph metrics, which are used for both the horizontal and vertical metrics
+func readSmallGlyphMetrics(r *parse.BinaryReader) bitmapGlyphMetrics {
+	metrics := bitmapGlyphMetrics{}
+	metrics.Height = r.ReadUint8()
+	metrics.Width = r.ReadUint8()
+	metrics.HoriBearingX = r.ReadInt8()
+	metrics.HoriBearingY = r.ReadInt8()
+	metrics.HoriAdvance = r.ReadUint8()
+	metrics.VertBearingX = metrics.HoriBearingX
+	metrics.VertBearingY = metrics.HoriBearingY
+	metrics.VertAdvance = metrics.HoriAdvance
+	return metrics
+}
+
+////////////////////////////////////////////////////////////////
+
+type eblcIndexSubtable struct {
+	FirstGlyphIndex uint16
+	LastGlyphIndex  uint16
+	IndexFormat     uint16
+	ImageFormat     uint16
+	ImageDataOffset uint32
+
+	Offsets   []uint32           // formats 1, 3, and 4, relative to ImageDataOffset
+	GlyphIDs  []uint16           // formats 4 and 5
+	ImageSize uint32             // formats 2 and 5
+	Metrics   bitmapGlyphMetrics // formats 2 and 5
+}
+
+// Get returns the offset and length of the glyph's image data in the bitmap data table.
+func (subtable *eblcIndexSubtable) Get(glyphID uint16) (uint32, uint32, bool) {
+	if glyphID < subtable.FirstGlyphIndex || subtable.LastGlyphIndex < glyphID {
+		return 0, 0, false
+	}
+	i := uint32(glyphID - subtable.FirstGlyphIndex)
+	switch subtable.IndexFormat {
+	case 1, 3:
+		if subtable.Offsets[i+1] <= subtable.Offsets[i] {
+			return 0, 0, false // missing glyph
+		}
+		return subtable.ImageDataOffset + subtable.Offsets[i], subtable.Offsets[i+1] - subtable.Offsets[i], true
+	case 2:
+		return subtable.ImageDataOffset + i*subtable.ImageSize, subtable.ImageSize, true
+	case 4, 5:
+		j := sort.Search(len(subtable.GlyphIDs), func(j int) bool { return glyphID <= subtable.GlyphIDs[j] })
+		if j == len(subtable.GlyphIDs) || subtable.GlyphIDs[j] != glyphID {
+			return 0, 0, false
+		} else if subtable.IndexFormat == 5 {
+			return subtable.ImageDataOffset + uint32(j)*subtable.ImageSize, subtable.ImageSize, true
+		} else if subtable.Offsets[j+1] <= subtable.Offsets[j] {
+			return 0, 0, false
+		}
+		return subtable.ImageDataOffset + subtable.Offsets[j], subtable.Offsets[j+1] - subtable.Offsets[j], true
+	}
+	return 0, 0, false
+}
+
+type eblcStrike struct {
+	Hori, Vert      sbitLineMetrics
+	StartGlyphIndex uint16
+	EndGlyphIndex   uint16
+	PpemX, PpemY    uint8
+	BitDepth        uint8
+	Flags           [8]bool
+	IndexSubtables  []eblcIndexSubtable
+}
+
+// Get returns the index subtable, and the offset and length of the glyph's image data in the bitmap data table.
+func (strike *eblcStrike) Get(glyphID uint16) (*eblcIndexSubtable, uint32, uint32, bool) {
+	if glyphID < strike.StartGlyphIndex || strike.EndGlyphIndex < glyphID {
+		return nil, 0, 0, false
+	}
+	for i := range strike.IndexSubtables {
+		if offset, length, ok := strike.IndexSubtables[i].Get(glyphID); ok {
+			return &strike.IndexSubtables[i], offset, length, true
+		}
+	}
+	return nil, 0, 0, false
+}
+
+type eblcTable struct {
+	Strikes []eblcStrike
+}
+
+func (sfnt *SFNT) parseEBLC() error {
+	b, ok := sfnt.Tables["EBLC"]
+	if !ok {
+		return fmt.Errorf("EBLC: missing table")
+	}
+
+	eblc, err := parseBitmapLocationTable("EBLC", b, 2)
+	if err != nil {
+		return err
+	}
+	sfnt.Eblc = eblc
+	return nil
+}
+
+// parseBitmapLocationTable parses the EBLC table, or the CBLC table which shares the same structure.
+func parseBitmapLocationTable(tag string, b []byte, version uint16) (*eblcTable, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("%s: bad table", tag)
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	majorVersion := r.ReadUint16()
+	minorVersion := r.ReadUint16()
+	if majorVersion != version || minorVersion != 0 {
+		return nil, fmt.Errorf("%s: bad version", tag)
+	}
+	numSizes := r.ReadUint32()
+	if uint32(r.Len())/48 < numSizes {
+		return nil, fmt.Errorf("%s: bad table", tag)
+	}
+
+	eblc := &eblcTable{}
+	eblc.Strikes = make([]eblcStrike, numSizes)
+	for i := range eblc.Strikes {
+		strike := &eblc.Strikes[i]
+		indexSubtableArrayOffset := r.ReadUint32()
+		_ = r.ReadUint32() // indexTablesSize
+		numIndexSubtables := r.ReadUint32()
+		_ = r.ReadUint32() // colorRef
+		strike.Hori = readSbitLineMetrics(r)
+		strike.Vert = readSbitLineMetrics(r)
+		strike.StartGlyphIndex = r.ReadUint16()
+		strike.EndGlyphIndex = r.ReadUint16()
+		strike.PpemX = r.ReadUint8()
+		strike.PpemY = r.ReadUint8()
+		strike.BitDepth = r.ReadUint8()
+		strike.Flags = Uint8ToFlags(r.ReadUint8())
+		if strike.BitDepth != 1 && strike.BitDepth != 2 && strike.BitDepth != 4 && strike.BitDepth != 8 && (strike.BitDepth != 32 || tag != "CBLC") {
+			return nil, fmt.Errorf("%s: bad bit depth for strike %d", tag, i)
+		} else if strike.EndGlyphIndex < strike.StartGlyphIndex {
+			return nil, fmt.Errorf("%s: bad glyph range for strike %d", tag, i)
+		} else if uint32(len(b)) < indexSubtableArrayOffset || (uint32(len(b))-indexSubtableArrayOffset)/8 < numIndexSubtables {
+			return nil, fmt.Errorf("%s: bad index subtable array for strike %d", tag, i)
+		}
+
+		strike.IndexSubtables = make([]eblcIndexSubtable, numIndexSubtables)
+		ra := parse.NewBinaryReaderBytes(b[indexSubtableArrayOffset:])
+		for j := range strike.IndexSubtables {
+			subtable := &strike.IndexSubtables[j]
+			subtable.FirstGlyphIndex = ra.ReadUint16()
+			subtable.LastGlyphIndex = ra.ReadUint16()
+			additionalOffset := ra.ReadUint32()
+			if subtable.LastGlyphIndex < subtable.FirstGlyphIndex {
+				return nil, fmt.Errorf("%s: bad glyph range for index subtable %d of strike %d", tag, j, i)
+			} else if uint32(len(b))-indexSubtableArrayOffset < additionalOffset {
+				return nil, fmt.Errorf("%s: bad offset for index subtable %d of strike %d", tag, j, i)
+			}
+			if err := subtable.parse(b[indexSubtableArrayOffset+additionalOffset:]); err != nil {
+				return nil, fmt.Errorf("%s: %v for index subtable %d of strike %d", tag, err, j, i)
+			}
+		}
+	}
+	return eblc, nil
+}
+
+func (subtable *eblcIndexSubtable) parse(b []byte) error {
+	if len(b) < 8 {
+		return fmt.Errorf("bad index subtable")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	subtable.IndexFormat = r.ReadUint16()
+	subtable.ImageFormat = r.ReadUint16()
+	subtable.ImageDataOffset = r.ReadUint32()
+
+	n := uint32(subtable.LastGlyphIndex-subtable.FirstGlyphIndex) + 1
+	switch subtable.IndexFormat {
+	case 1, 3:
+		size := uint32(4)
+		if subtable.IndexFormat == 3 {
+			size = 2
+		}
+		if uint32(r.Len())/size < n+1 {
+			return fmt.Errorf("bad index subtable")
+		}
+		subtable.Offsets = make([]uint32, n+1)
+		for i := range subtable.Offsets {
+			if size == 4 {
+				subtable.Offsets[i] = r.ReadUint32()
+			} else {
+				subtable.Offsets[i] = uint32(r.ReadUint16())
+			}
+		}
+	case 2:
+		if r.Len() < 12 {
+			return fmt.Errorf("bad index subtable")
+		}
+		subtable.ImageSize = r.ReadUint32()
+		subtable.Metrics = readBigGlyphMetrics(r)
+	case 4:
+		if r.Len() < 4 {
+			return fmt.Errorf("bad index subtable")
+		}
+		numGlyphs := r.ReadUint32()
+		if n < numGlyphs || uint32(r.Len())/4 < numGlyphs+1 {
+			return fmt.Errorf("bad index subtable")
+		}
+		subtable.GlyphIDs = make([]uint16, numGlyphs)
+		subtable.Offsets = make([]uint32, numGlyphs+1)
+		for i := range subtable.Offsets {
+			glyphID := r.ReadUint16()
+			subtable.Offsets[i] = uint32(r.ReadUint16())
+			if i < int(numGlyphs) {
+				subtable.GlyphIDs[i] = glyphID
+				if 0 < i && glyphID <= subtable.GlyphIDs[i-1] {
+					return fmt.Errorf("glyph IDs must be sorted in index subtable")
+				}
+			}
+		}
+	case 5:
+		if r.Len() < 16 {
+			return fmt.Errorf("bad index subtable")
+		}
+		subtable.ImageSize = r.ReadUint32()
+		subtable.Metrics = readBigGlyphMetrics(r)
+		numGlyphs := r.ReadUint32()
+		if n < numGlyphs || uint32(r.Len())/2 < numGlyphs {
+			return fmt.Errorf("bad index subtable")
+		}
+		subtable.GlyphIDs = make([]uint16, numGlyphs)
+		for i := range subtable.GlyphIDs {
+			subtable.GlyphIDs[i] = r.ReadUint16()
+			if 0 < i && subtable.GlyphIDs[i] <= subtable.GlyphIDs[i-1] {
+				return fmt.Errorf("glyph IDs must be sorted in index subtable")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported index format %d", subtable.IndexFormat)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type ebdtComponent struct {
+	GlyphID uint16
+	XOffset int8
+	YOffset int8
+}
+
+type ebdtGlyph struct {
+	Metrics    bitmapGlyphMetrics
+	BitAligned bool   // image data is bit-aligned instead of byte-aligned for each row
+	PNG        bool   // image data is a PNG image (CBDT only)
+	Data       []byte // image data
+	Components []ebdtComponent
+}
+
+type ebdtTable struct {
+	tag  string
+	data []byte
+}
+
+func (sfnt *SFNT) parseEBDT() error {
+	b, ok := sfnt.Tables["EBDT"]
+	if !ok {
+		return fmt.Errorf("EBDT: missing table")
+	}
+
+	ebdt, err := parseBitmapDataTable("EBDT", b, 2)
+	if err != nil {
+		return err
+	}
+	sfnt.Ebdt = ebdt
+	return nil
+}
+
+// parseBitmapDataTable parses the EBDT table, or the CBDT table which shares the same structure.
+func parseBitmapDataTable(tag string, b []byte, version uint16) (*ebdtTable, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("%s: bad table", tag)
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	majorVersion := r.ReadUint16()
+	minorVersion := r.ReadUint16()
+	if majorVersion != version || minorVersion != 0 {
+		return nil, fmt.Errorf("%s: bad version", tag)
+	}
+	return &ebdtTable{
+		tag:  tag,
+		data: b,
+	}, nil
+}
+
+// Get returns the glyph's image data and metrics for the index subtable and the data's offset and length as returned by the EBLC table.
+func (ebdt *ebdtTable) Get(subtable *eblcIndexSubtable, offset, length uint32) (*ebdtGlyph, error) {
+	if uint32(len(ebdt.data)) < offset || uint32(len(ebdt.data))-offset < length {
+		return nil, fmt.Errorf("%s: bad glyph offset", ebdt.tag)
+	}
+
+	glyph := &ebdtGlyph{}
+	r := parse.NewBinaryReaderBytes(ebdt.data[offset : offset+length])
+	switch subtable.ImageFormat {
+	case 1, 2, 8, 17:
+		if r.Len() < 5 {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		glyph.Metrics = readSmallGlyphMetrics(r)
+	case 5, 19:
+		if subtable.IndexFormat != 2 && subtable.IndexFormat != 5 {
+			return nil, fmt.Errorf("%s: image format %d requires metrics in the location table", ebdt.tag, subtable.ImageFormat)
+		}
+		glyph.Metrics = subtable.Metrics
+	case 6, 7, 9, 18:
+		if r.Len() < 8 {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		glyph.Metrics = readBigGlyphMetrics(r)
+	default:
+		return nil, fmt.Errorf("%s: unsupported image format %d", ebdt.tag, subtable.ImageFormat)
+	}
+
+	switch subtable.ImageFormat {
+	case 1, 6:
+		glyph.Data = r.ReadBytes(r.Len())
+	case 2, 5, 7:
+		glyph.BitAligned = true
+		glyph.Data = r.ReadBytes(r.Len())
+	case 17, 18, 19:
+		if r.Len() < 4 {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		dataLen := r.ReadUint32()
+		if uint32(r.Len()) < dataLen {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		glyph.PNG = true
+		glyph.Data = r.ReadBytes(int64(dataLen))
+	case 8, 9:
+		if subtable.ImageFormat == 8 {
+			_ = r.ReadUint8() // pad
+		}
+		if r.Len() < 2 {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		numComponents := r.ReadUint16()
+		if r.Len()/4 < int64(numComponents) {
+			return nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+		}
+		glyph.Components = make([]ebdtComponent, numComponents)
+		for i := range glyph.Components {
+			glyph.Components[i].GlyphID = r.ReadUint16()
+			glyph.Components[i].XOffset = r.ReadInt8()
+			glyph.Components[i].YOffset = r.ReadInt8()
+		}
+	}
+	return glyph, nil
+}
+
+// draw draws the glyph's coverage onto dst with the glyph's top-left corner at (x,y).
+func (ebdt *ebdtTable) draw(dst *image.Alpha, strike *eblcStrike, glyphID uint16, x, y int, depth int) (*ebdtGlyph, error) {
+	if maxBitmapComponentDepth < depth {
+		return nil, fmt.Errorf("%s: too many nested component glyphs", ebdt.tag)
+	}
+
+	subtable, offset, length, ok := strike.Get(glyphID)
+	if !ok {
+		if depth == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: missing component glyph %d", ebdt.tag, glyphID)
+	}
+	glyph, err := ebdt.Get(subtable, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if glyph.Components != nil {
+		for _, comp := range glyph.Components {
+			if _, err := ebdt.draw(dst, strike, comp.GlyphID, x+int(comp.XOffset), y+int(comp.YOffset), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return glyph, nil
+	}
+
+	bitDepth := uint(strike.BitDepth)
+	if glyph.PNG {
+		return nil, fmt.Errorf("%s: unsupported PNG image for glyph %d", ebdt.tag, glyphID)
+	} else if 8 < bitDepth {
+		return nil, fmt.Errorf("%s: unsupported bit depth %d", ebdt.tag, bitDepth)
+	}
+	width, height := uint(glyph.Metrics.Width), uint(glyph.Metrics.Height)
+	stride := (width*bitDepth + 7) / 8 * 8 // in bits
+	if glyph.BitAligned {
+		stride = width * bitDepth
+	}
+	if uint(len(glyph.Data))*8 < stride*height {
+		return nil, fmt.Errorf("%s: bad image data for glyph %d", ebdt.tag, glyphID)
+	}
+
+	maxValue := uint(1)<<bitDepth - 1
+	bounds := dst.Bounds()
+	for j := uint(0); j < height; j++ {
+		for i := uint(0); i < width; i++ {
+			px, py := x+int(i), y+int(j)
+			if !(image.Point{px, py}).In(bounds) {
+				continue
+			}
+
+			var v uint
+			pos := j*stride + i*bitDepth
+			for k := uint(0); k < bitDepth; k++ {
+				v = v<<1 | uint(glyph.Data[(pos+k)/8]>>(7-(pos+k)%8)&1)
+			}
+			if alpha := uint8(v * 255 / maxValue); dst.Pix[dst.PixOffset(px, py)] < alpha {
+				dst.Pix[dst.PixOffset(px, py)] = alpha
+			}
+		}
+	}
+	return glyph, nil
+}
+
+// Dependencies returns the glyph IDs of the components of the glyph in all strikes.
+func (ebdt *ebdtTable) Dependencies(eblc *eblcTable, glyphID uint16) ([]uint16, error) {
+	deps := []uint16{}
+	for i := range eblc.Strikes {
+		subtable, offset, length, ok := eblc.Strikes[i].Get(glyphID)
+		if !ok || subtable.ImageFormat != 8 && subtable.ImageFormat != 9 {
+			continue
+		}
+		glyph, err := ebdt.Get(subtable, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		for _, comp := range glyph.Components {
+			deps = append(deps, comp.GlyphID)
+		}
+	}
+	return deps, nil
+}
+
+// subset rewrites the bitmap location and data tables (EBLC and EBDT, or CBLC and CBDT) for the given glyph IDs, which become the new glyph IDs in order. Glyphs with metrics in the location table are converted to image formats with big metrics.
+func (ebdt *ebdtTable) subset(eblc *eblcTable, version uint16, glyphIDs []uint16, glyphMap map[uint16]uint16) ([]byte, []byte, error) {
+	type indexSubtable struct {
+		first, last uint16
+		imageFormat uint16
+		offsets     []uint32 // from the start of the data table
+	}
+	type strike struct {
+		*eblcStrike
+		subtables []indexSubtable
+	}
+
+	wData := parse.NewBinaryWriter([]byte{})
+	wData.WriteUint16(version) // majorVersion
+	wData.WriteUint16(0)       // minorVersion
+
+	strikes := []strike{}
+	for i := range eblc.Strikes {
+		subtables := []indexSubtable{}
+		for subsetGlyphID, glyphID := range glyphIDs {
+			subtable, offset, length, ok := eblc.Strikes[i].Get(glyphID)
+			if !ok {
+				continue
+			} else if uint32(len(ebdt.data)) < offset || uint32(len(ebdt.data))-offset < length {
+				return nil, nil, fmt.Errorf("%s: bad glyph offset", ebdt.tag)
+			}
+
+			start := uint32(wData.Len())
+			data := ebdt.data[offset : offset+length]
+			imageFormat := subtable.ImageFormat
+			switch imageFormat {
+			case 5, 19:
+				// metrics are in the location table
+				metrics := subtable.Metrics
+				wData.WriteUint8(metrics.Height)
+				wData.WriteUint8(metrics.Width)
+				wData.WriteInt8(metrics.HoriBearingX)
+				wData.WriteInt8(metrics.HoriBearingY)
+				wData.WriteUint8(metrics.HoriAdvance)
+				wData.WriteInt8(metrics.VertBearingX)
+				wData.WriteInt8(metrics.VertBearingY)
+				wData.WriteUint8(metrics.VertAdvance)
+				wData.WriteBytes(data)
+				if imageFormat == 5 {
+					imageFormat = 7
+				} else {
+					imageFormat = 18
+				}
+			case 8, 9:
+				// composite glyphs, update glyph IDs of the components
+				pos := uint32(8)
+				if imageFormat == 9 {
+					pos = 10
+				}
+				if length < pos {
+					return nil, nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+				}
+				wData.WriteBytes(data)
+				numComponents := uint32(binary.BigEndian.Uint16(data[pos-2:]))
+				if (length-pos)/4 < numComponents {
+					return nil, nil, fmt.Errorf("%s: bad glyph", ebdt.tag)
+				}
+				for j := uint32(0); j < numComponents; j++ {
+					compGlyphID := binary.BigEndian.Uint16(data[pos+4*j:])
+					binary.BigEndian.PutUint16(wData.Bytes()[start+pos+4*j:], glyphMap[compGlyphID])
+				}
+			default:
+				wData.WriteBytes(data)
+			}
+
+			n := len(subtables)
+			if 0 < n && subtables[n-1].last+1 == uint16(subsetGlyphID) && subtables[n-1].imageFormat == imageFormat {
+				subtables[n-1].last++
+			} else {
+				subtables = append(subtables, indexSubtable{
+					first:       uint16(subsetGlyphID),
+					last:        uint16(subsetGlyphID),
+					imageFormat: imageFormat,
+					offsets:     []uint32{start},
+				})
+				n++
+			}
+			subtables[n-1].offsets = append(subtables[n-1].offsets, uint32(wData.Len()))
+		}
+		if 0 < len(subtables) {
+			sort.Slice(subtables, func(i, j int) bool { return subtables[i].first < subtables[j].first })
+			strikes = append(strikes, strike{&eblc.Strikes[i], subtables})
+		}
+	}
+	if len(strikes) == 0 {
+		return nil, nil, nil
+	}
+
+	w := parse.NewBinaryWriter([]byte{})
+	w.WriteUint16(version) // majorVersion
+	w.WriteUint16(0)       // minorVersion
+	w.WriteUint32(uint32(len(strikes)))
+	offset := 8 + 48*uint32(len(strikes))
+	for _, strike := range strikes {
+		indexTablesSize := uint32(0)
+		for _, subtable := range strike.subtables {
+			indexTablesSize += 8 + 8 + 4*uint32(len(subtable.offsets))
+		}
+		w.WriteUint32(offset) // indexSubTableArrayOffset
+		w.WriteUint32(indexTablesSize)
+		w.WriteUint32(uint32(len(strike.subtables)))
+		w.WriteUint32(0) // colorRef
+		for _, metrics := range []sbitLineMetrics{strike.Hori, strike.Vert} {
+			w.WriteInt8(metrics.Ascender)
+			w.WriteInt8(metrics.Descender)
+			w.WriteUint8(metrics.WidthMax)
+			w.WriteInt8(metrics.CaretSlopeNumerator)
+			w.WriteInt8(metrics.CaretSlopeDenominator)
+			w.WriteInt8(metrics.CaretOffset)
+			w.WriteInt8(metrics.MinOriginSB)
+			w.WriteInt8(metrics.MinAdvanceSB)
+			w.WriteInt8(metrics.MaxBeforeBL)
+			w.WriteInt8(metrics.MinAfterBL)
+			w.WriteUint16(0) // pad1 and pad2
+		}
+		w.WriteUint16(strike.subtables[0].first)                      // startGlyphIndex
+		w.WriteUint16(strike.subtables[len(strike.subtables)-1].last) // endGlyphIndex
+		w.WriteUint8(strike.PpemX)
+		w.WriteUint8(strike.PpemY)
+		w.WriteUint8(strike.BitDepth)
+		w.WriteUint8(flagsToUint8(strike.Flags))
+		offset += indexTablesSize
+	}
+	for _, strike := range strikes {
+		// index subtable array
+		additionalOffset := 8 * uint32(len(strike.subtables))
+		for _, subtable := range strike.subtables {
+			w.WriteUint16(subtable.first)
+			w.WriteUint16(subtable.last)
+			w.WriteUint32(additionalOffset)
+			additionalOffset += 8 + 4*uint32(len(subtable.offsets))
+		}
+
+		// index subtables of format 1
+		for _, subtable := range strike.subtables {
+			w.WriteUint16(1) // indexFormat
+			w.WriteUint16(subtable.imageFormat)
+			w.WriteUint32(subtable.offsets[0]) // imageDataOffset
+			for _, offset := range subtable.offsets {
+				w.WriteUint32(offset - subtable.offsets[0])
+			}
+		}
+	}
+	return w.Bytes(), wData.Bytes(), nil
+}
+
+func (sfnt *SFNT) parseCBLC() error {
+	b, ok := sfnt.Tables["CBLC"]
+	if !ok {
+		return fmt.Errorf("CBLC: missing table")
+	}
+
+	cblc, err := parseBitmapLocationTable("CBLC", b, 3)
+	if err != nil {
+		return err
+	}
+	sfnt.Cblc = cblc
+	return nil
+}
+
+func (sfnt *SFNT) parseCBDT() error {
+	b, ok := sfnt.Tables["CBDT"]
+	if !ok {
+		return fmt.Errorf("CBDT: missing table")
+	}
+
+	cbdt, err := parseBitmapDataTable("CBDT", b, 3)
+	if err != nil {
+		return err
+	}
+	sfnt.Cbdt = cbdt
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type ebscScale struct {
+	Hori, Vert      sbitLineMetrics
+	PpemX, PpemY    uint8
+	SubstitutePpemX uint8
+	SubstitutePpemY uint8
+}
+
+type ebscTable struct {
+	Scales []ebscScale
+}
+
+func (sfnt *SFNT) parseEBSC() error {
+	b, ok := sfnt.Tables["EBSC"]
+	if !ok {
+		return fmt.Errorf("EBSC: missing table")
+	} else if len(b) < 8 {
+		return fmt.Errorf("EBSC: bad table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	majorVersion := r.ReadUint16()
+	minorVersion := r.ReadUint16()
+	if majorVersion != 2 || minorVersion != 0 {
+		return fmt.Errorf("EBSC: bad version")
+	}
+	numSizes := r.ReadUint32()
+	if uint32(r.Len())/28 < numSizes {
+		return fmt.Errorf("EBSC: bad table")
+	}
+
+	sfnt.Ebsc = &ebscTable{}
+	sfnt.Ebsc.Scales = make([]ebscScale, numSizes)
+	for i := range sfnt.Ebsc.Scales {
+		scale := &sfnt.Ebsc.Scales[i]
+		scale.Hori = readSbitLineMetrics(r)
+		scale.Vert = readSbitLineMetrics(r)
+		scale.PpemX = r.ReadUint8()
+		scale.PpemY = r.ReadUint8()
+		scale.SubstitutePpemX = r.ReadUint8()
+		scale.SubstitutePpemY = r.ReadUint8()
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// bitmapStrikes returns the strikes for the given ppem, and the scale factor to apply when the strikes are substituted as specified by the EBSC table.
+func (sfnt *SFNT) bitmapStrikes(ppem uint16) ([]*eblcStrike, float64) {
+	if sfnt.Eblc == nil || sfnt.Ebdt == nil || ppem == 0 {
+		return nil, 0.0
+	}
+
+	strikes := []*eblcStrike{}
+	for i, strike := range sfnt.Eblc.Strikes {
+		if uint16(strike.PpemY) == ppem {
+			strikes = append(strikes, &sfnt.Eblc.Strikes[i])
+		}
+	}
+	if 0 < len(strikes) || sfnt.Ebsc == nil {
+		return strikes, 1.0
+	}
+
+	for _, scale := range sfnt.Ebsc.Scales {
+		if uint16(scale.PpemY) == ppem && scale.SubstitutePpemY != 0 {
+			for i, strike := range sfnt.Eblc.Strikes {
+				if strike.PpemX == scale.SubstitutePpemX && strike.PpemY == scale.SubstitutePpemY {
+					strikes = append(strikes, &sfnt.Eblc.Strikes[i])
+				}
+			}
+			return strikes, float64(ppem) / float64(scale.SubstitutePpemY)
+		}
+	}
+	return nil, 0.0
+}
+
+// HasGlyphBitmap returns true if an embedded bitmap exists for the glyph at the given ppem (pixels-per-EM). Callers can use this to prefer GlyphBitmap over GlyphPath at small sizes.
+func (sfnt *SFNT) HasGlyphBitmap(glyphID, ppem uint16) bool {
+	strikes, _ := sfnt.bitmapStrikes(ppem)
+	for _, strike := range strikes {
+		if _, _, _, ok := strike.Get(glyphID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GlyphBitmap returns the embedded bitmap of the glyph at the given ppem (pixels-per-EM) from the EBLC and EBDT tables. Strikes of a different size are scaled when specified by the EBSC table. It returns nil when no bitmap exists for the glyph at that size.
+func (sfnt *SFNT) GlyphBitmap(glyphID, ppem uint16) (*GlyphBitmap, error) {
+	strikes, scale := sfnt.bitmapStrikes(ppem)
+	for _, strike := range strikes {
+		if _, _, _, ok := strike.Get(glyphID); !ok {
+			continue
+		}
+
+		// first pass to obtain the metrics, second pass to draw the glyph
+		dst := image.NewAlpha(image.Rect(0, 0, 0, 0))
+		glyph, err := sfnt.Ebdt.draw(dst, strike, glyphID, 0, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		dst = image.NewAlpha(image.Rect(0, 0, int(glyph.Metrics.Width), int(glyph.Metrics.Height)))
+		if _, err := sfnt.Ebdt.draw(dst, strike, glyphID, 0, 0, 0); err != nil {
+			return nil, err
+		}
+
+		bitmap := &GlyphBitmap{
+			Alpha:        dst,
+			BearingX:     int(glyph.Metrics.HoriBearingX),
+			BearingY:     int(glyph.Metrics.HoriBearingY),
+			Advance:      int(glyph.Metrics.HoriAdvance),
+			VertBearingX: int(glyph.Metrics.VertBearingX),
+			VertBearingY: int(glyph.Metrics.VertBearingY),
+			VertAdvance:  int(glyph.Metrics.VertAdvance),
+		}
+		if scale != 1.0 {
+			bitmap.scale(scale)
+		}
+		return bitmap, nil
+	}
+	return nil, nil
+}
+
+// scale scales the bitmap and its metrics using nearest-neighbour sampling.
+func (bitmap *GlyphBitmap) scale(f float64) {
+	src := bitmap.Alpha
+	width := int(math.Round(float64(src.Rect.Dx()) * f))
+	height := int(math.Round(float64(src.Rect.Dy()) * f))
+	dst := image.NewAlpha(image.Rect(0, 0, width, height))
+	for j := 0; j < height; j++ {
+		sy := min(int(float64(j)/f), src.Rect.Dy()-1)
+		for i := 0; i < width; i++ {
+			sx := min(int(float64(i)/f), src.Rect.Dx()-1)
+			dst.Pix[dst.PixOffset(i, j)] = src.Pix[src.PixOffset(sx, sy)]
+		}
+	}
+	bitmap.Alpha = dst
+	bitmap.BearingX = int(math.Round(float64(bitmap.BearingX) * f))
+	bitmap.BearingY = int(math.Round(float64(bitmap.BearingY) * f))
+	bitmap.Advance = int(math.Round(float64(bitmap.Advance) * f))
+	bitmap.VertBearingX = int(math.Round(float64(bitmap.VertBearingX) * f))
+	bitmap.VertBearingY = int(math.Round(float64(bitmap.VertBearingY) * f))
+	bitmap.VertAdvance = int(math.Round(float64(bitmap.VertAdvance) * f))
+}
@@ -0,0 +1,449 @@
+package font
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// FontBuilder builds a font from glyph outlines, advance widths, Unicode mappings, and style metadata. Glyphs are added by AddGlyph, whose outline is drawn using the returned GlyphBuilder. The font is built with TrueType outlines by BuildTrueType or with CFF outlines by BuildCFF, which compute the head, hhea, hmtx, maxp, OS/2, post, name, and cmap tables.
+type FontBuilder struct {
+	Family     string  // Family name, eg. Icons.
+	Style      string  // Style name, eg. Regular, Bold Italic, or Light. Defaults to Regular.
+	Version    float64 // Font revision, defaults to 1.0.
+	UnitsPerEm uint16  // Number of font units per em, between 16 and 16384.
+
+	// The following are computed from the glyphs or the style when zero.
+	Ascender           int16     // Ascender above the baseline, defaults to the maximum y of the glyphs.
+	Descender          int16     // Descender below the baseline, negative, defaults to the minimum y of the glyphs.
+	LineGap            int16     // Gap between lines.
+	XHeight            int16     // Height of lowercase letters, defaults to the maximum y of x.
+	CapHeight          int16     // Height of uppercase letters, defaults to the maximum y of H.
+	Weight             uint16    // OS/2 weight class, defaults to the weight class of the style name, eg. 700 for Bold.
+	ItalicAngle        float64   // Italic angle in degrees counter-clockwise from the vertical, negative for fonts that lean to the right.
+	UnderlinePosition  int16     // Position of the top of the underline, defaults to -0.1 em.
+	UnderlineThickness int16     // Thickness of the underline, defaults to 0.05 em.
+	Timestamp          time.Time // Date of creation and modification, defaults to the current time.
+
+	Names  map[NameID]string // Additional name records, such as NameCopyrightNotice, NameDesigner, or NameLicense.
+	Glyphs []*GlyphBuilder   // The first glyph is the .notdef glyph.
+}
+
+// NewFontBuilder returns a builder for a font with the given family and style name, and units per em. It contains a .notdef glyph with an empty outline and an advance width of half an em.
+func NewFontBuilder(family, style string, unitsPerEm uint16) *FontBuilder {
+	b := &FontBuilder{
+		Family:     family,
+		Style:      style,
+		Version:    1.0,
+		UnitsPerEm: unitsPerEm,
+	}
+	b.AddGlyph(".notdef", unitsPerEm/2)
+	return b
+}
+
+// AddGlyph adds a glyph with the given PostScript glyph name, advance width, and Unicode code points. The outline is drawn using the returned GlyphBuilder. The glyph ID equals the number of previously added glyphs.
+func (b *FontBuilder) AddGlyph(name string, advance uint16, rs ...rune) *GlyphBuilder {
+	glyph := &GlyphBuilder{
+		Name:    name,
+		Advance: advance,
+		Unicode: rs,
+	}
+	b.Glyphs = append(b.Glyphs, glyph)
+	return glyph
+}
+
+// GlyphBuilder is a Pather that records the outline of a glyph in font units with the y-axis pointing up. Outer contours should run counter-clockwise and inner contours clockwise as in CFF, they are reversed for TrueType. Contours are closed implicitly.
+type GlyphBuilder struct {
+	Name    string // PostScript glyph name, replaced by a name derived from the Unicode mapping when empty, invalid, or not unique.
+	Advance uint16 // Advance width.
+	Unicode []rune // Unicode code points mapped to the glyph.
+
+	outline outlinePather
+}
+
+func (g *GlyphBuilder) MoveTo(x, y float64) {
+	g.outline.MoveTo(x, y)
+}
+
+func (g *GlyphBuilder) LineTo(x, y float64) {
+	g.outline.LineTo(x, y)
+}
+
+func (g *GlyphBuilder) QuadTo(cpx, cpy, x, y float64) {
+	g.outline.QuadTo(cpx, cpy, x, y)
+}
+
+func (g *GlyphBuilder) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	g.outline.CubeTo(cpx1, cpy1, cpx2, cpy2, x, y)
+}
+
+func (g *GlyphBuilder) Close() {
+	g.outline.Close()
+}
+
+// BuildTrueType returns a font with TrueType outlines, where cubic Béziers are approximated by quadratic Béziers within the tolerance of the options.
+func (b *FontBuilder) BuildTrueType(options ConvertOptions) (*SFNT, error) {
+	return b.build(false, options)
+}
+
+// BuildCFF returns a font with CFF outlines, where quadratic Béziers are raised to cubic Béziers and the charstrings are optionally subroutinized.
+func (b *FontBuilder) BuildCFF(options ConvertOptions) (*SFNT, error) {
+	return b.build(true, options)
+}
+
+func (b *FontBuilder) build(isCFF bool, options ConvertOptions) (*SFNT, error) {
+	if len(b.Glyphs) == 0 {
+		return nil, fmt.Errorf("missing .notdef glyph")
+	} else if math.MaxUint16 < len(b.Glyphs) {
+		return nil, fmt.Errorf("too many glyphs")
+	} else if b.UnitsPerEm < 16 || 16384 < b.UnitsPerEm {
+		return nil, fmt.Errorf("unitsPerEm must be between 16 and 16384")
+	} else if b.Family == "" {
+		return nil, fmt.Errorf("missing family name")
+	}
+	numGlyphs := uint16(len(b.Glyphs))
+	unitsPerEm := b.UnitsPerEm
+	em := float64(unitsPerEm)
+
+	// style
+	style := strings.Join(strings.Fields(b.Style), " ")
+	if style == "" {
+		style = "Regular"
+	}
+	isItalic := b.ItalicAngle != 0.0
+	weightName := []string{}
+	for _, word := range strings.Fields(style) {
+		if strings.EqualFold(word, "italic") || strings.EqualFold(word, "oblique") {
+			isItalic = true
+		} else {
+			weightName = append(weightName, word)
+		}
+	}
+	weightClass := b.Weight
+	if weightClass == 0 {
+		weightClass = os2WeightClass(strings.Join(weightName, ""))
+	}
+	isBold := 700 <= weightClass
+	subfamily := "Regular"
+	if isBold && isItalic {
+		subfamily = "Bold Italic"
+	} else if isBold {
+		subfamily = "Bold"
+	} else if isItalic {
+		subfamily = "Italic"
+	}
+
+	// cmap
+	runeMap := map[rune]uint16{}
+	advances := make([]uint16, numGlyphs)
+	for glyphID, glyph := range b.Glyphs {
+		advances[glyphID] = glyph.Advance
+		for _, r := range glyph.Unicode {
+			if r < 0 || unicode.MaxRune < r {
+				return nil, fmt.Errorf("glyph %d: invalid code point U+%04X", glyphID, r)
+			} else if glyphID2, ok := runeMap[r]; ok && glyphID2 != uint16(glyphID) {
+				return nil, fmt.Errorf("glyph %d: code point U+%04X already mapped to glyph %d", glyphID, r, glyphID2)
+			}
+			runeMap[r] = uint16(glyphID)
+		}
+	}
+	rs := make([]rune, 0, len(runeMap))
+	for r := range runeMap {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+	unicodes := func(glyphID uint16) []rune {
+		return b.Glyphs[glyphID].Unicode
+	}
+
+	names := make([]string, numGlyphs)
+	for glyphID, glyph := range b.Glyphs {
+		names[glyphID] = glyph.Name
+	}
+	names = uniqueGlyphNames(names, unicodes)
+
+	// glyph outlines
+	tables := map[string][]byte{}
+	bounds := make([][4]int16, numGlyphs)
+	hasOutline := make([]bool, numGlyphs)
+	var maxp *maxpTable
+	var indexToLocFormat int16
+	var charStrings [][]byte
+	defaultWidth := cffDefaultWidth(advances)
+	if isCFF {
+		charStrings = make([][]byte, numGlyphs)
+		for glyphID, glyph := range b.Glyphs {
+			w := newCFFCharStringWriter(int32(advances[glyphID]) - int32(defaultWidth))
+			w.hasWidth = advances[glyphID] != defaultWidth
+			for _, contour := range glyph.outline.contours {
+				if 0 < len(contour.ops) {
+					contour.draw(w)
+				}
+			}
+			charStrings[glyphID] = w.Bytes()
+			if w.XMin <= w.XMax {
+				if w.XMin < math.MinInt16 || math.MaxInt16 < w.XMax || w.YMin < math.MinInt16 || math.MaxInt16 < w.YMax {
+					return nil, fmt.Errorf("glyph %d: coordinates out of range", glyphID)
+				}
+				hasOutline[glyphID] = true
+				bounds[glyphID] = [4]int16{int16(w.XMin), int16(w.YMin), int16(w.XMax), int16(w.YMax)}
+			}
+		}
+		maxp = &maxpTable{
+			Version:   0x00005000,
+			NumGlyphs: numGlyphs,
+		}
+	} else {
+		tolerance := options.Tolerance
+		if tolerance <= 0.0 {
+			tolerance = 1.0
+		}
+
+		var maxPoints, maxContours uint16
+		glyfOffsets := make([]uint32, numGlyphs+1) // for loca
+		w := parse.NewBinaryWriter([]byte{})
+		for i, glyph := range b.Glyphs {
+			glyphID := uint16(i)
+			contours := make([]outlineContour, len(glyph.outline.contours))
+			for j, contour := range glyph.outline.contours {
+				contour.reverse()
+				contours[j] = contour
+			}
+
+			contour, err := newGlyfContour(glyphID, contours, tolerance)
+			if err != nil {
+				return nil, err
+			} else if 0 < len(contour.EndPoints) { // not empty
+				writeGlyfContour(w, contour)
+				hasOutline[glyphID] = true
+				bounds[glyphID] = [4]int16{contour.XMin, contour.YMin, contour.XMax, contour.YMax}
+				maxPoints = max(maxPoints, uint16(len(contour.XCoordinates)))
+				maxContours = max(maxContours, uint16(len(contour.EndPoints)))
+			}
+
+			// padding to ensure glyph offsets are on even bytes for loca short format
+			if w.Len()%2 == 1 {
+				w.WriteByte(0)
+			}
+			glyfOffsets[glyphID+1] = uint32(w.Len())
+		}
+		tables["glyf"] = w.Bytes()
+		tables["loca"], indexToLocFormat = locaWrite(glyfOffsets)
+		maxp = &maxpTable{
+			Version:     0x00010000,
+			NumGlyphs:   numGlyphs,
+			MaxPoints:   maxPoints,
+			MaxContours: maxContours,
+			MaxZones:    2,
+		}
+	}
+
+	// bounding box and metrics
+	lsbs := make([]int16, numGlyphs)
+	xMin, yMin, xMax, yMax := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16), int16(math.MinInt16)
+	var minLsb, minRsb, xMaxExtent int16 = math.MaxInt16, math.MaxInt16, math.MinInt16
+	var advanceWidthMax uint16
+	for glyphID := range b.Glyphs {
+		advanceWidthMax = max(advanceWidthMax, advances[glyphID])
+		if hasOutline[glyphID] {
+			gxMin, gyMin, gxMax, gyMax := bounds[glyphID][0], bounds[glyphID][1], bounds[glyphID][2], bounds[glyphID][3]
+			lsbs[glyphID] = gxMin
+			xMin, yMin = min(xMin, gxMin), min(yMin, gyMin)
+			xMax, yMax = max(xMax, gxMax), max(yMax, gyMax)
+			minLsb = min(minLsb, gxMin)
+			minRsb = min(minRsb, int16(int(advances[glyphID])-int(gxMax)))
+			xMaxExtent = max(xMaxExtent, gxMax)
+		}
+	}
+	if xMax < xMin {
+		xMin, yMin, xMax, yMax = 0, 0, 0, 0
+		minLsb, minRsb, xMaxExtent = 0, 0, 0
+	}
+
+	ascender, descender := b.Ascender, b.Descender
+	if ascender == 0 && descender == 0 {
+		ascender, descender = max(0, yMax), min(0, yMin)
+		if ascender == 0 && descender == 0 {
+			ascender, descender = int16(math.Round(0.8*em)), -int16(math.Round(0.2*em))
+		}
+	}
+	xHeight, capHeight := b.XHeight, b.CapHeight
+	if glyphID, ok := runeMap['x']; ok && xHeight == 0 && hasOutline[glyphID] {
+		xHeight = bounds[glyphID][3]
+	}
+	if glyphID, ok := runeMap['H']; ok && capHeight == 0 && hasOutline[glyphID] {
+		capHeight = bounds[glyphID][3]
+	}
+	underlinePosition, underlineThickness := b.UnderlinePosition, b.UnderlineThickness
+	if underlinePosition == 0 {
+		underlinePosition = -int16(math.Round(0.1 * em))
+	}
+	if underlineThickness == 0 {
+		underlineThickness = int16(math.Round(0.05 * em))
+	}
+
+	isFixedPitch := true
+	for _, advance := range advances {
+		if advance != 0 && advance != advanceWidthMax {
+			isFixedPitch = false
+			break
+		}
+	}
+
+	revision := b.Version
+	if revision <= 0.0 || 32768.0 <= revision {
+		revision = 1.0
+	}
+	version := fmt.Sprintf("%.3f", revision)
+
+	// name
+	familyName := b.Family
+	fullName := b.Family
+	if style != "Regular" {
+		fullName += " " + style
+	}
+	fontName := cffFontName(b.Family + "-" + strings.ReplaceAll(style, " ", ""))
+	nameRecords := map[NameID]string{}
+	if subfamily != style {
+		// keep the family name to a regular/bold/italic/bold italic style group
+		nameRecords[NamePreferredFamily] = familyName
+		nameRecords[NamePreferredSubfamily] = style
+		if rest := strings.Join(weightName, " "); rest != "" && !strings.EqualFold(rest, "Regular") && !strings.EqualFold(rest, "Bold") {
+			familyName += " " + rest
+		}
+	}
+	nameRecords[NameFontFamily] = familyName
+	nameRecords[NameFontSubfamily] = subfamily
+	nameRecords[NameUniqueIdentifier] = version + ";" + fontName
+	nameRecords[NameFull] = fullName
+	nameRecords[NameVersion] = "Version " + version
+	nameRecords[NamePostScript] = fontName
+	for id, value := range b.Names {
+		nameRecords[id] = value
+	}
+	name := &nameTable{}
+	for id, value := range nameRecords {
+		if value != "" {
+			name.Add(id, value)
+		}
+	}
+	nameData, err := name.Write()
+	if err != nil {
+		return nil, err
+	}
+
+	// head
+	timestamp := b.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	timestamp = timestamp.UTC()
+	head := &headTable{
+		FontRevision:      uint32(math.Round(revision * (1 << 16))),
+		UnitsPerEm:        unitsPerEm,
+		Created:           timestamp,
+		Modified:          timestamp,
+		XMin:              xMin,
+		YMin:              yMin,
+		XMax:              xMax,
+		YMax:              yMax,
+		LowestRecPPEM:     3,
+		FontDirectionHint: 2,
+		IndexToLocFormat:  indexToLocFormat,
+	}
+	head.Flags[0] = true // baseline at y=0
+	head.Flags[1] = true // left side bearing at x=0
+	head.Flags[3] = true // integer scaling
+	head.MacStyle[0] = isBold
+	head.MacStyle[1] = isItalic
+
+	// hhea and hmtx
+	hmtx := newHmtxTable(advances, lsbs)
+	hhea := &hheaTable{
+		Ascender:            ascender,
+		Descender:           descender,
+		LineGap:             b.LineGap,
+		AdvanceWidthMax:     advanceWidthMax,
+		MinLeftSideBearing:  minLsb,
+		MinRightSideBearing: minRsb,
+		XMaxExtent:          xMaxExtent,
+		CaretSlopeRise:      1,
+		NumberOfHMetrics:    uint16(len(hmtx.HMetrics)),
+	}
+	if b.ItalicAngle != 0.0 {
+		hhea.CaretSlopeRise = int16(unitsPerEm)
+		hhea.CaretSlopeRun = int16(math.Round(-math.Tan(b.ItalicAngle*math.Pi/180.0) * em))
+	}
+
+	// OS/2
+	os2 := newOS2Table(unitsPerEm, weightClass, isItalic, advances, rs)
+	os2.YStrikeoutSize = underlineThickness
+	os2.STypoAscender = ascender
+	os2.STypoDescender = descender
+	os2.STypoLineGap = b.LineGap
+	os2.UsWinAscent = uint16(max(0, yMax, ascender))
+	os2.UsWinDescent = uint16(max(0, -yMin, -descender))
+	os2.SxHeight = xHeight
+	os2.SCapHeight = capHeight
+	if 0 < xHeight {
+		os2.YStrikeoutPosition = xHeight / 2
+	}
+
+	// post, glyph names are stored in the CFF table for CFF fonts
+	post := &postTable{
+		ItalicAngle:        b.ItalicAngle,
+		UnderlinePosition:  underlinePosition,
+		UnderlineThickness: underlineThickness,
+	}
+	if isFixedPitch {
+		post.IsFixedPitch = 1
+	}
+	if !isCFF {
+		post.setGlyphNames(names)
+	}
+	postData, err := post.Write()
+	if err != nil {
+		return nil, fmt.Errorf("post: %w", err)
+	}
+
+	if isCFF {
+		top := &cffTopDICT{
+			Version:            version,
+			Notice:             b.Names[NameTrademark],
+			Copyright:          b.Names[NameCopyrightNotice],
+			FullName:           fullName,
+			FamilyName:         b.Family,
+			IsFixedPitch:       isFixedPitch,
+			ItalicAngle:        b.ItalicAngle,
+			UnderlinePosition:  float64(underlinePosition) - float64(underlineThickness)/2.0,
+			UnderlineThickness: float64(underlineThickness),
+			CharstringType:     2,
+			FontMatrix:         [6]float64{1.0 / em, 0.0, 0.0, 1.0 / em, 0.0, 0.0},
+			FontBBox:           [4]float64{float64(xMin), float64(yMin), float64(xMax), float64(yMax)},
+		}
+		cff := newCFFTable(fontName, top, names, charStrings, defaultWidth, options.Subroutinize)
+		if tables["CFF "], err = cff.Write(); err != nil {
+			return nil, fmt.Errorf("CFF: %w", err)
+		}
+	}
+
+	tables["cmap"] = cmapWrite(rs, runeMap)
+	tables["head"] = head.Write()
+	tables["hhea"] = hhea.Write()
+	tables["hmtx"] = hmtx.Write()
+	tables["maxp"] = maxp.Write()
+	tables["name"] = nameData
+	tables["OS/2"] = os2.Write()
+	tables["post"] = postData
+	sfnt := &SFNT{
+		IsCFF:      isCFF,
+		IsTrueType: !isCFF,
+		Tables:     tables,
+	}
+	return ParseSFNT(sfnt.Write(), 0)
+}
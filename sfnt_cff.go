@@ -3,6 +3,7 @@ package font
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"sort"
 	"strconv"
@@ -12,7 +13,6 @@ import (
 
 // TODO: add method to regenerate subrs optimally (useful after subset)
 // TODO: use FDSelect for Font DICTs
-// TODO: CFF has winding rule even-odd? CFF2 has winding rule nonzero
 
 var ErrBadNumOperands = fmt.Errorf("bad number of operands for operator")
 
@@ -482,8 +482,8 @@ func (cff *cffTable) parseCharString(glyphID uint16, cb func(*parse.BinaryReader
 				}
 				stack = stack[:0]
 			case cffHintmask, cffCntrmask:
-				if b0 == cffHintmask && 0 < len(stack) {
-					// vstem or vstemhm
+				if 0 < len(stack) {
+					// implicit vstem or vstemhm
 					if len(stack)%2 != 0 {
 						return fmt.Errorf("%v: %v", table, ErrBadNumOperands)
 					}
@@ -555,8 +555,33 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 	var x, y int32
 	f /= float64(1 << 16) // correct back
 
-	err := cff.parseCharString(glyphID, func(_ *parse.BinaryReader, b0 int32, stack []int32) error {
+	px := func(x int32) float64 { return x0 + f*float64(x) }
+	py := func(y int32) float64 { return y0 + f*float64(y) }
+	var hinter *cffHinter
+	if hinting != NoHinting && ppem != 0 {
+		hinter = newCFFHinter(cff.top, cff.fonts.GetPrivate(glyphID), ppem, hinting)
+		px = func(x int32) float64 { return x0 + f*hinter.X(x) }
+		py = func(y int32) float64 { return y0 + f*hinter.Y(y) }
+	}
+
+	err := cff.parseCharString(glyphID, func(r *parse.BinaryReader, b0 int32, stack []int32) error {
 		switch b0 {
+		case cffHstem, cffHstemhm, cffVstem, cffVstemhm:
+			if hinter != nil {
+				hinter.addStems(stack, b0 == cffVstem || b0 == cffVstemhm)
+			}
+		case cffHintmask, cffCntrmask:
+			if hinter != nil {
+				if 0 < len(stack) {
+					hinter.addStems(stack, true) // implicit vstem
+				}
+				if b0 == cffCntrmask {
+					break
+				}
+				if mask := r.Clone().ReadBytes(int64((hinter.numStems() + 7) / 8)); len(mask) == (hinter.numStems()+7)/8 {
+					hinter.setMask(mask)
+				}
+			}
 		case cffRmoveto:
 			if len(stack) != 2 {
 				return ErrBadNumOperands
@@ -564,21 +589,21 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			x += stack[0]
 			y += stack[1]
 			p.Close()
-			p.MoveTo(x0+f*float64(x), y0+f*float64(y))
+			p.MoveTo(px(x), py(y))
 		case cffHmoveto:
 			if len(stack) != 1 {
 				return ErrBadNumOperands
 			}
 			x += stack[0]
 			p.Close()
-			p.MoveTo(x0+f*float64(x), y0+f*float64(y))
+			p.MoveTo(px(x), py(y))
 		case cffVmoveto:
 			if len(stack) != 1 {
 				return ErrBadNumOperands
 			}
 			y += stack[0]
 			p.Close()
-			p.MoveTo(x0+f*float64(x), y0+f*float64(y))
+			p.MoveTo(px(x), py(y))
 		case cffRlineto:
 			if len(stack) == 0 || len(stack)%2 != 0 {
 				return ErrBadNumOperands
@@ -586,7 +611,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			for i := 0; i < len(stack); i += 2 {
 				x += stack[i+0]
 				y += stack[i+1]
-				p.LineTo(x0+f*float64(x), y0+f*float64(y))
+				p.LineTo(px(x), py(y))
 			}
 		case cffHlineto, cffVlineto:
 			if len(stack) == 0 {
@@ -599,7 +624,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				} else {
 					y += stack[i]
 				}
-				p.LineTo(x0+f*float64(x), y0+f*float64(y))
+				p.LineTo(px(x), py(y))
 				vertical = !vertical
 			}
 		case cffRrcurveto:
@@ -615,7 +640,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				cpx2, cpy2 := x, y
 				x += stack[i+4]
 				y += stack[i+5]
-				p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+				p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 			}
 		case cffHhcurveto, cffVvcurveto:
 			if len(stack) < 4 || len(stack)%4 != 0 && (len(stack)-1)%4 != 0 {
@@ -646,7 +671,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				} else {
 					y += stack[i+3]
 				}
-				p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+				p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 			}
 		case cffHvcurveto, cffVhcurveto:
 			if len(stack) < 4 || len(stack)%4 != 0 && (len(stack)-1)%4 != 0 {
@@ -676,7 +701,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 					}
 					i++
 				}
-				p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+				p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 				vertical = !vertical
 			}
 		case cffRcurveline:
@@ -693,11 +718,11 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				cpx2, cpy2 := x, y
 				x += stack[i+4]
 				y += stack[i+5]
-				p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+				p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 			}
 			x += stack[i+0]
 			y += stack[i+1]
-			p.LineTo(x0+f*float64(x), y0+f*float64(y))
+			p.LineTo(px(x), py(y))
 		case cffRlinecurve:
 			if len(stack) < 6 || (len(stack)-6)%2 != 0 {
 				return ErrBadNumOperands
@@ -706,7 +731,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			for ; i < len(stack)-6; i += 2 {
 				x += stack[i+0]
 				y += stack[i+1]
-				p.LineTo(x0+f*float64(x), y0+f*float64(y))
+				p.LineTo(px(x), py(y))
 			}
 			x += stack[i+0]
 			y += stack[i+1]
@@ -716,7 +741,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			cpx2, cpy2 := x, y
 			x += stack[i+4]
 			y += stack[i+5]
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 		case cffFlex:
 			if len(stack) != 13 {
 				return ErrBadNumOperands
@@ -731,7 +756,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				cpx2, cpy2 := x, y
 				x += stack[i+4]
 				y += stack[i+5]
-				p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+				p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 			}
 		case cffHflex:
 			// hflex
@@ -746,7 +771,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			y += stack[2]
 			cpx2, cpy2 := x, y
 			x += stack[3]
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 
 			x += stack[4]
 			cpx1, cpy1 = x, y
@@ -754,7 +779,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			y = y1
 			cpx2, cpy2 = x, y
 			x += stack[6]
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 		case cffHflex1:
 			if len(stack) != 9 {
 				return ErrBadNumOperands
@@ -768,7 +793,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			y += stack[3]
 			cpx2, cpy2 := x, y
 			x += stack[4]
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 
 			x += stack[5]
 			cpx1, cpy1 = x, y
@@ -777,7 +802,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			cpx2, cpy2 = x, y
 			x += stack[8]
 			y = y1
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 		case cffFlex1:
 			if len(stack) != 11 {
 				return ErrBadNumOperands
@@ -792,7 +817,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 			cpx2, cpy2 := x, y
 			x += stack[4]
 			y += stack[5]
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 
 			x += stack[6]
 			y += stack[7]
@@ -814,7 +839,7 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 				x = x1
 				y += stack[10]
 			}
-			p.CubeTo(x0+f*float64(cpx1), y0+f*float64(cpy1), x0+f*float64(cpx2), y0+f*float64(cpy2), x0+f*float64(x), y0+f*float64(y))
+			p.CubeTo(px(cpx1), py(cpy1), px(cpx2), py(cpy2), px(x), py(y))
 		}
 		return nil
 	})
@@ -826,6 +851,174 @@ func (cff *cffTable) ToPath(p Pather, glyphID, ppem uint16, x0, y0, f float64, h
 	return nil
 }
 
+// cffHintMask is a hint mask that is written before a path operation of a charstring.
+type cffHintMask struct {
+	Op   int    // index of the path operation
+	Mask []byte // bit for each stem, with the horizontal stems first
+}
+
+// cffCharStringWriter is a Pather that encodes an outline as a Type 2 charstring. Hints are only written by WriteStems. Coordinates are rounded to integers and quadratic Béziers are converted to cubic Béziers.
+type cffCharStringWriter struct {
+	w                      *parse.BinaryWriter
+	width                  int32 // written before the first operator
+	hasWidth               bool
+	masks                  []cffHintMask // hint masks that remain to be written
+	ops                    int           // number of path operations
+	x, y                   float64       // current point
+	ix, iy                 int32         // rounded current point
+	XMin, YMin, XMax, YMax int32         // bounding box of the (control) points
+}
+
+// newCFFCharStringWriter returns a charstring writer for a glyph with the given width relative to nominalWidthX.
+func newCFFCharStringWriter(width int32) *cffCharStringWriter {
+	return &cffCharStringWriter{
+		w:        parse.NewBinaryWriter([]byte{}),
+		width:    width,
+		hasWidth: true,
+		XMin:     math.MaxInt32,
+		YMin:     math.MaxInt32,
+		XMax:     math.MinInt32,
+		YMax:     math.MinInt32,
+	}
+}
+
+func (p *cffCharStringWriter) writeNumber(i int32) {
+	cffWriteCharStringNumber(p.w, i)
+}
+
+// cffWriteCharStringNumber writes an integer operand of a Type 2 charstring.
+func cffWriteCharStringNumber(w *parse.BinaryWriter, i int32) {
+	if -107 <= i && i <= 107 {
+		w.WriteUint8(uint8(i + 139))
+	} else if 108 <= i && i <= 1131 {
+		i -= 108
+		w.WriteUint8(uint8(i/256 + 247))
+		w.WriteUint8(uint8(i % 256))
+	} else if -1131 <= i && i <= -108 {
+		i = -i - 108
+		w.WriteUint8(uint8(i/256 + 251))
+		w.WriteUint8(uint8(i % 256))
+	} else if -32768 <= i && i <= 32767 {
+		w.WriteUint8(28)
+		w.WriteInt16(int16(i))
+	} else {
+		w.WriteUint8(255) // 16.16 fixed point
+		w.WriteInt32(i << 16)
+	}
+}
+
+func (p *cffCharStringWriter) writeWidth() {
+	if p.hasWidth {
+		p.writeNumber(p.width)
+		p.hasWidth = false
+	}
+}
+
+// point writes the difference of (x,y) with the current point and updates the current point.
+func (p *cffCharStringWriter) point(x, y float64) {
+	ix, iy := int32(math.Round(x)), int32(math.Round(y))
+	p.writeNumber(ix - p.ix)
+	p.writeNumber(iy - p.iy)
+	p.x, p.y = x, y
+	p.ix, p.iy = ix, iy
+	p.XMin = min(p.XMin, ix)
+	p.YMin = min(p.YMin, iy)
+	p.XMax = max(p.XMax, ix)
+	p.YMax = max(p.YMax, iy)
+}
+
+// WriteStems writes the horizontal and vertical stem hints as pairs of position and width, and the hint masks that are written before the path operations. Stems must be sorted by position, may only overlap when there are hint masks, and must be written before the outline.
+func (p *cffCharStringWriter) WriteStems(hstems, vstems [][2]int32, masks []cffHintMask) {
+	hstem, vstem := cffHstem, cffVstem
+	if masks != nil {
+		hstem, vstem = cffHstemhm, cffVstemhm
+	}
+	p.masks = masks
+	for i, stems := range [][][2]int32{hstems, vstems} {
+		if len(stems) == 0 {
+			continue
+		}
+		p.writeWidth()
+		pos := int32(0)
+		for _, stem := range stems {
+			p.writeNumber(stem[0] - pos)
+			p.writeNumber(stem[1])
+			pos = stem[0] + stem[1]
+		}
+		if i == 0 {
+			p.w.WriteUint8(uint8(hstem))
+		} else {
+			p.w.WriteUint8(uint8(vstem))
+		}
+	}
+}
+
+// writeHintMask writes the hint mask before the next path operation.
+func (p *cffCharStringWriter) writeHintMask() {
+	for 0 < len(p.masks) && p.masks[0].Op <= p.ops {
+		p.w.WriteUint8(uint8(cffHintmask))
+		p.w.WriteBytes(p.masks[0].Mask)
+		p.masks = p.masks[1:]
+	}
+	p.ops++
+}
+
+func (p *cffCharStringWriter) MoveTo(x, y float64) {
+	p.writeWidth()
+	p.writeHintMask()
+	p.point(x, y)
+	p.w.WriteUint8(uint8(cffRmoveto))
+}
+
+func (p *cffCharStringWriter) LineTo(x, y float64) {
+	p.writeHintMask()
+	if int32(math.Round(x)) == p.ix && int32(math.Round(y)) == p.iy {
+		return
+	}
+	p.point(x, y)
+	p.w.WriteUint8(uint8(cffRlineto))
+}
+
+func (p *cffCharStringWriter) QuadTo(cpx, cpy, x, y float64) {
+	cpx1, cpy1 := p.x+2.0/3.0*(cpx-p.x), p.y+2.0/3.0*(cpy-p.y)
+	cpx2, cpy2 := x+2.0/3.0*(cpx-x), y+2.0/3.0*(cpy-y)
+	p.CubeTo(cpx1, cpy1, cpx2, cpy2, x, y)
+}
+
+func (p *cffCharStringWriter) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	p.writeHintMask()
+	p.point(cpx1, cpy1)
+	p.point(cpx2, cpy2)
+	p.point(x, y)
+	p.w.WriteUint8(uint8(cffRrcurveto))
+}
+
+func (p *cffCharStringWriter) Close() {
+	// contours are closed implicitly
+}
+
+// Bytes ends the charstring and returns it.
+func (p *cffCharStringWriter) Bytes() []byte {
+	p.writeWidth()
+	p.w.WriteUint8(uint8(cffEndchar))
+	return p.w.Bytes()
+}
+
+// cffDefaultWidth returns the most common advance width, or the smallest of the most common advance widths. It is used for both defaultWidthX and nominalWidthX so that most charstrings can omit their width.
+func cffDefaultWidth(advances []uint16) uint16 {
+	widths := map[uint16]int{}
+	for _, advance := range advances {
+		widths[advance]++
+	}
+	defaultWidth, defaultWidthCount := uint16(0), 0
+	for width, count := range widths {
+		if defaultWidthCount < count || defaultWidthCount == count && width < defaultWidth {
+			defaultWidth, defaultWidthCount = width, count
+		}
+	}
+	return defaultWidth
+}
+
 func cffCharStringSubrsBias(n int) int {
 	bias := 32768
 	if n < 1240 {
@@ -847,6 +1040,210 @@ func cffNumberSize(i int) int {
 	return 5
 }
 
+// cffSubroutinize moves repeated sequences of operators and their operands in the charstrings to local subroutines. It returns the new charstrings and the subroutines, or the original charstrings without subroutines when they use operators other than path construction and stem hints. Widths, stem hints, the first moveto, and endchar are never moved, and subroutines do not call other subroutines.
+func cffSubroutinize(charStrings [][]byte) ([][]byte, [][]byte) {
+	const maxTokens = 16   // maximum number of tokens in a subroutine
+	const callSize = 2     // estimated size of a subroutine call
+	const subrOverhead = 3 // return operator and offset in the INDEX
+
+	// split charstrings into tokens, which are an operator and its operands, and find the range of tokens that can be moved
+	offsets := make([][]int, len(charStrings))
+	starts := make([]int, len(charStrings))
+	ends := make([]int, len(charStrings))
+	for i, charString := range charStrings {
+		offsets[i] = []int{0}
+		starts[i] = -1
+		for j := 0; j < len(charString); {
+			for j < len(charString) {
+				if b0 := charString[j]; b0 == 28 {
+					j += 3
+				} else if 32 <= b0 && b0 <= 246 {
+					j++
+				} else if 247 <= b0 && b0 <= 254 {
+					j += 2
+				} else if b0 == 255 {
+					j += 5
+				} else {
+					break
+				}
+			}
+			if len(charString) <= j {
+				return charStrings, nil // operands without operator
+			}
+
+			op := int32(charString[j])
+			j++
+			if op == cffEscape {
+				if len(charString) <= j {
+					return charStrings, nil
+				}
+				op = 256 + int32(charString[j])
+				j++
+			}
+			if op == cffCallsubr || op == cffCallgsubr || op == cffReturn || op == cffHintmask || op == cffCntrmask || 256 <= op && op < 256+34 {
+				// subroutines, hint masks, and arithmetic operators are not supported
+				return charStrings, nil
+			}
+			offsets[i] = append(offsets[i], j)
+			if starts[i] == -1 && (op == cffRmoveto || op == cffHmoveto || op == cffVmoveto) {
+				starts[i] = len(offsets[i]) - 1
+			}
+			ends[i] = len(offsets[i]) - 1
+			if op == cffEndchar {
+				ends[i]--
+			}
+		}
+		if starts[i] == -1 {
+			starts[i] = ends[i]
+		}
+	}
+
+	// count the occurrences of sequences of tokens by their hash
+	hashes := make([][]uint64, len(charStrings))
+	counts := map[uint64]int{}
+	for i, charString := range charStrings {
+		hashes[i] = make([]uint64, len(offsets[i])-1)
+		for j := range hashes[i] {
+			h := fnv.New64a()
+			h.Write(charString[offsets[i][j]:offsets[i][j+1]])
+			hashes[i][j] = h.Sum64()
+		}
+		for j := starts[i]; j < ends[i]; j++ {
+			h := uint64(14695981039346656037)
+			for n := 1; n <= maxTokens && j+n <= ends[i]; n++ {
+				h = (h ^ hashes[i][j+n-1]) * 1099511628211
+				counts[h]++
+			}
+		}
+	}
+
+	// collect the positions of sequences that are likely to reduce the size
+	type cffSubrCandidate struct {
+		data      []byte
+		tokens    int
+		positions [][2]int // charstring and token index
+		saving    int
+	}
+	candidates := map[string]*cffSubrCandidate{}
+	for i, charString := range charStrings {
+		for j := starts[i]; j < ends[i]; j++ {
+			h := uint64(14695981039346656037)
+			for n := 1; n <= maxTokens && j+n <= ends[i]; n++ {
+				h = (h ^ hashes[i][j+n-1]) * 1099511628211
+				size := offsets[i][j+n] - offsets[i][j]
+				if count := counts[h]; count < 2 || count*(size-callSize) <= size+subrOverhead {
+					continue
+				}
+
+				data := charString[offsets[i][j]:offsets[i][j+n]]
+				candidate, ok := candidates[string(data)]
+				if !ok {
+					candidate = &cffSubrCandidate{
+						data:   data,
+						tokens: n,
+					}
+					candidates[string(data)] = candidate
+				}
+				candidate.positions = append(candidate.positions, [2]int{i, j})
+			}
+		}
+	}
+	sorted := make([]*cffSubrCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		size := len(candidate.data)
+		candidate.saving = len(candidate.positions)*(size-callSize) - size - subrOverhead
+		if 0 < candidate.saving {
+			sorted = append(sorted, candidate)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].saving == sorted[j].saving {
+			return bytes.Compare(sorted[i].data, sorted[j].data) < 0
+		}
+		return sorted[j].saving < sorted[i].saving
+	})
+
+	// greedily select the sequences with the largest savings that do not overlap previous selections
+	used := make([][]bool, len(charStrings))
+	calls := make([]map[int]int, len(charStrings)) // token index to subroutine
+	for i := range charStrings {
+		used[i] = make([]bool, len(offsets[i])-1)
+		calls[i] = map[int]int{}
+	}
+	subrs := []*cffSubrCandidate{}
+	for _, candidate := range sorted {
+		if math.MaxUint16 <= len(subrs) {
+			break
+		}
+
+		positions := [][2]int{}
+		prevI, prevEnd := -1, 0
+	PositionLoop:
+		for _, pos := range candidate.positions {
+			i, j := pos[0], pos[1]
+			if i == prevI && j < prevEnd {
+				continue
+			}
+			for k := j; k < j+candidate.tokens; k++ {
+				if used[i][k] {
+					continue PositionLoop
+				}
+			}
+			positions = append(positions, pos)
+			prevI, prevEnd = i, j+candidate.tokens
+		}
+
+		size := len(candidate.data)
+		if len(positions)*(size-callSize) <= size+subrOverhead {
+			continue
+		}
+		for _, pos := range positions {
+			for k := pos[1]; k < pos[1]+candidate.tokens; k++ {
+				used[pos[0]][k] = true
+			}
+			calls[pos[0]][pos[1]] = len(subrs)
+		}
+		candidate.positions = positions
+		subrs = append(subrs, candidate)
+	}
+	if len(subrs) == 0 {
+		return charStrings, nil
+	}
+
+	// most used subroutines get the smallest indices
+	order := make([]int, len(subrs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(subrs[order[j]].positions) < len(subrs[order[i]].positions)
+	})
+	indices := make([]int32, len(subrs))
+	localSubrs := make([][]byte, len(subrs))
+	for index, i := range order {
+		indices[i] = int32(index)
+		localSubrs[index] = append(append([]byte{}, subrs[i].data...), byte(cffReturn))
+	}
+
+	bias := int32(cffCharStringSubrsBias(len(subrs)))
+	newCharStrings := make([][]byte, len(charStrings))
+	for i, charString := range charStrings {
+		w := parse.NewBinaryWriter(make([]byte, 0, len(charString)))
+		for j := 0; j < len(offsets[i])-1; {
+			if subr, ok := calls[i][j]; ok {
+				cffWriteCharStringNumber(w, indices[subr]-bias)
+				w.WriteUint8(uint8(cffCallsubr))
+				j += subrs[subr].tokens
+			} else {
+				w.WriteBytes(charString[offsets[i][j]:offsets[i][j+1]])
+				j++
+			}
+		}
+		newCharStrings[i] = w.Bytes()
+	}
+	return newCharStrings, localSubrs
+}
+
 type cffSubrIndexChange struct {
 	start, end uint32
 	index      int32
@@ -1263,7 +1660,7 @@ func cffINDEXOffSize(n int) int {
 		return 1
 	} else if n <= math.MaxUint16 {
 		return 2
-	} else if n <= (2<<24 - 1) {
+	} else if n <= (1<<24 - 1) {
 		return 3
 	}
 	return 4
@@ -1294,7 +1691,7 @@ func (t *cffINDEX) Write() ([]byte, error) {
 	} else if offSize == 3 {
 		for _, offset := range t.offset {
 			w.WriteUint8(uint8((offset + 1) >> 16))
-			w.WriteUint16(uint16((offset + 1) & 0x00FF))
+			w.WriteUint16(uint16((offset + 1) & 0xFFFF))
 		}
 	} else if offSize == 4 {
 		for _, offset := range t.offset {
@@ -0,0 +1,1592 @@
+package font
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+type colrBaseGlyph struct {
+	GlyphID         uint16
+	FirstLayerIndex uint16
+	NumLayers       uint16
+}
+
+type colrLayer struct {
+	GlyphID      uint16
+	PaletteIndex uint16
+}
+
+type colrBaseGlyphPaint struct {
+	GlyphID uint16
+	Offset  uint32 // offset of the paint from the start of the table
+}
+
+type colrClip struct {
+	StartGlyphID uint16
+	EndGlyphID   uint16
+	Offset       uint32 // offset of the clip box from the start of the table
+}
+
+type colrTable struct {
+	Version    uint16
+	BaseGlyphs []colrBaseGlyph
+	Layers     []colrLayer
+
+	// version 1
+	BaseGlyphPaints          []colrBaseGlyphPaint
+	LayerPaints              []uint32 // offsets of the paints from the start of the table
+	Clips                    []colrClip
+	layerListOffset          uint32
+	varIndexMapOffset        uint32
+	itemVariationStoreOffset uint32
+
+	data []byte
+}
+
+// Get returns the layers of a version 0 color glyph, or nil if the glyph has no layers.
+func (colr *colrTable) Get(glyphID uint16) []colrLayer {
+	i := sort.Search(len(colr.BaseGlyphs), func(i int) bool { return glyphID <= colr.BaseGlyphs[i].GlyphID })
+	if i == len(colr.BaseGlyphs) || colr.BaseGlyphs[i].GlyphID != glyphID {
+		return nil
+	}
+	first, n := int(colr.BaseGlyphs[i].FirstLayerIndex), int(colr.BaseGlyphs[i].NumLayers)
+	if len(colr.Layers) < first+n {
+		return nil
+	}
+	return colr.Layers[first : first+n]
+}
+
+// GetPaint returns the offset of the root paint of a version 1 color glyph.
+func (colr *colrTable) GetPaint(glyphID uint16) (uint32, bool) {
+	i := sort.Search(len(colr.BaseGlyphPaints), func(i int) bool { return glyphID <= colr.BaseGlyphPaints[i].GlyphID })
+	if i == len(colr.BaseGlyphPaints) || colr.BaseGlyphPaints[i].GlyphID != glyphID {
+		return 0, false
+	}
+	return colr.BaseGlyphPaints[i].Offset, true
+}
+
+// GetClip returns the offset of the clip box of a version 1 color glyph.
+func (colr *colrTable) GetClip(glyphID uint16) (uint32, bool) {
+	i := sort.Search(len(colr.Clips), func(i int) bool { return glyphID <= colr.Clips[i].EndGlyphID })
+	if i == len(colr.Clips) || glyphID < colr.Clips[i].StartGlyphID {
+		return 0, false
+	}
+	return colr.Clips[i].Offset, true
+}
+
+// walkPaint calls the callback for each paint in the paint graph starting at offset, each paint is visited only once.
+func (colr *colrTable) walkPaint(offset uint32, visited map[uint32]bool, cb func(uint8, uint32) error) error {
+	if visited[offset] {
+		return nil
+	}
+	visited[offset] = true
+
+	if uint32(len(colr.data)) <= offset {
+		return fmt.Errorf("COLR: bad paint offset")
+	}
+	format := colr.data[offset]
+	if err := cb(format, offset); err != nil {
+		return err
+	}
+
+	minLength := uint32(1)
+	switch format {
+	case 1:
+		minLength = 6
+	case 10, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31:
+		minLength = 4
+	case 32:
+		minLength = 8
+	}
+	if uint32(len(colr.data))-offset < minLength {
+		return fmt.Errorf("COLR: bad paint")
+	}
+
+	r := parse.NewBinaryReaderBytes(colr.data[offset:])
+	_ = r.ReadUint8() // format
+	children := []uint32{}
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := uint32(r.ReadUint8())
+		firstLayerIndex := r.ReadUint32()
+		if uint32(len(colr.LayerPaints)) < firstLayerIndex || uint32(len(colr.LayerPaints))-firstLayerIndex < numLayers {
+			return fmt.Errorf("COLR: bad layer index")
+		}
+		children = append(children, colr.LayerPaints[firstLayerIndex:firstLayerIndex+numLayers]...)
+	case 2, 3, 4, 5, 6, 7, 8, 9, 11:
+		// no child paints
+	case 10, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31:
+		children = append(children, offset+r.ReadUint24())
+	case 32: // PaintComposite
+		children = append(children, offset+r.ReadUint24())
+		_ = r.ReadUint8() // compositeMode
+		children = append(children, offset+r.ReadUint24())
+	default:
+		return fmt.Errorf("COLR: unsupported paint format %d", format)
+	}
+	for _, child := range children {
+		if err := colr.walkPaint(child, visited, cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dependencies returns the glyph IDs referenced by the color glyph, either from its layers or from its paints.
+func (colr *colrTable) Dependencies(glyphID uint16) ([]uint16, error) {
+	deps := []uint16{}
+	for _, layer := range colr.Get(glyphID) {
+		deps = append(deps, layer.GlyphID)
+	}
+	if offset, ok := colr.GetPaint(glyphID); ok {
+		err := colr.walkPaint(offset, map[uint32]bool{}, func(format uint8, offset uint32) error {
+			if format == 10 { // PaintGlyph
+				if uint32(len(colr.data))-offset < 6 {
+					return fmt.Errorf("COLR: bad paint")
+				}
+				deps = append(deps, binary.BigEndian.Uint16(colr.data[offset+4:]))
+			} else if format == 11 { // PaintColrGlyph
+				if uint32(len(colr.data))-offset < 3 {
+					return fmt.Errorf("COLR: bad paint")
+				}
+				deps = append(deps, binary.BigEndian.Uint16(colr.data[offset+1:]))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+func (sfnt *SFNT) parseCOLR() error {
+	b, ok := sfnt.Tables["COLR"]
+	if !ok {
+		return fmt.Errorf("COLR: missing table")
+	} else if len(b) < 14 {
+		return fmt.Errorf("COLR: bad table")
+	}
+
+	sfnt.Colr = &colrTable{
+		data: b,
+	}
+	r := parse.NewBinaryReaderBytes(b)
+	sfnt.Colr.Version = r.ReadUint16()
+	numBaseGlyphRecords := uint32(r.ReadUint16())
+	baseGlyphRecordsOffset := r.ReadUint32()
+	layerRecordsOffset := r.ReadUint32()
+	numLayerRecords := uint32(r.ReadUint16())
+	if 1 < sfnt.Colr.Version {
+		return fmt.Errorf("COLR: bad version")
+	} else if numBaseGlyphRecords != 0 && (uint32(len(b)) < baseGlyphRecordsOffset || (uint32(len(b))-baseGlyphRecordsOffset)/6 < numBaseGlyphRecords) {
+		return fmt.Errorf("COLR: bad base glyph records")
+	} else if numLayerRecords != 0 && (uint32(len(b)) < layerRecordsOffset || (uint32(len(b))-layerRecordsOffset)/4 < numLayerRecords) {
+		return fmt.Errorf("COLR: bad layer records")
+	}
+
+	sfnt.Colr.BaseGlyphs = make([]colrBaseGlyph, numBaseGlyphRecords)
+	for i := range sfnt.Colr.BaseGlyphs {
+		pos := baseGlyphRecordsOffset + 6*uint32(i)
+		sfnt.Colr.BaseGlyphs[i].GlyphID = binary.BigEndian.Uint16(b[pos:])
+		sfnt.Colr.BaseGlyphs[i].FirstLayerIndex = binary.BigEndian.Uint16(b[pos+2:])
+		sfnt.Colr.BaseGlyphs[i].NumLayers = binary.BigEndian.Uint16(b[pos+4:])
+		if 0 < i && sfnt.Colr.BaseGlyphs[i].GlyphID <= sfnt.Colr.BaseGlyphs[i-1].GlyphID {
+			return fmt.Errorf("COLR: base glyph records must be sorted")
+		}
+	}
+	sfnt.Colr.Layers = make([]colrLayer, numLayerRecords)
+	for i := range sfnt.Colr.Layers {
+		pos := layerRecordsOffset + 4*uint32(i)
+		sfnt.Colr.Layers[i].GlyphID = binary.BigEndian.Uint16(b[pos:])
+		sfnt.Colr.Layers[i].PaletteIndex = binary.BigEndian.Uint16(b[pos+2:])
+	}
+	if sfnt.Colr.Version == 0 {
+		return nil
+	} else if len(b) < 34 {
+		return fmt.Errorf("COLR: bad table")
+	}
+
+	baseGlyphListOffset := r.ReadUint32()
+	layerListOffset := r.ReadUint32()
+	clipListOffset := r.ReadUint32()
+	sfnt.Colr.varIndexMapOffset = r.ReadUint32()
+	sfnt.Colr.itemVariationStoreOffset = r.ReadUint32()
+	if uint32(len(b)) < sfnt.Colr.varIndexMapOffset || uint32(len(b)) < sfnt.Colr.itemVariationStoreOffset {
+		return fmt.Errorf("COLR: bad table")
+	}
+
+	if baseGlyphListOffset != 0 {
+		if uint32(len(b))-4 < baseGlyphListOffset {
+			return fmt.Errorf("COLR: bad base glyph list")
+		}
+		r.Seek(int64(baseGlyphListOffset), 0)
+		numRecords := r.ReadUint32()
+		if uint32(r.Len())/6 < numRecords {
+			return fmt.Errorf("COLR: bad base glyph list")
+		}
+		sfnt.Colr.BaseGlyphPaints = make([]colrBaseGlyphPaint, numRecords)
+		for i := range sfnt.Colr.BaseGlyphPaints {
+			sfnt.Colr.BaseGlyphPaints[i].GlyphID = r.ReadUint16()
+			offset := r.ReadUint32()
+			if uint32(len(b))-baseGlyphListOffset <= offset {
+				return fmt.Errorf("COLR: bad base glyph list")
+			} else if 0 < i && sfnt.Colr.BaseGlyphPaints[i].GlyphID <= sfnt.Colr.BaseGlyphPaints[i-1].GlyphID {
+				return fmt.Errorf("COLR: base glyph list must be sorted")
+			}
+			sfnt.Colr.BaseGlyphPaints[i].Offset = baseGlyphListOffset + offset
+		}
+	}
+	if layerListOffset != 0 {
+		sfnt.Colr.layerListOffset = layerListOffset
+		if uint32(len(b))-4 < layerListOffset {
+			return fmt.Errorf("COLR: bad layer list")
+		}
+		r.Seek(int64(layerListOffset), 0)
+		numLayers := r.ReadUint32()
+		if uint32(r.Len())/4 < numLayers {
+			return fmt.Errorf("COLR: bad layer list")
+		}
+		sfnt.Colr.LayerPaints = make([]uint32, numLayers)
+		for i := range sfnt.Colr.LayerPaints {
+			offset := r.ReadUint32()
+			if uint32(len(b))-layerListOffset <= offset {
+				return fmt.Errorf("COLR: bad layer list")
+			}
+			sfnt.Colr.LayerPaints[i] = layerListOffset + offset
+		}
+	}
+	if clipListOffset != 0 {
+		if uint32(len(b))-5 < clipListOffset {
+			return fmt.Errorf("COLR: bad clip list")
+		}
+		r.Seek(int64(clipListOffset), 0)
+		format := r.ReadUint8()
+		numClips := r.ReadUint32()
+		if format != 1 {
+			return fmt.Errorf("COLR: unsupported clip list format %d", format)
+		} else if uint32(r.Len())/7 < numClips {
+			return fmt.Errorf("COLR: bad clip list")
+		}
+		sfnt.Colr.Clips = make([]colrClip, numClips)
+		for i := range sfnt.Colr.Clips {
+			sfnt.Colr.Clips[i].StartGlyphID = r.ReadUint16()
+			sfnt.Colr.Clips[i].EndGlyphID = r.ReadUint16()
+			offset := r.ReadUint24()
+			if sfnt.Colr.Clips[i].EndGlyphID < sfnt.Colr.Clips[i].StartGlyphID || uint32(len(b))-clipListOffset <= offset {
+				return fmt.Errorf("COLR: bad clip list")
+			} else if 0 < i && sfnt.Colr.Clips[i].StartGlyphID <= sfnt.Colr.Clips[i-1].EndGlyphID {
+				return fmt.Errorf("COLR: clip list must be sorted")
+			}
+			sfnt.Colr.Clips[i].Offset = clipListOffset + offset
+		}
+	}
+	return nil
+}
+
+// subset rewrites the COLR table for the given glyph IDs, which become the new glyph IDs in order. For version 1, only the paints and layers reachable from the kept glyphs are copied and their glyph IDs are remapped.
+func (colr *colrTable) subset(glyphIDs []uint16, glyphMap map[uint16]uint16) ([]byte, error) {
+	baseGlyphs := []colrBaseGlyph{}
+	layers := []colrLayer{}
+	baseGlyphPaints := []colrBaseGlyphPaint{}
+	for subsetGlyphID, glyphID := range glyphIDs {
+		if glyphLayers := colr.Get(glyphID); glyphLayers != nil {
+			baseGlyphs = append(baseGlyphs, colrBaseGlyph{
+				GlyphID:         uint16(subsetGlyphID),
+				FirstLayerIndex: uint16(len(layers)),
+				NumLayers:       uint16(len(glyphLayers)),
+			})
+			for _, layer := range glyphLayers {
+				layers = append(layers, colrLayer{
+					GlyphID:      glyphMap[layer.GlyphID],
+					PaletteIndex: layer.PaletteIndex,
+				})
+			}
+		}
+		if offset, ok := colr.GetPaint(glyphID); ok {
+			baseGlyphPaints = append(baseGlyphPaints, colrBaseGlyphPaint{
+				GlyphID: uint16(subsetGlyphID),
+				Offset:  offset,
+			})
+		}
+	}
+	if 65535 < len(layers) {
+		return nil, fmt.Errorf("COLR: too many layers")
+	}
+	sort.Slice(baseGlyphs, func(i, j int) bool { return baseGlyphs[i].GlyphID < baseGlyphs[j].GlyphID })
+	sort.Slice(baseGlyphPaints, func(i, j int) bool { return baseGlyphPaints[i].GlyphID < baseGlyphPaints[j].GlyphID })
+
+	headerLength := uint32(14)
+	if colr.Version == 1 {
+		headerLength = 34
+	}
+	baseGlyphRecordsOffset := headerLength
+	layerRecordsOffset := baseGlyphRecordsOffset + 6*uint32(len(baseGlyphs))
+
+	w := parse.NewBinaryWriter([]byte{})
+	w.WriteUint16(colr.Version)
+	w.WriteUint16(uint16(len(baseGlyphs)))
+	if len(baseGlyphs) == 0 {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(baseGlyphRecordsOffset)
+	}
+	if len(layers) == 0 {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(layerRecordsOffset)
+	}
+	w.WriteUint16(uint16(len(layers)))
+	if colr.Version == 0 {
+		for _, baseGlyph := range baseGlyphs {
+			w.WriteUint16(baseGlyph.GlyphID)
+			w.WriteUint16(baseGlyph.FirstLayerIndex)
+			w.WriteUint16(baseGlyph.NumLayers)
+		}
+		for _, layer := range layers {
+			w.WriteUint16(layer.GlyphID)
+			w.WriteUint16(layer.PaletteIndex)
+		}
+		return w.Bytes(), nil
+	}
+
+	// collect the paints reachable from the kept glyphs and their layers
+	p := &colrPaintSubsetter{
+		colr:     colr,
+		glyphMap: glyphMap,
+		tables:   map[uint32]*colrSubsetTable{},
+		visiting: map[uint32]bool{},
+		layers:   map[[2]uint32]uint32{},
+	}
+	for _, baseGlyphPaint := range baseGlyphPaints {
+		if err := p.addPaint(baseGlyphPaint.Offset, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	clips := []colrClip{}
+	for subsetGlyphID, glyphID := range glyphIDs {
+		if offset, ok := colr.GetClip(glyphID); ok {
+			if _, ok := colr.GetPaint(glyphID); !ok {
+				continue
+			}
+			if err := p.addClipBox(offset); err != nil {
+				return nil, err
+			}
+			clips = append(clips, colrClip{
+				StartGlyphID: uint16(subsetGlyphID),
+				EndGlyphID:   uint16(subsetGlyphID),
+				Offset:       offset,
+			})
+		}
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].StartGlyphID < clips[j].StartGlyphID })
+	for i := 1; i < len(clips); i++ {
+		if clips[i-1].EndGlyphID+1 == clips[i].StartGlyphID && clips[i-1].Offset == clips[i].Offset {
+			clips[i-1].EndGlyphID = clips[i].EndGlyphID
+			clips = append(clips[:i], clips[i+1:]...)
+			i--
+		}
+	}
+
+	data, offsets, err := p.write()
+	if err != nil {
+		return nil, err
+	}
+	for i := range baseGlyphPaints {
+		baseGlyphPaints[i].Offset = offsets[baseGlyphPaints[i].Offset]
+	}
+	for i := range clips {
+		clips[i].Offset = offsets[clips[i].Offset]
+	}
+
+	// the variation tables are copied as is, their variation indices do not depend on the paints
+	varIndexMap, err := colr.deltaSetIndexMap()
+	if err != nil {
+		return nil, err
+	}
+	itemVariationStore, err := colr.itemVariationStore()
+	if err != nil {
+		return nil, err
+	}
+
+	baseGlyphListOffset := layerRecordsOffset + 4*uint32(len(layers))
+	layerListOffset := baseGlyphListOffset + 4 + 6*uint32(len(baseGlyphPaints))
+	clipListOffset := layerListOffset
+	if len(p.layerPaints) != 0 {
+		clipListOffset += 4 + 4*uint32(len(p.layerPaints))
+	}
+	dataOffset := clipListOffset
+	if len(clips) != 0 {
+		dataOffset += 5 + 7*uint32(len(clips))
+	}
+	varIndexMapOffset := dataOffset + uint32(len(data))
+	itemVariationStoreOffset := varIndexMapOffset + uint32(len(varIndexMap))
+
+	w.WriteUint32(baseGlyphListOffset)
+	if len(p.layerPaints) == 0 {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(layerListOffset)
+	}
+	if len(clips) == 0 {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(clipListOffset)
+	}
+	if varIndexMap == nil {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(varIndexMapOffset)
+	}
+	if itemVariationStore == nil {
+		w.WriteUint32(0)
+	} else {
+		w.WriteUint32(itemVariationStoreOffset)
+	}
+	for _, baseGlyph := range baseGlyphs {
+		w.WriteUint16(baseGlyph.GlyphID)
+		w.WriteUint16(baseGlyph.FirstLayerIndex)
+		w.WriteUint16(baseGlyph.NumLayers)
+	}
+	for _, layer := range layers {
+		w.WriteUint16(layer.GlyphID)
+		w.WriteUint16(layer.PaletteIndex)
+	}
+
+	w.WriteUint32(uint32(len(baseGlyphPaints)))
+	for _, baseGlyphPaint := range baseGlyphPaints {
+		w.WriteUint16(baseGlyphPaint.GlyphID)
+		w.WriteUint32(dataOffset + baseGlyphPaint.Offset - baseGlyphListOffset)
+	}
+	if len(p.layerPaints) != 0 {
+		w.WriteUint32(uint32(len(p.layerPaints)))
+		for _, offset := range p.layerPaints {
+			w.WriteUint32(dataOffset + offsets[offset] - layerListOffset)
+		}
+	}
+	if len(clips) != 0 {
+		w.WriteUint8(1) // format
+		w.WriteUint32(uint32(len(clips)))
+		for _, clip := range clips {
+			offset := dataOffset + clip.Offset - clipListOffset
+			if 1<<24 <= offset {
+				return nil, fmt.Errorf("COLR: clip box offset overflow")
+			}
+			w.WriteUint16(clip.StartGlyphID)
+			w.WriteUint16(clip.EndGlyphID)
+			w.WriteUint24(offset)
+		}
+	}
+	w.WriteBytes(data)
+	w.WriteBytes(varIndexMap)
+	w.WriteBytes(itemVariationStore)
+	return w.Bytes(), nil
+}
+
+// colrPaintLength is the length of each paint format, including the varIndexBase of variable paints.
+var colrPaintLength = [33]uint32{
+	1: 6, 2: 5, 3: 9, 4: 16, 5: 20, 6: 16, 7: 20, 8: 12, 9: 16, 10: 6, 11: 3, 12: 7, 13: 7,
+	14: 8, 15: 12, 16: 8, 17: 12, 18: 12, 19: 16, 20: 6, 21: 10, 22: 10, 23: 14,
+	24: 6, 25: 10, 26: 10, 27: 14, 28: 8, 29: 12, 30: 12, 31: 16, 32: 8,
+}
+
+// colrSubsetTable is a paint or subtable of a version 1 COLR table that is kept in the subset.
+type colrSubsetTable struct {
+	offset, length uint32
+	children       [][2]uint32 // positions of the Offset24 to the child and the original offset of the child
+	glyphIDPos     uint32      // position of the glyph ID, zero if none
+	layers         bool        // PaintColrLayers
+	layerIndex     uint32      // new first layer index for PaintColrLayers
+}
+
+// colrPaintSubsetter collects the paints and subtables of a version 1 COLR table that are reachable from the kept glyphs, and writes them so that parents come before their children. Glyph IDs are remapped and layers are added to a new layer list.
+type colrPaintSubsetter struct {
+	colr     *colrTable
+	glyphMap map[uint16]uint16
+
+	tables      map[uint32]*colrSubsetTable // by original offset
+	visiting    map[uint32]bool
+	order       []*colrSubsetTable   // in postorder
+	layers      map[[2]uint32]uint32 // original first layer index and number of layers to new first layer index
+	layerPaints []uint32             // original offsets
+}
+
+// addTable adds the paint or subtable of the given length at offset, and returns nil if it was added before.
+func (p *colrPaintSubsetter) addTable(offset, length uint32) (*colrSubsetTable, error) {
+	if _, ok := p.tables[offset]; ok {
+		if p.visiting[offset] {
+			return nil, fmt.Errorf("COLR: paint graph has a cycle")
+		}
+		return nil, nil
+	} else if uint32(len(p.colr.data)) < offset || uint32(len(p.colr.data))-offset < length {
+		return nil, fmt.Errorf("COLR: bad paint")
+	}
+	table := &colrSubsetTable{
+		offset: offset,
+		length: length,
+	}
+	p.tables[offset] = table
+	return table, nil
+}
+
+// addChild adds the child paint or subtable referenced by the Offset24 at pos of the table.
+func (p *colrPaintSubsetter) addChild(table *colrSubsetTable, pos uint32, add func(uint32) error) error {
+	data := p.colr.data[table.offset+pos:]
+	child := table.offset + (uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2]))
+	table.children = append(table.children, [2]uint32{pos, child})
+	return add(child)
+}
+
+// addLeaf adds a subtable without children of the given length.
+func (p *colrPaintSubsetter) addLeaf(offset, length uint32) error {
+	table, err := p.addTable(offset, length)
+	if err != nil || table == nil {
+		return err
+	}
+	p.order = append(p.order, table)
+	return nil
+}
+
+func (p *colrPaintSubsetter) addPaint(offset uint32, depth int) error {
+	if maxPaintDepth < depth {
+		return fmt.Errorf("COLR: paint graph too deep")
+	} else if uint32(len(p.colr.data)) <= offset {
+		return fmt.Errorf("COLR: bad paint offset")
+	}
+	format := p.colr.data[offset]
+	if len(colrPaintLength) <= int(format) || colrPaintLength[format] == 0 {
+		return fmt.Errorf("COLR: unsupported paint format %d", format)
+	}
+	table, err := p.addTable(offset, colrPaintLength[format])
+	if err != nil || table == nil {
+		return err
+	}
+	p.visiting[offset] = true
+
+	addPaint := func(child uint32) error {
+		return p.addPaint(child, depth+1)
+	}
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := uint32(p.colr.data[offset+1])
+		firstLayerIndex := binary.BigEndian.Uint32(p.colr.data[offset+2:])
+		if uint32(len(p.colr.LayerPaints)) < firstLayerIndex || uint32(len(p.colr.LayerPaints))-firstLayerIndex < numLayers {
+			return fmt.Errorf("COLR: bad layer index")
+		}
+		key := [2]uint32{firstLayerIndex, numLayers}
+		layerIndex, ok := p.layers[key]
+		if !ok {
+			layerIndex = uint32(len(p.layerPaints))
+			p.layers[key] = layerIndex
+			p.layerPaints = append(p.layerPaints, p.colr.LayerPaints[firstLayerIndex:firstLayerIndex+numLayers]...)
+			for _, layerPaint := range p.colr.LayerPaints[firstLayerIndex : firstLayerIndex+numLayers] {
+				if err = p.addPaint(layerPaint, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		table.layers = true
+		table.layerIndex = layerIndex
+	case 2, 3:
+		// no child paints
+	case 4, 5, 6, 7, 8, 9: // gradients
+		stopLength := uint32(6)
+		if format%2 == 1 {
+			stopLength = 10
+		}
+		err = p.addChild(table, 1, func(child uint32) error {
+			if uint32(len(p.colr.data)) < child || uint32(len(p.colr.data))-child < 3 {
+				return fmt.Errorf("COLR: bad color line")
+			}
+			numStops := uint32(binary.BigEndian.Uint16(p.colr.data[child+1:]))
+			return p.addLeaf(child, 3+stopLength*numStops)
+		})
+	case 10: // PaintGlyph
+		table.glyphIDPos = 4
+		err = p.addChild(table, 1, addPaint)
+	case 11: // PaintColrGlyph
+		table.glyphIDPos = 1
+	case 12, 13: // PaintTransform
+		transformLength := uint32(24)
+		if format == 13 {
+			transformLength = 28
+		}
+		if err = p.addChild(table, 1, addPaint); err == nil {
+			err = p.addChild(table, 4, func(child uint32) error {
+				return p.addLeaf(child, transformLength)
+			})
+		}
+	case 32: // PaintComposite
+		if err = p.addChild(table, 1, addPaint); err == nil {
+			err = p.addChild(table, 5, addPaint)
+		}
+	default: // transformations
+		err = p.addChild(table, 1, addPaint)
+	}
+	if err != nil {
+		return err
+	}
+	p.visiting[offset] = false
+	p.order = append(p.order, table)
+	return nil
+}
+
+// addClipBox adds the clip box at offset.
+func (p *colrPaintSubsetter) addClipBox(offset uint32) error {
+	if uint32(len(p.colr.data)) <= offset {
+		return fmt.Errorf("COLR: bad clip box")
+	}
+	length := uint32(9)
+	if p.colr.data[offset] == 2 {
+		length = 13
+	}
+	return p.addLeaf(offset, length)
+}
+
+// write returns the data of the added paints and subtables in reverse postorder, so that all Offset24 are positive, and their new offsets by original offset.
+func (p *colrPaintSubsetter) write() ([]byte, map[uint32]uint32, error) {
+	length := uint32(0)
+	offsets := make(map[uint32]uint32, len(p.order))
+	for i := len(p.order) - 1; 0 <= i; i-- {
+		offsets[p.order[i].offset] = length
+		length += p.order[i].length
+	}
+
+	data := make([]byte, 0, length)
+	for i := len(p.order) - 1; 0 <= i; i-- {
+		table := p.order[i]
+		newOffset := uint32(len(data))
+		data = append(data, p.colr.data[table.offset:table.offset+table.length]...)
+		for _, child := range table.children {
+			v := offsets[child[1]] - newOffset
+			if offsets[child[1]] < newOffset || 1<<24 <= v {
+				return nil, nil, fmt.Errorf("COLR: paint offset overflow")
+			}
+			data[newOffset+child[0]] = byte(v >> 16)
+			data[newOffset+child[0]+1] = byte(v >> 8)
+			data[newOffset+child[0]+2] = byte(v)
+		}
+		if table.glyphIDPos != 0 {
+			glyphID := binary.BigEndian.Uint16(data[newOffset+table.glyphIDPos:])
+			binary.BigEndian.PutUint16(data[newOffset+table.glyphIDPos:], p.glyphMap[glyphID])
+		}
+		if table.layers {
+			binary.BigEndian.PutUint32(data[newOffset+2:], table.layerIndex)
+		}
+	}
+	return data, offsets, nil
+}
+
+// deltaSetIndexMap returns the DeltaSetIndexMap subtable, or nil if there is none.
+func (colr *colrTable) deltaSetIndexMap() ([]byte, error) {
+	if colr.varIndexMapOffset == 0 {
+		return nil, nil
+	}
+	r := newBinaryReader(colr.data[colr.varIndexMapOffset:])
+	format := r.ReadUint8()
+	entryFormat := r.ReadUint8()
+	var mapCount uint32
+	if format == 0 {
+		mapCount = uint32(r.ReadUint16())
+	} else if format == 1 {
+		mapCount = r.ReadUint32()
+	} else {
+		return nil, fmt.Errorf("COLR: unsupported delta set index map format %d", format)
+	}
+	entrySize := uint32(entryFormat&0x30>>4) + 1
+	if r.Err() != nil || uint32(r.Len())/entrySize < mapCount {
+		return nil, fmt.Errorf("COLR: bad delta set index map")
+	}
+	length := uint32(r.Pos()) + entrySize*mapCount
+	return colr.data[colr.varIndexMapOffset : colr.varIndexMapOffset+length], nil
+}
+
+// itemVariationStore returns the ItemVariationStore subtable, or nil if there is none.
+func (colr *colrTable) itemVariationStore() ([]byte, error) {
+	if colr.itemVariationStoreOffset == 0 {
+		return nil, nil
+	}
+	b := colr.data[colr.itemVariationStoreOffset:]
+	r := newBinaryReader(b)
+	format := r.ReadUint16()
+	regionListOffset := r.ReadUint32()
+	itemVariationDataCount := r.ReadUint16()
+	if r.Err() != nil || format != 1 || uint32(len(b))-4 < regionListOffset || r.Len()/4 < int64(itemVariationDataCount) {
+		return nil, fmt.Errorf("COLR: bad item variation store")
+	}
+	length := uint32(r.Pos()) + 4*uint32(itemVariationDataCount)
+	subtables := []uint32{regionListOffset}
+	for i := 0; i < int(itemVariationDataCount); i++ {
+		subtables = append(subtables, r.ReadUint32())
+	}
+	for i, offset := range subtables {
+		if uint32(len(b)) < offset {
+			return nil, fmt.Errorf("COLR: bad item variation store")
+		}
+		rs := newBinaryReader(b[offset:])
+		var end uint64
+		if i == 0 { // VariationRegionList
+			axisCount := uint64(rs.ReadUint16())
+			regionCount := uint64(rs.ReadUint16())
+			end = uint64(offset) + 4 + 6*axisCount*regionCount
+		} else { // ItemVariationData
+			itemCount := uint64(rs.ReadUint16())
+			wordDeltaCount := rs.ReadUint16()
+			regionIndexCount := uint64(rs.ReadUint16())
+			wordCount := uint64(wordDeltaCount & 0x7FFF)
+			if regionIndexCount < wordCount {
+				return nil, fmt.Errorf("COLR: bad item variation store")
+			}
+			rowLength := 2*wordCount + regionIndexCount - wordCount
+			if wordDeltaCount&0x8000 != 0 {
+				rowLength *= 2
+			}
+			end = uint64(offset) + 6 + 2*regionIndexCount + itemCount*rowLength
+		}
+		if rs.Err() != nil || uint64(len(b)) < end {
+			return nil, fmt.Errorf("COLR: bad item variation store")
+		}
+		length = max(length, uint32(end))
+	}
+	return b[:length], nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type cpalTable struct {
+	Version        uint16
+	Palettes       [][]color.NRGBA
+	PaletteTypes   []uint32 // version 1
+	PaletteLabels  []uint16 // version 1, name IDs or 0xFFFF
+	PaletteEntries []uint16 // version 1, name IDs of palette entry labels or 0xFFFF
+}
+
+func (sfnt *SFNT) parseCPAL() error {
+	b, ok := sfnt.Tables["CPAL"]
+	if !ok {
+		return fmt.Errorf("CPAL: missing table")
+	} else if len(b) < 12 {
+		return fmt.Errorf("CPAL: bad table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	version := r.ReadUint16()
+	numPaletteEntries := uint32(r.ReadUint16())
+	numPalettes := uint32(r.ReadUint16())
+	numColorRecords := uint32(r.ReadUint16())
+	colorRecordsArrayOffset := r.ReadUint32()
+	if 1 < version {
+		return fmt.Errorf("CPAL: bad version")
+	} else if uint32(r.Len())/2 < numPalettes {
+		return fmt.Errorf("CPAL: bad table")
+	} else if uint32(len(b)) < colorRecordsArrayOffset || (uint32(len(b))-colorRecordsArrayOffset)/4 < numColorRecords {
+		return fmt.Errorf("CPAL: bad color records")
+	}
+
+	sfnt.Cpal = &cpalTable{
+		Version: version,
+	}
+	colors := b[colorRecordsArrayOffset:]
+	sfnt.Cpal.Palettes = make([][]color.NRGBA, numPalettes)
+	for i := range sfnt.Cpal.Palettes {
+		colorRecordIndex := uint32(r.ReadUint16())
+		if numColorRecords < colorRecordIndex || numColorRecords-colorRecordIndex < numPaletteEntries {
+			return fmt.Errorf("CPAL: bad color record index for palette %d", i)
+		}
+		sfnt.Cpal.Palettes[i] = make([]color.NRGBA, numPaletteEntries)
+		for j := range sfnt.Cpal.Palettes[i] {
+			c := colors[4*(colorRecordIndex+uint32(j)):]
+			sfnt.Cpal.Palettes[i][j] = color.NRGBA{c[2], c[1], c[0], c[3]} // stored as BGRA
+		}
+	}
+	if version == 0 {
+		return nil
+	} else if r.Len() < 12 {
+		return fmt.Errorf("CPAL: bad table")
+	}
+
+	paletteTypesArrayOffset := r.ReadUint32()
+	paletteLabelsArrayOffset := r.ReadUint32()
+	paletteEntryLabelsArrayOffset := r.ReadUint32()
+	if paletteTypesArrayOffset != 0 {
+		if uint32(len(b)) < paletteTypesArrayOffset || (uint32(len(b))-paletteTypesArrayOffset)/4 < numPalettes {
+			return fmt.Errorf("CPAL: bad palette types")
+		}
+		rTypes := parse.NewBinaryReaderBytes(b[paletteTypesArrayOffset:])
+		sfnt.Cpal.PaletteTypes = make([]uint32, numPalettes)
+		for i := range sfnt.Cpal.PaletteTypes {
+			sfnt.Cpal.PaletteTypes[i] = rTypes.ReadUint32()
+		}
+	}
+	if paletteLabelsArrayOffset != 0 {
+		if uint32(len(b)) < paletteLabelsArrayOffset || (uint32(len(b))-paletteLabelsArrayOffset)/2 < numPalettes {
+			return fmt.Errorf("CPAL: bad palette labels")
+		}
+		rLabels := parse.NewBinaryReaderBytes(b[paletteLabelsArrayOffset:])
+		sfnt.Cpal.PaletteLabels = make([]uint16, numPalettes)
+		for i := range sfnt.Cpal.PaletteLabels {
+			sfnt.Cpal.PaletteLabels[i] = rLabels.ReadUint16()
+		}
+	}
+	if paletteEntryLabelsArrayOffset != 0 {
+		if uint32(len(b)) < paletteEntryLabelsArrayOffset || (uint32(len(b))-paletteEntryLabelsArrayOffset)/2 < numPaletteEntries {
+			return fmt.Errorf("CPAL: bad palette entry labels")
+		}
+		rLabels := parse.NewBinaryReaderBytes(b[paletteEntryLabelsArrayOffset:])
+		sfnt.Cpal.PaletteEntries = make([]uint16, numPaletteEntries)
+		for i := range sfnt.Cpal.PaletteEntries {
+			sfnt.Cpal.PaletteEntries[i] = rLabels.ReadUint16()
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type svgDocumentRecord struct {
+	StartGlyphID uint16
+	EndGlyphID   uint16
+	Offset       uint32 // offset from the start of the document list
+	Length       uint32
+}
+
+type svgTable struct {
+	Documents []svgDocumentRecord
+
+	data []byte // document list
+}
+
+// Get returns the (uncompressed) SVG document containing the glyph, or nil if the glyph has no SVG document. The glyph is the element with ID "glyph" followed by the glyph ID.
+func (svg *svgTable) Get(glyphID uint16) ([]byte, error) {
+	i := sort.Search(len(svg.Documents), func(i int) bool { return glyphID <= svg.Documents[i].EndGlyphID })
+	if i == len(svg.Documents) || glyphID < svg.Documents[i].StartGlyphID {
+		return nil, nil
+	}
+	return svg.document(i)
+}
+
+func (svg *svgTable) document(i int) ([]byte, error) {
+	doc := svg.data[svg.Documents[i].Offset : svg.Documents[i].Offset+svg.Documents[i].Length]
+	if 2 < len(doc) && doc[0] == 0x1F && doc[1] == 0x8B {
+		r, err := gzip.NewReader(bytes.NewReader(doc))
+		if err != nil {
+			return nil, fmt.Errorf("SVG: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(r, int64(MaxMemory)+1)); err != nil {
+			return nil, fmt.Errorf("SVG: %v", err)
+		} else if uint32(buf.Len()) > MaxMemory {
+			return nil, ErrExceedsMemory
+		}
+		doc = buf.Bytes()
+	}
+	return doc, nil
+}
+
+func (sfnt *SFNT) parseSVG() error {
+	b, ok := sfnt.Tables["SVG "]
+	if !ok {
+		return fmt.Errorf("SVG: missing table")
+	} else if len(b) < 10 {
+		return fmt.Errorf("SVG: bad table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	version := r.ReadUint16()
+	svgDocumentListOffset := r.ReadUint32()
+	if version != 0 {
+		return fmt.Errorf("SVG: bad version")
+	} else if uint32(len(b))-2 < svgDocumentListOffset {
+		return fmt.Errorf("SVG: bad document list")
+	}
+
+	data := b[svgDocumentListOffset:]
+	r = parse.NewBinaryReaderBytes(data)
+	numEntries := r.ReadUint16()
+	if r.Len()/12 < int64(numEntries) {
+		return fmt.Errorf("SVG: bad document list")
+	}
+	sfnt.Svg = &svgTable{
+		Documents: make([]svgDocumentRecord, numEntries),
+		data:      data,
+	}
+	for i := range sfnt.Svg.Documents {
+		doc := &sfnt.Svg.Documents[i]
+		doc.StartGlyphID = r.ReadUint16()
+		doc.EndGlyphID = r.ReadUint16()
+		doc.Offset = r.ReadUint32()
+		doc.Length = r.ReadUint32()
+		if doc.EndGlyphID < doc.StartGlyphID {
+			return fmt.Errorf("SVG: bad glyph range for document %d", i)
+		} else if 0 < i && doc.StartGlyphID <= sfnt.Svg.Documents[i-1].EndGlyphID {
+			return fmt.Errorf("SVG: documents must be sorted")
+		} else if uint32(len(data)) < doc.Offset || uint32(len(data))-doc.Offset < doc.Length {
+			return fmt.Errorf("SVG: bad offset for document %d", i)
+		}
+	}
+	return nil
+}
+
+var svgGlyphIDRegexp = regexp.MustCompile(`(id\s*=\s*["']|#)glyph([0-9]+)\b`)
+
+// subset rewrites the SVG table for the given glyph IDs, which become the new glyph IDs in order. Glyph element IDs in the documents are renamed to their new glyph IDs.
+func (svg *svgTable) subset(glyphIDs []uint16, glyphMap map[uint16]uint16) ([]byte, error) {
+	// get the original document index for each new glyph
+	docIndices := make([]int, len(glyphIDs))
+	for subsetGlyphID, glyphID := range glyphIDs {
+		i := sort.Search(len(svg.Documents), func(i int) bool { return glyphID <= svg.Documents[i].EndGlyphID })
+		if i == len(svg.Documents) || glyphID < svg.Documents[i].StartGlyphID {
+			i = -1
+		}
+		docIndices[subsetGlyphID] = i
+	}
+
+	// rewrite documents, glyph IDs that are not in the subset are renamed to avoid clashes
+	records := []svgDocumentRecord{}
+	docs := [][]byte{}
+	docMap := map[int]int{}
+	for subsetGlyphID, i := range docIndices {
+		if i == -1 {
+			continue
+		}
+		if n := len(records); 0 < n && records[n-1].EndGlyphID+1 == uint16(subsetGlyphID) && docIndices[subsetGlyphID-1] == i {
+			records[n-1].EndGlyphID++
+			continue
+		}
+		j, ok := docMap[i]
+		if !ok {
+			doc, err := svg.document(i)
+			if err != nil {
+				return nil, err
+			}
+			doc = svgGlyphIDRegexp.ReplaceAllFunc(doc, func(b []byte) []byte {
+				m := svgGlyphIDRegexp.FindSubmatch(b)
+				glyphID, err := strconv.ParseUint(string(m[2]), 10, 16)
+				if err == nil {
+					if subsetGlyphID, ok := glyphMap[uint16(glyphID)]; ok {
+						return append(append([]byte{}, m[1]...), "glyph"+strconv.Itoa(int(subsetGlyphID))...)
+					}
+				}
+				return append(append([]byte{}, m[1]...), "_glyph"+string(m[2])...)
+			})
+			j = len(docs)
+			docs = append(docs, doc)
+			docMap[i] = j
+		}
+		records = append(records, svgDocumentRecord{
+			StartGlyphID: uint16(subsetGlyphID),
+			EndGlyphID:   uint16(subsetGlyphID),
+			Offset:       uint32(j), // index into docs, set below
+		})
+	}
+
+	docOffsets := make([]uint32, len(docs))
+	offset := 2 + 12*uint32(len(records))
+	for i, doc := range docs {
+		docOffsets[i] = offset
+		offset += uint32(len(doc))
+	}
+
+	w := parse.NewBinaryWriter([]byte{})
+	w.WriteUint16(0)  // version
+	w.WriteUint32(10) // svgDocumentListOffset
+	w.WriteUint32(0)  // reserved
+	w.WriteUint16(uint16(len(records)))
+	for _, record := range records {
+		w.WriteUint16(record.StartGlyphID)
+		w.WriteUint16(record.EndGlyphID)
+		w.WriteUint32(docOffsets[record.Offset])
+		w.WriteUint32(uint32(len(docs[record.Offset])))
+	}
+	for _, doc := range docs {
+		w.WriteBytes(doc)
+	}
+	return w.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type sbixGlyph struct {
+	OriginOffsetX int16
+	OriginOffsetY int16
+	GraphicType   string // such as "png ", "jpg ", "tiff", or "dupe"
+	Data          []byte
+}
+
+type sbixStrike struct {
+	PPEM    uint16
+	PPI     uint16
+	offsets []uint32 // glyph data offsets from the start of the table
+}
+
+type sbixTable struct {
+	Version uint16
+	Flags   [16]bool
+	Strikes []sbixStrike
+
+	data []byte
+}
+
+// Strike returns the index of the strike that best matches the ppem, which is the smallest strike larger or equal to ppem, or otherwise the largest strike.
+func (sbix *sbixTable) Strike(ppem uint16) int {
+	best := -1
+	for i, strike := range sbix.Strikes {
+		if best == -1 || betterStrike(sbix.Strikes[best].PPEM, strike.PPEM, ppem) {
+			best = i
+		}
+	}
+	return best
+}
+
+// betterStrike returns true if a strike of candidate ppem is a better match for ppem than a strike of best ppem, preferring the smallest strike larger or equal to ppem, or otherwise the largest strike.
+func betterStrike(best, candidate, ppem uint16) bool {
+	return best < ppem && best < candidate || ppem <= candidate && candidate < best
+}
+
+// Get returns the glyph's graphic in the given strike, or nil if the glyph has no graphic. Glyphs of graphic type "dupe" are resolved.
+func (sbix *sbixTable) Get(strike int, glyphID uint16) (*sbixGlyph, error) {
+	for i := 0; i < 8; i++ {
+		glyph, err := sbix.get(strike, glyphID)
+		if err != nil || glyph == nil || glyph.GraphicType != "dupe" {
+			return glyph, err
+		} else if len(glyph.Data) < 2 {
+			return nil, fmt.Errorf("sbix: bad glyph %d", glyphID)
+		}
+		glyphID = binary.BigEndian.Uint16(glyph.Data)
+	}
+	return nil, fmt.Errorf("sbix: too many nested dupe glyphs")
+}
+
+func (sbix *sbixTable) get(strike int, glyphID uint16) (*sbixGlyph, error) {
+	if strike < 0 || len(sbix.Strikes) <= strike {
+		return nil, fmt.Errorf("sbix: bad strike %d", strike)
+	}
+	offsets := sbix.Strikes[strike].offsets
+	if len(offsets) <= int(glyphID)+1 {
+		return nil, fmt.Errorf("sbix: bad glyph ID %d", glyphID)
+	} else if offsets[glyphID] == offsets[glyphID+1] {
+		return nil, nil
+	}
+	b := sbix.data[offsets[glyphID]:offsets[glyphID+1]]
+	r := parse.NewBinaryReaderBytes(b)
+	glyph := &sbixGlyph{}
+	glyph.OriginOffsetX = r.ReadInt16()
+	glyph.OriginOffsetY = r.ReadInt16()
+	glyph.GraphicType = r.ReadString(4)
+	glyph.Data = b[8:]
+	return glyph, nil
+}
+
+// Dependencies returns the glyph IDs referenced by the glyph's graphics of type "dupe".
+func (sbix *sbixTable) Dependencies(glyphID uint16) ([]uint16, error) {
+	deps := []uint16{}
+	for i := range sbix.Strikes {
+		glyph, err := sbix.get(i, glyphID)
+		if err != nil {
+			return nil, err
+		} else if glyph != nil && glyph.GraphicType == "dupe" {
+			if len(glyph.Data) < 2 {
+				return nil, fmt.Errorf("sbix: bad glyph %d", glyphID)
+			}
+			deps = append(deps, binary.BigEndian.Uint16(glyph.Data))
+		}
+	}
+	return deps, nil
+}
+
+func (sfnt *SFNT) parseSbix() error {
+	b, ok := sfnt.Tables["sbix"]
+	if !ok {
+		return fmt.Errorf("sbix: missing table")
+	} else if len(b) < 8 {
+		return fmt.Errorf("sbix: bad table")
+	} else if sfnt.Maxp == nil {
+		return fmt.Errorf("sbix: missing maxp table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	sfnt.Sbix = &sbixTable{
+		data: b,
+	}
+	sfnt.Sbix.Version = r.ReadUint16()
+	sfnt.Sbix.Flags = Uint16ToFlags(r.ReadUint16())
+	numStrikes := r.ReadUint32()
+	if sfnt.Sbix.Version != 1 {
+		return fmt.Errorf("sbix: bad version")
+	} else if uint32(r.Len())/4 < numStrikes {
+		return fmt.Errorf("sbix: bad table")
+	}
+
+	numGlyphs := uint32(sfnt.Maxp.NumGlyphs)
+	sfnt.Sbix.Strikes = make([]sbixStrike, numStrikes)
+	for i := range sfnt.Sbix.Strikes {
+		strikeOffset := r.ReadUint32()
+		if uint32(len(b))-4 < strikeOffset || (uint32(len(b))-strikeOffset-4)/4 < numGlyphs+1 {
+			return fmt.Errorf("sbix: bad strike %d", i)
+		}
+
+		strike := &sfnt.Sbix.Strikes[i]
+		rStrike := parse.NewBinaryReaderBytes(b[strikeOffset:])
+		strike.PPEM = rStrike.ReadUint16()
+		strike.PPI = rStrike.ReadUint16()
+		strike.offsets = make([]uint32, numGlyphs+1)
+		for j := range strike.offsets {
+			offset := rStrike.ReadUint32()
+			if uint32(len(b))-strikeOffset < offset {
+				return fmt.Errorf("sbix: bad glyph data offset in strike %d", i)
+			}
+			strike.offsets[j] = strikeOffset + offset
+			if 0 < j && strike.offsets[j] < strike.offsets[j-1] {
+				return fmt.Errorf("sbix: glyph data offsets must be sorted in strike %d", i)
+			} else if 0 < j && strike.offsets[j] != strike.offsets[j-1] && strike.offsets[j]-strike.offsets[j-1] < 8 {
+				return fmt.Errorf("sbix: bad glyph data in strike %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+// subset rewrites the sbix table for the given glyph IDs, which become the new glyph IDs in order.
+func (sbix *sbixTable) subset(glyphIDs []uint16, glyphMap map[uint16]uint16) ([]byte, error) {
+	w := parse.NewBinaryWriter([]byte{})
+	w.WriteUint16(sbix.Version)
+	w.WriteUint16(flagsToUint16(sbix.Flags))
+	w.WriteUint32(uint32(len(sbix.Strikes)))
+	w.WriteBytes(make([]byte, 4*len(sbix.Strikes))) // strike offsets, written below
+	for i, strike := range sbix.Strikes {
+		strikeOffset := uint32(w.Len())
+		binary.BigEndian.PutUint32(w.Bytes()[8+4*i:], strikeOffset)
+
+		w.WriteUint16(strike.PPEM)
+		w.WriteUint16(strike.PPI)
+		offsetsPos := w.Len()
+		w.WriteBytes(make([]byte, 4*(len(glyphIDs)+1))) // glyph data offsets, written below
+		offsets := make([]uint32, len(glyphIDs)+1)
+		offsets[0] = uint32(w.Len()) - strikeOffset
+		for subsetGlyphID, glyphID := range glyphIDs {
+			glyph, err := sbix.get(i, glyphID)
+			if err != nil {
+				return nil, err
+			} else if glyph != nil {
+				w.WriteInt16(glyph.OriginOffsetX)
+				w.WriteInt16(glyph.OriginOffsetY)
+				w.WriteString(glyph.GraphicType)
+				if glyph.GraphicType == "dupe" && 2 <= len(glyph.Data) {
+					w.WriteUint16(glyphMap[binary.BigEndian.Uint16(glyph.Data)])
+				} else {
+					w.WriteBytes(glyph.Data)
+				}
+			}
+			offsets[subsetGlyphID+1] = uint32(w.Len()) - strikeOffset
+		}
+		for j, offset := range offsets {
+			binary.BigEndian.PutUint32(w.Bytes()[offsetsPos+4*int64(j):], offset)
+		}
+	}
+	return w.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Paint is a node of the paint graph of a COLR color glyph, it is one of *PaintLayers, *PaintSolid, *PaintLinearGradient, *PaintRadialGradient, *PaintSweepGradient, *PaintGlyph, *PaintTransform, or *PaintComposite. Coordinates are in font units with the y-axis pointing upwards.
+type Paint interface {
+	isPaint()
+}
+
+// PaintLayers paints its layers in order from bottom to top.
+type PaintLayers struct {
+	Layers []Paint
+}
+
+// PaintSolid fills with a palette color. The palette index 0xFFFF denotes the foreground color.
+type PaintSolid struct {
+	PaletteIndex uint16
+	Alpha        float64
+}
+
+// ColorStop is a color stop of a gradient's color line.
+type ColorStop struct {
+	Offset       float64
+	PaletteIndex uint16
+	Alpha        float64
+}
+
+// Extend is the extend mode of a gradient's color line outside of the interval [0,1].
+type Extend uint8
+
+// see Extend
+const (
+	ExtendPad Extend = iota
+	ExtendRepeat
+	ExtendReflect
+)
+
+// ColorLine is the color line of a gradient.
+type ColorLine struct {
+	Extend Extend
+	Stops  []ColorStop
+}
+
+// PaintLinearGradient fills with a linear gradient from P0 to P1, rotated so that its color lines are parallel to P0-P2.
+type PaintLinearGradient struct {
+	ColorLine
+	X0, Y0, X1, Y1, X2, Y2 float64
+}
+
+// PaintRadialGradient fills with a radial gradient between two circles.
+type PaintRadialGradient struct {
+	ColorLine
+	X0, Y0, R0, X1, Y1, R1 float64
+}
+
+// PaintSweepGradient fills with a sweep gradient around a center, with angles in counter-clockwise degrees.
+type PaintSweepGradient struct {
+	ColorLine
+	X, Y                 float64
+	StartAngle, EndAngle float64
+}
+
+// PaintGlyph fills the outline of a glyph with a paint.
+type PaintGlyph struct {
+	GlyphID uint16
+	Paint   Paint
+}
+
+// PaintTransform transforms a paint using the affine matrix [XX XY DX; YX YY DY].
+type PaintTransform struct {
+	XX, YX, XY, YY, DX, DY float64
+	Paint                  Paint
+}
+
+// PaintComposite composites a source paint onto a backdrop paint. The composite mode is defined by the COLR specification, e.g. 3 is source-over.
+type PaintComposite struct {
+	Source   Paint
+	Mode     uint8
+	Backdrop Paint
+}
+
+func (*PaintLayers) isPaint()         {}
+func (*PaintSolid) isPaint()          {}
+func (*PaintLinearGradient) isPaint() {}
+func (*PaintRadialGradient) isPaint() {}
+func (*PaintSweepGradient) isPaint()  {}
+func (*PaintGlyph) isPaint()          {}
+func (*PaintTransform) isPaint()      {}
+func (*PaintComposite) isPaint()      {}
+
+const maxPaintDepth = 64
+
+// colorLine parses a (variable) color line at offset.
+func (colr *colrTable) colorLine(offset uint32, variable bool) (ColorLine, error) {
+	stopLength := uint32(6)
+	if variable {
+		stopLength = 10
+	}
+	if uint32(len(colr.data)) < offset || uint32(len(colr.data))-offset < 3 {
+		return ColorLine{}, fmt.Errorf("COLR: bad color line")
+	}
+	r := parse.NewBinaryReaderBytes(colr.data[offset:])
+	colorLine := ColorLine{}
+	colorLine.Extend = Extend(r.ReadUint8())
+	numStops := uint32(r.ReadUint16())
+	if uint32(r.Len())/stopLength < numStops {
+		return ColorLine{}, fmt.Errorf("COLR: bad color line")
+	}
+	colorLine.Stops = make([]ColorStop, numStops)
+	for i := range colorLine.Stops {
+		colorLine.Stops[i].Offset = f2dot14(r.ReadInt16())
+		colorLine.Stops[i].PaletteIndex = r.ReadUint16()
+		colorLine.Stops[i].Alpha = f2dot14(r.ReadInt16())
+		if variable {
+			_ = r.ReadUint32() // varIndexBase
+		}
+	}
+	sort.SliceStable(colorLine.Stops, func(i, j int) bool { return colorLine.Stops[i].Offset < colorLine.Stops[j].Offset })
+	return colorLine, nil
+}
+
+// paint parses the paint at offset and its children. Variable paints use their default values.
+func (colr *colrTable) paint(offset uint32, depth int) (Paint, error) {
+	if maxPaintDepth < depth {
+		return nil, fmt.Errorf("COLR: paint graph too deep")
+	} else if uint32(len(colr.data)) <= offset {
+		return nil, fmt.Errorf("COLR: bad paint offset")
+	}
+
+	format := colr.data[offset]
+	variable := 2 < format && format < 32 && format%2 == 1 && format != 11
+	if variable {
+		format--
+	}
+	minLength := map[uint8]uint32{
+		1: 6, 2: 5, 4: 16, 6: 16, 8: 12, 10: 6, 11: 3, 12: 7, 14: 8, 16: 8,
+		18: 12, 20: 6, 22: 10, 24: 6, 26: 10, 28: 8, 30: 12, 32: 8,
+	}[format]
+	if minLength == 0 {
+		return nil, fmt.Errorf("COLR: unsupported paint format %d", colr.data[offset])
+	} else if uint32(len(colr.data))-offset < minLength {
+		return nil, fmt.Errorf("COLR: bad paint")
+	}
+
+	r := parse.NewBinaryReaderBytes(colr.data[offset+1:])
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := uint32(r.ReadUint8())
+		firstLayerIndex := r.ReadUint32()
+		if uint32(len(colr.LayerPaints)) < firstLayerIndex || uint32(len(colr.LayerPaints))-firstLayerIndex < numLayers {
+			return nil, fmt.Errorf("COLR: bad layer index")
+		}
+		layers := &PaintLayers{
+			Layers: make([]Paint, numLayers),
+		}
+		for i := range layers.Layers {
+			var err error
+			if layers.Layers[i], err = colr.paint(colr.LayerPaints[firstLayerIndex+uint32(i)], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return layers, nil
+	case 2: // PaintSolid
+		return &PaintSolid{
+			PaletteIndex: r.ReadUint16(),
+			Alpha:        f2dot14(r.ReadInt16()),
+		}, nil
+	case 4, 6, 8: // PaintLinearGradient, PaintRadialGradient, PaintSweepGradient
+		colorLine, err := colr.colorLine(offset+r.ReadUint24(), variable)
+		if err != nil {
+			return nil, err
+		}
+		if format == 4 {
+			return &PaintLinearGradient{
+				ColorLine: colorLine,
+				X0:        float64(r.ReadInt16()),
+				Y0:        float64(r.ReadInt16()),
+				X1:        float64(r.ReadInt16()),
+				Y1:        float64(r.ReadInt16()),
+				X2:        float64(r.ReadInt16()),
+				Y2:        float64(r.ReadInt16()),
+			}, nil
+		} else if format == 6 {
+			return &PaintRadialGradient{
+				ColorLine: colorLine,
+				X0:        float64(r.ReadInt16()),
+				Y0:        float64(r.ReadInt16()),
+				R0:        float64(r.ReadUint16()),
+				X1:        float64(r.ReadInt16()),
+				Y1:        float64(r.ReadInt16()),
+				R1:        float64(r.ReadUint16()),
+			}, nil
+		}
+		return &PaintSweepGradient{
+			ColorLine:  colorLine,
+			X:          float64(r.ReadInt16()),
+			Y:          float64(r.ReadInt16()),
+			StartAngle: 180.0 * f2dot14(r.ReadInt16()),
+			EndAngle:   180.0 * f2dot14(r.ReadInt16()),
+		}, nil
+	case 10: // PaintGlyph
+		paintOffset := offset + r.ReadUint24()
+		glyphID := r.ReadUint16()
+		paint, err := colr.paint(paintOffset, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &PaintGlyph{
+			GlyphID: glyphID,
+			Paint:   paint,
+		}, nil
+	case 11: // PaintColrGlyph
+		glyphID := r.ReadUint16()
+		paintOffset, ok := colr.GetPaint(glyphID)
+		if !ok {
+			return nil, fmt.Errorf("COLR: missing base glyph paint for glyph %d", glyphID)
+		}
+		return colr.paint(paintOffset, depth+1)
+	case 32: // PaintComposite
+		sourceOffset := offset + r.ReadUint24()
+		mode := r.ReadUint8()
+		backdropOffset := offset + r.ReadUint24()
+		source, err := colr.paint(sourceOffset, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		backdrop, err := colr.paint(backdropOffset, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &PaintComposite{
+			Source:   source,
+			Mode:     mode,
+			Backdrop: backdrop,
+		}, nil
+	}
+
+	// transformations
+	paint, err := colr.paint(offset+r.ReadUint24(), depth+1)
+	if err != nil {
+		return nil, err
+	}
+	t := &PaintTransform{XX: 1.0, YY: 1.0, Paint: paint}
+	switch format {
+	case 12: // PaintTransform
+		transformOffset := offset + r.ReadUint24()
+		if uint32(len(colr.data)) < transformOffset || uint32(len(colr.data))-transformOffset < 24 {
+			return nil, fmt.Errorf("COLR: bad transform")
+		}
+		rTransform := parse.NewBinaryReaderBytes(colr.data[transformOffset:])
+		t.XX = fixed16dot16(rTransform.ReadInt32())
+		t.YX = fixed16dot16(rTransform.ReadInt32())
+		t.XY = fixed16dot16(rTransform.ReadInt32())
+		t.YY = fixed16dot16(rTransform.ReadInt32())
+		t.DX = fixed16dot16(rTransform.ReadInt32())
+		t.DY = fixed16dot16(rTransform.ReadInt32())
+		return t, nil
+	case 14: // PaintTranslate
+		t.DX = float64(r.ReadInt16())
+		t.DY = float64(r.ReadInt16())
+		return t, nil
+	case 16, 18: // PaintScale, PaintScaleAroundCenter
+		t.XX = f2dot14(r.ReadInt16())
+		t.YY = f2dot14(r.ReadInt16())
+	case 20, 22: // PaintScaleUniform, PaintScaleUniformAroundCenter
+		t.XX = f2dot14(r.ReadInt16())
+		t.YY = t.XX
+	case 24, 26: // PaintRotate, PaintRotateAroundCenter
+		sin, cos := math.Sincos(math.Pi * f2dot14(r.ReadInt16()))
+		t.XX, t.YX, t.XY, t.YY = cos, sin, -sin, cos
+	case 28, 30: // PaintSkew, PaintSkewAroundCenter
+		t.XY = -math.Tan(math.Pi * f2dot14(r.ReadInt16()))
+		t.YX = math.Tan(math.Pi * f2dot14(r.ReadInt16()))
+	}
+	if format == 18 || format == 22 || format == 26 || format == 30 {
+		// around center
+		cx, cy := float64(r.ReadInt16()), float64(r.ReadInt16())
+		t.DX = cx - t.XX*cx - t.XY*cy
+		t.DY = cy - t.YX*cx - t.YY*cy
+	}
+	return t, nil
+}
+
+func f2dot14(v int16) float64 {
+	return float64(v) / (1 << 14)
+}
+
+func fixed16dot16(v int32) float64 {
+	return float64(v) / (1 << 16)
+}
+
+// GlyphPaint returns the paint graph of a color glyph from the COLR table, or nil if the glyph is not a color glyph. Paints of version 1 take precedence over the layers of version 0, which are returned as layers of glyphs filled with solid colors. Variable paints use their default values.
+func (sfnt *SFNT) GlyphPaint(glyphID uint16) (Paint, error) {
+	if sfnt.Colr == nil {
+		return nil, nil
+	} else if offset, ok := sfnt.Colr.GetPaint(glyphID); ok {
+		return sfnt.Colr.paint(offset, 0)
+	}
+
+	layers := sfnt.Colr.Get(glyphID)
+	if layers == nil {
+		return nil, nil
+	}
+	paint := &PaintLayers{
+		Layers: make([]Paint, len(layers)),
+	}
+	for i, layer := range layers {
+		paint.Layers[i] = &PaintGlyph{
+			GlyphID: layer.GlyphID,
+			Paint: &PaintSolid{
+				PaletteIndex: layer.PaletteIndex,
+				Alpha:        1.0,
+			},
+		}
+	}
+	return paint, nil
+}
+
+// NumPalettes returns the number of color palettes in the CPAL table.
+func (sfnt *SFNT) NumPalettes() int {
+	if sfnt.Cpal == nil {
+		return 0
+	}
+	return len(sfnt.Cpal.Palettes)
+}
+
+// Palette returns the colors of a palette from the CPAL table, or nil if it doesn't exist.
+func (sfnt *SFNT) Palette(index int) []color.NRGBA {
+	if sfnt.Cpal == nil || index < 0 || len(sfnt.Cpal.Palettes) <= index {
+		return nil
+	}
+	return sfnt.Cpal.Palettes[index]
+}
+
+// GlyphSVG returns the (uncompressed) SVG document of a glyph from the SVG table, or nil if the glyph has no SVG document. The document may contain other glyphs, the glyph itself is the element with ID "glyph" followed by the glyph ID.
+func (sfnt *SFNT) GlyphSVG(glyphID uint16) ([]byte, error) {
+	if sfnt.Svg == nil {
+		return nil, nil
+	}
+	return sfnt.Svg.Get(glyphID)
+}
+
+// GlyphImage is an embedded color image of a glyph from the sbix or CBDT table.
+type GlyphImage struct {
+	Format string // either "png ", "jpg ", or "tiff"
+	Data   []byte
+	PPEM   uint16 // ppem of the strike
+	X, Y   int    // offset of the image's bottom-left corner from the glyph origin in strike pixels, with the y-axis pointing upwards
+}
+
+// GlyphImage returns the embedded color image of a glyph from the strike that best matches the ppem, or nil if the glyph has no image. The image needs to be scaled by ppem/PPEM.
+func (sfnt *SFNT) GlyphImage(glyphID, ppem uint16) (*GlyphImage, error) {
+	if sfnt.Sbix != nil {
+		if strike := sfnt.Sbix.Strike(ppem); strike != -1 {
+			glyph, err := sfnt.Sbix.Get(strike, glyphID)
+			if err != nil {
+				return nil, err
+			} else if glyph != nil {
+				return &GlyphImage{
+					Format: glyph.GraphicType,
+					Data:   glyph.Data,
+					PPEM:   sfnt.Sbix.Strikes[strike].PPEM,
+					X:      int(glyph.OriginOffsetX),
+					Y:      int(glyph.OriginOffsetY),
+				}, nil
+			}
+		}
+	}
+	if sfnt.Cblc != nil && sfnt.Cbdt != nil {
+		var best *eblcStrike
+		for i, strike := range sfnt.Cblc.Strikes {
+			if _, _, _, ok := strike.Get(glyphID); !ok {
+				continue
+			} else if best == nil || betterStrike(uint16(best.PpemY), uint16(strike.PpemY), ppem) {
+				best = &sfnt.Cblc.Strikes[i]
+			}
+		}
+		if best != nil {
+			subtable, offset, length, _ := best.Get(glyphID)
+			glyph, err := sfnt.Cbdt.Get(subtable, offset, length)
+			if err != nil {
+				return nil, err
+			} else if glyph.PNG {
+				return &GlyphImage{
+					Format: "png ",
+					Data:   glyph.Data,
+					PPEM:   uint16(best.PpemY),
+					X:      int(glyph.Metrics.HoriBearingX),
+					Y:      int(glyph.Metrics.HoriBearingY) - int(glyph.Metrics.Height),
+				}, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,542 @@
+package font
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// ConvertOptions are the options for converting glyph outlines between TrueType and CFF.
+type ConvertOptions struct {
+	Tolerance    float64 // maximum distance in font units when approximating cubic Béziers by quadratic Béziers for TrueType, defaults to 1
+	Subroutinize bool    // move repeated parts of the charstrings to local subroutines for CFF
+}
+
+// ToTrueType converts the CFF glyph outlines to TrueType glyph outlines in-place by replacing the CFF table by the glyf and loca tables. Cubic Béziers are approximated by quadratic Béziers within the tolerance, and contours are reversed to the clockwise direction used by TrueType. Hints are removed and the glyph names are moved to the post table. The head, hhea, hmtx, maxp, and post tables are updated accordingly. It does nothing for TrueType fonts.
+func (sfnt *SFNT) ToTrueType(options ConvertOptions) error {
+	if sfnt.IsTrueType {
+		return nil
+	} else if _, ok := sfnt.Tables["CFF2"]; ok {
+		// TODO: support converting CFF2
+		return fmt.Errorf("converting CFF2 fonts not supported")
+	} else if !sfnt.IsCFF {
+		return fmt.Errorf("only TrueType and CFF are supported")
+	}
+
+	tolerance := options.Tolerance
+	if tolerance <= 0.0 {
+		tolerance = 1.0
+	}
+
+	numGlyphs := sfnt.NumGlyphs()
+	bounds := make([][4]int16, numGlyphs)
+	hasOutline := make([]bool, numGlyphs)
+	var maxPoints, maxContours uint16
+	glyfOffsets := make([]uint32, numGlyphs+1) // for loca
+	w := parse.NewBinaryWriter([]byte{})
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		p := &outlinePather{}
+		if err := sfnt.CFF.ToPath(p, glyphID, 0, 0.0, 0.0, 1.0, NoHinting); err != nil {
+			return err
+		}
+		for i := range p.contours {
+			p.contours[i].reverse()
+		}
+
+		contour, err := newGlyfContour(glyphID, p.contours, tolerance)
+		if err != nil {
+			return err
+		} else if 0 < len(contour.EndPoints) { // not empty
+			writeGlyfContour(w, contour)
+			hasOutline[glyphID] = true
+			bounds[glyphID] = [4]int16{contour.XMin, contour.YMin, contour.XMax, contour.YMax}
+			maxPoints = max(maxPoints, uint16(len(contour.XCoordinates)))
+			maxContours = max(maxContours, uint16(len(contour.EndPoints)))
+		}
+
+		// padding to ensure glyph offsets are on even bytes for loca short format
+		if w.Len()%2 == 1 {
+			w.WriteByte(0)
+		}
+		glyfOffsets[glyphID+1] = uint32(w.Len())
+	}
+	glyf := w.Bytes()
+	loca, indexToLocFormat := locaWrite(glyfOffsets)
+
+	// glyph names move from the CFF charset to the post table
+	post := &postTable{
+		ItalicAngle:        sfnt.Post.ItalicAngle,
+		UnderlinePosition:  sfnt.Post.UnderlinePosition,
+		UnderlineThickness: sfnt.Post.UnderlineThickness,
+		IsFixedPitch:       sfnt.Post.IsFixedPitch,
+	}
+	if !sfnt.CFF.top.IsCID {
+		names := make([]string, numGlyphs)
+		for glyphID := range names {
+			names[glyphID], _ = sfnt.CFF.GlyphName(uint16(glyphID))
+		}
+		post.setGlyphNames(names)
+	}
+	postData, err := post.Write()
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	maxp := &maxpTable{
+		Version:     0x00010000,
+		NumGlyphs:   numGlyphs,
+		MaxPoints:   maxPoints,
+		MaxContours: maxContours,
+		MaxZones:    2,
+	}
+
+	sfnt.Tables["glyf"] = glyf
+	sfnt.Tables["loca"] = loca
+	sfnt.Tables["maxp"] = maxp.Write()
+	sfnt.Tables["post"] = postData
+	delete(sfnt.Tables, "CFF ")
+	delete(sfnt.Tables, "VORG")
+	sfnt.Version = "\x00\x01\x00\x00"
+	sfnt.IsCFF, sfnt.IsTrueType = false, true
+	sfnt.CFF = nil
+	sfnt.Maxp = maxp
+	sfnt.Post = post
+	sfnt.setOutlineMetrics(bounds, hasOutline, indexToLocFormat)
+	if err := sfnt.parseLoca(); err != nil {
+		return err
+	} else if err := sfnt.parseGlyf(); err != nil {
+		return err
+	}
+	sfnt.autohinterOnce = sync.Once{}
+	sfnt.autohinter = nil
+	return nil
+}
+
+// ToCFF converts the TrueType glyph outlines to CFF glyph outlines in-place by replacing the glyf and loca tables by the CFF table. Quadratic Béziers are raised to cubic Béziers, contours are reversed to the counter-clockwise direction used by CFF, and the charstrings are optionally subroutinized. Instructions are removed together with the cvt, fpgm, prep, hdmx, LTSH, and VDMX tables. The head, hhea, hmtx, maxp, and post tables are updated accordingly, and the glyph names of the post table are moved to the CFF charset. It does nothing for CFF fonts.
+func (sfnt *SFNT) ToCFF(options ConvertOptions) error {
+	if sfnt.IsCFF {
+		return nil
+	} else if !sfnt.IsTrueType {
+		return fmt.Errorf("only TrueType and CFF are supported")
+	}
+
+	// the most common advance width is the default width and is omitted from the charstrings
+	numGlyphs := sfnt.NumGlyphs()
+	advances := make([]uint16, numGlyphs)
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		advances[glyphID] = sfnt.Hmtx.Advance(glyphID)
+	}
+	defaultWidth := cffDefaultWidth(advances)
+
+	bounds := make([][4]int16, numGlyphs)
+	hasOutline := make([]bool, numGlyphs)
+	xMin, yMin, xMax, yMax := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16), int16(math.MinInt16)
+	charStrings := make([][]byte, numGlyphs)
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		p := &outlinePather{}
+		if err := sfnt.Glyf.ToPath(p, glyphID, 0, 0.0, 0.0, 1.0, NoHinting); err != nil {
+			return err
+		}
+
+		w := newCFFCharStringWriter(int32(advances[glyphID]) - int32(defaultWidth))
+		w.hasWidth = advances[glyphID] != defaultWidth
+		for i := range p.contours {
+			if 0 < len(p.contours[i].ops) {
+				p.contours[i].reverse()
+				p.contours[i].draw(w)
+			}
+		}
+		charStrings[glyphID] = w.Bytes()
+
+		if w.XMin <= w.XMax {
+			gxMin, gyMin, gxMax, gyMax := int16(w.XMin), int16(w.YMin), int16(w.XMax), int16(w.YMax)
+			hasOutline[glyphID] = true
+			bounds[glyphID] = [4]int16{gxMin, gyMin, gxMax, gyMax}
+			xMin, yMin = min(xMin, gxMin), min(yMin, gyMin)
+			xMax, yMax = max(xMax, gxMax), max(yMax, gyMax)
+		}
+	}
+	if xMax < xMin {
+		xMin, yMin, xMax, yMax = 0, 0, 0, 0
+	}
+
+	// CFF
+	unitsPerEm := float64(sfnt.Head.UnitsPerEm)
+	version := ""
+	var fontName, notice, copyright, fullName, familyName string
+	if sfnt.Name != nil {
+		fontName = cffFontName(sfnt.Name.Find(NamePostScript))
+		version = strings.TrimPrefix(sfnt.Name.Find(NameVersion), "Version ")
+		notice = sfnt.Name.Find(NameTrademark)
+		copyright = sfnt.Name.Find(NameCopyrightNotice)
+		fullName = sfnt.Name.Find(NameFull)
+		familyName = sfnt.Name.Find(NameFontFamily)
+	}
+	if fontName == "" {
+		fontName = "Untitled"
+	}
+	top := &cffTopDICT{
+		Version:            version,
+		Notice:             notice,
+		Copyright:          copyright,
+		FullName:           fullName,
+		FamilyName:         familyName,
+		IsFixedPitch:       sfnt.Post.IsFixedPitch != 0,
+		ItalicAngle:        sfnt.Post.ItalicAngle,
+		UnderlinePosition:  float64(sfnt.Post.UnderlinePosition) - float64(sfnt.Post.UnderlineThickness)/2.0,
+		UnderlineThickness: float64(sfnt.Post.UnderlineThickness),
+		CharstringType:     2,
+		FontMatrix:         [6]float64{1.0 / unitsPerEm, 0.0, 0.0, 1.0 / unitsPerEm, 0.0, 0.0},
+		FontBBox:           [4]float64{float64(xMin), float64(yMin), float64(xMax), float64(yMax)},
+	}
+	names := make([]string, numGlyphs)
+	for glyphID := range names {
+		names[glyphID] = sfnt.Post.Get(uint16(glyphID))
+	}
+	names = uniqueGlyphNames(names, sfnt.GlyphToUnicode)
+	cff := newCFFTable(fontName, top, names, charStrings, defaultWidth, options.Subroutinize)
+	cffData, err := cff.Write()
+	if err != nil {
+		return fmt.Errorf("CFF: %w", err)
+	}
+
+	// post without glyph names
+	post := &postTable{
+		ItalicAngle:        sfnt.Post.ItalicAngle,
+		UnderlinePosition:  sfnt.Post.UnderlinePosition,
+		UnderlineThickness: sfnt.Post.UnderlineThickness,
+		IsFixedPitch:       sfnt.Post.IsFixedPitch,
+	}
+	postData, err := post.Write()
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	maxp := &maxpTable{
+		Version:   0x00005000,
+		NumGlyphs: numGlyphs,
+	}
+
+	sfnt.Tables["CFF "] = cffData
+	sfnt.Tables["maxp"] = maxp.Write()
+	sfnt.Tables["post"] = postData
+	for _, tag := range []string{"glyf", "loca", "cvt ", "fpgm", "prep", "hdmx", "LTSH", "VDMX"} {
+		delete(sfnt.Tables, tag)
+	}
+	sfnt.Version = "OTTO"
+	sfnt.IsCFF, sfnt.IsTrueType = true, false
+	sfnt.Glyf = nil
+	sfnt.Loca = nil
+	sfnt.Hdmx = nil
+	sfnt.Ltsh = nil
+	sfnt.Vdmx = nil
+	sfnt.Maxp = maxp
+	sfnt.Post = post
+	sfnt.setOutlineMetrics(bounds, hasOutline, 0)
+	if err := sfnt.parseCFF(); err != nil {
+		return err
+	}
+	sfnt.autohinterOnce = sync.Once{}
+	sfnt.autohinter = nil
+	return nil
+}
+
+// setOutlineMetrics rewrites the hmtx, hhea, and head tables after converting the glyph outlines. The left side bearings are set to the minimum x of the bounding boxes, and the font's bounding box and extents are recalculated.
+func (sfnt *SFNT) setOutlineMetrics(bounds [][4]int16, hasOutline []bool, indexToLocFormat int16) {
+	numGlyphs := sfnt.NumGlyphs()
+	advances := make([]uint16, numGlyphs)
+	lsbs := make([]int16, numGlyphs)
+	xMin, yMin, xMax, yMax := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16), int16(math.MinInt16)
+	sfnt.Hhea.AdvanceWidthMax = 0
+	sfnt.Hhea.MinLeftSideBearing = math.MaxInt16
+	sfnt.Hhea.MinRightSideBearing = math.MaxInt16
+	sfnt.Hhea.XMaxExtent = math.MinInt16
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		advances[glyphID] = sfnt.Hmtx.Advance(glyphID)
+		sfnt.Hhea.AdvanceWidthMax = max(sfnt.Hhea.AdvanceWidthMax, advances[glyphID])
+		if hasOutline[glyphID] {
+			gxMin, gyMin, gxMax, gyMax := bounds[glyphID][0], bounds[glyphID][1], bounds[glyphID][2], bounds[glyphID][3]
+			lsbs[glyphID] = gxMin
+			xMin, yMin = min(xMin, gxMin), min(yMin, gyMin)
+			xMax, yMax = max(xMax, gxMax), max(yMax, gyMax)
+			sfnt.Hhea.MinLeftSideBearing = min(sfnt.Hhea.MinLeftSideBearing, gxMin)
+			sfnt.Hhea.MinRightSideBearing = min(sfnt.Hhea.MinRightSideBearing, int16(int(advances[glyphID])-int(gxMax)))
+			sfnt.Hhea.XMaxExtent = max(sfnt.Hhea.XMaxExtent, gxMax)
+		}
+	}
+	if xMax < xMin {
+		xMin, yMin, xMax, yMax = 0, 0, 0, 0
+		sfnt.Hhea.MinLeftSideBearing = 0
+		sfnt.Hhea.MinRightSideBearing = 0
+		sfnt.Hhea.XMaxExtent = 0
+	}
+
+	sfnt.Hmtx = newHmtxTable(advances, lsbs)
+	sfnt.Tables["hmtx"] = sfnt.Hmtx.Write()
+
+	sfnt.Hhea.NumberOfHMetrics = uint16(len(sfnt.Hmtx.HMetrics))
+	sfnt.Tables["hhea"] = sfnt.Hhea.Write()
+
+	sfnt.Head.XMin, sfnt.Head.YMin = xMin, yMin
+	sfnt.Head.XMax, sfnt.Head.YMax = xMax, yMax
+	sfnt.Head.Flags[1] = true // left side bearing at x=0
+	sfnt.Head.IndexToLocFormat = indexToLocFormat
+	sfnt.Tables["head"] = sfnt.Head.Write()
+}
+
+// newCFFTable returns a CFF table with a single font without hints. The widths in the charstrings must be relative to the default width, which is used for both defaultWidthX and nominalWidthX. The charstrings are optionally subroutinized.
+func newCFFTable(name string, top *cffTopDICT, charset []string, charStrings [][]byte, defaultWidth uint16, subroutinize bool) *cffTable {
+	var localSubrs [][]byte
+	if subroutinize {
+		charStrings, localSubrs = cffSubroutinize(charStrings)
+	}
+
+	cff := &cffTable{
+		version:     1,
+		name:        name,
+		top:         top,
+		globalSubrs: &cffINDEX{},
+		charset:     charset,
+		charStrings: &cffINDEX{},
+		fonts: &cffFontINDEX{
+			private: []*cffPrivateDICT{{
+				BlueScale:       0.039625,
+				BlueShift:       7.0,
+				BlueFuzz:        1.0,
+				ExpansionFactor: 0.06,
+				DefaultWidthX:   float64(defaultWidth),
+				NominalWidthX:   float64(defaultWidth),
+			}},
+		},
+	}
+	for _, charString := range charStrings {
+		cff.charStrings.Add(charString)
+	}
+	if 0 < len(localSubrs) {
+		subrs := &cffINDEX{}
+		for _, subr := range localSubrs {
+			subrs.Add(subr)
+		}
+		cff.fonts.localSubrs = []*cffINDEX{subrs}
+	}
+	return cff
+}
+
+// newGlyfContour returns a simple TrueType glyph for the contours, where cubic Béziers are approximated by quadratic Béziers within the tolerance. Points are rounded to integers and on-curve points that lie within half a unit of the midpoint between two off-curve points are omitted.
+func newGlyfContour(glyphID uint16, contours []outlineContour, tolerance float64) (*glyfContour, error) {
+	glyph := &glyfContour{
+		GlyphID: glyphID,
+		XMin:    math.MaxInt16,
+		YMin:    math.MaxInt16,
+		XMax:    math.MinInt16,
+		YMax:    math.MinInt16,
+	}
+	round := func(v float64) int16 {
+		return int16(max(math.MinInt16, min(math.MaxInt16, math.Round(v))))
+	}
+	for _, contour := range contours {
+		if len(contour.ops) == 0 {
+			continue
+		}
+
+		var xs, ys []int16
+		var onCurve []bool
+		add := func(point [2]float64, on bool) {
+			x, y := round(point[0]), round(point[1])
+			if n := len(xs); on && 0 < n && onCurve[n-1] && xs[n-1] == x && ys[n-1] == y {
+				return // duplicate point
+			}
+			xs = append(xs, x)
+			ys = append(ys, y)
+			onCurve = append(onCurve, on)
+		}
+
+		start := contour.points[0]
+		add(start, true)
+		points := contour.points[1:]
+		for _, op := range contour.ops {
+			switch op {
+			case 'L':
+				add(points[0], true)
+				start, points = points[0], points[1:]
+			case 'Q':
+				add(points[0], false)
+				add(points[1], true)
+				start, points = points[1], points[2:]
+			case 'C':
+				for _, quad := range cubicToQuads(start, points[0], points[1], points[2], tolerance) {
+					add(quad[0], false)
+					add(quad[1], true)
+				}
+				start, points = points[2], points[3:]
+			}
+		}
+
+		// contours are closed implicitly
+		if n := len(xs); 1 < n && onCurve[n-1] && xs[n-1] == xs[0] && ys[n-1] == ys[0] {
+			xs, ys, onCurve = xs[:n-1], ys[:n-1], onCurve[:n-1]
+		}
+		if len(xs) < 2 {
+			continue
+		}
+
+		n := len(xs)
+		for i := 0; i < n; i++ {
+			prev, next := (i+n-1)%n, (i+1)%n
+			if 0 < i && onCurve[i] && !onCurve[prev] && !onCurve[next] {
+				// on-curve point is implied when it is within rounding of the midpoint of its neighbours
+				dx := 2*int32(xs[i]) - int32(xs[prev]) - int32(xs[next])
+				dy := 2*int32(ys[i]) - int32(ys[prev]) - int32(ys[next])
+				if -1 <= dx && dx <= 1 && -1 <= dy && dy <= 1 {
+					continue
+				}
+			}
+			glyph.XCoordinates = append(glyph.XCoordinates, xs[i])
+			glyph.YCoordinates = append(glyph.YCoordinates, ys[i])
+			glyph.OnCurve = append(glyph.OnCurve, onCurve[i])
+			glyph.XMin, glyph.YMin = min(glyph.XMin, xs[i]), min(glyph.YMin, ys[i])
+			glyph.XMax, glyph.YMax = max(glyph.XMax, xs[i]), max(glyph.YMax, ys[i])
+		}
+		if math.MaxUint16 < len(glyph.XCoordinates) {
+			return nil, fmt.Errorf("glyf: glyph %d has too many points", glyphID)
+		}
+		glyph.EndPoints = append(glyph.EndPoints, uint16(len(glyph.XCoordinates)-1))
+	}
+	glyph.OverlapSimple = make([]bool, len(glyph.XCoordinates))
+	return glyph, nil
+}
+
+// cubicToQuads approximates a cubic Bézier by quadratic Béziers that deviate at most the tolerance. It returns the control point and end point of each quadratic Bézier.
+func cubicToQuads(p0, p1, p2, p3 [2]float64, tolerance float64) [][2][2]float64 {
+	// a single quadratic Bézier with control point (3*(p1+p2)-p0-p3)/4 deviates at most sqrt(3)/36*|p3-3*p2+3*p1-p0|, splitting into n parts reduces the deviation by n^3
+	dx := p3[0] - 3.0*p2[0] + 3.0*p1[0] - p0[0]
+	dy := p3[1] - 3.0*p2[1] + 3.0*p1[1] - p0[1]
+	n := int(math.Ceil(math.Cbrt(math.Sqrt(3.0) / 36.0 * math.Hypot(dx, dy) / tolerance)))
+	n = max(1, min(64, n))
+
+	point := func(t float64) [2]float64 {
+		s := 1.0 - t
+		a, b, c, d := s*s*s, 3.0*s*s*t, 3.0*s*t*t, t*t*t
+		return [2]float64{a*p0[0] + b*p1[0] + c*p2[0] + d*p3[0], a*p0[1] + b*p1[1] + c*p2[1] + d*p3[1]}
+	}
+	derivative := func(t float64) [2]float64 {
+		s := 1.0 - t
+		a, b, c := 3.0*s*s, 6.0*s*t, 3.0*t*t
+		return [2]float64{a*(p1[0]-p0[0]) + b*(p2[0]-p1[0]) + c*(p3[0]-p2[0]), a*(p1[1]-p0[1]) + b*(p2[1]-p1[1]) + c*(p3[1]-p2[1])}
+	}
+
+	quads := make([][2][2]float64, 0, n)
+	q0, d0 := p0, derivative(0.0)
+	h := 1.0 / (3.0 * float64(n))
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		q3, d3 := point(t), derivative(t)
+		if i == n {
+			q3 = p3
+		}
+		q1 := [2]float64{q0[0] + h*d0[0], q0[1] + h*d0[1]}
+		q2 := [2]float64{q3[0] - h*d3[0], q3[1] - h*d3[1]}
+		control := [2]float64{(3.0*(q1[0]+q2[0]) - q0[0] - q3[0]) / 4.0, (3.0*(q1[1]+q2[1]) - q0[1] - q3[1]) / 4.0}
+		quads = append(quads, [2][2]float64{control, q3})
+		q0, d0 = q3, d3
+	}
+	return quads
+}
+
+// setGlyphNames sets the glyph names of a version 2 post table, using the standard Macintosh glyph names where possible. Glyph names are removed when any name is empty or too long.
+func (post *postTable) setGlyphNames(names []string) {
+	post.NumGlyphs = 0
+	post.glyphNameIndex = nil
+	post.stringData = nil
+	post.nameMap = nil
+	post.once = sync.Once{}
+	if len(names) == 0 || math.MaxUint16 < len(names) {
+		return
+	}
+
+	macintoshIndex := make(map[string]uint16, len(macintoshGlyphNames))
+	for index, name := range macintoshGlyphNames {
+		macintoshIndex[name] = uint16(index)
+	}
+	stringIndex := map[string]uint16{}
+	glyphNameIndex := make([]uint16, len(names))
+	stringData := [][]byte{}
+	for glyphID, name := range names {
+		if name == "" || 63 < len(name) {
+			return
+		} else if index, ok := macintoshIndex[name]; ok {
+			glyphNameIndex[glyphID] = index
+		} else if index, ok := stringIndex[name]; ok {
+			glyphNameIndex[glyphID] = index
+		} else if math.MaxUint16-258 < len(stringData) {
+			return
+		} else {
+			index := uint16(258 + len(stringData))
+			stringIndex[name] = index
+			stringData = append(stringData, []byte(name))
+			glyphNameIndex[glyphID] = index
+		}
+	}
+	post.NumGlyphs = uint16(len(names))
+	post.glyphNameIndex = glyphNameIndex
+	post.stringData = stringData
+}
+
+// uniqueGlyphNames returns valid and unique glyph names for use in the CFF charset or post table. Invalid or duplicate names are replaced by a name derived from the glyph's first Unicode code point, such as uni0041, or otherwise by a name such as glyph12. The first glyph is always named .notdef.
+func uniqueGlyphNames(names []string, unicode func(uint16) []rune) []string {
+	uniqueNames := make([]string, len(names))
+	used := make(map[string]bool, len(names))
+	for i, name := range names {
+		glyphID := uint16(i)
+		if glyphID == 0 {
+			name = ".notdef"
+		} else if !isGlyphName(name) || used[name] {
+			name = ""
+			if rs := unicode(glyphID); len(rs) != 0 && rs[0] <= 0xFFFF {
+				name = fmt.Sprintf("uni%04X", rs[0])
+			} else if len(rs) != 0 {
+				name = fmt.Sprintf("u%X", rs[0])
+			}
+			if name == "" || used[name] {
+				name = fmt.Sprintf("glyph%d", glyphID)
+				for j := 1; used[name]; j++ {
+					name = fmt.Sprintf("glyph%d.%d", glyphID, j)
+				}
+			}
+		}
+		uniqueNames[i] = name
+		used[name] = true
+	}
+	return uniqueNames
+}
+
+// isGlyphName returns true if the name is a valid PostScript glyph name, which consists of up to 63 letters, digits, periods, and underscores, and does not start with a digit or period.
+func isGlyphName(name string) bool {
+	if name == "" || 63 < len(name) || '0' <= name[0] && name[0] <= '9' || name[0] == '.' {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// cffFontName returns the PostScript font name with invalid characters removed, which are spaces, non-ASCII characters, and the characters [](){}<>/%. It is truncated to 63 characters.
+func cffFontName(name string) string {
+	var sb strings.Builder
+	for _, c := range []byte(name) {
+		if 33 <= c && c <= 126 && strings.IndexByte("[](){}<>/%", c) == -1 {
+			sb.WriteByte(c)
+		}
+	}
+	name = sb.String()
+	if 63 < len(name) {
+		name = name[:63]
+	}
+	return name
+}
@@ -115,6 +115,7 @@ type FeatureTag string
 
 const (
 	UnknownFeature = FeatureTag("")
+	KernFeature    = FeatureTag("kern")
 
 // TODO: add features
 )
@@ -0,0 +1,1938 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// TrueType bytecode interpreter, see https://learn.microsoft.com/en-us/typography/opentype/spec/tt_instructions
+// Coordinates and distances are in 26.6 fixed-point pixels, vectors are unit vectors in 2.14 fixed-point.
+
+const ttMaxCallDepth = 64       // maximum depth of nested function calls
+const ttMaxSteps = 1000000      // maximum number of instructions executed per program
+const ttMaxTwilightPoints = 512 // limit on maxp's maxTwilightPoints
+const ttMaxStackElements = 4096 // limit on maxp's maxStackElements
+
+type ttVector struct {
+	X, Y int32
+}
+
+type ttPoint struct {
+	X, Y int32
+}
+
+type ttZone struct {
+	cur, orig          []ttPoint
+	touchedX, touchedY []bool
+	onCurve            []bool
+	endPoints          []uint16
+}
+
+func newTTZone(n int) *ttZone {
+	return &ttZone{
+		cur:      make([]ttPoint, n),
+		orig:     make([]ttPoint, n),
+		touchedX: make([]bool, n),
+		touchedY: make([]bool, n),
+		onCurve:  make([]bool, n),
+	}
+}
+
+func (z *ttZone) copy() *ttZone {
+	return &ttZone{
+		cur:       append([]ttPoint{}, z.cur...),
+		orig:      append([]ttPoint{}, z.orig...),
+		touchedX:  append([]bool{}, z.touchedX...),
+		touchedY:  append([]bool{}, z.touchedY...),
+		onCurve:   append([]bool{}, z.onCurve...),
+		endPoints: append([]uint16{}, z.endPoints...),
+	}
+}
+
+func (z *ttZone) valid(i int32) bool {
+	return 0 <= i && int(i) < len(z.cur)
+}
+
+// see ttGraphicsState.round
+const (
+	ttRoundToGrid uint8 = iota
+	ttRoundToHalfGrid
+	ttRoundToDoubleGrid
+	ttRoundDownToGrid
+	ttRoundUpToGrid
+	ttRoundOff
+	ttRoundSuper
+	ttRoundSuper45
+)
+
+type ttGraphicsState struct {
+	pv, fv, dv                 ttVector // projection, freedom, and dual projection vectors
+	rp                         [3]int32 // reference points
+	zp                         [3]int32 // zone pointers
+	loop                       int32
+	minDist                    int32
+	round                      uint8
+	period, phase, threshold   int32 // super rounding
+	cvtCutIn, swCutIn, swValue int32
+	deltaBase, deltaShift      int32
+	autoFlip                   bool
+	instructControl            int32
+}
+
+var ttDefaultGraphicsState = ttGraphicsState{
+	pv:         ttVector{0x4000, 0},
+	fv:         ttVector{0x4000, 0},
+	dv:         ttVector{0x4000, 0},
+	zp:         [3]int32{1, 1, 1},
+	loop:       1,
+	minDist:    64,
+	round:      ttRoundToGrid,
+	cvtCutIn:   68, // 17/16 pixels
+	deltaBase:  9,
+	deltaShift: 3,
+	autoFlip:   true,
+}
+
+// ttHinter hints TrueType glyphs by running the font program (fpgm), the control value program (prep), and the glyph instructions. The state after running the font and control value programs is cached for the last used ppem.
+type ttHinter struct {
+	sync.Mutex
+	sfnt       *SFNT
+	init       bool
+	unitsPerEm uint16
+
+	fpgm, prep  []byte
+	cvt         []int16 // unscaled control values
+	maxStorage  int
+	maxStack    int
+	maxTwilight int
+
+	// state after running fpgm and prep
+	ppem      uint16
+	hinting   Hinting
+	err       error
+	functions map[int32][]byte
+	idefs     map[uint8][]byte
+	gs        ttGraphicsState
+	scaledCvt []int32
+	storage   []int32
+	twilight  *ttZone
+}
+
+func newTTHinter(sfnt *SFNT) *ttHinter {
+	return &ttHinter{
+		sfnt: sfnt,
+	}
+}
+
+func (h *ttHinter) scale(v int32) int32 {
+	return ttMulDiv(int64(v), 64*int64(h.ppem), int64(h.unitsPerEm))
+}
+
+// setup runs the font and control value programs for the ppem, unless they ran already for that ppem.
+func (h *ttHinter) setup(ppem uint16, hinting Hinting) error {
+	if !h.init {
+		h.init = true
+		if h.sfnt.Head == nil || h.sfnt.Maxp == nil || h.sfnt.Head.UnitsPerEm == 0 {
+			h.err = fmt.Errorf("glyf: missing head or maxp table")
+			return h.err
+		}
+		h.unitsPerEm = h.sfnt.Head.UnitsPerEm
+		h.fpgm = h.sfnt.Tables["fpgm"]
+		h.prep = h.sfnt.Tables["prep"]
+		cvt := h.sfnt.Tables["cvt "]
+		h.cvt = make([]int16, len(cvt)/2)
+		for i := range h.cvt {
+			h.cvt[i] = int16(binary.BigEndian.Uint16(cvt[2*i:]))
+		}
+		h.maxStorage = int(h.sfnt.Maxp.MaxStorage)
+		h.maxStack = min(int(h.sfnt.Maxp.MaxStackElements)+32, ttMaxStackElements) // some fonts underestimate
+		h.maxTwilight = min(int(h.sfnt.Maxp.MaxTwilightPoints), ttMaxTwilightPoints)
+	} else if h.ppem == ppem && h.hinting == hinting {
+		return h.err
+	}
+	h.ppem = ppem
+	h.hinting = hinting
+	h.err = nil
+
+	h.functions = map[int32][]byte{}
+	h.idefs = map[uint8][]byte{}
+	h.scaledCvt = make([]int32, len(h.cvt))
+	for i, v := range h.cvt {
+		h.scaledCvt[i] = h.scale(int32(v))
+	}
+	h.storage = make([]int32, h.maxStorage)
+	h.twilight = newTTZone(h.maxTwilight)
+
+	c := h.newContext(newTTZone(0), ttDefaultGraphicsState)
+	if len(h.fpgm) != 0 {
+		c.program = ttProgramFont
+		if err := c.run(h.fpgm, 0); err != nil {
+			h.err = fmt.Errorf("fpgm: %v", err)
+			return h.err
+		}
+	}
+
+	c.gs = ttDefaultGraphicsState
+	if len(h.prep) != 0 {
+		c.program = ttProgramControlValue
+		if err := c.run(h.prep, 0); err != nil {
+			h.err = fmt.Errorf("prep: %v", err)
+			return h.err
+		}
+	}
+	h.gs = c.gs
+	return nil
+}
+
+// Hint returns the hinted points of a glyph, including the four phantom points.
+func (h *ttHinter) Hint(glyphID, ppem uint16, hinting Hinting) (*ttZone, error) {
+	h.Lock()
+	defer h.Unlock()
+	if err := h.setup(ppem, hinting); err != nil {
+		return nil, err
+	}
+	return h.glyph(glyphID, 0)
+}
+
+// phantom returns the unscaled phantom points of a glyph: the horizontal origin and advance, and the vertical origin and advance.
+func (h *ttHinter) phantom(glyphID uint16, xMin, yMax int16) [4]ttPoint {
+	var lsb, advance int32
+	if h.sfnt.Hmtx != nil && 0 < len(h.sfnt.Hmtx.HMetrics) {
+		lsb = int32(h.sfnt.Hmtx.LeftSideBearing(glyphID))
+		advance = int32(h.sfnt.Hmtx.Advance(glyphID))
+	}
+	var tsb, vertAdvance int32
+	if h.sfnt.Vmtx != nil && 0 < len(h.sfnt.Vmtx.VMetrics) {
+		tsb = int32(h.sfnt.Vmtx.TopSideBearing(glyphID))
+		vertAdvance = int32(h.sfnt.Vmtx.Advance(glyphID))
+	} else if h.sfnt.Hhea != nil {
+		tsb = int32(h.sfnt.Hhea.Ascender) - int32(yMax)
+		vertAdvance = int32(h.sfnt.Hhea.Ascender) - int32(h.sfnt.Hhea.Descender)
+	}
+	x := int32(xMin) - lsb
+	y := int32(yMax) + tsb
+	return [4]ttPoint{{x, 0}, {x + advance, 0}, {0, y}, {0, y - vertAdvance}}
+}
+
+// glyph loads and hints a glyph, composite glyphs hint their components first.
+func (h *ttHinter) glyph(glyphID uint16, level int) (*ttZone, error) {
+	b := h.sfnt.Glyf.Get(glyphID)
+	if b == nil {
+		return nil, fmt.Errorf("glyf: bad glyphID %v", glyphID)
+	} else if len(b) == 0 {
+		zone := newTTZone(4)
+		h.setPhantom(zone, 0, h.phantom(glyphID, 0, 0))
+		return zone, nil
+	} else if len(b) < 10 {
+		return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+	}
+	numberOfContours := int16(binary.BigEndian.Uint16(b))
+	xMin := int16(binary.BigEndian.Uint16(b[2:]))
+	yMax := int16(binary.BigEndian.Uint16(b[8:]))
+	phantom := h.phantom(glyphID, xMin, yMax)
+
+	if 0 <= numberOfContours {
+		contour, err := h.sfnt.Glyf.contour(glyphID, level)
+		if err != nil {
+			return nil, err
+		}
+		n := len(contour.XCoordinates)
+		zone := newTTZone(n + 4)
+		zone.endPoints = contour.EndPoints
+		copy(zone.onCurve, contour.OnCurve)
+		for i := 0; i < n; i++ {
+			zone.orig[i] = ttPoint{h.scale(int32(contour.XCoordinates[i])), h.scale(int32(contour.YCoordinates[i]))}
+		}
+		h.setPhantom(zone, n, phantom)
+		copy(zone.cur, zone.orig)
+		h.roundPhantom(zone)
+		h.runGlyph(zone, contour.Instructions, false)
+		return zone, nil
+	}
+
+	// composite glyph
+	if 7 < level {
+		return nil, fmt.Errorf("glyf: compound glyphs too deeply nested")
+	}
+	r := parse.NewBinaryReaderBytes(b[10:])
+	components, instructions, err := parseGlyfComponents(r, glyphID)
+	if err != nil {
+		return nil, err
+	}
+	zone := newTTZone(0)
+	var usePhantom *[4]ttPoint
+	for _, component := range components {
+		sub, err := h.glyph(component.GlyphID, level+1)
+		if err != nil {
+			return nil, err
+		}
+		if component.Flags&0x00C8 != 0 { // has transformation
+			t := component.Transform
+			for i := range sub.cur {
+				p := sub.cur[i]
+				sub.cur[i].X = ttMul14(p.X, int32(t[0])) + ttMul14(p.Y, int32(t[2]))
+				sub.cur[i].Y = ttMul14(p.X, int32(t[1])) + ttMul14(p.Y, int32(t[3]))
+			}
+		}
+
+		n := len(sub.cur) - 4
+		var dx, dy int32
+		if component.Flags&0x0002 != 0 { // ARGS_ARE_XY_VALUES
+			dx, dy = h.scale(component.Arg1), h.scale(component.Arg2)
+			if component.Flags&0x0004 != 0 { // ROUND_XY_TO_GRID
+				if h.hinting != VerticalHinting {
+					dx = (dx + 32) &^ 63
+				}
+				dy = (dy + 32) &^ 63
+			}
+		} else {
+			// match a point of the composite so far with a point of the component
+			if component.Arg1 < 0 || len(zone.cur) <= int(component.Arg1) || component.Arg2 < 0 || n <= int(component.Arg2) {
+				return nil, fmt.Errorf("glyf: bad point numbers for composite glyph %v", glyphID)
+			}
+			p1, p2 := zone.cur[component.Arg1], sub.cur[component.Arg2]
+			dx, dy = p1.X-p2.X, p1.Y-p2.Y
+		}
+		for i := range sub.cur {
+			sub.cur[i].X += dx
+			sub.cur[i].Y += dy
+		}
+
+		offset := uint16(len(zone.cur))
+		for _, endPoint := range sub.endPoints {
+			zone.endPoints = append(zone.endPoints, offset+endPoint)
+		}
+		zone.cur = append(zone.cur, sub.cur[:n]...)
+		zone.onCurve = append(zone.onCurve, sub.onCurve[:n]...)
+		if component.Flags&0x0200 != 0 { // USE_MY_METRICS
+			usePhantom = &[4]ttPoint{}
+			copy(usePhantom[:], sub.cur[n:])
+		}
+	}
+
+	n := len(zone.cur)
+	zone.cur = append(zone.cur, make([]ttPoint, 4)...)
+	zone.onCurve = append(zone.onCurve, make([]bool, 4)...)
+	zone.orig = make([]ttPoint, n+4)
+	zone.touchedX = make([]bool, n+4)
+	zone.touchedY = make([]bool, n+4)
+	h.setPhantom(zone, n, phantom)
+	copy(zone.cur[n:], zone.orig[n:])
+	h.roundPhantom(zone)
+	if usePhantom != nil {
+		copy(zone.cur[n:], usePhantom[:])
+	}
+
+	// the hinted components are the original positions for the composite's instructions
+	copy(zone.orig, zone.cur)
+	h.runGlyph(zone, instructions, true)
+	return zone, nil
+}
+
+func (h *ttHinter) setPhantom(zone *ttZone, n int, phantom [4]ttPoint) {
+	for i, p := range phantom {
+		zone.orig[n+i] = ttPoint{h.scale(p.X), h.scale(p.Y)}
+		zone.cur[n+i] = zone.orig[n+i]
+	}
+}
+
+func (h *ttHinter) roundPhantom(zone *ttZone) {
+	n := len(zone.cur) - 4
+	zone.cur[n+0].X = (zone.cur[n+0].X + 32) &^ 63
+	zone.cur[n+1].X = (zone.cur[n+1].X + 32) &^ 63
+	zone.cur[n+2].Y = (zone.cur[n+2].Y + 32) &^ 63
+	zone.cur[n+3].Y = (zone.cur[n+3].Y + 32) &^ 63
+}
+
+// runGlyph runs the glyph's instructions on the zone. If the instructions fail, the points are left unhinted.
+func (h *ttHinter) runGlyph(zone *ttZone, instructions []byte, composite bool) {
+	if len(instructions) == 0 || h.gs.instructControl&1 != 0 {
+		return
+	}
+
+	gs := h.gs
+	if gs.instructControl&2 != 0 {
+		gs = ttDefaultGraphicsState
+	}
+	gs.pv, gs.fv, gs.dv = ttDefaultGraphicsState.pv, ttDefaultGraphicsState.fv, ttDefaultGraphicsState.dv
+	gs.rp, gs.zp = [3]int32{}, ttDefaultGraphicsState.zp
+	gs.loop = 1
+	gs.round = ttRoundToGrid
+
+	orig := zone.copy()
+	c := h.newContext(zone, gs)
+	c.cvt = append([]int32{}, h.scaledCvt...)
+	c.storage = append([]int32{}, h.storage...)
+	c.zones[0] = h.twilight.copy()
+	c.program = ttProgramGlyph
+	c.composite = composite
+	c.backwardCompatibility = h.hinting == VerticalHinting && h.gs.instructControl&4 == 0
+	if err := c.run(instructions, 0); err != nil {
+		*zone = *orig
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// see ttContext.program
+const (
+	ttProgramFont = iota
+	ttProgramControlValue
+	ttProgramGlyph
+)
+
+// ttContext is the execution context of the interpreter.
+type ttContext struct {
+	h       *ttHinter
+	gs      ttGraphicsState
+	stack   []int32
+	cvt     []int32
+	storage []int32
+	zones   [2]*ttZone // twilight and glyph zones
+	steps   int
+
+	program               int
+	composite             bool
+	backwardCompatibility bool // ignore horizontal movements, and vertical movements after IUP, as in FreeType's v40 interpreter
+	iupX, iupY            bool
+}
+
+func (h *ttHinter) newContext(zone *ttZone, gs ttGraphicsState) *ttContext {
+	return &ttContext{
+		h:       h,
+		gs:      gs,
+		stack:   make([]int32, 0, h.maxStack),
+		cvt:     h.scaledCvt,
+		storage: h.storage,
+		zones:   [2]*ttZone{h.twilight, zone},
+	}
+}
+
+func (c *ttContext) push(v int32) error {
+	if len(c.stack) == cap(c.stack) {
+		return fmt.Errorf("stack overflow")
+	}
+	c.stack = append(c.stack, v)
+	return nil
+}
+
+func (c *ttContext) pop() (int32, error) {
+	if len(c.stack) == 0 {
+		return 0, fmt.Errorf("stack underflow")
+	}
+	v := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	return v, nil
+}
+
+// zone returns the zone of zone pointer i.
+func (c *ttContext) zone(i int) *ttZone {
+	return c.zones[c.gs.zp[i]]
+}
+
+// point returns the zone of zone pointer i and checks whether the point exists.
+func (c *ttContext) point(i int, p int32) (*ttZone, error) {
+	z := c.zones[c.gs.zp[i]]
+	if !z.valid(p) {
+		return nil, fmt.Errorf("bad point %d in zone %d", p, c.gs.zp[i])
+	}
+	return z, nil
+}
+
+// project projects a vector on the projection vector.
+func (c *ttContext) project(dx, dy int32) int32 {
+	return int32((int64(dx)*int64(c.gs.pv.X) + int64(dy)*int64(c.gs.pv.Y) + 0x2000) >> 14)
+}
+
+// dualProject projects a vector on the dual projection vector.
+func (c *ttContext) dualProject(dx, dy int32) int32 {
+	return int32((int64(dx)*int64(c.gs.dv.X) + int64(dy)*int64(c.gs.dv.Y) + 0x2000) >> 14)
+}
+
+// move moves a point along the freedom vector such that it moves by d along the projection vector.
+func (c *ttContext) move(z *ttZone, p int32, d int32, touch bool) {
+	fv := c.gs.fv
+	fdotp := (int64(fv.X)*int64(c.gs.pv.X) + int64(fv.Y)*int64(c.gs.pv.Y)) >> 14
+	if -0x400 < fdotp && fdotp < 0x400 {
+		fdotp = 0x4000
+	}
+	if fv.X != 0 {
+		if !c.backwardCompatibility {
+			z.cur[p].X += ttMulDiv(int64(d), int64(fv.X), fdotp)
+		}
+		if touch {
+			z.touchedX[p] = true
+		}
+	}
+	if fv.Y != 0 {
+		if !c.backwardCompatibility || !c.iupX || !c.iupY {
+			z.cur[p].Y += ttMulDiv(int64(d), int64(fv.Y), fdotp)
+		}
+		if touch {
+			z.touchedY[p] = true
+		}
+	}
+}
+
+// shift moves a point by d along the freedom vector.
+func (c *ttContext) shift(z *ttZone, p int32, dx, dy int32, touch bool) {
+	if c.gs.fv.X != 0 {
+		if !c.backwardCompatibility {
+			z.cur[p].X += dx
+		}
+		if touch {
+			z.touchedX[p] = true
+		}
+	}
+	if c.gs.fv.Y != 0 {
+		if !c.backwardCompatibility || !c.iupX || !c.iupY {
+			z.cur[p].Y += dy
+		}
+		if touch {
+			z.touchedY[p] = true
+		}
+	}
+}
+
+// displacement returns the displacement of the reference point along the freedom vector for SHP, SHC, and SHZ.
+func (c *ttContext) displacement(opcode uint8) (*ttZone, int32, int32, int32, error) {
+	var z *ttZone
+	var p int32
+	var err error
+	if opcode&1 == 0 {
+		p = c.gs.rp[2]
+		z, err = c.point(1, p)
+	} else {
+		p = c.gs.rp[1]
+		z, err = c.point(0, p)
+	}
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	d := c.project(z.cur[p].X-z.orig[p].X, z.cur[p].Y-z.orig[p].Y)
+	fdotp := (int64(c.gs.fv.X)*int64(c.gs.pv.X) + int64(c.gs.fv.Y)*int64(c.gs.pv.Y)) >> 14
+	if -0x400 < fdotp && fdotp < 0x400 {
+		fdotp = 0x4000
+	}
+	return z, p, ttMulDiv(int64(d), int64(c.gs.fv.X), fdotp), ttMulDiv(int64(d), int64(c.gs.fv.Y), fdotp), nil
+}
+
+// round rounds a distance according to the round state.
+func (c *ttContext) round(d int32) int32 {
+	var v int32
+	switch c.gs.round {
+	case ttRoundOff:
+		return d
+	case ttRoundToGrid:
+		if 0 <= d {
+			return (d + 32) &^ 63
+		}
+		return -((-d + 32) &^ 63)
+	case ttRoundToHalfGrid:
+		if 0 <= d {
+			return d&^63 + 32
+		}
+		return -((-d)&^63 + 32)
+	case ttRoundToDoubleGrid:
+		if 0 <= d {
+			return (d + 16) &^ 31
+		}
+		return -((-d + 16) &^ 31)
+	case ttRoundDownToGrid:
+		if 0 <= d {
+			return d &^ 63
+		}
+		return -((-d) &^ 63)
+	case ttRoundUpToGrid:
+		if 0 <= d {
+			return (d + 63) &^ 63
+		}
+		return -((-d + 63) &^ 63)
+	}
+
+	// super rounding
+	period, phase, threshold := c.gs.period, c.gs.phase, c.gs.threshold
+	if period <= 0 {
+		return d
+	}
+	if 0 <= d {
+		v = ttFloorDiv(d-phase+threshold, period)*period + phase
+		if v < 0 {
+			v = phase
+		}
+	} else {
+		v = -(ttFloorDiv(-d-phase+threshold, period)*period + phase)
+		if 0 < v {
+			v = -phase
+		}
+	}
+	return v
+}
+
+// setSuperRound sets the super rounding period, phase, and threshold, where gridPeriod is in 2.14.
+func (c *ttContext) setSuperRound(gridPeriod, selector int32) {
+	switch selector & 0xC0 {
+	case 0x00:
+		c.gs.period = gridPeriod / 2
+	case 0x80:
+		c.gs.period = gridPeriod * 2
+	default:
+		c.gs.period = gridPeriod
+	}
+	switch selector & 0x30 {
+	case 0x00:
+		c.gs.phase = 0
+	case 0x10:
+		c.gs.phase = c.gs.period / 4
+	case 0x20:
+		c.gs.phase = c.gs.period / 2
+	case 0x30:
+		c.gs.phase = c.gs.period * 3 / 4
+	}
+	if selector&0x0F == 0 {
+		c.gs.threshold = c.gs.period - 1
+	} else {
+		c.gs.threshold = (selector&0x0F - 4) * c.gs.period / 8
+	}
+	c.gs.period >>= 8
+	c.gs.phase >>= 8
+	c.gs.threshold >>= 8
+}
+
+// vectorTo returns the unit vector from point p1 (zone pointer 2) to point p2 (zone pointer 1), optionally rotated counter-clockwise by 90 degrees.
+func (c *ttContext) vectorTo(p1, p2 int32, perpendicular, orig bool) (ttVector, error) {
+	z2, err := c.point(2, p1)
+	if err != nil {
+		return ttVector{}, err
+	}
+	z1, err := c.point(1, p2)
+	if err != nil {
+		return ttVector{}, err
+	}
+	var dx, dy int32
+	if orig {
+		dx, dy = z1.orig[p2].X-z2.orig[p1].X, z1.orig[p2].Y-z2.orig[p1].Y
+	} else {
+		dx, dy = z1.cur[p2].X-z2.cur[p1].X, z1.cur[p2].Y-z2.cur[p1].Y
+	}
+	if dx == 0 && dy == 0 {
+		dx, perpendicular = 0x4000, false
+	}
+	if perpendicular {
+		dx, dy = -dy, dx
+	}
+	return ttNormalize(dx, dy), nil
+}
+
+// iup interpolates the untouched points of the glyph zone in the x or y direction.
+func (c *ttContext) iup(x bool) {
+	z := c.zones[1]
+	touched := z.touchedY
+	coord := func(p *ttPoint) *int32 { return &p.Y }
+	if x {
+		touched = z.touchedX
+		coord = func(p *ttPoint) *int32 { return &p.X }
+	}
+
+	// interpolate points i1...i2 between the touched reference points ref1 and ref2
+	interpolate := func(i1, i2, ref1, ref2 int) {
+		if i2 < i1 {
+			return
+		}
+		o1, o2 := *coord(&z.orig[ref1]), *coord(&z.orig[ref2])
+		c1, c2 := *coord(&z.cur[ref1]), *coord(&z.cur[ref2])
+		if o2 < o1 {
+			o1, o2 = o2, o1
+			c1, c2 = c2, c1
+		}
+		d1, d2 := c1-o1, c2-o2
+		for i := i1; i <= i2; i++ {
+			o := *coord(&z.orig[i])
+			if o <= o1 {
+				*coord(&z.cur[i]) = o + d1
+			} else if o2 <= o {
+				*coord(&z.cur[i]) = o + d2
+			} else {
+				*coord(&z.cur[i]) = c1 + ttMulDiv(int64(o-o1), int64(c2-c1), int64(o2-o1))
+			}
+		}
+	}
+
+	start := 0
+	for _, endPoint := range z.endPoints {
+		end := int(endPoint)
+		if len(z.cur)-4 <= end {
+			break
+		}
+		first := -1
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				first = i
+				break
+			}
+		}
+		if first != -1 {
+			prev := first
+			for i := first + 1; i <= end; i++ {
+				if touched[i] {
+					interpolate(prev+1, i-1, prev, i)
+					prev = i
+				}
+			}
+			if prev == first {
+				// only one touched point, shift the contour
+				d := *coord(&z.cur[first]) - *coord(&z.orig[first])
+				for i := start; i <= end; i++ {
+					if i != first {
+						*coord(&z.cur[i]) += d
+					}
+				}
+			} else {
+				interpolate(prev+1, end, prev, first)
+				interpolate(start, first-1, prev, first)
+			}
+		}
+		start = end + 1
+	}
+}
+
+// skip returns the position after the instruction at pc, skipping the push instructions' data.
+func ttSkip(code []byte, pc int) int {
+	opcode := code[pc]
+	switch {
+	case opcode == 0x40: // NPUSHB
+		if pc+1 < len(code) {
+			return pc + 2 + int(code[pc+1])
+		}
+	case opcode == 0x41: // NPUSHW
+		if pc+1 < len(code) {
+			return pc + 2 + 2*int(code[pc+1])
+		}
+	case 0xB0 <= opcode && opcode <= 0xB7: // PUSHB
+		return pc + 1 + int(opcode-0xB0+1)
+	case 0xB8 <= opcode && opcode <= 0xBF: // PUSHW
+		return pc + 1 + 2*int(opcode-0xB8+1)
+	}
+	return pc + 1
+}
+
+// skipIf skips to after the matching ELSE (if else is set) or EIF instruction.
+func ttSkipIf(code []byte, pc int, stopAtElse bool) (int, error) {
+	depth := 1
+	for pc < len(code) {
+		switch code[pc] {
+		case 0x58: // IF
+			depth++
+		case 0x1B: // ELSE
+			if depth == 1 && stopAtElse {
+				return pc + 1, nil
+			}
+		case 0x59: // EIF
+			depth--
+			if depth == 0 {
+				return pc + 1, nil
+			}
+		}
+		pc = ttSkip(code, pc)
+	}
+	return 0, fmt.Errorf("unmatched IF")
+}
+
+// ttNumArgs is the number of arguments that instructions pop from the stack, excluding arguments for the loop variable and DELTA instructions.
+var ttNumArgs = [256]int8{
+	// SVTCA, SPVTCA, SFVTCA, SPVTL, SFVTL, SPVFS, SFVFS, GPV, GFV, SFVTPV, ISECT
+	0, 0, 0, 0, 0, 0, 2, 2, 2, 2, 2, 2, 0, 0, 0, 5,
+	// SRP0-2, SZP0-2, SZPS, SLOOP, RTG, RTHG, SMD, ELSE, JMPR, SCVTCI, SSWCI, SSW
+	1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 0, 1, 1, 1, 1,
+	// DUP, POP, CLEAR, SWAP, DEPTH, CINDEX, MINDEX, ALIGNPTS, -, UTP, LOOPCALL, CALL, FDEF, ENDF, MDAP
+	1, 1, 0, 2, 0, 1, 1, 2, 0, 1, 2, 1, 1, 0, 1, 1,
+	// IUP, SHP, SHC, SHZ, SHPIX, IP, MSIRP, ALIGNRP, RTDG, MIAP
+	0, 0, 0, 0, 1, 1, 1, 1, 1, 0, 2, 2, 0, 0, 2, 2,
+	// NPUSHB, NPUSHW, WS, RS, WCVTP, RCVT, GC, SCFS, MD, MPPEM, MPS, FLIPON, FLIPOFF, DEBUG
+	0, 0, 2, 1, 2, 1, 1, 1, 2, 2, 2, 0, 0, 0, 0, 1,
+	// LT, LTEQ, GT, GTEQ, EQ, NEQ, ODD, EVEN, IF, EIF, AND, OR, NOT, DELTAP1, SDB, SDS
+	2, 2, 2, 2, 2, 2, 1, 1, 1, 0, 2, 2, 1, 1, 1, 1,
+	// ADD, SUB, DIV, MUL, ABS, NEG, FLOOR, CEILING, ROUND, NROUND
+	2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	// WCVTF, DELTAP2-3, DELTAC1-3, SROUND, S45ROUND, JROT, JROF, ROFF, -, RUTG, RDTG, SANGW, AA
+	2, 1, 1, 1, 1, 1, 1, 1, 2, 2, 0, 0, 0, 0, 1, 1,
+	// FLIPPT, FLIPRGON, FLIPRGOFF, -, -, SCANCTRL, SDPVTL, GETINFO, IDEF, ROLL, MAX, MIN, SCANTYPE, INSTCTRL
+	0, 2, 2, 0, 0, 1, 2, 2, 1, 1, 3, 2, 2, 1, 2, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	// PUSHB, PUSHW
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	// MDRP
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	// MIRP
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+}
+
+// run executes the instructions.
+func (c *ttContext) run(code []byte, depth int) error {
+	if ttMaxCallDepth < depth {
+		return fmt.Errorf("too many nested calls")
+	}
+
+	pc := 0
+	for pc < len(code) {
+		c.steps++
+		if ttMaxSteps < c.steps {
+			return fmt.Errorf("too many instructions")
+		}
+
+		opcode := code[pc]
+		n := int(ttNumArgs[opcode])
+		if len(c.stack) < n {
+			return fmt.Errorf("stack underflow for opcode 0x%02X", opcode)
+		}
+		args := c.stack[len(c.stack)-n:]
+		c.stack = c.stack[:len(c.stack)-n]
+		pc++
+
+		switch opcode {
+		case 0x00, 0x01: // SVTCA
+			v := ttVector{0, 0x4000}
+			if opcode == 0x01 {
+				v = ttVector{0x4000, 0}
+			}
+			c.gs.pv, c.gs.dv, c.gs.fv = v, v, v
+		case 0x02, 0x03: // SPVTCA
+			v := ttVector{0, 0x4000}
+			if opcode == 0x03 {
+				v = ttVector{0x4000, 0}
+			}
+			c.gs.pv, c.gs.dv = v, v
+		case 0x04, 0x05: // SFVTCA
+			v := ttVector{0, 0x4000}
+			if opcode == 0x05 {
+				v = ttVector{0x4000, 0}
+			}
+			c.gs.fv = v
+		case 0x06, 0x07: // SPVTL
+			v, err := c.vectorTo(args[1], args[0], opcode == 0x07, false)
+			if err != nil {
+				return err
+			}
+			c.gs.pv, c.gs.dv = v, v
+		case 0x08, 0x09: // SFVTL
+			v, err := c.vectorTo(args[1], args[0], opcode == 0x09, false)
+			if err != nil {
+				return err
+			}
+			c.gs.fv = v
+		case 0x0A: // SPVFS
+			c.gs.pv = ttNormalize(args[0], args[1])
+			c.gs.dv = c.gs.pv
+		case 0x0B: // SFVFS
+			c.gs.fv = ttNormalize(args[0], args[1])
+		case 0x0C: // GPV
+			if err := c.push(c.gs.pv.X); err != nil {
+				return err
+			} else if err := c.push(c.gs.pv.Y); err != nil {
+				return err
+			}
+		case 0x0D: // GFV
+			if err := c.push(c.gs.fv.X); err != nil {
+				return err
+			} else if err := c.push(c.gs.fv.Y); err != nil {
+				return err
+			}
+		case 0x0E: // SFVTPV
+			c.gs.fv = c.gs.pv
+		case 0x0F: // ISECT
+			p, a0, a1, b0, b1 := args[0], args[1], args[2], args[3], args[4]
+			zp, err := c.point(2, p)
+			if err != nil {
+				return err
+			}
+			za, err := c.point(1, a0)
+			if err != nil {
+				return err
+			} else if !za.valid(a1) {
+				return fmt.Errorf("bad point %d", a1)
+			}
+			zb, err := c.point(0, b0)
+			if err != nil {
+				return err
+			} else if !zb.valid(b1) {
+				return fmt.Errorf("bad point %d", b1)
+			}
+			pa0, pa1, pb0, pb1 := za.cur[a0], za.cur[a1], zb.cur[b0], zb.cur[b1]
+			dax, day := float64(pa1.X-pa0.X), float64(pa1.Y-pa0.Y)
+			dbx, dby := float64(pb1.X-pb0.X), float64(pb1.Y-pb0.Y)
+			discriminant := dax*-dby + day*dbx
+			dotProduct := dax*dbx + day*dby
+			if 19.0*math.Abs(discriminant) > math.Abs(dotProduct) {
+				dx, dy := float64(pb0.X-pa0.X), float64(pb0.Y-pa0.Y)
+				v := (dx*-dby + dy*dbx) / discriminant
+				zp.cur[p] = ttPoint{pa0.X + int32(math.Round(v*dax)), pa0.Y + int32(math.Round(v*day))}
+			} else {
+				// lines are (nearly) parallel, take the middle of the middles
+				zp.cur[p] = ttPoint{(pa0.X + pa1.X + pb0.X + pb1.X) / 4, (pa0.Y + pa1.Y + pb0.Y + pb1.Y) / 4}
+			}
+			zp.touchedX[p], zp.touchedY[p] = true, true
+		case 0x10, 0x11, 0x12: // SRP0, SRP1, SRP2
+			c.gs.rp[opcode-0x10] = args[0]
+		case 0x13, 0x14, 0x15, 0x16: // SZP0, SZP1, SZP2, SZPS
+			if args[0] != 0 && args[0] != 1 {
+				return fmt.Errorf("bad zone %d", args[0])
+			}
+			if opcode == 0x16 {
+				c.gs.zp = [3]int32{args[0], args[0], args[0]}
+			} else {
+				c.gs.zp[opcode-0x13] = args[0]
+			}
+		case 0x17: // SLOOP
+			if args[0] < 0 {
+				return fmt.Errorf("bad loop value")
+			}
+			c.gs.loop = args[0]
+		case 0x18: // RTG
+			c.gs.round = ttRoundToGrid
+		case 0x19: // RTHG
+			c.gs.round = ttRoundToHalfGrid
+		case 0x1A: // SMD
+			c.gs.minDist = args[0]
+		case 0x1B: // ELSE
+			var err error
+			if pc, err = ttSkipIf(code, pc, false); err != nil {
+				return err
+			}
+		case 0x1C: // JMPR
+			if pc += int(args[0]) - 1; pc < 0 || len(code) < pc {
+				return fmt.Errorf("bad jump")
+			}
+		case 0x1D: // SCVTCI
+			c.gs.cvtCutIn = args[0]
+		case 0x1E: // SSWCI
+			c.gs.swCutIn = args[0]
+		case 0x1F: // SSW
+			c.gs.swValue = c.h.scale(args[0])
+		case 0x20: // DUP
+			c.stack = append(c.stack, args[0], args[0])
+		case 0x21: // POP
+		case 0x22: // CLEAR
+			c.stack = c.stack[:0]
+		case 0x23: // SWAP
+			c.stack = append(c.stack, args[1], args[0])
+		case 0x24: // DEPTH
+			if err := c.push(int32(len(c.stack))); err != nil {
+				return err
+			}
+		case 0x25, 0x26: // CINDEX, MINDEX
+			k := int(args[0])
+			if k <= 0 || len(c.stack) < k {
+				return fmt.Errorf("bad stack index")
+			}
+			v := c.stack[len(c.stack)-k]
+			if opcode == 0x26 {
+				copy(c.stack[len(c.stack)-k:], c.stack[len(c.stack)-k+1:])
+				c.stack = c.stack[:len(c.stack)-1]
+			}
+			c.stack = append(c.stack, v)
+		case 0x27: // ALIGNPTS
+			z1, err := c.point(1, args[0])
+			if err != nil {
+				return err
+			}
+			z0, err := c.point(0, args[1])
+			if err != nil {
+				return err
+			}
+			p1, p2 := args[0], args[1]
+			d := c.project(z0.cur[p2].X-z1.cur[p1].X, z0.cur[p2].Y-z1.cur[p1].Y) / 2
+			c.move(z1, p1, d, true)
+			c.move(z0, p2, -d, true)
+		case 0x29: // UTP
+			z, err := c.point(0, args[0])
+			if err != nil {
+				return err
+			}
+			if c.gs.fv.X != 0 {
+				z.touchedX[args[0]] = false
+			}
+			if c.gs.fv.Y != 0 {
+				z.touchedY[args[0]] = false
+			}
+		case 0x2A, 0x2B: // LOOPCALL, CALL
+			count, f := int32(1), args[0]
+			if opcode == 0x2A {
+				count, f = args[0], args[1]
+			}
+			fn, ok := c.h.functions[f]
+			if !ok {
+				return fmt.Errorf("undefined function %d", f)
+			}
+			for i := int32(0); i < count; i++ {
+				if err := c.run(fn, depth+1); err != nil {
+					return err
+				}
+			}
+		case 0x2C: // FDEF
+			if c.program == ttProgramGlyph {
+				return fmt.Errorf("FDEF in glyph program")
+			}
+			start := pc
+			for pc < len(code) && code[pc] != 0x2D && code[pc] != 0x2C && code[pc] != 0x89 {
+				pc = ttSkip(code, pc)
+			}
+			if len(code) <= pc || code[pc] != 0x2D {
+				return fmt.Errorf("unmatched FDEF")
+			}
+			c.h.functions[args[0]] = code[start:pc]
+			pc++
+		case 0x2D: // ENDF
+			if depth == 0 {
+				return fmt.Errorf("ENDF outside of function")
+			}
+			return nil
+		case 0x2E, 0x2F: // MDAP
+			p := args[0]
+			z, err := c.point(0, p)
+			if err != nil {
+				return err
+			}
+			var d int32
+			if opcode == 0x2F {
+				d = c.project(z.cur[p].X, z.cur[p].Y)
+				d = c.round(d) - d
+			}
+			c.move(z, p, d, true)
+			c.gs.rp[0], c.gs.rp[1] = p, p
+		case 0x30, 0x31: // IUP
+			if c.gs.zp[2] == 1 && 0 < len(c.zones[1].endPoints) {
+				if opcode == 0x31 {
+					if !c.backwardCompatibility {
+						c.iup(true)
+					}
+					c.iupX = true
+				} else {
+					if !c.backwardCompatibility || !c.iupX || !c.iupY {
+						c.iup(false)
+					}
+					c.iupY = true
+				}
+			}
+		case 0x32, 0x33: // SHP
+			_, _, dx, dy, err := c.displacement(opcode)
+			if err != nil {
+				return err
+			}
+			for ; 0 < c.gs.loop; c.gs.loop-- {
+				p, err := c.pop()
+				if err != nil {
+					return err
+				}
+				z, err := c.point(2, p)
+				if err != nil {
+					return err
+				}
+				c.shift(z, p, dx, dy, true)
+			}
+			c.gs.loop = 1
+		case 0x34, 0x35: // SHC
+			zr, pr, dx, dy, err := c.displacement(opcode)
+			if err != nil {
+				return err
+			}
+			z := c.zone(2)
+			contour := int(args[0])
+			if contour < 0 || len(z.endPoints) <= contour {
+				return fmt.Errorf("bad contour %d", contour)
+			}
+			start := 0
+			if 0 < contour {
+				start = int(z.endPoints[contour-1]) + 1
+			}
+			for i := start; i <= int(z.endPoints[contour]) && i < len(z.cur); i++ {
+				if z != zr || int32(i) != pr {
+					c.shift(z, int32(i), dx, dy, true)
+				}
+			}
+		case 0x36, 0x37: // SHZ
+			zr, pr, dx, dy, err := c.displacement(opcode)
+			if err != nil {
+				return err
+			} else if args[0] != 0 && args[0] != 1 {
+				return fmt.Errorf("bad zone %d", args[0])
+			}
+			z := c.zones[args[0]]
+			n := len(z.cur)
+			if args[0] == 1 {
+				n -= 4 // skip phantom points
+			}
+			for i := 0; i < n; i++ {
+				if z != zr || int32(i) != pr {
+					c.shift(z, int32(i), dx, dy, false)
+				}
+			}
+		case 0x38: // SHPIX
+			dx, dy := ttMul14(args[0], c.gs.fv.X), ttMul14(args[0], c.gs.fv.Y)
+			for ; 0 < c.gs.loop; c.gs.loop-- {
+				p, err := c.pop()
+				if err != nil {
+					return err
+				}
+				z, err := c.point(2, p)
+				if err != nil {
+					return err
+				}
+				if !c.backwardCompatibility || c.gs.zp[2] == 0 {
+					z.cur[p].X += dx
+					z.cur[p].Y += dy
+					z.touchedX[p] = z.touchedX[p] || dx != 0 || c.gs.fv.X != 0
+					z.touchedY[p] = z.touchedY[p] || dy != 0 || c.gs.fv.Y != 0
+				} else if !c.iupX || !c.iupY {
+					if c.composite && c.gs.fv.Y != 0 || z.touchedY[p] {
+						z.cur[p].Y += dy
+						z.touchedY[p] = true
+					}
+				}
+			}
+			c.gs.loop = 1
+		case 0x39: // IP
+			z0, err := c.point(0, c.gs.rp[1])
+			if err != nil {
+				return err
+			}
+			z1, err := c.point(1, c.gs.rp[2])
+			if err != nil {
+				return err
+			}
+			orig1, cur1 := z0.orig[c.gs.rp[1]], z0.cur[c.gs.rp[1]]
+			orig2, cur2 := z1.orig[c.gs.rp[2]], z1.cur[c.gs.rp[2]]
+			origRange := c.dualProject(orig2.X-orig1.X, orig2.Y-orig1.Y)
+			curRange := c.project(cur2.X-cur1.X, cur2.Y-cur1.Y)
+			for ; 0 < c.gs.loop; c.gs.loop-- {
+				p, err := c.pop()
+				if err != nil {
+					return err
+				}
+				z, err := c.point(2, p)
+				if err != nil {
+					return err
+				}
+				origDist := c.dualProject(z.orig[p].X-orig1.X, z.orig[p].Y-orig1.Y)
+				curDist := c.project(z.cur[p].X-cur1.X, z.cur[p].Y-cur1.Y)
+				var newDist int32
+				if origDist != 0 {
+					if origRange != 0 {
+						newDist = ttMulDiv(int64(origDist), int64(curRange), int64(origRange))
+					} else {
+						newDist = origDist
+					}
+				}
+				c.move(z, p, newDist-curDist, true)
+			}
+			c.gs.loop = 1
+		case 0x3A, 0x3B: // MSIRP
+			p, d := args[0], args[1]
+			z0, err := c.point(0, c.gs.rp[0])
+			if err != nil {
+				return err
+			}
+			z1, err := c.point(1, p)
+			if err != nil {
+				return err
+			}
+			rp0 := c.gs.rp[0]
+			if c.gs.zp[1] == 0 {
+				z1.orig[p] = z0.orig[rp0]
+				z1.cur[p] = z1.orig[p]
+			}
+			dist := c.project(z1.cur[p].X-z0.cur[rp0].X, z1.cur[p].Y-z0.cur[rp0].Y)
+			c.move(z1, p, d-dist, true)
+			c.gs.rp[1], c.gs.rp[2] = rp0, p
+			if opcode == 0x3B {
+				c.gs.rp[0] = p
+			}
+		case 0x3C: // ALIGNRP
+			z0, err := c.point(0, c.gs.rp[0])
+			if err != nil {
+				return err
+			}
+			ref := z0.cur[c.gs.rp[0]]
+			for ; 0 < c.gs.loop; c.gs.loop-- {
+				p, err := c.pop()
+				if err != nil {
+					return err
+				}
+				z, err := c.point(1, p)
+				if err != nil {
+					return err
+				}
+				c.move(z, p, -c.project(z.cur[p].X-ref.X, z.cur[p].Y-ref.Y), true)
+			}
+			c.gs.loop = 1
+		case 0x3D: // RTDG
+			c.gs.round = ttRoundToDoubleGrid
+		case 0x3E, 0x3F: // MIAP
+			p, n := args[0], args[1]
+			z, err := c.point(0, p)
+			if err != nil {
+				return err
+			}
+			var d int32
+			if 0 <= n && int(n) < len(c.cvt) {
+				d = c.cvt[n]
+			}
+			if c.gs.zp[0] == 0 {
+				z.orig[p] = ttPoint{ttMul14(d, c.gs.fv.X), ttMul14(d, c.gs.fv.Y)}
+				z.cur[p] = z.orig[p]
+			}
+			origDist := c.project(z.cur[p].X, z.cur[p].Y)
+			if opcode == 0x3F {
+				if c.gs.cvtCutIn < ttAbs(d-origDist) {
+					d = origDist
+				}
+				d = c.round(d)
+			}
+			c.move(z, p, d-origDist, true)
+			c.gs.rp[0], c.gs.rp[1] = p, p
+		case 0x40, 0x41: // NPUSHB, NPUSHW
+			if len(code) <= pc {
+				return fmt.Errorf("bad push instruction")
+			}
+			n := int(code[pc])
+			pc++
+			if err := c.pushData(code, &pc, n, opcode == 0x41); err != nil {
+				return err
+			}
+		case 0x42: // WS
+			if 0 <= args[0] && int(args[0]) < len(c.storage) {
+				c.storage[args[0]] = args[1]
+			}
+		case 0x43: // RS
+			var v int32
+			if 0 <= args[0] && int(args[0]) < len(c.storage) {
+				v = c.storage[args[0]]
+			}
+			c.stack = append(c.stack, v)
+		case 0x44, 0x70: // WCVTP, WCVTF
+			if 0 <= args[0] && int(args[0]) < len(c.cvt) {
+				if opcode == 0x70 {
+					c.cvt[args[0]] = c.h.scale(args[1])
+				} else {
+					c.cvt[args[0]] = args[1]
+				}
+			}
+		case 0x45: // RCVT
+			var v int32
+			if 0 <= args[0] && int(args[0]) < len(c.cvt) {
+				v = c.cvt[args[0]]
+			}
+			c.stack = append(c.stack, v)
+		case 0x46, 0x47: // GC
+			p := args[0]
+			z, err := c.point(2, p)
+			if err != nil {
+				return err
+			}
+			if opcode == 0x46 {
+				c.stack = append(c.stack, c.project(z.cur[p].X, z.cur[p].Y))
+			} else {
+				c.stack = append(c.stack, c.dualProject(z.orig[p].X, z.orig[p].Y))
+			}
+		case 0x48: // SCFS
+			p := args[0]
+			z, err := c.point(2, p)
+			if err != nil {
+				return err
+			}
+			c.move(z, p, args[1]-c.project(z.cur[p].X, z.cur[p].Y), true)
+			if c.gs.zp[2] == 0 {
+				z.orig[p] = z.cur[p]
+			}
+		case 0x49, 0x4A: // MD
+			p1, p2 := args[0], args[1]
+			z0, err := c.point(0, p1)
+			if err != nil {
+				return err
+			}
+			z1, err := c.point(1, p2)
+			if err != nil {
+				return err
+			}
+			if opcode == 0x4A {
+				c.stack = append(c.stack, c.project(z0.cur[p1].X-z1.cur[p2].X, z0.cur[p1].Y-z1.cur[p2].Y))
+			} else {
+				c.stack = append(c.stack, c.dualProject(z0.orig[p1].X-z1.orig[p2].X, z0.orig[p1].Y-z1.orig[p2].Y))
+			}
+		case 0x4B, 0x4C: // MPPEM, MPS
+			if err := c.push(int32(c.h.ppem)); err != nil {
+				return err
+			}
+		case 0x4D: // FLIPON
+			c.gs.autoFlip = true
+		case 0x4E: // FLIPOFF
+			c.gs.autoFlip = false
+		case 0x4F: // DEBUG
+		case 0x50: // LT
+			c.stack = append(c.stack, ttBool(args[0] < args[1]))
+		case 0x51: // LTEQ
+			c.stack = append(c.stack, ttBool(args[0] <= args[1]))
+		case 0x52: // GT
+			c.stack = append(c.stack, ttBool(args[0] > args[1]))
+		case 0x53: // GTEQ
+			c.stack = append(c.stack, ttBool(args[0] >= args[1]))
+		case 0x54: // EQ
+			c.stack = append(c.stack, ttBool(args[0] == args[1]))
+		case 0x55: // NEQ
+			c.stack = append(c.stack, ttBool(args[0] != args[1]))
+		case 0x56: // ODD
+			c.stack = append(c.stack, ttBool(c.round(args[0])&127 == 64))
+		case 0x57: // EVEN
+			c.stack = append(c.stack, ttBool(c.round(args[0])&127 == 0))
+		case 0x58: // IF
+			if args[0] == 0 {
+				var err error
+				if pc, err = ttSkipIf(code, pc, true); err != nil {
+					return err
+				}
+			}
+		case 0x59: // EIF
+		case 0x5A: // AND
+			c.stack = append(c.stack, ttBool(args[0] != 0 && args[1] != 0))
+		case 0x5B: // OR
+			c.stack = append(c.stack, ttBool(args[0] != 0 || args[1] != 0))
+		case 0x5C: // NOT
+			c.stack = append(c.stack, ttBool(args[0] == 0))
+		case 0x5D, 0x71, 0x72: // DELTAP1, DELTAP2, DELTAP3
+			base := c.gs.deltaBase
+			if opcode == 0x71 {
+				base += 16
+			} else if opcode == 0x72 {
+				base += 32
+			}
+			for i := int32(0); i < args[0]; i++ {
+				if len(c.stack) < 2 {
+					return fmt.Errorf("stack underflow for opcode 0x%02X", opcode)
+				}
+				p, arg := c.stack[len(c.stack)-1], c.stack[len(c.stack)-2]
+				c.stack = c.stack[:len(c.stack)-2]
+				z, err := c.point(0, p)
+				if err != nil {
+					return err
+				} else if base+(arg>>4&0xF) != int32(c.h.ppem) {
+					continue
+				}
+				d := c.delta(arg)
+				if !c.backwardCompatibility {
+					c.move(z, p, d, true)
+				} else if !c.iupX || !c.iupY {
+					if c.composite && c.gs.fv.Y != 0 || z.touchedY[p] {
+						c.move(z, p, d, true)
+					}
+				}
+			}
+		case 0x5E: // SDB
+			c.gs.deltaBase = args[0]
+		case 0x5F: // SDS
+			if args[0] < 0 || 6 < args[0] {
+				return fmt.Errorf("bad delta shift")
+			}
+			c.gs.deltaShift = args[0]
+		case 0x60: // ADD
+			c.stack = append(c.stack, args[0]+args[1])
+		case 0x61: // SUB
+			c.stack = append(c.stack, args[0]-args[1])
+		case 0x62: // DIV
+			if args[1] == 0 {
+				return fmt.Errorf("division by zero")
+			}
+			c.stack = append(c.stack, int32(int64(args[0])*64/int64(args[1])))
+		case 0x63: // MUL
+			c.stack = append(c.stack, int32(int64(args[0])*int64(args[1])/64))
+		case 0x64: // ABS
+			c.stack = append(c.stack, ttAbs(args[0]))
+		case 0x65: // NEG
+			c.stack = append(c.stack, -args[0])
+		case 0x66: // FLOOR
+			c.stack = append(c.stack, args[0]&^63)
+		case 0x67: // CEILING
+			c.stack = append(c.stack, (args[0]+63)&^63)
+		case 0x68, 0x69, 0x6A, 0x6B: // ROUND
+			c.stack = append(c.stack, c.round(args[0]))
+		case 0x6C, 0x6D, 0x6E, 0x6F: // NROUND
+			c.stack = append(c.stack, args[0])
+		case 0x73, 0x74, 0x75: // DELTAC1, DELTAC2, DELTAC3
+			base := c.gs.deltaBase + 16*int32(opcode-0x73)
+			for i := int32(0); i < args[0]; i++ {
+				if len(c.stack) < 2 {
+					return fmt.Errorf("stack underflow for opcode 0x%02X", opcode)
+				}
+				n, arg := c.stack[len(c.stack)-1], c.stack[len(c.stack)-2]
+				c.stack = c.stack[:len(c.stack)-2]
+				if n < 0 || len(c.cvt) <= int(n) {
+					return fmt.Errorf("bad control value %d", n)
+				} else if base+(arg>>4&0xF) == int32(c.h.ppem) {
+					c.cvt[n] += c.delta(arg)
+				}
+			}
+		case 0x76: // SROUND
+			c.gs.round = ttRoundSuper
+			c.setSuperRound(0x4000, args[0])
+		case 0x77: // S45ROUND
+			c.gs.round = ttRoundSuper45
+			c.setSuperRound(0x2D41, args[0])
+		case 0x78, 0x79: // JROT, JROF
+			if (args[1] != 0) == (opcode == 0x78) {
+				if pc += int(args[0]) - 1; pc < 0 || len(code) < pc {
+					return fmt.Errorf("bad jump")
+				}
+			}
+		case 0x7A: // ROFF
+			c.gs.round = ttRoundOff
+		case 0x7C: // RUTG
+			c.gs.round = ttRoundUpToGrid
+		case 0x7D: // RDTG
+			c.gs.round = ttRoundDownToGrid
+		case 0x7E, 0x7F: // SANGW, AA
+		case 0x80: // FLIPPT
+			for ; 0 < c.gs.loop; c.gs.loop-- {
+				p, err := c.pop()
+				if err != nil {
+					return err
+				}
+				z, err := c.point(0, p)
+				if err != nil {
+					return err
+				}
+				z.onCurve[p] = !z.onCurve[p]
+			}
+			c.gs.loop = 1
+		case 0x81, 0x82: // FLIPRGON, FLIPRGOFF
+			z := c.zone(0)
+			if !z.valid(args[0]) || !z.valid(args[1]) {
+				return fmt.Errorf("bad point range")
+			}
+			for p := args[0]; p <= args[1]; p++ {
+				z.onCurve[p] = opcode == 0x81
+			}
+		case 0x85: // SCANCTRL
+		case 0x86, 0x87: // SDPVTL
+			pv, err := c.vectorTo(args[1], args[0], opcode == 0x87, false)
+			if err != nil {
+				return err
+			}
+			dv, err := c.vectorTo(args[1], args[0], opcode == 0x87, true)
+			if err != nil {
+				return err
+			}
+			c.gs.pv, c.gs.dv = pv, dv
+		case 0x88: // GETINFO
+			c.stack = append(c.stack, c.getInfo(args[0]))
+		case 0x89: // IDEF
+			if c.program == ttProgramGlyph {
+				return fmt.Errorf("IDEF in glyph program")
+			}
+			start := pc
+			for pc < len(code) && code[pc] != 0x2D && code[pc] != 0x2C && code[pc] != 0x89 {
+				pc = ttSkip(code, pc)
+			}
+			if len(code) <= pc || code[pc] != 0x2D {
+				return fmt.Errorf("unmatched IDEF")
+			}
+			c.h.idefs[uint8(args[0])] = code[start:pc]
+			pc++
+		case 0x8A: // ROLL
+			c.stack = append(c.stack, args[1], args[2], args[0])
+		case 0x8B: // MAX
+			c.stack = append(c.stack, max(args[0], args[1]))
+		case 0x8C: // MIN
+			c.stack = append(c.stack, min(args[0], args[1]))
+		case 0x8D: // SCANTYPE
+		case 0x8E: // INSTCTRL
+			selector, value := args[1], args[0]
+			if selector < 1 || 3 < selector {
+				return fmt.Errorf("bad INSTCTRL selector")
+			} else if c.program == ttProgramControlValue {
+				flag := int32(1) << (selector - 1)
+				if value != 0 {
+					value = flag
+				}
+				c.gs.instructControl = c.gs.instructControl&^flag | value
+			}
+		default:
+			if 0xB0 <= opcode && opcode <= 0xBF { // PUSHB, PUSHW
+				if err := c.pushData(code, &pc, int(opcode&7)+1, 0xB8 <= opcode); err != nil {
+					return err
+				}
+			} else if 0xC0 <= opcode { // MDRP, MIRP
+				if err := c.moveRelative(opcode, args); err != nil {
+					return err
+				}
+			} else if fn, ok := c.h.idefs[opcode]; ok {
+				if err := c.run(fn, depth+1); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("unknown opcode 0x%02X", opcode)
+			}
+		}
+	}
+	return nil
+}
+
+// pushData pushes n bytes or words from the instruction stream.
+func (c *ttContext) pushData(code []byte, pc *int, n int, words bool) error {
+	size := 1
+	if words {
+		size = 2
+	}
+	if len(code) < *pc+n*size {
+		return fmt.Errorf("bad push instruction")
+	} else if cap(c.stack) < len(c.stack)+n {
+		return fmt.Errorf("stack overflow")
+	}
+	for i := 0; i < n; i++ {
+		if words {
+			c.stack = append(c.stack, int32(int16(binary.BigEndian.Uint16(code[*pc:]))))
+		} else {
+			c.stack = append(c.stack, int32(code[*pc]))
+		}
+		*pc += size
+	}
+	return nil
+}
+
+// delta returns the distance for a DELTAP or DELTAC argument.
+func (c *ttContext) delta(arg int32) int32 {
+	steps := arg&0xF - 8
+	if 0 <= steps {
+		steps++
+	}
+	return steps * 64 / (1 << c.gs.deltaShift)
+}
+
+// getInfo returns information about the interpreter, see the GETINFO instruction.
+func (c *ttContext) getInfo(selector int32) int32 {
+	var v int32
+	if c.h.hinting == VerticalHinting {
+		if selector&1 != 0 {
+			v = 40 // version
+		}
+		if selector&64 != 0 {
+			v |= 1 << 13 // ClearType hinting
+		}
+		if selector&1024 != 0 {
+			v |= 1 << 17 // sub-pixel positioned
+		}
+		if selector&2048 != 0 {
+			v |= 1 << 18 // symmetrical smoothing
+		}
+		if selector&4096 != 0 {
+			v |= 1 << 19 // ClearType hinting and grayscale rendering
+		}
+	} else {
+		if selector&1 != 0 {
+			v = 35 // version
+		}
+		if selector&32 != 0 {
+			v |= 1 << 12 // grayscale rendering
+		}
+	}
+	return v
+}
+
+// moveRelative executes the MDRP and MIRP instructions.
+func (c *ttContext) moveRelative(opcode uint8, args []int32) error {
+	p := args[0]
+	rp0 := c.gs.rp[0]
+	z0, err := c.point(0, rp0)
+	if err != nil {
+		return err
+	}
+	z1, err := c.point(1, p)
+	if err != nil {
+		return err
+	}
+
+	var d, origDist int32
+	if opcode < 0xE0 {
+		// MDRP
+		origDist = c.dualProject(z1.orig[p].X-z0.orig[rp0].X, z1.orig[p].Y-z0.orig[rp0].Y)
+		if ttAbs(origDist-c.gs.swValue) < c.gs.swCutIn {
+			if 0 <= origDist {
+				origDist = c.gs.swValue
+			} else {
+				origDist = -c.gs.swValue
+			}
+		}
+		d = origDist
+		if opcode&0x04 != 0 {
+			d = c.round(d)
+		}
+	} else {
+		// MIRP
+		var cvtDist int32
+		if n := args[1]; 0 <= n && int(n) < len(c.cvt) {
+			cvtDist = c.cvt[n]
+		}
+		if ttAbs(cvtDist-c.gs.swValue) < c.gs.swCutIn {
+			if 0 <= cvtDist {
+				cvtDist = c.gs.swValue
+			} else {
+				cvtDist = -c.gs.swValue
+			}
+		}
+		if c.gs.zp[1] == 0 {
+			z1.orig[p] = ttPoint{z0.orig[rp0].X + ttMul14(cvtDist, c.gs.fv.X), z0.orig[rp0].Y + ttMul14(cvtDist, c.gs.fv.Y)}
+			z1.cur[p] = z1.orig[p]
+		}
+		origDist = c.dualProject(z1.orig[p].X-z0.orig[rp0].X, z1.orig[p].Y-z0.orig[rp0].Y)
+		if c.gs.autoFlip && (origDist^cvtDist) < 0 {
+			cvtDist = -cvtDist
+		}
+		d = cvtDist
+		if opcode&0x04 != 0 {
+			if c.gs.zp[0] == c.gs.zp[1] && c.gs.cvtCutIn < ttAbs(cvtDist-origDist) {
+				d = origDist
+			}
+			d = c.round(d)
+		}
+	}
+
+	if opcode&0x08 != 0 {
+		// minimum distance
+		if 0 <= origDist {
+			d = max(d, c.gs.minDist)
+		} else {
+			d = min(d, -c.gs.minDist)
+		}
+	}
+	curDist := c.project(z1.cur[p].X-z0.cur[rp0].X, z1.cur[p].Y-z0.cur[rp0].Y)
+	c.move(z1, p, d-curDist, true)
+
+	c.gs.rp[1], c.gs.rp[2] = rp0, p
+	if opcode&0x10 != 0 {
+		c.gs.rp[0] = p
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func ttBool(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func ttAbs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ttMulDiv returns a*b/c rounded to the nearest integer.
+func ttMulDiv(a, b, c int64) int32 {
+	if c == 0 {
+		return 0
+	}
+	v := a * b
+	if (v < 0) != (c < 0) {
+		return int32((v - c/2) / c)
+	}
+	return int32((v + c/2) / c)
+}
+
+// ttMul14 multiplies by a 2.14 fixed-point number.
+func ttMul14(a, b int32) int32 {
+	return int32((int64(a)*int64(b) + 0x2000) >> 14)
+}
+
+func ttFloorDiv(a, b int32) int32 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// ttNormalize returns the unit vector in 2.14 along (x,y).
+func ttNormalize(x, y int32) ttVector {
+	if x == 0 && y == 0 {
+		return ttVector{0x4000, 0}
+	}
+	length := math.Hypot(float64(x), float64(y))
+	return ttVector{int32(math.Round(float64(x) / length * 0x4000)), int32(math.Round(float64(y) / length * 0x4000))}
+}
+
+////////////////////////////////////////////////////////////////
+
+// CFF stem hinter, see https://adobe-type-tools.github.io/font-tech-notes/pdfs/5177.Type2.pdf
+// The stem edges of the hstem and vstem hints are snapped to the pixel grid, and horizontal stem edges are aligned to the blue zones. Other points are interpolated between the stem edges. Coordinates are in 16.16 fixed-point charstring units.
+
+type cffStem struct {
+	lo, hi float64
+	ghost  int8 // -1 for a ghost bottom edge, 1 for a ghost top edge
+}
+
+type cffBlueZone struct {
+	bottom, top, flat float64
+	isTop             bool
+}
+
+type cffHintEdge struct {
+	orig, hinted float64
+}
+
+type cffHinter struct {
+	scale             float64 // pixels per charstring unit
+	hinting           Hinting
+	blues             []cffBlueZone
+	blueFuzz          float64
+	blueShift         float64
+	suppressOvershoot bool
+	stdHW, stdVW      float64
+
+	hstems, vstems []cffStem
+	mask           []byte // hint mask over hstems followed by vstems, nil if all are active
+	dirty          bool
+	ymap, xmap     []cffHintEdge // sorted by original coordinate
+}
+
+func newCFFHinter(top *cffTopDICT, private *cffPrivateDICT, ppem uint16, hinting Hinting) *cffHinter {
+	const unit = 1 << 16
+	fm := 0.001
+	if top != nil && top.FontMatrix[3] != 0.0 {
+		fm = math.Abs(top.FontMatrix[3])
+	}
+	h := &cffHinter{
+		scale:   float64(ppem) * fm / unit,
+		hinting: hinting,
+	}
+	if private == nil {
+		return h
+	}
+
+	// blue values are delta encoded pairs, the first zone of BlueValues is the baseline zone
+	maxZoneHeight := 0.0
+	addZones := func(values []float64, isTop bool) {
+		v := 0.0
+		for i := 0; i+1 < len(values); i += 2 {
+			bottom := v + values[i]
+			top := bottom + values[i+1]
+			v = top
+			if top < bottom {
+				continue
+			}
+			maxZoneHeight = math.Max(maxZoneHeight, top-bottom)
+			zone := cffBlueZone{bottom: bottom * unit, top: top * unit, flat: top * unit}
+			if isTop && i != 0 {
+				zone.isTop = true
+				zone.flat = zone.bottom
+			}
+			h.blues = append(h.blues, zone)
+		}
+	}
+	addZones(private.BlueValues, true)
+	addZones(private.OtherBlues, false)
+
+	blueScale := private.BlueScale
+	if 0.0 < maxZoneHeight && 1.0/maxZoneHeight < blueScale {
+		blueScale = 1.0 / maxZoneHeight
+	}
+	h.suppressOvershoot = float64(ppem)*fm < blueScale
+	h.blueFuzz = private.BlueFuzz * unit
+	h.blueShift = private.BlueShift * unit
+	h.stdHW = private.StdHW * unit
+	h.stdVW = private.StdVW * unit
+	return h
+}
+
+// addStems adds the stem hints of the hstem, vstem, or hintmask operators.
+func (h *cffHinter) addStems(stack []int32, vertical bool) {
+	v := 0.0
+	for i := 0; i+1 < len(stack); i += 2 {
+		lo := v + float64(stack[i])
+		width := float64(stack[i+1])
+		v = lo + width
+		stem := cffStem{lo: lo, hi: lo + width}
+		if width == -21*(1<<16) {
+			stem.lo, stem.ghost = stem.hi, -1
+		} else if width == -20*(1<<16) {
+			stem.hi, stem.ghost = stem.lo, 1
+		} else if width < 0.0 {
+			stem.lo, stem.hi = stem.hi, stem.lo
+		}
+		if vertical {
+			h.vstems = append(h.vstems, stem)
+		} else {
+			h.hstems = append(h.hstems, stem)
+		}
+	}
+	h.dirty = true
+}
+
+// numStems returns the number of stems, which determines the length of the hint mask.
+func (h *cffHinter) numStems() int {
+	return len(h.hstems) + len(h.vstems)
+}
+
+// setMask sets the active stems.
+func (h *cffHinter) setMask(mask []byte) {
+	h.mask = mask
+	h.dirty = true
+}
+
+func (h *cffHinter) active(i int) bool {
+	return h.mask == nil || h.mask[i/8]&(0x80>>(i%8)) != 0
+}
+
+// X returns the hinted x coordinate.
+func (h *cffHinter) X(x int32) float64 {
+	if h.dirty {
+		h.build()
+	}
+	return cffMapHint(h.xmap, float64(x))
+}
+
+// Y returns the hinted y coordinate.
+func (h *cffHinter) Y(y int32) float64 {
+	if h.dirty {
+		h.build()
+	}
+	return cffMapHint(h.ymap, float64(y))
+}
+
+func (h *cffHinter) build() {
+	h.dirty = false
+	stems := []cffStem{}
+	for i, stem := range h.hstems {
+		if h.active(i) {
+			stems = append(stems, stem)
+		}
+	}
+	h.ymap = h.buildMap(stems, h.stdHW, true)
+
+	h.xmap = h.xmap[:0]
+	if h.hinting == FullHinting {
+		stems = stems[:0]
+		for i, stem := range h.vstems {
+			if h.active(len(h.hstems) + i) {
+				stems = append(stems, stem)
+			}
+		}
+		h.xmap = h.buildMap(stems, h.stdVW, false)
+	}
+}
+
+// capture returns the hinted edge in pixels if the edge lies in a blue zone.
+func (h *cffHinter) capture(edge float64, isTop bool) (float64, bool) {
+	for _, zone := range h.blues {
+		if zone.isTop != isTop || edge < zone.bottom-h.blueFuzz || zone.top+h.blueFuzz < edge {
+			continue
+		}
+		flat := math.Round(zone.flat * h.scale)
+		if h.suppressOvershoot {
+			return flat, true
+		} else if !isTop && h.blueShift <= zone.flat-edge {
+			return math.Min(math.Round(edge*h.scale), flat-1.0), true
+		} else if isTop && h.blueShift <= edge-zone.flat {
+			return math.Max(math.Round(edge*h.scale), flat+1.0), true
+		}
+		return math.Round(edge * h.scale), true
+	}
+	return 0.0, false
+}
+
+// buildMap snaps the stem edges to the pixel grid and returns the hinted edges sorted by their original position.
+func (h *cffHinter) buildMap(stems []cffStem, stdWidth float64, blues bool) []cffHintEdge {
+	type hintedStem struct {
+		lo, hi   cffHintEdge
+		captured bool
+	}
+	hinted := make([]hintedStem, 0, len(stems))
+	for _, stem := range stems {
+		// stem width in pixels, snapped to the standard stem width if close
+		width := stem.hi - stem.lo
+		if stem.ghost == 0 && 0.0 < stdWidth && math.Abs(width-stdWidth)*h.scale < 0.5 {
+			width = stdWidth
+		}
+		pxWidth := 0.0
+		if stem.ghost == 0 {
+			pxWidth = math.Max(1.0, math.Round(width*h.scale))
+		}
+
+		s := hintedStem{lo: cffHintEdge{orig: stem.lo}, hi: cffHintEdge{orig: stem.hi}}
+		var loOK, hiOK bool
+		if blues {
+			if stem.ghost != 1 {
+				s.lo.hinted, loOK = h.capture(stem.lo, false)
+			}
+			if stem.ghost != -1 {
+				s.hi.hinted, hiOK = h.capture(stem.hi, true)
+			}
+		}
+		s.captured = loOK || hiOK
+		if loOK && !hiOK {
+			s.hi.hinted = s.lo.hinted + pxWidth
+		} else if !loOK && hiOK {
+			s.lo.hinted = s.hi.hinted - pxWidth
+		} else if !loOK && !hiOK {
+			center := (stem.lo + stem.hi) / 2.0 * h.scale
+			s.lo.hinted = math.Round(center - pxWidth/2.0)
+			s.hi.hinted = s.lo.hinted + pxWidth
+		}
+		s.lo.hinted /= h.scale
+		s.hi.hinted /= h.scale
+		hinted = append(hinted, s)
+	}
+
+	// insert stems captured by blue zones first, and skip stems that overlap or would change the order of the edges
+	edges := []cffHintEdge{}
+	insert := func(s hintedStem) {
+		i := 0
+		for i < len(edges) && edges[i].orig < s.lo.orig {
+			i++
+		}
+		if i%2 == 1 || i < len(edges) && edges[i].orig <= s.hi.orig {
+			return // overlaps another stem
+		} else if 0 < i && s.lo.hinted < edges[i-1].hinted || i < len(edges) && edges[i].hinted < s.hi.hinted {
+			return // edges would cross
+		}
+		edges = append(edges[:i], append([]cffHintEdge{s.lo, s.hi}, edges[i:]...)...)
+	}
+	for _, s := range hinted {
+		if s.captured {
+			insert(s)
+		}
+	}
+	for _, s := range hinted {
+		if !s.captured {
+			insert(s)
+		}
+	}
+	return edges
+}
+
+// cffMapHint maps a coordinate using the hinted edges, coordinates between edges are interpolated linearly and coordinates outside are shifted along with the nearest edge.
+func cffMapHint(edges []cffHintEdge, v float64) float64 {
+	if len(edges) == 0 {
+		return v
+	} else if v <= edges[0].orig {
+		return v + edges[0].hinted - edges[0].orig
+	}
+	for i := 1; i < len(edges); i++ {
+		if v <= edges[i].orig {
+			e0, e1 := edges[i-1], edges[i]
+			if e1.orig == e0.orig {
+				return e1.hinted
+			}
+			return e0.hinted + (v-e0.orig)*(e1.hinted-e0.hinted)/(e1.orig-e0.orig)
+		}
+	}
+	last := edges[len(edges)-1]
+	return v + last.hinted - last.orig
+}
@@ -173,7 +173,7 @@ type coverageFormat1 struct {
 
 func (table *coverageFormat1) Index(glyphID uint16) (uint16, bool) {
 	for i, coverageGlyphID := range table.glyphArray {
-		if coverageGlyphID < glyphID {
+		if glyphID < coverageGlyphID {
 			break
 		} else if coverageGlyphID == glyphID {
 			return uint16(i), true
@@ -190,9 +190,9 @@ type coverageFormat2 struct {
 
 func (table *coverageFormat2) Index(glyphID uint16) (uint16, bool) {
 	for i := 0; i < len(table.startGlyphID); i++ {
-		if table.endGlyphID[i] < glyphID {
+		if glyphID < table.startGlyphID[i] {
 			break
-		} else if table.startGlyphID[i] <= glyphID {
+		} else if glyphID <= table.endGlyphID[i] {
 			return table.startCoverageIndex[i] + glyphID - table.startGlyphID[i], true
 		}
 	}
@@ -463,7 +463,7 @@ type pairPosFormat2 struct {
 func (table *pairPosFormat2) Get(glyphID1, glyphID2 uint16) (ValueRecord, ValueRecord, bool) {
 	if _, ok := table.Index(glyphID1); ok {
 		class1 := table.classDef1.Get(glyphID1)
-		class2 := table.classDef1.Get(glyphID2)
+		class2 := table.classDef2.Get(glyphID2)
 		return table.class1Records[class1][class2].valueRecord1, table.class1Records[class1][class2].valueRecord2, true
 	}
 	return ValueRecord{}, ValueRecord{}, false
@@ -763,6 +763,29 @@ func (table *gposgsubTable) GetLookups(script ScriptTag, language LanguageTag, f
 	return tables, nil
 }
 
+// kerning returns the horizontal advance adjustment of the first glyph from the pair adjustment lookups of the kern feature.
+func (table *gposgsubTable) kerning(left, right uint16) (int16, bool) {
+	for i, tag := range table.featureList.tag {
+		if tag != KernFeature {
+			continue
+		}
+		for _, lookupIndex := range table.featureList.feature[i] {
+			if len(table.tables) <= int(lookupIndex) {
+				continue
+			}
+			subtables, _ := table.tables[lookupIndex].([]interface{})
+			for _, subtable := range subtables {
+				if pairPos, ok := subtable.(pairPosTable); ok {
+					if valueRecord1, _, ok := pairPos.Get(left, right); ok {
+						return valueRecord1.XAdvance, true
+					}
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
 type subtableMap map[uint16]func([]byte) (interface{}, error)
 
 func (sfnt *SFNT) parseGPOS() error {
@@ -2,6 +2,8 @@ package font
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/tdewolff/parse/v2"
 )
@@ -137,6 +139,156 @@ func (sfnt *SFNT) parseOS2() error {
 	return nil
 }
 
+func (os2 *os2Table) Write() []byte {
+	w := parse.NewBinaryWriter(make([]byte, 0, 100))
+	w.WriteUint16(os2.Version)
+	w.WriteInt16(os2.XAvgCharWidth)
+	w.WriteUint16(os2.UsWeightClass)
+	w.WriteUint16(os2.UsWidthClass)
+	w.WriteUint16(os2.FsType)
+	w.WriteInt16(os2.YSubscriptXSize)
+	w.WriteInt16(os2.YSubscriptYSize)
+	w.WriteInt16(os2.YSubscriptXOffset)
+	w.WriteInt16(os2.YSubscriptYOffset)
+	w.WriteInt16(os2.YSuperscriptXSize)
+	w.WriteInt16(os2.YSuperscriptYSize)
+	w.WriteInt16(os2.YSuperscriptXOffset)
+	w.WriteInt16(os2.YSuperscriptYOffset)
+	w.WriteInt16(os2.YStrikeoutSize)
+	w.WriteInt16(os2.YStrikeoutPosition)
+	w.WriteInt16(os2.SFamilyClass)
+	w.WriteUint8(os2.BFamilyType)
+	w.WriteUint8(os2.BSerifStyle)
+	w.WriteUint8(os2.BWeight)
+	w.WriteUint8(os2.BProportion)
+	w.WriteUint8(os2.BContrast)
+	w.WriteUint8(os2.BStrokeVariation)
+	w.WriteUint8(os2.BArmStyle)
+	w.WriteUint8(os2.BLetterform)
+	w.WriteUint8(os2.BMidline)
+	w.WriteUint8(os2.BXHeight)
+	w.WriteUint32(os2.UlUnicodeRange1)
+	w.WriteUint32(os2.UlUnicodeRange2)
+	w.WriteUint32(os2.UlUnicodeRange3)
+	w.WriteUint32(os2.UlUnicodeRange4)
+	w.WriteBytes(os2.AchVendID[:])
+	w.WriteUint16(os2.FsSelection)
+	w.WriteUint16(os2.UsFirstCharIndex)
+	w.WriteUint16(os2.UsLastCharIndex)
+	w.WriteInt16(os2.STypoAscender)
+	w.WriteInt16(os2.STypoDescender)
+	w.WriteInt16(os2.STypoLineGap)
+	w.WriteUint16(os2.UsWinAscent)
+	w.WriteUint16(os2.UsWinDescent)
+	if os2.Version == 0 {
+		return w.Bytes()
+	}
+	w.WriteUint32(os2.UlCodePageRange1)
+	w.WriteUint32(os2.UlCodePageRange2)
+	if os2.Version == 1 {
+		return w.Bytes()
+	}
+	w.WriteInt16(os2.SxHeight)
+	w.WriteInt16(os2.SCapHeight)
+	w.WriteUint16(os2.UsDefaultChar)
+	w.WriteUint16(os2.UsBreakChar)
+	w.WriteUint16(os2.UsMaxContent)
+	if os2.Version <= 4 {
+		return w.Bytes()
+	}
+	w.WriteUint16(os2.UsLowerOpticalPointSize)
+	w.WriteUint16(os2.UsUpperOpticalPointSize)
+	return w.Bytes()
+}
+
+// newOS2Table returns a version 4 OS/2 table for a new font. The sizes and offsets of subscripts, superscripts, and the strikeout are proportional to the em size, and the average character width, Unicode ranges, first and last character, code page, and style flags are derived from the advance widths, the sorted runes of the character map, the weight class, and whether the font is italic. Vertical metrics are left for the caller.
+func newOS2Table(unitsPerEm, weightClass uint16, isItalic bool, advances []uint16, rs []rune) *os2Table {
+	em := float64(unitsPerEm)
+	os2 := &os2Table{
+		Version:             4,
+		UsWeightClass:       weightClass,
+		UsWidthClass:        5, // medium
+		YSubscriptXSize:     int16(math.Round(0.65 * em)),
+		YSubscriptYSize:     int16(math.Round(0.6 * em)),
+		YSubscriptYOffset:   int16(math.Round(0.075 * em)),
+		YSuperscriptXSize:   int16(math.Round(0.65 * em)),
+		YSuperscriptYSize:   int16(math.Round(0.6 * em)),
+		YSuperscriptYOffset: int16(math.Round(0.35 * em)),
+		YStrikeoutSize:      int16(math.Round(0.05 * em)),
+		YStrikeoutPosition:  int16(math.Round(0.22 * em)),
+		AchVendID:           [4]byte{' ', ' ', ' ', ' '},
+		UsBreakChar:         ' ',
+		UsMaxContent:        1,
+	}
+
+	sumAdvances, numAdvances := 0, 0
+	for _, advance := range advances {
+		if advance != 0 {
+			sumAdvances += int(advance)
+			numAdvances++
+		}
+	}
+	if numAdvances != 0 {
+		os2.XAvgCharWidth = int16(math.Round(float64(sumAdvances) / float64(numAdvances)))
+	}
+
+	ulUnicodeRange := os2UlUnicodeRange(rs)
+	os2.UlUnicodeRange1 = ulUnicodeRange[0]
+	os2.UlUnicodeRange2 = ulUnicodeRange[1]
+	os2.UlUnicodeRange3 = ulUnicodeRange[2]
+	os2.UlUnicodeRange4 = ulUnicodeRange[3]
+	isSymbol := 0 < len(rs)
+	for _, r := range rs {
+		if r < 0xF000 || 0xF0FF < r {
+			isSymbol = false
+			break
+		}
+	}
+	if 0 < len(rs) {
+		os2.UsFirstCharIndex = uint16(min(0xFFFF, rs[0]))
+		os2.UsLastCharIndex = uint16(min(0xFFFF, rs[len(rs)-1]))
+	}
+	if isSymbol {
+		os2.UlCodePageRange1 = 1 << 31 // symbol character set
+	} else {
+		os2.UlCodePageRange1 = 1 << 0 // Latin 1
+	}
+
+	isBold := 700 <= weightClass
+	if isItalic {
+		os2.FsSelection |= 0x0001 // ITALIC
+	}
+	if isBold {
+		os2.FsSelection |= 0x0020 // BOLD
+	}
+	if !isItalic && !isBold {
+		os2.FsSelection |= 0x0040 // REGULAR
+	}
+	return os2
+}
+
+// os2WeightClass returns the OS/2 weight class for a weight name such as Light or Bold. Medium is regarded as the regular weight, since it is commonly used as such by Type 1 fonts.
+func os2WeightClass(weight string) uint16 {
+	weight = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(weight))
+	switch weight {
+	case "thin", "hairline":
+		return 100
+	case "extralight", "ultralight":
+		return 200
+	case "light":
+		return 300
+	case "semibold", "demibold", "demi":
+		return 600
+	case "bold":
+		return 700
+	case "extrabold", "ultrabold", "heavy":
+		return 800
+	case "black", "ultra":
+		return 900
+	}
+	return 400
+}
+
 func (sfnt *SFNT) estimateOS2() {
 	if sfnt.IsTrueType {
 		contour, err := sfnt.Glyf.Contour(sfnt.GlyphIndex('x'))
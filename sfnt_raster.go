@@ -0,0 +1,448 @@
+package font
+
+import (
+	"image"
+	"math"
+)
+
+// FillRule is the winding rule used to determine which parts of a path are filled.
+type FillRule int
+
+// see FillRule
+const (
+	NonZero FillRule = iota // filled when the winding number is not zero, used by TrueType, CFF, and CFF2 outlines
+	EvenOdd                 // filled when the winding number is odd
+)
+
+// rasterTolerance is the maximum deviation in pixels when flattening curves.
+const rasterTolerance = 0.05
+
+// Rasterizer is a Pather that computes the exact area coverage of a path for each pixel of an image. Coordinates are in pixels with the origin at the top-left corner and the Y axis pointing down.
+type Rasterizer struct {
+	FillRule
+
+	width, height int
+	area          []float64 // signed area and cover accumulation buffer, with two extra columns per row
+	start, pos    [2]float64
+}
+
+// NewRasterizer returns a new rasterizer for an image of the given size.
+func NewRasterizer(width, height int, fillRule FillRule) *Rasterizer {
+	return &Rasterizer{
+		FillRule: fillRule,
+		width:    width,
+		height:   height,
+		area:     make([]float64, (width+2)*height),
+	}
+}
+
+// MoveTo starts a new contour, closing the previous one.
+func (r *Rasterizer) MoveTo(x, y float64) {
+	r.Close()
+	r.start = [2]float64{x, y}
+	r.pos = r.start
+}
+
+// LineTo adds a line segment.
+func (r *Rasterizer) LineTo(x, y float64) {
+	r.line(r.pos[0], r.pos[1], x, y)
+	r.pos = [2]float64{x, y}
+}
+
+// QuadTo adds a quadratic Bézier curve.
+func (r *Rasterizer) QuadTo(cpx, cpy, x, y float64) {
+	x0, y0 := r.pos[0], r.pos[1]
+	dd := math.Hypot(x0-2.0*cpx+x, y0-2.0*cpy+y)
+	n := int(math.Ceil(math.Sqrt(dd / (4.0 * rasterTolerance))))
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1.0 - t
+		r.LineTo(u*u*x0+2.0*u*t*cpx+t*t*x, u*u*y0+2.0*u*t*cpy+t*t*y)
+	}
+	r.LineTo(x, y)
+}
+
+// CubeTo adds a cubic Bézier curve.
+func (r *Rasterizer) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	x0, y0 := r.pos[0], r.pos[1]
+	dd := math.Max(math.Hypot(x0-2.0*cpx1+cpx2, y0-2.0*cpy1+cpy2), math.Hypot(cpx1-2.0*cpx2+x, cpy1-2.0*cpy2+y))
+	n := int(math.Ceil(math.Sqrt(3.0 * dd / (4.0 * rasterTolerance))))
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1.0 - t
+		r.LineTo(u*u*u*x0+3.0*u*u*t*cpx1+3.0*u*t*t*cpx2+t*t*t*x, u*u*u*y0+3.0*u*u*t*cpy1+3.0*u*t*t*cpy2+t*t*t*y)
+	}
+	r.LineTo(x, y)
+}
+
+// Close closes the current contour.
+func (r *Rasterizer) Close() {
+	if r.pos != r.start {
+		r.LineTo(r.start[0], r.start[1])
+	}
+}
+
+// line accumulates the signed area and cover of a line segment for each pixel it crosses. The accumulated values of a row summed from left to right give the winding number weighted by coverage. The segment is split at the left and right edges of the image, so that its parts outside the image become vertical lines along the edges.
+func (r *Rasterizer) line(x0, y0, x1, y1 float64) {
+	for _, edge := range [2]float64{0.0, float64(r.width)} {
+		if (x0 < edge) != (x1 < edge) && x0 != edge && x1 != edge {
+			y := y0 + (edge-x0)*(y1-y0)/(x1-x0)
+			r.line(x0, y0, edge, y)
+			r.line(edge, y, x1, y1)
+			return
+		}
+	}
+	r.lineClamped(x0, y0, x1, y1)
+}
+
+// lineClamped accumulates a line segment that does not cross the left or right edges of the image.
+func (r *Rasterizer) lineClamped(x0, y0, x1, y1 float64) {
+	if y0 == y1 {
+		return
+	}
+	dir := 1.0
+	if y1 < y0 {
+		dir = -1.0
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	if y1 <= 0.0 || float64(r.height) <= y0 {
+		return
+	}
+
+	stride := r.width + 2
+	dxdy := (x1 - x0) / (y1 - y0)
+	x := x0
+	if y0 < 0.0 {
+		x -= y0 * dxdy
+	}
+	clamp := func(x float64) float64 {
+		return math.Max(0.0, math.Min(x, float64(r.width)))
+	}
+	for y := max(0, int(y0)); y < min(r.height, int(math.Ceil(y1))); y++ {
+		row := r.area[y*stride : (y+1)*stride]
+		dy := math.Min(float64(y+1), y1) - math.Max(float64(y), y0)
+		xNext := x + dxdy*dy
+		d := dy * dir
+
+		xa, xb := clamp(x), clamp(xNext)
+		if xb < xa {
+			xa, xb = xb, xa
+		}
+		xaFloor, xbCeil := math.Floor(xa), math.Ceil(xb)
+		ia, ib := int(xaFloor), int(xbCeil)
+		if ib <= ia+1 {
+			// within a single pixel
+			xm := 0.5*(xa+xb) - xaFloor
+			row[ia] += d - d*xm
+			row[ia+1] += d * xm
+		} else {
+			s := 1.0 / (xb - xa)
+			xaFrac := xa - xaFloor
+			a0 := 0.5 * s * (1.0 - xaFrac) * (1.0 - xaFrac)
+			xbFrac := xb - xbCeil + 1.0
+			am := 0.5 * s * xbFrac * xbFrac
+			row[ia] += d * a0
+			if ib == ia+2 {
+				row[ia+1] += d * (1.0 - a0 - am)
+			} else {
+				a1 := s * (1.5 - xaFrac)
+				row[ia+1] += d * (a1 - a0)
+				for i := ia + 2; i < ib-1; i++ {
+					row[i] += d * s
+				}
+				a2 := a1 + float64(ib-ia-3)*s
+				row[ib-1] += d * (1.0 - a2 - am)
+			}
+			row[ib] += d * am
+		}
+		x = xNext
+	}
+}
+
+// Alpha returns the coverage of the path, where 0 is background and 255 is foreground.
+func (r *Rasterizer) Alpha() *image.Alpha {
+	r.Close()
+
+	img := image.NewAlpha(image.Rect(0, 0, r.width, r.height))
+	stride := r.width + 2
+	for y := 0; y < r.height; y++ {
+		acc := 0.0
+		for x := 0; x < r.width; x++ {
+			acc += r.area[y*stride+x]
+			coverage := math.Abs(acc)
+			if r.FillRule == EvenOdd {
+				coverage = math.Mod(coverage, 2.0)
+				if 1.0 < coverage {
+					coverage = 2.0 - coverage
+				}
+			} else if 1.0 < coverage {
+				coverage = 1.0
+			}
+			img.Pix[img.PixOffset(x, y)] = uint8(coverage*255.0 + 0.5)
+		}
+	}
+	return img
+}
+
+// imagePather converts glyph coordinates with the Y axis pointing up to image coordinates with the Y axis pointing down, where the image coordinates may be scaled to a subpixel resolution.
+type imagePather struct {
+	Pather
+	sx, sy float64 // scale
+	x0, y1 float64 // left and top edge of the image in pixels
+}
+
+func (p imagePather) point(x, y float64) (float64, float64) {
+	return p.sx * (x - p.x0), p.sy * (p.y1 - y)
+}
+
+func (p imagePather) MoveTo(x, y float64) {
+	p.Pather.MoveTo(p.point(x, y))
+}
+
+func (p imagePather) LineTo(x, y float64) {
+	p.Pather.LineTo(p.point(x, y))
+}
+
+func (p imagePather) QuadTo(cpx, cpy, x, y float64) {
+	cpx, cpy = p.point(cpx, cpy)
+	x, y = p.point(x, y)
+	p.Pather.QuadTo(cpx, cpy, x, y)
+}
+
+func (p imagePather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	cpx1, cpy1 = p.point(cpx1, cpy1)
+	cpx2, cpy2 = p.point(cpx2, cpy2)
+	x, y = p.point(x, y)
+	p.Pather.CubeTo(cpx1, cpy1, cpx2, cpy2, x, y)
+}
+
+// GlyphRaster is a rasterized glyph outline at a specific ppem (pixels-per-EM). The image holds the glyph's coverage, where 0 is background and 255 is foreground. The origin of the image is at its top-left corner.
+type GlyphRaster struct {
+	*image.Alpha
+	BearingX, BearingY int // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Advance            int // horizontal advance in pixels
+}
+
+// rasterAdvance returns the advance in pixels of a rasterized glyph. Unhinted glyphs use the rounded linear advance, since the hinted advance does not match their outline.
+func (sfnt *SFNT) rasterAdvance(glyphID, ppem uint16, hinting Hinting) int {
+	if hinting == AutoHinting {
+		hinting = sfnt.Hinting(ppem)
+	}
+	if hinting == NoHinting {
+		return int(math.Round(float64(sfnt.GlyphAdvance(glyphID)) * float64(ppem) / float64(sfnt.Head.UnitsPerEm)))
+	}
+	return int(sfnt.GlyphAdvancePPEM(glyphID, ppem))
+}
+
+// RasterizeGlyph rasterizes the glyph outline at the given ppem (pixels-per-EM) using the nonzero winding rule. The subpixel offset in [0,1) shifts the glyph to the right before rasterization, which allows positioning glyphs at fractional pixel positions. Glyphs without an outline return an empty image.
+func (sfnt *SFNT) RasterizeGlyph(glyphID, ppem uint16, hinting Hinting, subpixelOffset float64) (*GlyphRaster, error) {
+	f := float64(ppem) / float64(sfnt.Head.UnitsPerEm)
+	bounds := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+	if err := sfnt.GlyphPath(bounds, glyphID, ppem, subpixelOffset, 0.0, f, hinting); err != nil {
+		return nil, err
+	}
+
+	raster := &GlyphRaster{
+		Alpha:   image.NewAlpha(image.Rect(0, 0, 0, 0)),
+		Advance: sfnt.rasterAdvance(glyphID, ppem, hinting),
+	}
+	if math.IsInf(bounds.XMin, 0) {
+		return raster, nil
+	}
+
+	x0, x1 := int(math.Floor(bounds.XMin)), int(math.Ceil(bounds.XMax))
+	y0, y1 := int(math.Floor(bounds.YMin)), int(math.Ceil(bounds.YMax))
+	r := NewRasterizer(x1-x0, y1-y0, NonZero)
+	p := imagePather{Pather: r, sx: 1.0, sy: 1.0, x0: float64(x0), y1: float64(y1)}
+	if err := sfnt.GlyphPath(p, glyphID, ppem, subpixelOffset, 0.0, f, hinting); err != nil {
+		return nil, err
+	}
+	raster.Alpha = r.Alpha()
+	raster.BearingX = x0
+	raster.BearingY = y1
+	return raster, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// LCDLayout is the order and orientation of the subpixels of an LCD screen.
+type LCDLayout int
+
+// see LCDLayout
+const (
+	LCDRGB  LCDLayout = iota // horizontal red, green, blue
+	LCDBGR                   // horizontal blue, green, red
+	LCDVRGB                  // vertical red, green, blue from top to bottom
+	LCDVBGR                  // vertical blue, green, red from top to bottom
+)
+
+// LCDFilter is the filter applied to the subpixel coverage to reduce color fringes.
+type LCDFilter int
+
+// see LCDFilter
+const (
+	LCDFilterDefault LCDFilter = iota // FIR filter with weights 8, 77, 86, 77, 8 as FreeType's default filter
+	LCDFilterLight                    // FIR filter with weights 0, 85, 86, 85, 0 as FreeType's light filter, sharper but with more color fringes
+	LCDFilterLegacy                   // intra-pixel filter of FreeType's legacy filter, as used by older versions of libXft
+	LCDFilterNone                     // no filtering
+	LCDFilterCustom                   // FIR filter with the weights of LCDOptions.Weights
+)
+
+// LCDOptions are the options for subpixel rendering.
+type LCDOptions struct {
+	Layout  LCDLayout
+	Filter  LCDFilter
+	Weights [5]uint8 // FIR filter weights for LCDFilterCustom, which should sum to 256 to preserve brightness
+}
+
+var lcdFilterWeights = map[LCDFilter][5]uint8{
+	LCDFilterDefault: {0x08, 0x4D, 0x56, 0x4D, 0x08},
+	LCDFilterLight:   {0x00, 0x55, 0x56, 0x55, 0x00},
+}
+
+// lcdLegacyFilter are the weights of FreeType's legacy filter in 16.16 fixed point, for each subpixel (row) its contribution to the red, green, and blue output (column).
+var lcdLegacyFilter = [3][3]uint32{
+	{65538 * 9 / 13, 65538 * 1 / 6, 65538 * 1 / 13},
+	{65538 * 3 / 13, 65538 * 4 / 6, 65538 * 3 / 13},
+	{65538 * 1 / 13, 65538 * 1 / 6, 65538 * 9 / 13},
+}
+
+// lcdFilter filters a line of n subpixels of which the values are the stride apart.
+func lcdFilter(pix []uint8, n, stride int, options LCDOptions) {
+	if options.Filter == LCDFilterLegacy {
+		for i := 0; i+2 < n; i += 3 {
+			var rgb [3]uint32
+			for j := 0; j < 3; j++ {
+				v := uint32(pix[(i+j)*stride])
+				for k := 0; k < 3; k++ {
+					rgb[k] += lcdLegacyFilter[j][k] * v
+				}
+			}
+			for k := 0; k < 3; k++ {
+				pix[(i+k)*stride] = uint8(min(255, rgb[k]>>16))
+			}
+		}
+		return
+	}
+
+	weights, ok := lcdFilterWeights[options.Filter]
+	if options.Filter == LCDFilterCustom {
+		weights, ok = options.Weights, true
+	}
+	if !ok {
+		return
+	}
+	line := make([]uint8, n)
+	for i := range line {
+		line[i] = pix[i*stride]
+	}
+	for i := 0; i < n; i++ {
+		v := uint32(0)
+		for k, w := range weights {
+			if j := i + k - 2; 0 <= j && j < n {
+				v += uint32(w) * uint32(line[j])
+			}
+		}
+		pix[i*stride] = uint8(min(255, (v+128)>>8))
+	}
+}
+
+// GlyphLCD is a glyph rasterized for subpixel rendering on LCD screens at a specific ppem (pixels-per-EM). The image holds the glyph's coverage for each of the red, green, and blue subpixels, where the alpha channel is the maximum of the three. The origin of the image is at its top-left corner.
+type GlyphLCD struct {
+	*image.RGBA
+	BearingX, BearingY int // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Advance            int // horizontal advance in pixels
+}
+
+// RasterizeGlyphLCD rasterizes the glyph outline at the given ppem (pixels-per-EM) at three times the resolution along the subpixels of the layout, and filters the subpixels to reduce color fringes. For horizontal layouts, full hinting is reduced to vertical hinting as with FreeType's v40 interpreter and the automatic hinter only hints vertically, so that the subpixel offset and subpixel precision of horizontal stems are preserved. The subpixel offset in [0,1) shifts the glyph to the right before rasterization.
+func (sfnt *SFNT) RasterizeGlyphLCD(glyphID, ppem uint16, hinting Hinting, subpixelOffset float64, options LCDOptions) (*GlyphLCD, error) {
+	vertical := options.Layout == LCDVRGB || options.Layout == LCDVBGR
+	if hinting == AutoHinting {
+		hinting = sfnt.Hinting(ppem)
+	}
+	if !vertical && hinting == FullHinting {
+		hinting = VerticalHinting
+	}
+
+	f := float64(ppem) / float64(sfnt.Head.UnitsPerEm)
+	glyphPath := func(p Pather) error {
+		if !vertical && hinting == AutohinterHinting && ppem != 0 {
+			// snapping x coordinates would cancel the subpixel offset
+			return sfnt.autohint(p, glyphID, ppem, subpixelOffset, 0.0, f, true)
+		}
+		return sfnt.GlyphPath(p, glyphID, ppem, subpixelOffset, 0.0, f, hinting)
+	}
+	bounds := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+	if err := glyphPath(bounds); err != nil {
+		return nil, err
+	}
+
+	lcd := &GlyphLCD{
+		RGBA:    image.NewRGBA(image.Rect(0, 0, 0, 0)),
+		Advance: sfnt.rasterAdvance(glyphID, ppem, hinting),
+	}
+	if math.IsInf(bounds.XMin, 0) {
+		return lcd, nil
+	}
+
+	// pad by one pixel along the subpixels for the filter
+	x0, x1 := int(math.Floor(bounds.XMin)), int(math.Ceil(bounds.XMax))
+	y0, y1 := int(math.Floor(bounds.YMin)), int(math.Ceil(bounds.YMax))
+	if vertical {
+		y0--
+		y1++
+	} else {
+		x0--
+		x1++
+	}
+	width, height := x1-x0, y1-y0
+
+	p := imagePather{sx: 1.0, sy: 1.0, x0: float64(x0), y1: float64(y1)}
+	if vertical {
+		p.sy = 3.0
+		p.Pather = NewRasterizer(width, 3*height, NonZero)
+	} else {
+		p.sx = 3.0
+		p.Pather = NewRasterizer(3*width, height, NonZero)
+	}
+	if err := glyphPath(p); err != nil {
+		return nil, err
+	}
+	coverage := p.Pather.(*Rasterizer).Alpha()
+	if vertical {
+		for x := 0; x < width; x++ {
+			lcdFilter(coverage.Pix[x:], 3*height, coverage.Stride, options)
+		}
+	} else {
+		for y := 0; y < height; y++ {
+			lcdFilter(coverage.Pix[y*coverage.Stride:], 3*width, 1, options)
+		}
+	}
+
+	bgr := options.Layout == LCDBGR || options.Layout == LCDVBGR
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var rgb [3]uint8
+			for k := 0; k < 3; k++ {
+				if vertical {
+					rgb[k] = coverage.Pix[(3*y+k)*coverage.Stride+x]
+				} else {
+					rgb[k] = coverage.Pix[y*coverage.Stride+3*x+k]
+				}
+			}
+			if bgr {
+				rgb[0], rgb[2] = rgb[2], rgb[0]
+			}
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = rgb[0]
+			img.Pix[i+1] = rgb[1]
+			img.Pix[i+2] = rgb[2]
+			img.Pix[i+3] = max(rgb[0], rgb[1], rgb[2])
+		}
+	}
+	lcd.RGBA = img
+	lcd.BearingX = x0
+	lcd.BearingY = y1
+	return lcd, nil
+}
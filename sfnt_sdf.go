@@ -0,0 +1,474 @@
+package font
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Signed distance fields (SDF) and multi-channel signed distance fields (MSDF) following Chlumsky's msdfgen. Edges are colored by a combination of the red, green, and blue channels so that each corner of a contour is shared by edges of different channels. Each channel stores the signed pseudo-distance to the nearest edge of its color, which preserves sharp corners when taking the median of the channels.
+
+const (
+	sdfRed   uint8 = 1
+	sdfGreen uint8 = 2
+	sdfBlue  uint8 = 4
+
+	sdfYellow  = sdfRed | sdfGreen
+	sdfMagenta = sdfRed | sdfBlue
+	sdfCyan    = sdfGreen | sdfBlue
+	sdfWhite   = sdfRed | sdfGreen | sdfBlue
+)
+
+// sdfCornerThreshold is the sine of the minimum angle between edges to be considered a corner.
+var sdfCornerThreshold = math.Sin(3.0)
+
+type sdfVec [2]float64
+
+func (a sdfVec) sub(b sdfVec) sdfVec             { return sdfVec{a[0] - b[0], a[1] - b[1]} }
+func (a sdfVec) add(b sdfVec) sdfVec             { return sdfVec{a[0] + b[0], a[1] + b[1]} }
+func (a sdfVec) mul(f float64) sdfVec            { return sdfVec{a[0] * f, a[1] * f} }
+func (a sdfVec) dot(b sdfVec) float64            { return a[0]*b[0] + a[1]*b[1] }
+func (a sdfVec) cross(b sdfVec) float64          { return a[0]*b[1] - a[1]*b[0] }
+func (a sdfVec) length() float64                 { return math.Hypot(a[0], a[1]) }
+func (a sdfVec) lerp(b sdfVec, t float64) sdfVec { return a.add(b.sub(a).mul(t)) }
+
+func (a sdfVec) normalize() sdfVec {
+	if l := a.length(); l != 0.0 {
+		return a.mul(1.0 / l)
+	}
+	return sdfVec{0.0, 1.0}
+}
+
+func sdfSign(v float64) float64 {
+	if 0.0 < v {
+		return 1.0
+	}
+	return -1.0
+}
+
+// sdfDistance is a signed distance, where ties are broken by the orthogonality (smaller is better) of the edge to the query point.
+type sdfDistance struct {
+	dist, dot float64
+}
+
+func (a sdfDistance) less(b sdfDistance) bool {
+	return math.Abs(a.dist) < math.Abs(b.dist) || math.Abs(a.dist) == math.Abs(b.dist) && a.dot < b.dot
+}
+
+// sdfEdge is a line (two points) or a cubic Bézier curve (four points). Quadratic Bézier curves are elevated to cubic curves.
+type sdfEdge struct {
+	p     [4]sdfVec
+	cubic bool
+	color uint8
+}
+
+func (e *sdfEdge) end() sdfVec {
+	if e.cubic {
+		return e.p[3]
+	}
+	return e.p[1]
+}
+
+func (e *sdfEdge) point(t float64) sdfVec {
+	if !e.cubic {
+		return e.p[0].lerp(e.p[1], t)
+	}
+	p01, p12, p23 := e.p[0].lerp(e.p[1], t), e.p[1].lerp(e.p[2], t), e.p[2].lerp(e.p[3], t)
+	return p01.lerp(p12, t).lerp(p12.lerp(p23, t), t)
+}
+
+func (e *sdfEdge) direction(t float64) sdfVec {
+	if !e.cubic {
+		return e.p[1].sub(e.p[0])
+	} else if t == 0.0 {
+		if d := e.p[1].sub(e.p[0]); d != (sdfVec{}) {
+			return d
+		}
+		return e.p[2].sub(e.p[0])
+	} else if t == 1.0 {
+		if d := e.p[3].sub(e.p[2]); d != (sdfVec{}) {
+			return d
+		}
+		return e.p[3].sub(e.p[1])
+	}
+	p01, p12, p23 := e.p[0].lerp(e.p[1], t), e.p[1].lerp(e.p[2], t), e.p[2].lerp(e.p[3], t)
+	return p12.lerp(p23, t).sub(p01.lerp(p12, t))
+}
+
+// split splits the edge into three parts of equal parameter length.
+func (e *sdfEdge) split() [3]sdfEdge {
+	if !e.cubic {
+		a, b := e.point(1.0/3.0), e.point(2.0/3.0)
+		return [3]sdfEdge{
+			{p: [4]sdfVec{e.p[0], a}, color: e.color},
+			{p: [4]sdfVec{a, b}, color: e.color},
+			{p: [4]sdfVec{b, e.p[1]}, color: e.color},
+		}
+	}
+	sub := func(t0, t1 float64) sdfEdge {
+		// the derivative scaled by the parameter interval gives the control points
+		p0, p3 := e.point(t0), e.point(t1)
+		d0, d1 := e.derivative(t0).mul((t1-t0)/3.0), e.derivative(t1).mul((t1-t0)/3.0)
+		return sdfEdge{p: [4]sdfVec{p0, p0.add(d0), p3.sub(d1), p3}, cubic: true, color: e.color}
+	}
+	return [3]sdfEdge{sub(0.0, 1.0/3.0), sub(1.0/3.0, 2.0/3.0), sub(2.0/3.0, 1.0)}
+}
+
+func (e *sdfEdge) derivative(t float64) sdfVec {
+	u := 1.0 - t
+	return e.p[1].sub(e.p[0]).mul(3.0 * u * u).add(e.p[2].sub(e.p[1]).mul(6.0 * u * t)).add(e.p[3].sub(e.p[2]).mul(3.0 * t * t))
+}
+
+// signedDistance returns the signed distance from the edge to p, and the parameter of the nearest point on the edge, which lies outside [0,1] when the nearest point is an endpoint beyond which p lies. The distance is positive to the right of the edge.
+func (e *sdfEdge) signedDistance(p sdfVec) (sdfDistance, float64) {
+	if !e.cubic {
+		aq, ab := p.sub(e.p[0]), e.p[1].sub(e.p[0])
+		t := aq.dot(ab) / ab.dot(ab)
+		eq := e.p[0].sub(p)
+		if 0.5 < t {
+			eq = e.p[1].sub(p)
+		}
+		endDist := eq.length()
+		if 0.0 < t && t < 1.0 {
+			if ortho := aq.cross(ab) / ab.length(); math.Abs(ortho) < endDist {
+				return sdfDistance{ortho, 0.0}, t
+			}
+		}
+		return sdfDistance{sdfSign(aq.cross(ab)) * endDist, math.Abs(ab.normalize().dot(eq.normalize()))}, t
+	}
+
+	const starts, steps = 8, 4
+	qa := e.p[0].sub(p)
+	ab := e.p[1].sub(e.p[0])
+	br := e.p[2].sub(e.p[1]).sub(ab)
+	as := e.p[3].sub(e.p[2]).sub(e.p[2].sub(e.p[1])).sub(br)
+
+	dir := e.direction(0.0)
+	minDist := sdfSign(dir.cross(qa)) * qa.length()
+	t := -qa.dot(dir) / dir.dot(dir)
+	dir = e.direction(1.0)
+	if dist := e.p[3].sub(p).length(); dist < math.Abs(minDist) {
+		minDist = sdfSign(dir.cross(e.p[3].sub(p))) * dist
+		t = dir.sub(e.p[3].sub(p)).dot(dir) / dir.dot(dir)
+	}
+	for i := 0; i <= starts; i++ {
+		s := float64(i) / starts
+		qe := qa.add(ab.mul(3.0 * s)).add(br.mul(3.0 * s * s)).add(as.mul(s * s * s))
+		for j := 0; j < steps; j++ {
+			d1 := ab.mul(3.0).add(br.mul(6.0 * s)).add(as.mul(3.0 * s * s))
+			d2 := br.mul(6.0).add(as.mul(6.0 * s))
+			s -= qe.dot(d1) / (d1.dot(d1) + qe.dot(d2))
+			if s <= 0.0 || 1.0 <= s {
+				break
+			}
+			qe = qa.add(ab.mul(3.0 * s)).add(br.mul(3.0 * s * s)).add(as.mul(s * s * s))
+			if dist := qe.length(); dist < math.Abs(minDist) {
+				d1 = ab.mul(3.0).add(br.mul(6.0 * s)).add(as.mul(3.0 * s * s))
+				minDist = sdfSign(d1.cross(qe)) * dist
+				t = s
+			}
+		}
+	}
+
+	if 0.0 <= t && t <= 1.0 {
+		return sdfDistance{minDist, 0.0}, t
+	} else if t < 0.5 {
+		return sdfDistance{minDist, math.Abs(e.direction(0.0).normalize().dot(qa.normalize()))}, t
+	}
+	return sdfDistance{minDist, math.Abs(e.direction(1.0).normalize().dot(e.p[3].sub(p).normalize()))}, t
+}
+
+// pseudoDistance extends the edge beyond its endpoints along its tangents, and returns the distance to the extended edge if it is nearer.
+func (e *sdfEdge) pseudoDistance(d sdfDistance, p sdfVec, t float64) sdfDistance {
+	if t < 0.0 {
+		dir := e.direction(0.0).normalize()
+		aq := p.sub(e.p[0])
+		if aq.dot(dir) < 0.0 {
+			if pseudo := aq.cross(dir); math.Abs(pseudo) <= math.Abs(d.dist) {
+				return sdfDistance{pseudo, 0.0}
+			}
+		}
+	} else if 1.0 < t {
+		dir := e.direction(1.0).normalize()
+		bq := p.sub(e.end())
+		if 0.0 < bq.dot(dir) {
+			if pseudo := bq.cross(dir); math.Abs(pseudo) <= math.Abs(d.dist) {
+				return sdfDistance{pseudo, 0.0}
+			}
+		}
+	}
+	return d
+}
+
+// sdfPather records the contours of a glyph outline as edges.
+type sdfPather struct {
+	contours [][]sdfEdge
+	start    sdfVec
+	pos      sdfVec
+}
+
+func (p *sdfPather) MoveTo(x, y float64) {
+	p.Close()
+	p.contours = append(p.contours, nil)
+	p.start = sdfVec{x, y}
+	p.pos = p.start
+}
+
+func (p *sdfPather) LineTo(x, y float64) {
+	if end := (sdfVec{x, y}); end != p.pos {
+		p.add(sdfEdge{p: [4]sdfVec{p.pos, end}})
+	}
+}
+
+func (p *sdfPather) QuadTo(cpx, cpy, x, y float64) {
+	cp, end := sdfVec{cpx, cpy}, sdfVec{x, y}
+	p.add(sdfEdge{p: [4]sdfVec{p.pos, p.pos.lerp(cp, 2.0/3.0), end.lerp(cp, 2.0/3.0), end}, cubic: true})
+}
+
+func (p *sdfPather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	p.add(sdfEdge{p: [4]sdfVec{p.pos, {cpx1, cpy1}, {cpx2, cpy2}, {x, y}}, cubic: true})
+}
+
+func (p *sdfPather) Close() {
+	if 0 < len(p.contours) {
+		p.LineTo(p.start[0], p.start[1])
+	}
+}
+
+func (p *sdfPather) add(e sdfEdge) {
+	if len(p.contours) == 0 {
+		p.contours = append(p.contours, nil)
+	}
+	p.contours[len(p.contours)-1] = append(p.contours[len(p.contours)-1], e)
+	p.pos = e.end()
+}
+
+// orientation returns 1 if the outer contours are clockwise, such that distances to the right of the edges are inside, and -1 otherwise.
+func (p *sdfPather) orientation() float64 {
+	area := 0.0
+	for _, contour := range p.contours {
+		for _, e := range contour {
+			// the control polygon has the same orientation as the curve
+			end := 1
+			if e.cubic {
+				end = 3
+			}
+			for i := 0; i < end; i++ {
+				area += e.p[i].cross(e.p[i+1])
+			}
+		}
+	}
+	if 0.0 < area {
+		return -1.0
+	}
+	return 1.0
+}
+
+// colorEdges assigns colors to the edges such that the edges adjacent to a corner do not share all channels, as in msdfgen's simple edge coloring.
+func (p *sdfPather) colorEdges() {
+	switchColor := func(c uint8, banned uint8) uint8 {
+		if combined := c & banned; combined == sdfRed || combined == sdfGreen || combined == sdfBlue {
+			return combined ^ sdfWhite
+		} else if c == sdfWhite {
+			return sdfCyan
+		}
+		// rotate the two channels
+		shifted := c << 1
+		return (shifted | shifted>>3) & sdfWhite
+	}
+
+	for k, edges := range p.contours {
+		if len(edges) == 0 {
+			continue
+		}
+		corners := []int{}
+		prev := edges[len(edges)-1].direction(1.0).normalize()
+		for i := range edges {
+			cur := edges[i].direction(0.0).normalize()
+			if prev.dot(cur) <= 0.0 || sdfCornerThreshold < math.Abs(prev.cross(cur)) {
+				corners = append(corners, i)
+			}
+			prev = edges[i].direction(1.0).normalize()
+		}
+
+		if len(corners) == 0 {
+			// smooth contour
+			for i := range edges {
+				edges[i].color = sdfWhite
+			}
+		} else if len(corners) == 1 {
+			// teardrop, split the edges to have at least three
+			if len(edges) < 3 {
+				split := []sdfEdge{}
+				for i := range edges {
+					parts := edges[(corners[0]+i)%len(edges)].split()
+					split = append(split, parts[:]...)
+				}
+				edges = split
+				corners[0] = 0
+				p.contours[k] = edges
+			}
+			colors := [3]uint8{switchColor(sdfWhite, 0), sdfWhite, 0}
+			colors[2] = switchColor(colors[0], 0)
+			m := len(edges)
+			for i := 0; i < m; i++ {
+				trichotomy := int(3.0+2.875*float64(i)/float64(m-1)-1.4375+0.5) - 3
+				edges[(corners[0]+i)%m].color = colors[1+trichotomy]
+			}
+		} else {
+			spline := 0
+			c := switchColor(sdfWhite, 0)
+			initial := c
+			for i := range edges {
+				index := (corners[0] + i) % len(edges)
+				if spline+1 < len(corners) && corners[spline+1] == index {
+					spline++
+					banned := uint8(0)
+					if spline == len(corners)-1 {
+						banned = initial
+					}
+					c = switchColor(c, banned)
+				}
+				edges[index].color = c
+			}
+		}
+	}
+}
+
+// GlyphSDF is a signed distance field of a glyph at a specific ppem (pixels-per-EM). The image holds 128 on the outline, larger values inside and smaller values outside the glyph, changing by 255 over the distance range. The origin of the image is at its top-left corner.
+type GlyphSDF struct {
+	*image.Gray
+	BearingX, BearingY int     // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Range              float64 // distance range in pixels
+}
+
+// GlyphMSDF is a multi-channel signed distance field of a glyph at a specific ppem (pixels-per-EM). The median of the red, green, and blue channels gives the signed distance as in GlyphSDF, but preserves sharp corners. The origin of the image is at its top-left corner.
+type GlyphMSDF struct {
+	*image.RGBA
+	BearingX, BearingY int     // horizontal offset from the origin to the left edge, and from the baseline to the top edge (upwards) in pixels
+	Range              float64 // distance range in pixels
+}
+
+// sdfGlyph records the glyph outline in pixels and returns the image bounds that include the distance range around the outline.
+func (sfnt *SFNT) sdfGlyph(glyphID, ppem uint16, distanceRange float64) (*sdfPather, int, int, int, int, error) {
+	if distanceRange <= 0.0 {
+		return nil, 0, 0, 0, 0, fmt.Errorf("distance range must be positive")
+	}
+	f := float64(ppem) / float64(sfnt.Head.UnitsPerEm)
+	p := &sdfPather{}
+	if err := sfnt.GlyphPath(p, glyphID, ppem, 0.0, 0.0, f, NoHinting); err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+	p.Close()
+
+	bounds := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+	for _, contour := range p.contours {
+		for _, e := range contour {
+			n := 2
+			if e.cubic {
+				n = 4
+			}
+			for _, v := range e.p[:n] {
+				bounds.MoveTo(v[0], v[1])
+			}
+		}
+	}
+	if math.IsInf(bounds.XMin, 0) {
+		return p, 0, 0, 0, 0, nil
+	}
+	pad := math.Ceil(distanceRange / 2.0)
+	x0, x1 := int(math.Floor(bounds.XMin)-pad), int(math.Ceil(bounds.XMax)+pad)
+	y0, y1 := int(math.Floor(bounds.YMin)-pad), int(math.Ceil(bounds.YMax)+pad)
+	return p, x0, y0, x1, y1, nil
+}
+
+func sdfValue(d sdfDistance, distanceRange float64) uint8 {
+	v := 0.5 + d.dist/distanceRange
+	return uint8(math.Max(0.0, math.Min(v, 1.0))*255.0 + 0.5)
+}
+
+// GlyphSDF returns the signed distance field of the glyph at the given ppem (pixels-per-EM), where the distance range in pixels is the range of distances that can be represented, half inside and half outside the glyph. The image is padded by half the distance range.
+func (sfnt *SFNT) GlyphSDF(glyphID, ppem uint16, distanceRange float64) (*GlyphSDF, error) {
+	p, x0, y0, x1, y1, err := sfnt.sdfGlyph(glyphID, ppem, distanceRange)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := p.orientation()
+	img := image.NewGray(image.Rect(0, 0, x1-x0, y1-y0))
+	for j := 0; j < y1-y0; j++ {
+		for i := 0; i < x1-x0; i++ {
+			q := sdfVec{float64(x0+i) + 0.5, float64(y1-j) - 0.5}
+			minDist := sdfDistance{math.Inf(1), 0.0}
+			for _, contour := range p.contours {
+				for k := range contour {
+					if d, _ := contour[k].signedDistance(q); d.less(minDist) {
+						minDist = d
+					}
+				}
+			}
+			minDist.dist *= orientation
+			img.Pix[img.PixOffset(i, j)] = sdfValue(minDist, distanceRange)
+		}
+	}
+	return &GlyphSDF{
+		Gray:     img,
+		BearingX: x0,
+		BearingY: y1,
+		Range:    distanceRange,
+	}, nil
+}
+
+// GlyphMSDF returns the multi-channel signed distance field of the glyph at the given ppem (pixels-per-EM), where the distance range in pixels is the range of distances that can be represented, half inside and half outside the glyph. The image is padded by half the distance range.
+func (sfnt *SFNT) GlyphMSDF(glyphID, ppem uint16, distanceRange float64) (*GlyphMSDF, error) {
+	p, x0, y0, x1, y1, err := sfnt.sdfGlyph(glyphID, ppem, distanceRange)
+	if err != nil {
+		return nil, err
+	}
+	p.colorEdges()
+
+	type channel struct {
+		dist sdfDistance
+		edge *sdfEdge
+		t    float64
+	}
+	orientation := p.orientation()
+	img := image.NewRGBA(image.Rect(0, 0, x1-x0, y1-y0))
+	for j := 0; j < y1-y0; j++ {
+		for i := 0; i < x1-x0; i++ {
+			q := sdfVec{float64(x0+i) + 0.5, float64(y1-j) - 0.5}
+			channels := [3]channel{}
+			for c := range channels {
+				channels[c].dist = sdfDistance{math.Inf(1), 0.0}
+			}
+			for _, contour := range p.contours {
+				for k := range contour {
+					e := &contour[k]
+					d, t := e.signedDistance(q)
+					for c := range channels {
+						if e.color&(1<<c) != 0 && d.less(channels[c].dist) {
+							channels[c] = channel{d, e, t}
+						}
+					}
+				}
+			}
+
+			var rgb [3]uint8
+			for c, ch := range channels {
+				if ch.edge != nil {
+					ch.dist = ch.edge.pseudoDistance(ch.dist, q, ch.t)
+				}
+				ch.dist.dist *= orientation
+				rgb[c] = sdfValue(ch.dist, distanceRange)
+			}
+			img.SetRGBA(i, j, color.RGBA{rgb[0], rgb[1], rgb[2], 255})
+		}
+	}
+	return &GlyphMSDF{
+		RGBA:     img,
+		BearingX: x0,
+		BearingY: y1,
+		Range:    distanceRange,
+	}, nil
+}
@@ -34,29 +34,6 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 		glyphMap[glyphID] = uint16(subsetGlyphID)
 	}
 
-	if sfnt.IsTrueType {
-		// add dependencies for composite glyphs add the end
-		origLen := len(glyphIDs)
-		for i := 0; i < origLen; i++ {
-			if glyphIDs[i] == 0 {
-				continue
-			}
-			deps, err := sfnt.Glyf.Dependencies(glyphIDs[i])
-			if err != nil {
-				return nil, err
-			}
-			for _, glyphID := range deps[1:] {
-				if _, ok := glyphMap[glyphID]; !ok {
-					glyphMap[glyphID] = uint16(len(glyphIDs))
-					glyphIDs = append(glyphIDs, glyphID)
-				}
-			}
-		}
-	}
-	if math.MaxUint16 < len(glyphIDs) {
-		return nil, fmt.Errorf("too many glyphs for one font")
-	}
-
 	// specify tables to include
 	var tags []string
 	if len(options.Tables) == 1 && options.Tables[0] == "min" {
@@ -98,6 +75,26 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 	}
 	sort.Strings(tags) // so that glyf is before loca
 
+	// add dependencies at the end, such as for composite glyphs or color glyph layers
+	for i := 0; i < len(glyphIDs); i++ {
+		if glyphIDs[i] == 0 {
+			continue
+		}
+		deps, err := sfnt.glyphDependencies(glyphIDs[i], tags)
+		if err != nil {
+			return nil, err
+		}
+		for _, glyphID := range deps {
+			if _, ok := glyphMap[glyphID]; !ok {
+				glyphMap[glyphID] = uint16(len(glyphIDs))
+				glyphIDs = append(glyphIDs, glyphID)
+			}
+		}
+	}
+	if math.MaxUint16 < len(glyphIDs) {
+		return nil, fmt.Errorf("too many glyphs for one font")
+	}
+
 	// preliminary calculations
 	indexToLocFormat := int16(1)                   // for head and loca
 	glyfOffsets := make([]uint32, len(glyphIDs)+1) // for loca
@@ -140,6 +137,7 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 			break
 		}
 	}
+	bitmapTables := map[string][]byte{} // for CBDT, CBLC, EBDT, and EBLC
 	for _, tag := range tags {
 		table, ok := sfntOld.Tables[tag]
 		if !ok {
@@ -165,6 +163,44 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 			if err := sfnt.parseCmap(); err != nil {
 				return nil, err
 			}
+		case "CBDT", "CBLC", "EBDT", "EBLC":
+			// location and data tables are rewritten together
+			if _, ok := bitmapTables[tag]; !ok {
+				locationTag, dataTag, version := "EBLC", "EBDT", uint16(2)
+				location, data := sfntOld.Eblc, sfntOld.Ebdt
+				if tag[0] == 'C' {
+					locationTag, dataTag, version = "CBLC", "CBDT", 3
+					location, data = sfntOld.Cblc, sfntOld.Cbdt
+				}
+				if location == nil || data == nil {
+					continue
+				}
+				bLocation, bData, err := data.subset(location, version, glyphIDs, glyphMap)
+				if err != nil {
+					return nil, err
+				}
+				bitmapTables[locationTag] = bLocation
+				bitmapTables[dataTag] = bData
+			}
+			if bitmapTables[tag] == nil {
+				continue // no glyphs in any strike
+			}
+			sfnt.Tables[tag] = bitmapTables[tag]
+
+			var err error
+			switch tag {
+			case "CBDT":
+				err = sfnt.parseCBDT()
+			case "CBLC":
+				err = sfnt.parseCBLC()
+			case "EBDT":
+				err = sfnt.parseEBDT()
+			case "EBLC":
+				err = sfnt.parseEBLC()
+			}
+			if err != nil {
+				return nil, err
+			}
 		case "CFF ":
 			cff := *sfntOld.CFF
 			cff.charStrings = &cffINDEX{}
@@ -201,6 +237,24 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 			}
 			sfnt.Tables[tag] = b
 			sfnt.CFF = &cff
+		case "COLR":
+			if sfntOld.Colr == nil {
+				continue
+			}
+			b, err := sfntOld.Colr.subset(glyphIDs, glyphMap)
+			if err != nil {
+				return nil, err
+			}
+			sfnt.Tables[tag] = b
+			if err := sfnt.parseCOLR(); err != nil {
+				return nil, err
+			}
+		case "CPAL":
+			sfnt.Tables[tag] = table
+			sfnt.Cpal = sfntOld.Cpal
+		case "EBSC":
+			sfnt.Tables[tag] = table
+			sfnt.Ebsc = sfntOld.Ebsc
 		case "glyf":
 			w := parse.NewBinaryWriter([]byte{})
 			for i, glyphID := range glyphIDs {
@@ -234,89 +288,7 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 						w.WriteBytes(sfntOld.Glyf.Get(glyphID))
 					} else if 0 < len(contour.EndPoints) { // not empty
 						// optimize glyph data
-						numberOfContours := int16(len(contour.EndPoints))
-						w.WriteInt16(numberOfContours)
-						w.WriteInt16(contour.XMin)
-						w.WriteInt16(contour.YMin)
-						w.WriteInt16(contour.XMax)
-						w.WriteInt16(contour.YMax)
-						for _, endPoint := range contour.EndPoints {
-							w.WriteUint16(endPoint)
-						}
-						w.WriteUint16(uint16(len(contour.Instructions)))
-						w.WriteBytes(contour.Instructions)
-
-						repeats := 0
-						xs := parse.NewBinaryWriter([]byte{})
-						ys := parse.NewBinaryWriter([]byte{})
-						numPoints := int(contour.EndPoints[numberOfContours-1]) + 1
-						for i := 0; i < numPoints; i++ {
-							dx := contour.XCoordinates[i]
-							dy := contour.YCoordinates[i]
-							if 0 < i {
-								dx -= contour.XCoordinates[i-1]
-								dy -= contour.YCoordinates[i-1]
-							}
-
-							var flag byte
-							if dx == 0 {
-								flag |= 0x10 // X_IS_SAME_OR_POSITIVE_X_SHORT_VECTOR
-							} else if -256 < dx && dx < 256 {
-								flag |= 0x02 // X_SHORT_VECTOR
-								if 0 < dx {
-									flag |= 0x10 // X_IS_SAME_OR_POSITIVE_X_SHORT_VECTOR
-									xs.WriteInt8(int8(dx))
-								} else {
-									xs.WriteInt8(int8(-dx))
-								}
-							} else {
-								xs.WriteInt16(dx)
-							}
-
-							if dy == 0 {
-								flag |= 0x20 // Y_IS_SAME_OR_POSITIVE_Y_SHORT_VECTOR
-							} else if -256 < dy && dy < 256 {
-								flag |= 0x04 // Y_SHORT_VECTOR
-								if 0 < dy {
-									flag |= 0x20 // Y_IS_SAME_OR_POSITIVE_Y_SHORT_VECTOR
-									ys.WriteByte(byte(dy))
-								} else {
-									ys.WriteByte(byte(-dy))
-								}
-							} else {
-								ys.WriteInt16(dy)
-							}
-
-							if contour.OnCurve[i] {
-								flag |= 0x01
-							}
-							if contour.OverlapSimple[i] {
-								flag |= 0x40
-							}
-
-							// handle flag repeats
-							if 0 < i && repeats < 255 && flag == w.Bytes()[w.Len()-1] {
-								repeats++
-							} else {
-								if 1 < repeats {
-									w.Bytes()[w.Len()-1] |= 0x08 // REPEAT_FLAG
-									w.WriteByte(byte(repeats))
-									repeats = 0
-								} else if repeats == 1 {
-									w.WriteByte(w.Bytes()[w.Len()-1])
-									repeats = 0
-								}
-								w.WriteByte(flag)
-							}
-						}
-						if 1 < repeats {
-							w.Bytes()[w.Len()-1] |= 0x08 // REPEAT_FLAG
-							w.WriteByte(byte(repeats))
-						} else if repeats == 1 {
-							w.WriteByte(w.Bytes()[w.Len()-1])
-						}
-						w.WriteBytes(xs.Bytes())
-						w.WriteBytes(ys.Bytes())
+						writeGlyfContour(w, contour)
 					}
 				}
 
@@ -332,9 +304,28 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 			sfnt.Tables[tag] = w.Bytes()
 
 			sfnt.Glyf = &glyfTable{
-				data: w.Bytes(),
-				loca: sfnt.Loca,
+				data:   w.Bytes(),
+				loca:   sfnt.Loca,
+				hinter: newTTHinter(sfnt),
 			}
+		case "hdmx":
+			if sfntOld.Hdmx == nil {
+				continue
+			}
+			sfnt.Hdmx = &hdmxTable{}
+			sfnt.Hdmx.Records = make([]hdmxRecord, len(sfntOld.Hdmx.Records))
+			for i, record := range sfntOld.Hdmx.Records {
+				sfnt.Hdmx.Records[i] = hdmxRecord{
+					PixelSize: record.PixelSize,
+					Widths:    make([]uint8, len(glyphIDs)),
+				}
+				for subsetGlyphID, glyphID := range glyphIDs {
+					width := record.Widths[glyphID]
+					sfnt.Hdmx.Records[i].Widths[subsetGlyphID] = width
+					sfnt.Hdmx.Records[i].MaxWidth = max(sfnt.Hdmx.Records[i].MaxWidth, width)
+				}
+			}
+			sfnt.Tables[tag] = sfnt.Hdmx.Write()
 		case "head":
 			w := parse.NewBinaryWriter(make([]byte, 0, len(sfntOld.Tables["head"])))
 			w.WriteBytes(table[:50])
@@ -428,6 +419,16 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 
 			sfnt.Loca.Format = indexToLocFormat
 			sfnt.Loca.data = w.Bytes()
+		case "LTSH":
+			if sfntOld.Ltsh == nil {
+				continue
+			}
+			sfnt.Ltsh = &ltshTable{}
+			sfnt.Ltsh.YPels = make([]uint8, len(glyphIDs))
+			for subsetGlyphID, glyphID := range glyphIDs {
+				sfnt.Ltsh.YPels[subsetGlyphID] = sfntOld.Ltsh.YPels[glyphID]
+			}
+			sfnt.Tables[tag] = sfnt.Ltsh.Write()
 		case "maxp":
 			w := parse.NewBinaryWriter(make([]byte, 0, len(sfntOld.Tables["maxp"])))
 			w.WriteBytes(table[:4])
@@ -473,6 +474,30 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 				MinMemType1:        sfntOld.Post.MinMemType1,
 				MaxMemType1:        sfntOld.Post.MaxMemType1,
 			}
+		case "sbix":
+			if sfntOld.Sbix == nil {
+				continue
+			}
+			b, err := sfntOld.Sbix.subset(glyphIDs, glyphMap)
+			if err != nil {
+				return nil, err
+			}
+			sfnt.Tables[tag] = b
+			if err := sfnt.parseSbix(); err != nil {
+				return nil, err
+			}
+		case "SVG ":
+			if sfntOld.Svg == nil {
+				continue
+			}
+			b, err := sfntOld.Svg.subset(glyphIDs, glyphMap)
+			if err != nil {
+				return nil, err
+			}
+			sfnt.Tables[tag] = b
+			if err := sfnt.parseSVG(); err != nil {
+				return nil, err
+			}
 		default:
 			sfnt.Tables[tag] = table
 		}
@@ -483,6 +508,45 @@ func (sfnt *SFNT) Subset(glyphIDs []uint16, options SubsetOptions) (*SFNT, error
 	return sfnt, nil
 }
 
+// glyphDependencies returns the glyph IDs that the glyph depends on in the given tables, such as the components of composite glyphs or the layers of color glyphs.
+func (sfnt *SFNT) glyphDependencies(glyphID uint16, tags []string) ([]uint16, error) {
+	deps := []uint16{}
+	if sfnt.IsTrueType {
+		glyfDeps, err := sfnt.Glyf.Dependencies(glyphID)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, glyfDeps[1:]...)
+	}
+	for _, tag := range tags {
+		var tableDeps []uint16
+		var err error
+		switch tag {
+		case "CBDT":
+			if sfnt.Cbdt != nil && sfnt.Cblc != nil {
+				tableDeps, err = sfnt.Cbdt.Dependencies(sfnt.Cblc, glyphID)
+			}
+		case "COLR":
+			if sfnt.Colr != nil {
+				tableDeps, err = sfnt.Colr.Dependencies(glyphID)
+			}
+		case "EBDT":
+			if sfnt.Ebdt != nil && sfnt.Eblc != nil {
+				tableDeps, err = sfnt.Ebdt.Dependencies(sfnt.Eblc, glyphID)
+			}
+		case "sbix":
+			if sfnt.Sbix != nil {
+				tableDeps, err = sfnt.Sbix.Dependencies(glyphID)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, tableDeps...)
+	}
+	return deps, nil
+}
+
 func (sfnt *SFNT) SetGlyphNames(names []string) error {
 	if sfnt.IsCFF {
 		same := false
@@ -0,0 +1,423 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// SyntheticStyle specifies how to synthesize a bold and/or oblique face from a regular face, which is useful when a font family lacks a bold or italic face. Emboldening offsets the outlines outwards while keeping the baseline and left side bearing in place, and slanting shears the outlines horizontally around the baseline.
+type SyntheticStyle struct {
+	Embolden float64 // stroke width in font units that is added to the outlines and to the advance widths
+	Slant    float64 // tangent of the slant angle, positive values lean to the right
+}
+
+// SyntheticStyle returns the style that FreeType uses to fake bold and oblique faces, that is emboldening by 1/24 of an em and slanting by 12 degrees.
+func (sfnt *SFNT) SyntheticStyle(bold, oblique bool) SyntheticStyle {
+	style := SyntheticStyle{}
+	if bold {
+		style.Embolden = float64(sfnt.Head.UnitsPerEm) / 24.0
+	}
+	if oblique {
+		style.Slant = 0.2126 // tan(12°)
+	}
+	return style
+}
+
+// IsZero returns true if the style doesn't change the glyphs.
+func (style SyntheticStyle) IsZero() bool {
+	return style.Embolden == 0.0 && style.Slant == 0.0
+}
+
+// Advance returns the advance width of a synthesized glyph given its original advance width. Glyphs without advance remain so.
+func (style SyntheticStyle) Advance(advance uint16) uint16 {
+	if advance == 0 {
+		return 0
+	}
+	return uint16(max(0, min(math.MaxUint16, int(advance)+int(math.Round(style.Embolden)))))
+}
+
+// SyntheticGlyphPath draws the glyph's contours as a path to the pather with the synthetic style applied, see GlyphPath.
+func (sfnt *SFNT) SyntheticGlyphPath(p Pather, glyphID, ppem uint16, x, y, scale float64, hinting Hinting, style SyntheticStyle) error {
+	if style.IsZero() {
+		return sfnt.GlyphPath(p, glyphID, ppem, x, y, scale, hinting)
+	}
+
+	q := NewSyntheticPather(p, style, y, scale)
+	if err := sfnt.GlyphPath(q, glyphID, ppem, x, y, scale, hinting); err != nil {
+		return err
+	}
+	q.Flush()
+	return nil
+}
+
+// syntheticEmbolden offsets the points of the contours outwards by strength/2 along the bisector of the adjacent edges, limited by the edge lengths to avoid collapsing short segments, and then moves all points up and to the right by strength/2. This is FreeType's FT_Outline_EmboldenXY applied to the control polygon. The direction of the outer contours is determined by the total signed area, since TrueType outlines are clockwise and CFF outlines are counter clockwise.
+func syntheticEmbolden(contours [][][2]float64, strength float64) {
+	if strength == 0.0 {
+		return
+	}
+
+	area := 0.0
+	for _, points := range contours {
+		for i := range points {
+			j := (i + 1) % len(points)
+			area += points[i][0]*points[j][1] - points[j][0]*points[i][1]
+		}
+	}
+	if area == 0.0 {
+		return
+	}
+	clockwise := area < 0.0
+
+	s := strength / 2.0
+	for _, points := range contours {
+		n := len(points)
+		shifts := make([][2]float64, n)
+		for i := range points {
+			// find previous and next distinct points
+			prev, next := -1, -1
+			for k := 1; k < n; k++ {
+				if j := (i - k + n) % n; points[j] != points[i] {
+					prev = j
+					break
+				}
+			}
+			if prev == -1 {
+				break // contour is a single point
+			}
+			for k := 1; k < n; k++ {
+				if j := (i + k) % n; points[j] != points[i] {
+					next = j
+					break
+				}
+			}
+
+			inX, inY := points[i][0]-points[prev][0], points[i][1]-points[prev][1]
+			outX, outY := points[next][0]-points[i][0], points[next][1]-points[i][1]
+			lIn, lOut := math.Hypot(inX, inY), math.Hypot(outX, outY)
+			inX, inY = inX/lIn, inY/lIn
+			outX, outY = outX/lOut, outY/lOut
+
+			d := inX*outX + inY*outY // cosine of the turn
+			if d <= -0.9375 {
+				continue // turns of more than about 160 degrees are not shifted
+			}
+			d += 1.0
+
+			shiftX, shiftY := inY+outY, inX+outX
+			q := outX*inY - outY*inX // sine of the turn
+			if clockwise {
+				shiftX = -shiftX
+				q = -q
+			} else {
+				shiftY = -shiftY
+			}
+
+			f := s / d
+			if l := math.Min(lIn, lOut); l*d < s*q {
+				f = l / q
+			}
+			shifts[i] = [2]float64{f * shiftX, f * shiftY}
+		}
+		for i := range points {
+			points[i][0] += s + shifts[i][0]
+			points[i][1] += s + shifts[i][1]
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// SyntheticPather is a Pather that applies a synthetic style to the outline before drawing it to the underlying pather. Emboldening requires complete contours, so that the outline is buffered until Flush is called. The y-axis of the outline must point up, as is the case for GlyphPath with a positive scale.
+type SyntheticPather struct {
+	p        Pather
+	strength float64
+	slant    float64
+	y0       float64
+
+	contours []outlineContour
+}
+
+// NewSyntheticPather returns a pather that applies the synthetic style to glyphs drawn with the baseline at y and scaled by scale, as passed to GlyphPath.
+func NewSyntheticPather(p Pather, style SyntheticStyle, y, scale float64) *SyntheticPather {
+	return &SyntheticPather{
+		p:        p,
+		strength: style.Embolden * math.Abs(scale),
+		slant:    style.Slant,
+		y0:       y,
+	}
+}
+
+func (p *SyntheticPather) MoveTo(x, y float64) {
+	p.contours = append(p.contours, outlineContour{points: [][2]float64{{x, y}}})
+}
+
+func (p *SyntheticPather) add(op byte, points ...[2]float64) {
+	if len(p.contours) == 0 {
+		p.MoveTo(0.0, 0.0)
+	}
+	contour := &p.contours[len(p.contours)-1]
+	contour.ops = append(contour.ops, op)
+	contour.points = append(contour.points, points...)
+}
+
+func (p *SyntheticPather) LineTo(x, y float64) {
+	p.add('L', [2]float64{x, y})
+}
+
+func (p *SyntheticPather) QuadTo(cpx, cpy, x, y float64) {
+	p.add('Q', [2]float64{cpx, cpy}, [2]float64{x, y})
+}
+
+func (p *SyntheticPather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	p.add('C', [2]float64{cpx1, cpy1}, [2]float64{cpx2, cpy2}, [2]float64{x, y})
+}
+
+func (p *SyntheticPather) Close() {
+	// contours are closed when flushed
+}
+
+// Flush draws the buffered outline with the synthetic style applied to the underlying pather.
+func (p *SyntheticPather) Flush() {
+	contours := make([][][2]float64, len(p.contours))
+	for i := range p.contours {
+		contours[i] = p.contours[i].points
+	}
+	syntheticEmbolden(contours, p.strength)
+
+	for i := range p.contours {
+		points := p.contours[i].points
+		for j := range points {
+			points[j][0] += p.slant * (points[j][1] - p.y0)
+		}
+		p.contours[i].draw(p.p)
+	}
+	p.contours = p.contours[:0]
+}
+
+////////////////////////////////////////////////////////////////
+
+// Synthesize applies the synthetic style to all glyphs by rewriting the glyf and loca tables or the CFF table. The advance widths in hmtx are increased by the emboldening, and the bounding boxes, style flags, and italic angle in the head, hhea, maxp, OS/2, and post tables are updated accordingly. Glyph instructions and CFF hints are removed, composite glyphs are converted to simple glyphs, and the hdmx table is removed.
+func (sfnt *SFNT) Synthesize(style SyntheticStyle) error {
+	if _, ok := sfnt.Tables["CFF2"]; ok {
+		// TODO: support synthesizing CFF2
+		return fmt.Errorf("synthesizing CFF2 fonts not supported")
+	} else if !sfnt.IsTrueType && !sfnt.IsCFF {
+		return fmt.Errorf("only TrueType and CFF are supported")
+	} else if style.IsZero() {
+		return nil
+	}
+
+	numGlyphs := sfnt.NumGlyphs()
+	advances := make([]uint16, numGlyphs)
+	lsbs := make([]int16, numGlyphs)
+	rsbs := make([]int16, numGlyphs)
+	hasOutline := make([]bool, numGlyphs)
+	xMin, yMin, xMax, yMax := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16), int16(math.MinInt16)
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		advances[glyphID] = style.Advance(sfnt.Hmtx.Advance(glyphID))
+		lsbs[glyphID] = sfnt.Hmtx.LeftSideBearing(glyphID)
+	}
+	bounds := func(glyphID uint16, gxMin, gyMin, gxMax, gyMax int16) {
+		hasOutline[glyphID] = true
+		lsbs[glyphID] = gxMin
+		rsbs[glyphID] = int16(int(advances[glyphID]) - int(gxMax))
+		xMin, yMin = min(xMin, gxMin), min(yMin, gyMin)
+		xMax, yMax = max(xMax, gxMax), max(yMax, gyMax)
+	}
+
+	if sfnt.IsTrueType {
+		var maxPoints, maxContours uint16
+		glyfOffsets := make([]uint32, numGlyphs+1) // for loca
+		w := parse.NewBinaryWriter([]byte{})
+		for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+			contour, err := sfnt.Glyf.Contour(glyphID)
+			if err != nil {
+				return err
+			} else if 0 < len(contour.EndPoints) { // not empty
+				style.transformGlyfContour(contour)
+				writeGlyfContour(w, contour)
+				bounds(glyphID, contour.XMin, contour.YMin, contour.XMax, contour.YMax)
+				maxPoints = max(maxPoints, uint16(len(contour.XCoordinates)))
+				maxContours = max(maxContours, uint16(len(contour.EndPoints)))
+			}
+
+			// padding to ensure glyph offsets are on even bytes for loca short format
+			if w.Len()%2 == 1 {
+				w.WriteByte(0)
+			}
+			glyfOffsets[glyphID+1] = uint32(w.Len())
+		}
+		sfnt.Tables["glyf"] = w.Bytes()
+
+		loca, indexToLocFormat := locaWrite(glyfOffsets)
+		sfnt.Tables["loca"] = loca
+		sfnt.Loca.Format = indexToLocFormat
+		sfnt.Loca.data = loca
+		sfnt.Head.IndexToLocFormat = indexToLocFormat
+		sfnt.Glyf.data = sfnt.Tables["glyf"]
+
+		if sfnt.Maxp.Version == 0x00010000 {
+			sfnt.Maxp.MaxPoints = max(sfnt.Maxp.MaxPoints, maxPoints)
+			sfnt.Maxp.MaxContours = max(sfnt.Maxp.MaxContours, maxContours)
+			sfnt.Tables["maxp"] = sfnt.Maxp.Write()
+		}
+	} else {
+		cff := *sfnt.CFF
+		cff.charStrings = &cffINDEX{}
+		for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+			privateDICT, err := sfnt.CFF.PrivateDICT(glyphID)
+			if err != nil {
+				return fmt.Errorf("CFF: %w", err)
+			}
+
+			w := newCFFCharStringWriter(int32(math.Round(float64(advances[glyphID]) - privateDICT.NominalWidthX)))
+			p := NewSyntheticPather(w, style, 0.0, 1.0)
+			if err := sfnt.CFF.ToPath(p, glyphID, 0, 0.0, 0.0, 1.0, NoHinting); err != nil {
+				return err
+			}
+			p.Flush()
+			cff.charStrings.Add(w.Bytes())
+			if w.XMin <= w.XMax {
+				bounds(glyphID, int16(w.XMin), int16(w.YMin), int16(w.XMax), int16(w.YMax))
+			}
+		}
+
+		// remove subroutines that are no longer used
+		if len(cff.fonts.localSubrs) < 2 {
+			if err := cff.ReindexSubrs(); err != nil {
+				return err
+			}
+		}
+
+		b, err := cff.Write()
+		if err != nil {
+			return err
+		}
+		sfnt.Tables["CFF "] = b
+		sfnt.CFF = &cff
+	}
+
+	// hmtx
+	sfnt.Hmtx = newHmtxTable(advances, lsbs)
+	sfnt.Tables["hmtx"] = sfnt.Hmtx.Write()
+	numberOfHMetrics := uint16(len(sfnt.Hmtx.HMetrics))
+
+	// hhea
+	if table, ok := sfnt.Tables["hhea"]; ok {
+		sfnt.Hhea.NumberOfHMetrics = numberOfHMetrics
+		sfnt.Hhea.AdvanceWidthMax = 0
+		sfnt.Hhea.MinLeftSideBearing = math.MaxInt16
+		sfnt.Hhea.MinRightSideBearing = math.MaxInt16
+		sfnt.Hhea.XMaxExtent = math.MinInt16
+		for glyphID := range advances {
+			sfnt.Hhea.AdvanceWidthMax = max(sfnt.Hhea.AdvanceWidthMax, advances[glyphID])
+			if hasOutline[glyphID] {
+				sfnt.Hhea.MinLeftSideBearing = min(sfnt.Hhea.MinLeftSideBearing, lsbs[glyphID])
+				sfnt.Hhea.MinRightSideBearing = min(sfnt.Hhea.MinRightSideBearing, rsbs[glyphID])
+				sfnt.Hhea.XMaxExtent = max(sfnt.Hhea.XMaxExtent, int16(int(advances[glyphID])-int(rsbs[glyphID])))
+			}
+		}
+		if style.Slant != 0.0 && sfnt.Hhea.CaretSlopeRun == 0 {
+			sfnt.Hhea.CaretSlopeRise = int16(sfnt.Head.UnitsPerEm)
+			sfnt.Hhea.CaretSlopeRun = int16(math.Round(style.Slant * float64(sfnt.Head.UnitsPerEm)))
+		}
+
+		table = append([]byte{}, table...)
+		binary.BigEndian.PutUint16(table[10:], sfnt.Hhea.AdvanceWidthMax)
+		binary.BigEndian.PutUint16(table[12:], uint16(sfnt.Hhea.MinLeftSideBearing))
+		binary.BigEndian.PutUint16(table[14:], uint16(sfnt.Hhea.MinRightSideBearing))
+		binary.BigEndian.PutUint16(table[16:], uint16(sfnt.Hhea.XMaxExtent))
+		binary.BigEndian.PutUint16(table[18:], uint16(sfnt.Hhea.CaretSlopeRise))
+		binary.BigEndian.PutUint16(table[20:], uint16(sfnt.Hhea.CaretSlopeRun))
+		binary.BigEndian.PutUint16(table[34:], numberOfHMetrics)
+		sfnt.Tables["hhea"] = table
+	}
+
+	// head
+	if table, ok := sfnt.Tables["head"]; ok {
+		if xMin <= xMax {
+			sfnt.Head.XMin, sfnt.Head.YMin = xMin, yMin
+			sfnt.Head.XMax, sfnt.Head.YMax = xMax, yMax
+		}
+		if style.Embolden != 0.0 {
+			sfnt.Head.MacStyle[0] = true // bold
+		}
+		if style.Slant != 0.0 {
+			sfnt.Head.MacStyle[1] = true // italic
+		}
+
+		table = append([]byte{}, table...)
+		binary.BigEndian.PutUint16(table[36:], uint16(sfnt.Head.XMin))
+		binary.BigEndian.PutUint16(table[38:], uint16(sfnt.Head.YMin))
+		binary.BigEndian.PutUint16(table[40:], uint16(sfnt.Head.XMax))
+		binary.BigEndian.PutUint16(table[42:], uint16(sfnt.Head.YMax))
+		binary.BigEndian.PutUint16(table[44:], flagsToUint16(sfnt.Head.MacStyle))
+		binary.BigEndian.PutUint16(table[50:], uint16(sfnt.Head.IndexToLocFormat))
+		sfnt.Tables["head"] = table
+	}
+
+	// OS/2
+	if table, ok := sfnt.Tables["OS/2"]; ok && sfnt.OS2 != nil {
+		if style.Embolden != 0.0 {
+			sfnt.OS2.UsWeightClass = max(sfnt.OS2.UsWeightClass, 700)
+			sfnt.OS2.FsSelection |= 0x0020 // BOLD
+		}
+		if style.Slant != 0.0 {
+			sfnt.OS2.FsSelection |= 0x0001 // ITALIC
+			if 4 <= sfnt.OS2.Version {
+				sfnt.OS2.FsSelection |= 0x0200 // OBLIQUE
+			}
+		}
+		sfnt.OS2.FsSelection &^= 0x0040 // REGULAR
+
+		table = append([]byte{}, table...)
+		binary.BigEndian.PutUint16(table[4:], sfnt.OS2.UsWeightClass)
+		binary.BigEndian.PutUint16(table[62:], sfnt.OS2.FsSelection)
+		sfnt.Tables["OS/2"] = table
+	}
+
+	// post
+	if table, ok := sfnt.Tables["post"]; ok && style.Slant != 0.0 && sfnt.Post.ItalicAngle == 0.0 {
+		sfnt.Post.ItalicAngle = -math.Atan(style.Slant) * 180.0 / math.Pi
+
+		table = append([]byte{}, table...)
+		binary.BigEndian.PutUint32(table[4:], uint32(int32(math.Round(sfnt.Post.ItalicAngle*(1<<16)))))
+		sfnt.Tables["post"] = table
+	}
+
+	// pixel advances are no longer valid
+	delete(sfnt.Tables, "hdmx")
+	sfnt.Hdmx = nil
+	return nil
+}
+
+// transformGlyfContour applies the synthetic style to the points of a TrueType glyph and updates its bounding box. Instructions are removed as they no longer fit the outline.
+func (style SyntheticStyle) transformGlyfContour(contour *glyfContour) {
+	contours := make([][][2]float64, len(contour.EndPoints))
+	i := 0
+	for j, endPoint := range contour.EndPoints {
+		for ; i <= int(endPoint); i++ {
+			contours[j] = append(contours[j], [2]float64{float64(contour.XCoordinates[i]), float64(contour.YCoordinates[i])})
+		}
+	}
+	syntheticEmbolden(contours, style.Embolden)
+
+	contour.XMin, contour.YMin = math.MaxInt16, math.MaxInt16
+	contour.XMax, contour.YMax = math.MinInt16, math.MinInt16
+	i = 0
+	for _, points := range contours {
+		for _, point := range points {
+			x := int16(math.Round(point[0] + style.Slant*point[1]))
+			y := int16(math.Round(point[1]))
+			contour.XCoordinates[i] = x
+			contour.YCoordinates[i] = y
+			contour.XMin, contour.YMin = min(contour.XMin, x), min(contour.YMin, y)
+			contour.XMax, contour.YMax = max(contour.XMax, x), max(contour.YMax, y)
+			i++
+		}
+	}
+	contour.Instructions = nil
+}
@@ -1,9 +1,16 @@
 package font
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
 	"io/ioutil"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/tdewolff/parse/v2"
 	"github.com/tdewolff/test"
 )
 
@@ -66,3 +73,1144 @@ func TestSFNTSubset(t *testing.T) {
 
 	//ioutil.WriteFile("out.otf", subset, 0644)
 }
+
+func TestSFNTCoverage(t *testing.T) {
+	sfnt := &SFNT{}
+	coverage1, err := sfnt.parseCoverageTable([]byte{0, 1, 0, 3, 0, 3, 0, 5, 0, 9})
+	test.Error(t, err)
+	coverage2, err := sfnt.parseCoverageTable([]byte{0, 2, 0, 2, 0, 3, 0, 5, 0, 0, 0, 9, 0, 10, 0, 3})
+	test.Error(t, err)
+
+	var tests = []struct {
+		coverage coverageTable
+		glyphID  uint16
+		index    uint16
+		ok       bool
+	}{
+		{coverage1, 3, 0, true},
+		{coverage1, 5, 1, true},
+		{coverage1, 9, 2, true},
+		{coverage1, 2, 0, false},
+		{coverage1, 4, 0, false},
+		{coverage1, 10, 0, false},
+		{coverage2, 3, 0, true},
+		{coverage2, 4, 1, true},
+		{coverage2, 10, 4, true},
+		{coverage2, 2, 0, false},
+		{coverage2, 7, 0, false},
+		{coverage2, 11, 0, false},
+	}
+	for _, tt := range tests {
+		index, ok := tt.coverage.Index(tt.glyphID)
+		test.T(t, ok, tt.ok, tt.glyphID)
+		test.T(t, index, tt.index, tt.glyphID)
+	}
+}
+
+func TestSFNTPairPosClass(t *testing.T) {
+	// glyphs 3 and 5 are in the first classes 1 and 2, glyph 7 is in the second class 1
+	pairPos := &pairPosFormat2{
+		coverageTable: &coverageFormat1{glyphArray: []uint16{3, 5}},
+		classDef1:     &classDefFormat1{startGlyphID: 3, classValueArray: []uint16{1, 0, 2}},
+		classDef2:     &classDefFormat1{startGlyphID: 7, classValueArray: []uint16{1}},
+		class1Records: make([][]class2Record, 3),
+	}
+	for class1 := range pairPos.class1Records {
+		pairPos.class1Records[class1] = make([]class2Record, 2)
+		for class2 := range pairPos.class1Records[class1] {
+			pairPos.class1Records[class1][class2].valueRecord1.XAdvance = int16(-10*class1 - class2)
+		}
+	}
+
+	valueRecord1, _, ok := pairPos.Get(3, 7)
+	test.That(t, ok)
+	test.T(t, valueRecord1.XAdvance, int16(-11))
+	valueRecord1, _, ok = pairPos.Get(5, 7)
+	test.That(t, ok)
+	test.T(t, valueRecord1.XAdvance, int16(-21))
+	valueRecord1, _, ok = pairPos.Get(5, 3)
+	test.That(t, ok)
+	test.T(t, valueRecord1.XAdvance, int16(-20))
+	_, _, ok = pairPos.Get(4, 7)
+	test.That(t, !ok)
+}
+
+func TestSFNTBitmap(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// glyph 1 is byte-aligned, glyph 2 is bit-aligned with metrics in EBLC, glyph 3 is a composite of glyph 1 and 2
+	ebdt := parse.NewBinaryWriter([]byte{})
+	ebdt.WriteUint32(0x00020000)                    // version
+	ebdt.WriteBytes([]byte{2, 3, 0, 2, 4})          // small metrics
+	ebdt.WriteBytes([]byte{0xA0, 0x40})             // image format 1
+	ebdt.WriteBytes([]byte{0xA8})                   // image format 5
+	ebdt.WriteBytes([]byte{4, 3, 0, 4, 4, 0, 0, 5}) // big metrics
+	ebdt.WriteUint16(2)                             // numComponents
+	ebdt.WriteBytes([]byte{0, 1, 0, 0, 0, 2, 0, 2})
+
+	eblc := parse.NewBinaryWriter([]byte{})
+	eblc.WriteUint32(0x00020000) // version
+	eblc.WriteUint32(1)          // numSizes
+	eblc.WriteUint32(56)         // indexSubTableArrayOffset
+	eblc.WriteUint32(44)         // indexTablesSize
+	eblc.WriteUint32(3)          // numberofIndexSubTables
+	eblc.WriteUint32(0)          // colorRef
+	eblc.WriteBytes(make([]byte, 24))
+	eblc.WriteBytes([]byte{0, 1, 0, 3, 8, 8, 1, 0x01}) // startGlyphIndex, endGlyphIndex, ppemX, ppemY, bitDepth, flags
+	eblc.WriteBytes([]byte{0, 1, 0, 1, 0, 0, 0, 24, 0, 2, 0, 2, 0, 0, 0, 40, 0, 3, 0, 3, 0, 0, 0, 60})
+	eblc.WriteBytes([]byte{0, 1, 0, 1, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 7})
+	eblc.WriteBytes([]byte{0, 2, 0, 5, 0, 0, 0, 11, 0, 0, 0, 1, 2, 3, 0, 2, 4, 0, 0, 0})
+	eblc.WriteBytes([]byte{0, 3, 0, 9, 0, 0, 0, 12, 0, 0, 0, 18})
+
+	ebsc := parse.NewBinaryWriter([]byte{})
+	ebsc.WriteUint32(0x00020000) // version
+	ebsc.WriteUint32(1)          // numSizes
+	ebsc.WriteBytes(make([]byte, 24))
+	ebsc.WriteBytes([]byte{16, 16, 8, 8})
+
+	sfnt.Tables["EBDT"] = ebdt.Bytes()
+	sfnt.Tables["EBLC"] = eblc.Bytes()
+	sfnt.Tables["EBSC"] = ebsc.Bytes()
+	test.Error(t, sfnt.parseEBDT())
+	test.Error(t, sfnt.parseEBLC())
+	test.Error(t, sfnt.parseEBSC())
+
+	// a bit depth of 32 is only valid for CBLC
+	eblc32 := append([]byte{}, eblc.Bytes()...)
+	eblc32[54] = 32
+	_, err = parseBitmapLocationTable("EBLC", eblc32, 2)
+	test.That(t, err != nil)
+
+	test.That(t, !sfnt.HasGlyphBitmap(0, 8))
+	test.That(t, !sfnt.HasGlyphBitmap(1, 9))
+	test.That(t, sfnt.HasGlyphBitmap(1, 8))
+	test.That(t, sfnt.HasGlyphBitmap(1, 16))
+
+	for _, glyphID := range []uint16{1, 2} {
+		bitmap, err := sfnt.GlyphBitmap(glyphID, 8)
+		test.Error(t, err)
+		test.T(t, bitmap.Rect, image.Rect(0, 0, 3, 2))
+		test.T(t, bitmap.Pix, []byte{255, 0, 255, 0, 255, 0})
+		test.T(t, bitmap.BearingY, 2)
+		test.T(t, bitmap.Advance, 4)
+	}
+
+	bitmap, err := sfnt.GlyphBitmap(3, 8)
+	test.Error(t, err)
+	test.T(t, bitmap.Pix, []byte{255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 255, 0})
+	test.T(t, bitmap.VertAdvance, 5)
+
+	bitmap, err = sfnt.GlyphBitmap(1, 16)
+	test.Error(t, err)
+	test.T(t, bitmap.Rect, image.Rect(0, 0, 6, 4))
+	test.T(t, bitmap.Pix[:6], []byte{255, 255, 0, 0, 255, 255})
+	test.T(t, bitmap.Advance, 8)
+
+	bitmap, err = sfnt.GlyphBitmap(1, 9)
+	test.Error(t, err)
+	test.That(t, bitmap == nil)
+
+	// composite glyph 3 depends on glyphs 1 and 2
+	sfntSubset, err := sfnt.Subset([]uint16{0, 3}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, sfntSubset.NumGlyphs(), uint16(4))
+	test.That(t, sfntSubset.HasGlyphBitmap(2, 8))
+	test.That(t, sfntSubset.HasGlyphBitmap(3, 16))
+
+	bitmap, err = sfntSubset.GlyphBitmap(1, 8)
+	test.Error(t, err)
+	test.T(t, bitmap.Pix, []byte{255, 0, 255, 0, 255, 0, 255, 0, 255, 0, 255, 0})
+}
+
+func TestSFNTGlyphPaintTransform(t *testing.T) {
+	// glyph 1 rotates a linear gradient by 90 degrees around (100,0)
+	colr := parse.NewBinaryWriter([]byte{})
+	colr.WriteUint16(1) // version
+	colr.WriteBytes(make([]byte, 12))
+	colr.WriteUint32(34) // baseGlyphListOffset
+	colr.WriteBytes(make([]byte, 16))
+	colr.WriteBytes([]byte{0, 0, 0, 1, 0, 1, 0, 0, 0, 10})
+	colr.WriteBytes([]byte{26, 0, 0, 10, 0x20, 0, 0, 100, 0, 0})                 // PaintRotateAroundCenter
+	colr.WriteBytes([]byte{4, 0, 0, 16, 0, 0, 0, 0, 0, 100, 0, 0, 0, 0, 0, 100}) // PaintLinearGradient
+	colr.WriteBytes([]byte{1, 0, 1, 0, 0, 0, 2, 0x40, 0})                        // ColorLine
+
+	sfnt := &SFNT{Tables: map[string][]byte{"COLR": colr.Bytes()}}
+	test.Error(t, sfnt.parseCOLR())
+	paint, err := sfnt.GlyphPaint(1)
+	test.Error(t, err)
+	transform, ok := paint.(*PaintTransform)
+	test.That(t, ok)
+	test.Float(t, transform.XX, 0.0)
+	test.Float(t, transform.YX, 1.0)
+	test.Float(t, transform.XY, -1.0)
+	test.Float(t, transform.DX, 100.0)
+	test.Float(t, transform.DY, -100.0)
+	test.T(t, transform.Paint, Paint(&PaintLinearGradient{ColorLine{ExtendRepeat, []ColorStop{{0.0, 2, 1.0}}}, 0, 0, 100, 0, 0, 100}))
+}
+
+func TestSFNTSubsetColor(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// glyph 36 has two layers of glyphs 37 and 38, glyph 39 paints glyph 40
+	colr := parse.NewBinaryWriter([]byte{})
+	colr.WriteUint16(1)  // version
+	colr.WriteUint16(1)  // numBaseGlyphRecords
+	colr.WriteUint32(34) // baseGlyphRecordsOffset
+	colr.WriteUint32(40) // layerRecordsOffset
+	colr.WriteUint16(2)  // numLayerRecords
+	colr.WriteUint32(48) // baseGlyphListOffset
+	colr.WriteBytes(make([]byte, 16))
+	colr.WriteBytes([]byte{0, 36, 0, 0, 0, 2})
+	colr.WriteBytes([]byte{0, 37, 0, 0, 0, 38, 0, 1})
+	colr.WriteBytes([]byte{0, 0, 0, 1, 0, 39, 0, 0, 0, 10})
+	colr.WriteBytes([]byte{10, 0, 0, 6, 0, 40}) // PaintGlyph
+	colr.WriteBytes([]byte{2, 0, 1, 0x40, 0})   // PaintSolid
+
+	cpal := parse.NewBinaryWriter([]byte{})
+	cpal.WriteUint16(0)  // version
+	cpal.WriteUint16(2)  // numPaletteEntries
+	cpal.WriteUint16(1)  // numPalettes
+	cpal.WriteUint16(2)  // numColorRecords
+	cpal.WriteUint32(14) // colorRecordsArrayOffset
+	cpal.WriteUint16(0)  // colorRecordIndices
+	cpal.WriteBytes([]byte{0, 0, 255, 255, 255, 0, 0, 128})
+
+	doc := `<svg><g id="glyph36"/><g id="glyph37"/><g id="glyph1"/><use href="#glyph36"/></svg>`
+	svg := parse.NewBinaryWriter([]byte{})
+	svg.WriteUint16(0)  // version
+	svg.WriteUint32(10) // svgDocumentListOffset
+	svg.WriteUint32(0)  // reserved
+	svg.WriteUint16(1)  // numEntries
+	svg.WriteBytes([]byte{0, 36, 0, 37, 0, 0, 0, 14, 0, 0, 0, byte(len(doc))})
+	svg.WriteString(doc)
+
+	// glyph 36 is a PNG image, glyph 38 is a duplicate of glyph 36
+	numGlyphs := int(sfnt.NumGlyphs())
+	sbix := parse.NewBinaryWriter([]byte{})
+	sbix.WriteUint16(1)  // version
+	sbix.WriteUint16(1)  // flags
+	sbix.WriteUint32(1)  // numStrikes
+	sbix.WriteUint32(12) // strikeOffsets
+	sbix.WriteUint16(20) // ppem
+	sbix.WriteUint16(72) // ppi
+	offset := uint32(4 + 4*(numGlyphs+1))
+	for glyphID := 0; glyphID <= numGlyphs; glyphID++ {
+		sbix.WriteUint32(offset)
+		if glyphID == 36 {
+			offset += 11
+		} else if glyphID == 38 {
+			offset += 10
+		}
+	}
+	sbix.WriteBytes([]byte{0, 1, 0, 2})
+	sbix.WriteString("png PNG")
+	sbix.WriteBytes([]byte{0, 0, 0, 0})
+	sbix.WriteString("dupe")
+	sbix.WriteUint16(36)
+
+	sfnt.Tables["COLR"] = colr.Bytes()
+	sfnt.Tables["CPAL"] = cpal.Bytes()
+	sfnt.Tables["SVG "] = svg.Bytes()
+	sfnt.Tables["sbix"] = sbix.Bytes()
+	test.Error(t, sfnt.parseCOLR())
+	test.Error(t, sfnt.parseCPAL())
+	test.Error(t, sfnt.parseSVG())
+	test.Error(t, sfnt.parseSbix())
+	test.T(t, sfnt.Cpal.Palettes[0][1], color.NRGBA{0, 0, 255, 128})
+
+	paint, err := sfnt.GlyphPaint(36)
+	test.Error(t, err)
+	test.T(t, paint, Paint(&PaintLayers{[]Paint{&PaintGlyph{37, &PaintSolid{0, 1.0}}, &PaintGlyph{38, &PaintSolid{1, 1.0}}}}))
+	paint, err = sfnt.GlyphPaint(39)
+	test.Error(t, err)
+	test.T(t, paint, Paint(&PaintGlyph{40, &PaintSolid{1, 1.0}}))
+	img, err := sfnt.GlyphImage(38, 16)
+	test.Error(t, err)
+	test.T(t, *img, GlyphImage{"png ", []byte("PNG"), 20, 1, 2})
+
+	sfntSubset, err := sfnt.Subset([]uint16{0, 36, 39}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, sfntSubset.NumGlyphs(), uint16(6)) // glyphs 37, 38, and 40 are added as 3, 4, and 5
+
+	test.T(t, sfntSubset.Colr.Get(1), []colrLayer{{3, 0}, {4, 1}})
+	deps, err := sfntSubset.Colr.Dependencies(2)
+	test.Error(t, err)
+	test.T(t, deps, []uint16{5})
+
+	svgDoc, err := sfntSubset.Svg.Get(3)
+	test.Error(t, err)
+	test.String(t, string(svgDoc), `<svg><g id="glyph1"/><g id="glyph3"/><g id="_glyph1"/><use href="#glyph1"/></svg>`)
+	svgDoc, err = sfntSubset.Svg.Get(2)
+	test.Error(t, err)
+	test.That(t, svgDoc == nil)
+
+	glyph, err := sfntSubset.Sbix.Get(0, 4)
+	test.Error(t, err)
+	test.T(t, glyph.GraphicType, "png ")
+	test.T(t, glyph.Data, []byte("PNG"))
+	test.T(t, glyph.OriginOffsetY, int16(2))
+
+	_, err = ParseSFNT(sfntSubset.Write(), 0)
+	test.Error(t, err)
+}
+
+func TestSFNTSubsetColorPaints(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// glyph 36 has layers painting glyphs 37 and 38, glyph 39 translates glyph 40, glyph 41 composites glyph 42 and color glyph 39
+	colr := parse.NewBinaryWriter([]byte{})
+	colr.WriteUint16(1) // version
+	colr.WriteBytes(make([]byte, 12))
+	colr.WriteUint32(34) // baseGlyphListOffset
+	colr.WriteUint32(56) // layerListOffset
+	colr.WriteUint32(68) // clipListOffset
+	colr.WriteBytes(make([]byte, 8))
+	colr.WriteBytes([]byte{0, 0, 0, 3, 0, 36, 0, 0, 0, 53, 0, 39, 0, 0, 0, 96, 0, 41, 0, 0, 0, 133}) // BaseGlyphList
+	colr.WriteBytes([]byte{0, 0, 0, 2, 0, 0, 0, 37, 0, 0, 0, 43})                                    // LayerList
+	colr.WriteBytes([]byte{1, 0, 0, 0, 2, 0, 36, 0, 36, 0, 0, 121, 0, 39, 0, 39, 0, 0, 121})         // ClipList
+	colr.WriteBytes([]byte{1, 2, 0, 0, 0, 0})                                                        // PaintColrLayers
+	colr.WriteBytes([]byte{10, 0, 0, 91, 0, 37})                                                     // PaintGlyph
+	colr.WriteBytes([]byte{10, 0, 0, 6, 0, 38})                                                      // PaintGlyph
+	colr.WriteBytes([]byte{4, 0, 0, 16, 0, 0, 0, 0, 0, 100, 0, 0, 0, 0, 0, 100})                     // PaintLinearGradient
+	colr.WriteBytes([]byte{0, 0, 1, 0, 0, 0, 1, 0x40, 0})                                            // ColorLine
+	colr.WriteBytes([]byte{12, 0, 0, 31, 0, 0, 7})                                                   // PaintTransform
+	colr.WriteBytes([]byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 10, 0, 0, 0, 0, 0, 0}) // Affine2x3
+	colr.WriteBytes([]byte{10, 0, 0, 23, 0, 40})                                                     // PaintGlyph
+	colr.WriteBytes([]byte{32, 0, 0, 8, 3, 0, 0, 14})                                                // PaintComposite
+	colr.WriteBytes([]byte{10, 0, 0, 9, 0, 42})                                                      // PaintGlyph
+	colr.WriteBytes([]byte{11, 0, 39})                                                               // PaintColrGlyph
+	colr.WriteBytes([]byte{2, 0, 0, 0x40, 0})                                                        // PaintSolid
+	colr.WriteBytes([]byte{1, 0, 0, 0, 0, 0, 100, 0, 100})                                           // ClipBox
+
+	sfnt.Tables["COLR"] = colr.Bytes()
+	test.Error(t, sfnt.parseCOLR())
+
+	translate := &PaintTransform{XX: 1.0, YY: 1.0, DX: 10.0}
+	paint, err := sfnt.GlyphPaint(41)
+	test.Error(t, err)
+	translate.Paint = &PaintGlyph{40, &PaintSolid{0, 1.0}}
+	test.T(t, paint, Paint(&PaintComposite{&PaintGlyph{42, &PaintSolid{0, 1.0}}, 3, translate}))
+
+	// glyphs 42, 39, and 40 are added as 2, 3, and 4, the layers of glyph 36 are dropped
+	sfntSubset, err := sfnt.Subset([]uint16{0, 41}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, sfntSubset.NumGlyphs(), uint16(5))
+	test.T(t, len(sfntSubset.Colr.LayerPaints), 0)
+	test.T(t, len(sfntSubset.Colr.Clips), 1)
+	test.T(t, sfntSubset.Colr.Clips[0].StartGlyphID, uint16(3))
+
+	paint, err = sfntSubset.GlyphPaint(1)
+	test.Error(t, err)
+	translate.Paint = &PaintGlyph{4, &PaintSolid{0, 1.0}}
+	test.T(t, paint, Paint(&PaintComposite{&PaintGlyph{2, &PaintSolid{0, 1.0}}, 3, translate}))
+
+	for _, subset := range [][]uint16{{0, 41}, {0, 36}, {0, 39}, {0, 36, 39, 41}} {
+		sfntSubset, err = sfnt.Subset(subset, SubsetOptions{Tables: KeepAllTables})
+		test.Error(t, err)
+		for _, baseGlyphPaint := range sfntSubset.Colr.BaseGlyphPaints {
+			deps, err := sfntSubset.Colr.Dependencies(baseGlyphPaint.GlyphID)
+			test.Error(t, err)
+			for _, glyphID := range deps {
+				test.That(t, glyphID < sfntSubset.NumGlyphs(), "glyph", glyphID, "in subset", subset)
+			}
+		}
+	}
+
+	sfntSubset, err = sfnt.Subset([]uint16{0, 36}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, len(sfntSubset.Colr.LayerPaints), 2)
+	paint, err = sfntSubset.GlyphPaint(1)
+	test.Error(t, err)
+	gradient := &PaintLinearGradient{ColorLine{ExtendPad, []ColorStop{{0.0, 1, 1.0}}}, 0, 0, 100, 0, 0, 100}
+	test.T(t, paint, Paint(&PaintLayers{[]Paint{&PaintGlyph{2, &PaintSolid{0, 1.0}}, &PaintGlyph{3, gradient}}}))
+	test.That(t, len(sfntSubset.Tables["COLR"]) < len(sfnt.Tables["COLR"]))
+
+	_, err = ParseSFNT(sfntSubset.Write(), 0)
+	test.Error(t, err)
+}
+
+func TestSFNTSubsetColorClip(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// glyph 36 is clipped and paints glyph 37 with a repeating gradient, the table is in the layout written by subset
+	colr := parse.NewBinaryWriter([]byte{})
+	colr.WriteUint16(1) // version
+	colr.WriteBytes(make([]byte, 12))
+	colr.WriteUint32(34) // baseGlyphListOffset
+	colr.WriteUint32(0)  // layerListOffset
+	colr.WriteUint32(44) // clipListOffset
+	colr.WriteBytes(make([]byte, 8))
+	colr.WriteBytes([]byte{0, 0, 0, 1, 0, 36, 0, 0, 0, 31})                      // BaseGlyphList
+	colr.WriteBytes([]byte{1, 0, 0, 0, 1, 0, 36, 0, 36, 0, 0, 12})               // ClipList
+	colr.WriteBytes([]byte{1, 0, 10, 0, 20, 0, 100, 0, 110})                     // ClipBox
+	colr.WriteBytes([]byte{10, 0, 0, 6, 0, 37})                                  // PaintGlyph
+	colr.WriteBytes([]byte{4, 0, 0, 16, 0, 0, 0, 0, 0, 100, 0, 0, 0, 0, 0, 100}) // PaintLinearGradient
+	colr.WriteBytes([]byte{1, 0, 1, 0, 0, 0, 2, 0x40, 0})                        // ColorLine
+
+	sfnt.Tables["COLR"] = colr.Bytes()
+	test.Error(t, sfnt.parseCOLR())
+
+	glyphIDs := make([]uint16, 38)
+	for i := range glyphIDs {
+		glyphIDs[i] = uint16(i)
+	}
+	sfntSubset, err := sfnt.Subset(glyphIDs, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, sfntSubset.Tables["COLR"], sfnt.Tables["COLR"])
+}
+
+func TestSFNTHinting(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// draw in pixels at 12ppem
+	id := sfnt.GlyphIndex('H')
+	f := 12.0 / float64(sfnt.Head.UnitsPerEm)
+
+	unhinted := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(unhinted, id, 12, 0, 0, f, NoHinting))
+	test.That(t, unhinted.YMax != 9.0)
+
+	full := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(full, id, 12, 0, 0, f, FullHinting))
+	test.Float(t, full.XMax, math.Round(full.XMax))
+	test.Float(t, full.YMin, 0.0)
+	test.Float(t, full.YMax, 9.0)
+
+	vertical := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(vertical, id, 12, 0, 0, f, VerticalHinting))
+	test.That(t, math.Abs(vertical.XMax-unhinted.XMax) < 1.0/64.0, "x must not be hinted")
+	test.Float(t, vertical.YMin, 0.0)
+	test.Float(t, vertical.YMax, 9.0)
+}
+
+func TestTTInterpreter(t *testing.T) {
+	var tts = []struct {
+		code  []byte
+		stack []int32
+	}{
+		{[]byte{0xB1, 2, 3, 0x60}, []int32{5}},                                  // PUSHB[1] ADD
+		{[]byte{0xB8, 0xFF, 0xFE, 0x64}, []int32{2}},                            // PUSHW[0] ABS
+		{[]byte{0xB1, 128, 64, 0x62}, []int32{128}},                             // PUSHB[1] DIV
+		{[]byte{0xB1, 1, 2, 0x23, 0x20}, []int32{2, 1, 1}},                      // SWAP DUP
+		{[]byte{0xB0, 1, 0x58, 0xB0, 7, 0x1B, 0xB0, 8, 0x59}, []int32{7}},       // IF ELSE EIF
+		{[]byte{0xB0, 0, 0x58, 0xB0, 7, 0x1B, 0xB0, 8, 0x59}, []int32{8}},       // IF ELSE EIF
+		{[]byte{0xB0, 0, 0x58, 0x40, 1, 0x1B, 0x1B, 0xB0, 8, 0x59}, []int32{8}}, // skip push data
+		{[]byte{0xB2, 3, 0, 0, 0x2C, 0xB0, 9, 0x2D, 0x2A}, []int32{9, 9, 9}},    // FDEF LOOPCALL
+		{[]byte{0xB0, 3, 0x1C, 0xB0, 5, 0xB0, 6}, []int32{6}},                   // JMPR
+		{[]byte{0xB0, 100, 0x68}, []int32{128}},                                 // ROUND
+		{[]byte{0xB0, 1, 0x88}, []int32{35}},                                    // GETINFO
+	}
+	for _, tt := range tts {
+		t.Run(fmt.Sprintf("%X", tt.code), func(t *testing.T) {
+			h := &ttHinter{ppem: 12, hinting: FullHinting, maxStack: 32, functions: map[int32][]byte{}, idefs: map[uint8][]byte{}}
+			c := h.newContext(newTTZone(0), ttDefaultGraphicsState)
+			test.Error(t, c.run(tt.code, 0))
+			test.T(t, c.stack, tt.stack)
+		})
+	}
+}
+
+func TestSFNTHintingCFF(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/EBGaramond12-Regular.otf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// draw in pixels at 12ppem, the baseline and x-height of 'o' are aligned to blue zones
+	id := sfnt.GlyphIndex('o')
+	f := 12.0 / float64(sfnt.Head.UnitsPerEm)
+
+	unhinted := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(unhinted, id, 12, 0, 0, f, NoHinting))
+	test.That(t, unhinted.YMax != 5.0)
+
+	vertical := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(vertical, id, 12, 0, 0, f, VerticalHinting))
+	test.Float(t, vertical.YMin, 0.0)
+	test.Float(t, vertical.YMax, 5.0)
+	test.Float(t, vertical.XMax, unhinted.XMax)
+
+	full := &bboxPather{}
+	test.Error(t, sfnt.GlyphPath(full, id, 12, 0, 0, f, FullHinting))
+	test.Float(t, full.YMax, 5.0)
+	test.Float(t, full.XMax, math.Round(full.XMax))
+}
+
+func TestSFNTAutohint(t *testing.T) {
+	var tts = []struct {
+		filename   string
+		r          rune
+		yMin, yMax float64
+	}{
+		{"DejaVuSerif.ttf", 'o', 0.0, 6.0},
+		{"DejaVuSerif.ttf", 'H', 0.0, 9.0},
+		{"DejaVuSerif.ttf", 'm', 0.0, 6.0},
+		{"EBGaramond12-Regular.otf", 'o', 0.0, 5.0},
+	}
+	for _, tt := range tts {
+		t.Run(fmt.Sprintf("%v/%c", tt.filename, tt.r), func(t *testing.T) {
+			b, err := ioutil.ReadFile("resources/" + tt.filename)
+			test.Error(t, err)
+
+			sfnt, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+
+			// draw in pixels at 12ppem, baseline and x-height or cap-height are aligned to the grid
+			id := sfnt.GlyphIndex(tt.r)
+			f := 12.0 / float64(sfnt.Head.UnitsPerEm)
+			p := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.GlyphPath(p, id, 12, 0, 0, f, AutohinterHinting))
+			test.Float(t, p.YMin, tt.yMin)
+			test.Float(t, p.YMax, tt.yMax)
+		})
+	}
+}
+
+func TestRasterizer(t *testing.T) {
+	// square of 2x2 pixels offset by half a pixel
+	r := NewRasterizer(3, 3, NonZero)
+	r.MoveTo(0.5, 0.5)
+	r.LineTo(2.5, 0.5)
+	r.LineTo(2.5, 2.5)
+	r.LineTo(0.5, 2.5)
+	r.Close()
+	test.T(t, r.Alpha().Pix, []uint8{64, 128, 64, 128, 255, 128, 64, 128, 64})
+
+	// two overlapping squares in the same direction
+	for _, fillRule := range []FillRule{NonZero, EvenOdd} {
+		r = NewRasterizer(2, 1, fillRule)
+		for i := 0; i < 2; i++ {
+			r.MoveTo(0.0, 0.0)
+			r.LineTo(float64(i+1), 0.0)
+			r.LineTo(float64(i+1), 1.0)
+			r.LineTo(0.0, 1.0)
+			r.Close()
+		}
+		if fillRule == NonZero {
+			test.T(t, r.Alpha().Pix, []uint8{255, 255})
+		} else {
+			test.T(t, r.Alpha().Pix, []uint8{0, 255})
+		}
+	}
+
+	// triangles with edges crossing the left and right sides of the image within a row
+	for _, triangle := range [][3][2]float64{
+		{{-3.0, 0.5}, {15.0, 3.5}, {5.0, 11.5}},
+		{{6.0, -2.0}, {14.5, 5.25}, {-2.5, 7.75}},
+		{{-1.25, 11.0}, {0.75, 0.5}, {13.0, 6.5}},
+	} {
+		r = NewRasterizer(12, 12, NonZero)
+		r.MoveTo(triangle[0][0], triangle[0][1])
+		r.LineTo(triangle[1][0], triangle[1][1])
+		r.LineTo(triangle[2][0], triangle[2][1])
+		r.Close()
+		img := r.Alpha()
+		for y := 0; y < 12; y++ {
+			for x := 0; x < 12; x++ {
+				// clip the triangle to the pixel and compute its area
+				poly := triangle[:]
+				for _, edge := range [4][3]float64{{1, 0, float64(x)}, {-1, 0, -float64(x + 1)}, {0, 1, float64(y)}, {0, -1, -float64(y + 1)}} {
+					clipped := [][2]float64{}
+					for i, p := range poly {
+						q := poly[(i+1)%len(poly)]
+						dp, dq := edge[0]*p[0]+edge[1]*p[1]-edge[2], edge[0]*q[0]+edge[1]*q[1]-edge[2]
+						if 0.0 <= dp {
+							clipped = append(clipped, p)
+						}
+						if (dp < 0.0) != (dq < 0.0) {
+							t := dp / (dp - dq)
+							clipped = append(clipped, [2]float64{p[0] + t*(q[0]-p[0]), p[1] + t*(q[1]-p[1])})
+						}
+					}
+					poly = clipped
+				}
+				area := 0.0
+				for i, p := range poly {
+					q := poly[(i+1)%len(poly)]
+					area += p[0]*q[1] - q[0]*p[1]
+				}
+				coverage := math.Abs(area) / 2.0 * 255.0
+				test.That(t, math.Abs(float64(img.Pix[img.PixOffset(x, y)])-coverage) <= 1.0, triangle, x, y, img.Pix[img.PixOffset(x, y)], coverage)
+			}
+		}
+	}
+
+	// circle approximated by cubic Béziers has an area of pi*r^2
+	r = NewRasterizer(20, 20, NonZero)
+	k := 0.5522847498 * 8.0
+	r.MoveTo(18.0, 10.0)
+	r.CubeTo(18.0, 10.0+k, 10.0+k, 18.0, 10.0, 18.0)
+	r.CubeTo(10.0-k, 18.0, 2.0, 10.0+k, 2.0, 10.0)
+	r.CubeTo(2.0, 10.0-k, 10.0-k, 2.0, 10.0, 2.0)
+	r.CubeTo(10.0+k, 2.0, 18.0, 10.0-k, 18.0, 10.0)
+	r.Close()
+	area := 0.0
+	for _, v := range r.Alpha().Pix {
+		area += float64(v) / 255.0
+	}
+	test.That(t, math.Abs(area-math.Pi*64.0) < 0.01*math.Pi*64.0, area)
+}
+
+func TestSFNTRasterizeGlyph(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// cap-height of 'H' is 9 pixels at 12ppem
+	raster, err := sfnt.RasterizeGlyph(sfnt.GlyphIndex('H'), 12, FullHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, raster.BearingY, 9)
+	test.T(t, raster.Rect.Dy(), 9)
+	test.T(t, raster.Advance, int(sfnt.GlyphAdvancePPEM(sfnt.GlyphIndex('H'), 12)))
+	test.T(t, raster.AlphaAt(raster.Rect.Dx()/2, 4).A, uint8(255)) // crossbar
+
+	// unhinted glyphs use the linear advance, the hinted advance of 'm' at 12ppem is wider
+	raster, err = sfnt.RasterizeGlyph(sfnt.GlyphIndex('m'), 12, FullHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, raster.Advance, 12)
+	raster, err = sfnt.RasterizeGlyph(sfnt.GlyphIndex('m'), 12, NoHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, raster.Advance, 11)
+	lcd, err := sfnt.RasterizeGlyphLCD(sfnt.GlyphIndex('m'), 12, NoHinting, 0.0, LCDOptions{})
+	test.Error(t, err)
+	test.T(t, lcd.Advance, 11)
+
+	// subpixel offset shifts the coverage to the right
+	r0, err := sfnt.RasterizeGlyph(sfnt.GlyphIndex('o'), 12, NoHinting, 0.0)
+	test.Error(t, err)
+	r1, err := sfnt.RasterizeGlyph(sfnt.GlyphIndex('o'), 12, NoHinting, 0.5)
+	test.Error(t, err)
+	sum := func(r *GlyphRaster) (float64, float64) {
+		total, moment := 0.0, 0.0
+		for y := 0; y < r.Rect.Dy(); y++ {
+			for x := 0; x < r.Rect.Dx(); x++ {
+				v := float64(r.AlphaAt(x, y).A)
+				total += v
+				moment += v * (float64(r.BearingX+x) + 0.5)
+			}
+		}
+		return total, moment / total
+	}
+	total0, center0 := sum(r0)
+	total1, center1 := sum(r1)
+	test.That(t, math.Abs(total0-total1) < 255.0, total0, total1)
+	test.Float(t, math.Round((center1-center0)*10.0)/10.0, 0.5)
+
+	// space has no outline
+	raster, err = sfnt.RasterizeGlyph(sfnt.GlyphIndex(' '), 12, NoHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, raster.Rect.Empty(), true)
+}
+
+func TestSFNTRasterizeGlyphLCD(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	id := sfnt.GlyphIndex('o')
+	sum := func(pix []uint8, skip int) int {
+		n := 0
+		for i, v := range pix {
+			if i%4 != skip {
+				n += int(v)
+			}
+		}
+		return n
+	}
+
+	// without filter, the subpixels equal the coverage at three times the horizontal resolution
+	none, err := sfnt.RasterizeGlyphLCD(id, 12, NoHinting, 0.0, LCDOptions{Filter: LCDFilterNone})
+	test.Error(t, err)
+	raster, err := sfnt.RasterizeGlyph(id, 12, NoHinting, 0.0)
+	test.Error(t, err)
+	test.T(t, none.BearingX, raster.BearingX-1)
+	test.T(t, none.BearingY, raster.BearingY)
+	test.T(t, none.Rect.Dx(), raster.Rect.Dx()+2)
+	test.T(t, none.Rect.Dy(), raster.Rect.Dy())
+	test.That(t, math.Abs(float64(sum(none.Pix, 3))/3.0-float64(sum(raster.Pix, -1))) < float64(len(raster.Pix)), sum(none.Pix, 3), sum(raster.Pix, -1))
+
+	// filters preserve the brightness and BGR swaps red and blue
+	for _, filter := range []LCDFilter{LCDFilterDefault, LCDFilterLight, LCDFilterLegacy} {
+		rgb, err := sfnt.RasterizeGlyphLCD(id, 12, NoHinting, 0.0, LCDOptions{Filter: filter})
+		test.Error(t, err)
+		test.That(t, math.Abs(float64(sum(rgb.Pix, 3)-sum(none.Pix, 3))) < 0.02*float64(sum(none.Pix, 3)), filter, sum(rgb.Pix, 3), sum(none.Pix, 3))
+
+		bgr, err := sfnt.RasterizeGlyphLCD(id, 12, NoHinting, 0.0, LCDOptions{Layout: LCDBGR, Filter: filter})
+		test.Error(t, err)
+		for i := 0; i < len(rgb.Pix); i += 4 {
+			test.T(t, bgr.Pix[i:i+4], []uint8{rgb.Pix[i+2], rgb.Pix[i+1], rgb.Pix[i], rgb.Pix[i+3]})
+		}
+	}
+
+	// full hinting is reduced to vertical hinting for horizontal layouts
+	full, err := sfnt.RasterizeGlyphLCD(id, 12, FullHinting, 0.0, LCDOptions{})
+	test.Error(t, err)
+	vertical, err := sfnt.RasterizeGlyphLCD(id, 12, VerticalHinting, 0.0, LCDOptions{})
+	test.Error(t, err)
+	test.T(t, full.Pix, vertical.Pix)
+
+	// the automatic hinter only hints vertically for horizontal layouts and keeps the subpixel offset
+	autofit, err := sfnt.RasterizeGlyphLCD(id, 12, AutohinterHinting, 0.0, LCDOptions{})
+	test.Error(t, err)
+	shifted, err := sfnt.RasterizeGlyphLCD(id, 12, AutohinterHinting, 1.0/3.0, LCDOptions{})
+	test.Error(t, err)
+	test.T(t, shifted.BearingX, autofit.BearingX)
+	test.That(t, !bytes.Equal(shifted.Pix, autofit.Pix), "subpixel offset has no effect")
+
+	vrgb, err := sfnt.RasterizeGlyphLCD(id, 12, NoHinting, 0.0, LCDOptions{Layout: LCDVRGB})
+	test.Error(t, err)
+	test.T(t, vrgb.Rect.Dx(), raster.Rect.Dx())
+	test.T(t, vrgb.Rect.Dy(), raster.Rect.Dy()+2)
+	test.T(t, vrgb.BearingY, raster.BearingY+1)
+}
+
+func TestSFNTGlyphSDF(t *testing.T) {
+	for _, filename := range []string{"DejaVuSerif.ttf", "EBGaramond12-Regular.otf"} {
+		t.Run(filename, func(t *testing.T) {
+			b, err := ioutil.ReadFile("resources/" + filename)
+			test.Error(t, err)
+
+			sfnt, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+
+			id := sfnt.GlyphIndex('A')
+			sdf, err := sfnt.GlyphSDF(id, 32, 4.0)
+			test.Error(t, err)
+			msdf, err := sfnt.GlyphMSDF(id, 32, 4.0)
+			test.Error(t, err)
+			raster, err := sfnt.RasterizeGlyph(id, 32, NoHinting, 0.0)
+			test.Error(t, err)
+			test.T(t, sdf.Rect, msdf.Rect)
+			test.T(t, sdf.BearingX, raster.BearingX-2)
+			test.T(t, sdf.BearingY, raster.BearingY+2)
+
+			// pixels fully inside or outside have the same sign, and the median of the MSDF equals the SDF away from corners
+			mismatches, differences := 0, 0
+			for y := 0; y < raster.Rect.Dy(); y++ {
+				for x := 0; x < raster.Rect.Dx(); x++ {
+					v := sdf.GrayAt(x+2, y+2).Y
+					if a := raster.AlphaAt(x, y).A; a == 0 && 128 <= v || a == 255 && v < 128 {
+						mismatches++
+					}
+					c := msdf.RGBAAt(x+2, y+2)
+					median := max(min(c.R, c.G), min(max(c.R, c.G), c.B))
+					if 8 < int(median)-int(v) || 8 < int(v)-int(median) {
+						differences++
+					}
+				}
+			}
+			test.T(t, mismatches, 0)
+			test.That(t, differences < raster.Rect.Dx()*raster.Rect.Dy()/50, differences)
+		})
+	}
+}
+
+func TestSFNTSynthetic(t *testing.T) {
+	for _, filename := range []string{"DejaVuSerif.ttf", "EBGaramond12-Regular.otf"} {
+		t.Run(filename, func(t *testing.T) {
+			b, err := ioutil.ReadFile("resources/" + filename)
+			test.Error(t, err)
+
+			sfnt, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+
+			id := sfnt.GlyphIndex('H')
+			regular := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.GlyphPath(regular, id, 0, 0, 0, 1.0, NoHinting))
+
+			// emboldening keeps the baseline and left side bearing
+			style := sfnt.SyntheticStyle(true, false)
+			bold := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.SyntheticGlyphPath(bold, id, 0, 0, 0, 1.0, NoHinting, style))
+			test.That(t, math.Abs(bold.XMin-regular.XMin) < 1.0, bold.XMin, regular.XMin)
+			test.That(t, math.Abs(bold.YMin-regular.YMin) < 1.0, bold.YMin, regular.YMin)
+			test.That(t, math.Abs(bold.XMax-regular.XMax-style.Embolden) < 1.0, bold.XMax, regular.XMax)
+			test.That(t, math.Abs(bold.YMax-regular.YMax-style.Embolden) < 1.0, bold.YMax, regular.YMax)
+			test.T(t, style.Advance(sfnt.GlyphAdvance(id)), sfnt.GlyphAdvance(id)+uint16(math.Round(style.Embolden)))
+
+			// slanting shears around the baseline, the top serif need not be the widest
+			style = sfnt.SyntheticStyle(false, true)
+			oblique := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.SyntheticGlyphPath(oblique, id, 0, 0, 0, 1.0, NoHinting, style))
+			test.Float(t, oblique.YMin, regular.YMin)
+			test.Float(t, oblique.YMax, regular.YMax)
+			test.That(t, math.Abs(oblique.XMax-regular.XMax-style.Slant*regular.YMax) < 5.0, oblique.XMax, regular.XMax)
+
+			// baked into the font
+			style = sfnt.SyntheticStyle(true, true)
+			synthetic := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.SyntheticGlyphPath(synthetic, id, 0, 0, 0, 1.0, NoHinting, style))
+			advance := style.Advance(sfnt.GlyphAdvance(id))
+			test.Error(t, sfnt.Synthesize(style))
+
+			sfnt, err = ParseSFNT(sfnt.Write(), 0)
+			test.Error(t, err)
+			baked := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+			test.Error(t, sfnt.GlyphPath(baked, id, 0, 0, 0, 1.0, NoHinting))
+			test.That(t, math.Abs(baked.XMin-synthetic.XMin) <= 1.0, baked.XMin, synthetic.XMin)
+			test.That(t, math.Abs(baked.XMax-synthetic.XMax) <= 1.0, baked.XMax, synthetic.XMax)
+			test.That(t, math.Abs(baked.YMax-synthetic.YMax) <= 1.0, baked.YMax, synthetic.YMax)
+			test.T(t, sfnt.GlyphAdvance(id), advance)
+			test.That(t, sfnt.Head.MacStyle[0] && sfnt.Head.MacStyle[1])
+			test.T(t, sfnt.OS2.FsSelection&0x0021, uint16(0x0021))
+			test.That(t, sfnt.Post.ItalicAngle < -11.9 && -12.1 < sfnt.Post.ItalicAngle, sfnt.Post.ItalicAngle)
+		})
+	}
+}
+
+func TestSFNTGasp(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	test.T(t, len(sfnt.Gasp.Ranges), 2)
+	test.T(t, sfnt.GaspBehavior(8), GaspDoGray)
+	test.T(t, sfnt.GaspBehavior(9), GaspGridFit|GaspDoGray)
+	test.T(t, sfnt.Hinting(8), NoHinting)
+	test.T(t, sfnt.Hinting(12), FullHinting)
+
+	id := sfnt.GlyphIndex('H')
+	f := 12.0 / float64(sfnt.Head.UnitsPerEm)
+	for _, ppem := range []uint16{8, 12} {
+		auto := &bboxPather{}
+		test.Error(t, sfnt.GlyphPath(auto, id, ppem, 0, 0, f, AutoHinting))
+		expected := &bboxPather{}
+		test.Error(t, sfnt.GlyphPath(expected, id, ppem, 0, 0, f, sfnt.Hinting(ppem)))
+		test.T(t, *auto, *expected)
+	}
+}
+
+func TestSFNTDeviceMetrics(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// without hdmx the advances are taken from the hinted phantom points, reference values are from the FreeType port golang/freetype and differ from the rounded linear advances
+	var tts = []struct {
+		r       rune
+		ppem    uint16
+		linear  uint16
+		advance uint16
+	}{
+		{'H', 11, 10, 9},
+		{'H', 16, 14, 13},
+		{'o', 11, 7, 6},
+		{'m', 12, 11, 12},
+		{'m', 14, 13, 14},
+		{'W', 10, 10, 11},
+		{'W', 14, 14, 15},
+	}
+	test.That(t, sfnt.Hdmx == nil)
+	for _, tt := range tts {
+		glyphID := sfnt.GlyphIndex(tt.r)
+		linear := uint16(math.Round(float64(sfnt.GlyphAdvance(glyphID)) * float64(tt.ppem) / float64(sfnt.Head.UnitsPerEm)))
+		test.T(t, linear, tt.linear, string(tt.r), tt.ppem)
+		test.T(t, sfnt.GlyphAdvancePPEM(glyphID, tt.ppem), tt.advance, string(tt.r), tt.ppem)
+	}
+
+	idH, idI, idM := sfnt.GlyphIndex('H'), sfnt.GlyphIndex('i'), sfnt.GlyphIndex('m')
+	ascender, descender := sfnt.VerticalMetricsPPEM(12)
+	test.T(t, ascender, uint16(11))
+	test.T(t, descender, uint16(3))
+
+	// hdmx has a record for 12ppem, LTSH marks H as linear from 10ppem
+	numGlyphs := int(sfnt.NumGlyphs())
+	hdmx := parse.NewBinaryWriter([]byte{})
+	hdmx.WriteUint16(0)                              // version
+	hdmx.WriteInt16(1)                               // numRecords
+	hdmx.WriteInt32(int32((2 + numGlyphs + 3) &^ 3)) // sizeDeviceRecord
+	hdmx.WriteBytes([]byte{12, 20})
+	widths := make([]byte, (2+numGlyphs+3)&^3-2)
+	widths[idI] = 20
+	widths[idM] = 9
+	hdmx.WriteBytes(widths)
+
+	ltsh := parse.NewBinaryWriter([]byte{})
+	ltsh.WriteUint16(0) // version
+	ltsh.WriteUint16(uint16(numGlyphs))
+	yPels := make([]byte, numGlyphs)
+	yPels[idH] = 10
+	ltsh.WriteBytes(yPels)
+
+	vdmx := parse.NewBinaryWriter([]byte{})
+	vdmx.WriteUint16(1)                             // version
+	vdmx.WriteUint16(1)                             // numRecs
+	vdmx.WriteUint16(2)                             // numRatios
+	vdmx.WriteBytes([]byte{1, 2, 1, 1})             // 2:1 ratio
+	vdmx.WriteBytes([]byte{1, 0, 0, 0})             // all ratios
+	vdmx.WriteUint16(18)                            // offset
+	vdmx.WriteUint16(18)                            // offset
+	vdmx.WriteBytes([]byte{0, 2, 12, 13})           // group
+	vdmx.WriteBytes([]byte{0, 12, 0, 12, 255, 252}) // 12ppem
+	vdmx.WriteBytes([]byte{0, 13, 0, 13, 255, 252}) // 13ppem
+
+	sfnt.Tables["hdmx"] = hdmx.Bytes()
+	sfnt.Tables["LTSH"] = ltsh.Bytes()
+	sfnt.Tables["VDMX"] = vdmx.Bytes()
+	test.Error(t, sfnt.parseHdmx())
+	test.Error(t, sfnt.parseLTSH())
+	test.Error(t, sfnt.parseVDMX())
+
+	test.T(t, sfnt.GlyphAdvancePPEM(idI, 12), uint16(20))
+	test.T(t, sfnt.GlyphAdvancePPEM(idM, 12), uint16(9))  // from hdmx, not hinted
+	test.T(t, sfnt.GlyphAdvancePPEM(idM, 14), uint16(14)) // no hdmx record, hinted
+	test.T(t, sfnt.GlyphAdvancePPEM(idH, 11), uint16(math.Round(float64(sfnt.GlyphAdvance(idH))*11.0/float64(sfnt.Head.UnitsPerEm))))
+	ascender, descender = sfnt.VerticalMetricsPPEM(12)
+	test.T(t, ascender, uint16(12))
+	test.T(t, descender, uint16(4))
+	_, _, ok := sfnt.Vdmx.Get(12, 2, 1)
+	test.That(t, ok)
+	_, _, ok = sfnt.Vdmx.Get(14, 1, 1)
+	test.That(t, !ok)
+
+	sfntSubset, err := sfnt.Subset([]uint16{0, idI, idH}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, sfntSubset.Hdmx.Records[0].Widths, []uint8{0, 20, 0})
+	test.T(t, sfntSubset.Hdmx.Records[0].MaxWidth, uint8(20))
+	test.T(t, sfntSubset.Ltsh.YPels, []uint8{0, 0, 10})
+}
+
+func TestSFNTConvert(t *testing.T) {
+	// sample points on the outline of a glyph about every four font units
+	sample := func(sfnt *SFNT, glyphID uint16) [][2]float64 {
+		p := &outlinePather{}
+		test.Error(t, sfnt.GlyphPath(p, glyphID, 0, 0.0, 0.0, 1.0, NoHinting))
+		points := [][2]float64{}
+		for _, contour := range p.contours {
+			start := contour.points[0]
+			contour.ops = append(contour.ops, 'L')
+			contour.points = append(contour.points, start)
+			ps := contour.points[1:]
+			for _, op := range contour.ops {
+				n := map[byte]int{'L': 1, 'Q': 2, 'C': 3}[op]
+				length, prev := 0.0, start
+				for _, q := range ps[:n] {
+					length += math.Hypot(q[0]-prev[0], q[1]-prev[1])
+					prev = q
+				}
+				m := max(1, int(length/4.0))
+				for i := 0; i < m; i++ {
+					t := float64(i) / float64(m)
+					switch op {
+					case 'L':
+						points = append(points, [2]float64{start[0] + t*(ps[0][0]-start[0]), start[1] + t*(ps[0][1]-start[1])})
+					case 'Q':
+						a, b, c := (1-t)*(1-t), 2*(1-t)*t, t*t
+						points = append(points, [2]float64{a*start[0] + b*ps[0][0] + c*ps[1][0], a*start[1] + b*ps[0][1] + c*ps[1][1]})
+					case 'C':
+						a, b, c, d := (1-t)*(1-t)*(1-t), 3*(1-t)*(1-t)*t, 3*(1-t)*t*t, t*t*t
+						points = append(points, [2]float64{a*start[0] + b*ps[0][0] + c*ps[1][0] + d*ps[2][0], a*start[1] + b*ps[0][1] + c*ps[1][1] + d*ps[2][1]})
+					}
+				}
+				start, ps = ps[n-1], ps[n:]
+			}
+		}
+		return points
+	}
+
+	// maximum distance of the points of one outline to the nearest point of the other
+	distance := func(a, b [][2]float64) float64 {
+		dmax := 0.0
+		for _, p := range a {
+			dmin := math.Inf(1)
+			for _, q := range b {
+				dmin = math.Min(dmin, math.Hypot(p[0]-q[0], p[1]-q[1]))
+			}
+			dmax = math.Max(dmax, dmin)
+		}
+		return dmax
+	}
+
+	var tests = []struct {
+		filename     string
+		subroutinize bool
+	}{
+		{"DejaVuSerif.ttf", false},
+		{"DejaVuSerif.ttf", true},
+		{"EBGaramond12-Regular.otf", false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/subroutinize=%v", tt.filename, tt.subroutinize), func(t *testing.T) {
+			b, err := ioutil.ReadFile("resources/" + tt.filename)
+			test.Error(t, err)
+
+			orig, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+			sfnt, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+
+			options := ConvertOptions{Subroutinize: tt.subroutinize}
+			if orig.IsTrueType {
+				test.Error(t, sfnt.ToCFF(options))
+				test.That(t, sfnt.CFF != nil && sfnt.Glyf == nil)
+				test.T(t, sfnt.Maxp.Version, uint32(0x00005000))
+				if tt.subroutinize {
+					test.That(t, 0 < sfnt.CFF.fonts.localSubrs[0].Len())
+				}
+			} else {
+				test.Error(t, sfnt.ToTrueType(options))
+				test.That(t, sfnt.CFF == nil && sfnt.Glyf != nil)
+				test.T(t, sfnt.Maxp.Version, uint32(0x00010000))
+				test.T(t, sfnt.Head.IndexToLocFormat, int16(1))
+			}
+
+			sfnt, err = ParseSFNT(sfnt.Write(), 0)
+			test.Error(t, err)
+			test.T(t, sfnt.IsCFF, orig.IsTrueType)
+			test.T(t, sfnt.NumGlyphs(), orig.NumGlyphs())
+			for _, r := range "AgQ&8" {
+				glyphID := orig.GlyphIndex(r)
+				test.T(t, sfnt.GlyphIndex(r), glyphID)
+				test.T(t, sfnt.GlyphAdvance(glyphID), orig.GlyphAdvance(glyphID))
+				test.T(t, sfnt.GlyphName(glyphID), orig.GlyphName(glyphID))
+
+				// within the tolerance plus rounding to integers and the sampling distance
+				a, b := sample(orig, glyphID), sample(sfnt, glyphID)
+				test.That(t, distance(a, b) < 4.0, string(r), distance(a, b))
+				test.That(t, distance(b, a) < 4.0, string(r), distance(b, a))
+			}
+
+			_, err = sfnt.WriteWOFF2()
+			test.Error(t, err)
+		})
+	}
+}
+
+func TestCubicToQuads(t *testing.T) {
+	p0, p1, p2, p3 := [2]float64{0.0, 0.0}, [2]float64{0.0, 100.0}, [2]float64{100.0, 100.0}, [2]float64{100.0, 0.0}
+	for _, tolerance := range []float64{10.0, 1.0, 0.1} {
+		quads := cubicToQuads(p0, p1, p2, p3, tolerance)
+		test.T(t, quads[len(quads)-1][1], p3)
+
+		// compare at the end points and midpoints of the quadratic Béziers
+		start := p0
+		for i, quad := range quads {
+			t0 := float64(i) / float64(len(quads))
+			for _, dt := range []float64{0.5, 1.0} {
+				s := t0 + dt/float64(len(quads))
+				x := 3.0*(1.0-s)*(1.0-s)*s*p1[0] + 3.0*(1.0-s)*s*s*p2[0] + s*s*s*p3[0]
+				y := 3.0*(1.0-s)*(1.0-s)*s*p1[1] + 3.0*(1.0-s)*s*s*p2[1]
+				qx := (1.0-dt)*(1.0-dt)*start[0] + 2.0*(1.0-dt)*dt*quad[0][0] + dt*dt*quad[1][0]
+				qy := (1.0-dt)*(1.0-dt)*start[1] + 2.0*(1.0-dt)*dt*quad[0][1] + dt*dt*quad[1][1]
+				test.That(t, math.Hypot(x-qx, y-qy) <= tolerance, tolerance, len(quads), i)
+			}
+			start = quad[1]
+		}
+	}
+}
+
+func TestFontBuilder(t *testing.T) {
+	b := NewFontBuilder("Test Icons", "Light Italic", 1000)
+	b.Timestamp = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.Names = map[NameID]string{NameCopyrightNotice: "Copyright (c) 2020"}
+	b.UnderlinePosition = -150 // thickness keeps its default
+
+	square := b.AddGlyph("square", 600, 0xE000)
+	square.MoveTo(50.0, 0.0)
+	square.LineTo(550.0, 0.0)
+	square.LineTo(550.0, 500.0)
+	square.LineTo(50.0, 500.0)
+	square.Close()
+
+	// circle of radius 250 with a hole of radius 150, the hole runs clockwise
+	ring := b.AddGlyph("", 600, 0xE001, 0xE002)
+	for _, r := range []float64{250.0, -150.0} {
+		k := 0.5522847498 * math.Abs(r)
+		ring.MoveTo(300.0+math.Abs(r), 250.0)
+		for i := 0; i < 4; i++ {
+			a0, a1 := float64(i)*math.Pi/2.0, float64(i+1)*math.Pi/2.0
+			sin0, cos0 := math.Sin(a0), math.Cos(a0)
+			sin1, cos1 := math.Sin(a1), math.Cos(a1)
+			if r < 0.0 {
+				sin0, sin1 = -sin0, -sin1
+			}
+			x0, y0 := 300.0+math.Abs(r)*cos0, 250.0+math.Abs(r)*sin0
+			x1, y1 := 300.0+math.Abs(r)*cos1, 250.0+math.Abs(r)*sin1
+			if r < 0.0 {
+				ring.CubeTo(x0+k*sin0, y0-k*cos0, x1-k*sin1, y1+k*cos1, x1, y1)
+			} else {
+				ring.CubeTo(x0-k*sin0, y0+k*cos0, x1+k*sin1, y1-k*cos1, x1, y1)
+			}
+		}
+		ring.Close()
+	}
+	b.AddGlyph("space", 300, ' ')
+
+	for _, isCFF := range []bool{false, true} {
+		t.Run(fmt.Sprint("cff=", isCFF), func(t *testing.T) {
+			var sfnt *SFNT
+			var err error
+			if isCFF {
+				sfnt, err = b.BuildCFF(ConvertOptions{})
+			} else {
+				sfnt, err = b.BuildTrueType(ConvertOptions{})
+			}
+			test.Error(t, err)
+			test.T(t, sfnt.IsCFF, isCFF)
+			test.T(t, sfnt.NumGlyphs(), uint16(4))
+			test.T(t, sfnt.GlyphIndex(0xE000), uint16(1))
+			test.T(t, sfnt.GlyphIndex(0xE002), uint16(2))
+			test.T(t, sfnt.GlyphIndex(' '), uint16(3))
+			test.T(t, sfnt.GlyphName(1), "square")
+			test.T(t, sfnt.GlyphName(2), "uniE001")
+			test.T(t, sfnt.GlyphAdvance(0), uint16(500))
+			test.T(t, sfnt.GlyphAdvance(2), uint16(600))
+			test.T(t, sfnt.Head.Created, b.Timestamp)
+			test.T(t, [4]int16{sfnt.Head.XMin, sfnt.Head.YMin, sfnt.Head.XMax, sfnt.Head.YMax}, [4]int16{50, 0, 550, 500})
+			test.T(t, sfnt.Hhea.Ascender, int16(500))
+			test.T(t, sfnt.Hhea.Descender, int16(0))
+			test.T(t, sfnt.Hmtx.LeftSideBearing(2), int16(50))
+			test.T(t, sfnt.OS2.UsWeightClass, uint16(300))
+			test.T(t, sfnt.OS2.FsSelection, uint16(0x0001)) // ITALIC
+			test.T(t, sfnt.Post.IsFixedPitch, uint32(0))
+			test.T(t, sfnt.Post.UnderlinePosition, int16(-150))
+			test.T(t, sfnt.Post.UnderlineThickness, int16(50))
+
+			test.T(t, sfnt.Name.Find(NameFontFamily), "Test Icons Light")
+			test.T(t, sfnt.Name.Find(NameFontSubfamily), "Italic")
+			test.T(t, sfnt.Name.Find(NamePreferredFamily), "Test Icons")
+			test.T(t, sfnt.Name.Find(NamePreferredSubfamily), "Light Italic")
+			test.T(t, sfnt.Name.Find(NameFull), "Test Icons Light Italic")
+			test.T(t, sfnt.Name.Find(NamePostScript), "TestIcons-LightItalic")
+			test.T(t, sfnt.Name.Find(NameCopyrightNotice), "Copyright (c) 2020")
+
+			// the ring's hole is not filled
+			raster, err := sfnt.RasterizeGlyph(2, 100, NoHinting, 0.0)
+			test.Error(t, err)
+			test.T(t, raster.AlphaAt(30-raster.BearingX, raster.BearingY-25).A, uint8(0))
+			test.T(t, raster.AlphaAt(50-raster.BearingX, raster.BearingY-25).A, uint8(255))
+
+			_, err = sfnt.WriteWOFF2()
+			test.Error(t, err)
+		})
+	}
+}
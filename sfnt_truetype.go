@@ -64,8 +64,9 @@ func (contour *glyfContour) String() string {
 }
 
 type glyfTable struct {
-	data []byte
-	loca *locaTable
+	data   []byte
+	loca   *locaTable
+	hinter *ttHinter
 }
 
 // Get returns the glyph data corresponding to the passed glyphID. It returns nil if the glyph doesn't exist.
@@ -154,6 +155,92 @@ func glyfCompositeLength(flags uint16) (length uint32, more bool) {
 	return
 }
 
+type glyfComponent struct {
+	Flags      uint16
+	GlyphID    uint16
+	Arg1, Arg2 int32    // offset if ARGS_ARE_XY_VALUES is set, otherwise point numbers
+	Transform  [4]int16 // xx, xy, yx, yy in 2.14, zero if there is no transformation
+}
+
+// parseGlyfComponents parses the components of a composite glyph and its instructions, the reader must be positioned after the glyph header.
+func parseGlyfComponents(r *parse.BinaryReader, glyphID uint16) ([]glyfComponent, []byte, error) {
+	components := []glyfComponent{}
+	hasInstructions := false
+	for {
+		if r.Len() < 4 {
+			return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+		}
+
+		component := glyfComponent{}
+		component.Flags = r.ReadUint16()
+		component.GlyphID = r.ReadUint16()
+		if component.Flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
+			if r.Len() < 4 {
+				return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+			if component.Flags&0x0002 != 0 { // ARGS_ARE_XY_VALUES
+				component.Arg1 = int32(r.ReadInt16())
+				component.Arg2 = int32(r.ReadInt16())
+			} else {
+				component.Arg1 = int32(r.ReadUint16())
+				component.Arg2 = int32(r.ReadUint16())
+			}
+		} else {
+			if r.Len() < 2 {
+				return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+			if component.Flags&0x0002 != 0 { // ARGS_ARE_XY_VALUES
+				component.Arg1 = int32(r.ReadInt8())
+				component.Arg2 = int32(r.ReadInt8())
+			} else {
+				component.Arg1 = int32(r.ReadUint8())
+				component.Arg2 = int32(r.ReadUint8())
+			}
+		}
+		if component.Flags&0x0008 != 0 { // WE_HAVE_A_SCALE
+			if r.Len() < 2 {
+				return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+			component.Transform[0] = r.ReadInt16()
+			component.Transform[3] = component.Transform[0]
+		} else if component.Flags&0x0040 != 0 { // WE_HAVE_AN_X_AND_Y_SCALE
+			if r.Len() < 4 {
+				return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+			component.Transform[0] = r.ReadInt16()
+			component.Transform[3] = r.ReadInt16()
+		} else if component.Flags&0x0080 != 0 { // WE_HAVE_A_TWO_BY_TWO
+			if r.Len() < 8 {
+				return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+			}
+			component.Transform[0] = r.ReadInt16()
+			component.Transform[1] = r.ReadInt16()
+			component.Transform[2] = r.ReadInt16()
+			component.Transform[3] = r.ReadInt16()
+		}
+		if component.Flags&0x0100 != 0 { // WE_HAVE_INSTRUCTIONS
+			hasInstructions = true
+		}
+		components = append(components, component)
+		if component.Flags&0x0020 == 0 { // MORE_COMPONENTS
+			break
+		}
+	}
+
+	var instructions []byte
+	if hasInstructions {
+		if r.Len() < 2 {
+			return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+		}
+		instructionLength := r.ReadUint16()
+		if r.Len() < int64(instructionLength) {
+			return nil, nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
+		}
+		instructions = r.ReadBytes(int64(instructionLength))
+	}
+	return components, instructions, nil
+}
+
 // Contour returns the contours of a glyph. It unpacks composite glyphs into their final shape.
 func (glyf *glyfTable) Contour(glyphID uint16) (*glyfContour, error) {
 	// TODO: cache output
@@ -269,58 +356,18 @@ func (glyf *glyfTable) contour(glyphID uint16, level int) (*glyfContour, error)
 		}
 
 		// composite glyph
-		hasInstructions := false
-		for {
-			if r.Len() < 4 {
-				return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-			}
-
-			flags := r.ReadUint16()
-			subGlyphID := r.ReadUint16()
-			if flags&0x0002 == 0 { // ARGS_ARE_XY_VALUES
+		components, instructions, err := parseGlyfComponents(r, glyphID)
+		if err != nil {
+			return nil, err
+		}
+		for _, component := range components {
+			if component.Flags&0x0002 == 0 { // ARGS_ARE_XY_VALUES
 				return nil, fmt.Errorf("glyf: composite glyph not supported")
 			}
-			var dx, dy int16
-			if flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
-				if r.Len() < 4 {
-					return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-				}
-				dx = r.ReadInt16()
-				dy = r.ReadInt16()
-			} else {
-				if r.Len() < 2 {
-					return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-				}
-				dx = int16(r.ReadInt8())
-				dy = int16(r.ReadInt8())
-			}
-			var txx, txy, tyx, tyy int16
-			if flags&0x0008 != 0 { // WE_HAVE_A_SCALE
-				if r.Len() < 2 {
-					return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-				}
-				txx = r.ReadInt16()
-				tyy = txx
-			} else if flags&0x0040 != 0 { // WE_HAVE_AN_X_AND_Y_SCALE
-				if r.Len() < 4 {
-					return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-				}
-				txx = r.ReadInt16()
-				tyy = r.ReadInt16()
-			} else if flags&0x0080 != 0 { // WE_HAVE_A_TWO_BY_TWO
-				if r.Len() < 8 {
-					return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-				}
-				txx = r.ReadInt16()
-				txy = r.ReadInt16()
-				tyx = r.ReadInt16()
-				tyy = r.ReadInt16()
-			}
-			if flags&0x0100 != 0 {
-				hasInstructions = true
-			}
+			dx, dy := int16(component.Arg1), int16(component.Arg2)
+			txx, txy, tyx, tyy := component.Transform[0], component.Transform[1], component.Transform[2], component.Transform[3]
 
-			subContour, err := glyf.contour(subGlyphID, level+1)
+			subContour, err := glyf.contour(component.GlyphID, level+1)
 			if err != nil {
 				return nil, err
 			}
@@ -337,7 +384,7 @@ func (glyf *glyfTable) contour(glyphID uint16, level int) (*glyfContour, error)
 			for i := 0; i < len(subContour.XCoordinates); i++ {
 				x := subContour.XCoordinates[i]
 				y := subContour.YCoordinates[i]
-				if flags&0x00C8 != 0 { // has transformation
+				if component.Flags&0x00C8 != 0 { // has transformation
 					const half = 1 << 13
 					xt := int16((int64(x)*int64(txx)+half)>>14) + int16((int64(y)*int64(tyx)+half)>>14)
 					yt := int16((int64(x)*int64(txy)+half)>>14) + int16((int64(y)*int64(tyy)+half)>>14)
@@ -346,29 +393,46 @@ func (glyf *glyfTable) contour(glyphID uint16, level int) (*glyfContour, error)
 				contour.XCoordinates = append(contour.XCoordinates, dx+x)
 				contour.YCoordinates = append(contour.YCoordinates, dy+y)
 			}
-			if flags&0x0020 == 0 { // MORE_COMPONENTS
-				break
-			}
-		}
-		if hasInstructions {
-			instructionLength := r.ReadUint16()
-			if r.Len() < int64(instructionLength) {
-				return nil, fmt.Errorf("glyf: bad table for glyphID %v", glyphID)
-			}
-			contour.Instructions = r.ReadBytes(int64(instructionLength))
 		}
+		contour.Instructions = instructions
 	}
 	return contour, nil
 }
 
+// ToPath draws the glyph's contours to the pather. With hinting enabled, the glyph is hinted by the font's instructions at the given ppem and falls back to the unhinted outline if the instructions fail.
 func (glyf *glyfTable) ToPath(p Pather, glyphID, ppem uint16, x, y, f float64, hinting Hinting) error {
+	if hinting != NoHinting && ppem != 0 && glyf.hinter != nil {
+		if zone, err := glyf.hinter.Hint(glyphID, ppem, hinting); err == nil {
+			// hinted points are in 26.6 pixels, convert back to font units
+			s := float64(glyf.hinter.unitsPerEm) / float64(64*int(ppem))
+			n := len(zone.cur) - 4 // skip phantom points
+			xs, ys := make([]float64, n), make([]float64, n)
+			for i := 0; i < n; i++ {
+				xs[i] = s * float64(zone.cur[i].X)
+				ys[i] = s * float64(zone.cur[i].Y)
+			}
+			glyfPath(p, zone.endPoints, zone.onCurve, xs, ys, x, y, f)
+			return nil
+		}
+	}
+
 	contour, err := glyf.Contour(glyphID)
 	if err != nil {
 		return err
 	}
+	xs, ys := make([]float64, len(contour.XCoordinates)), make([]float64, len(contour.YCoordinates))
+	for i := range xs {
+		xs[i] = float64(contour.XCoordinates[i])
+		ys[i] = float64(contour.YCoordinates[i])
+	}
+	glyfPath(p, contour.EndPoints, contour.OnCurve, xs, ys, x, y, f)
+	return nil
+}
 
+// glyfPath draws the quadratic contours to the pather, translated to (x,y) and scaled by f.
+func glyfPath(p Pather, endPoints []uint16, onCurve []bool, xs, ys []float64, x, y, f float64) {
 	var i uint16
-	for _, endPoint := range contour.EndPoints {
+	for _, endPoint := range endPoints {
 		j := i
 		first := true
 		firstOff := false
@@ -376,9 +440,9 @@ func (glyf *glyfTable) ToPath(p Pather, glyphID, ppem uint16, x, y, f float64, h
 		startX, startY := 0.0, 0.0
 		for ; i <= endPoint; i++ {
 			if first {
-				if contour.OnCurve[i] {
-					startX = float64(contour.XCoordinates[i])
-					startY = float64(contour.YCoordinates[i])
+				if onCurve[i] {
+					startX = xs[i]
+					startY = ys[i]
 					p.MoveTo(x+f*startX, y+f*startY)
 					first = false
 				} else if !prevOff {
@@ -387,43 +451,129 @@ func (glyf *glyfTable) ToPath(p Pather, glyphID, ppem uint16, x, y, f float64, h
 					prevOff = true
 				} else {
 					// first and second point are off
-					startX = float64(contour.XCoordinates[i-1]+contour.XCoordinates[i]) / 2.0
-					startY = float64(contour.YCoordinates[i-1]+contour.YCoordinates[i]) / 2.0
+					startX = (xs[i-1] + xs[i]) / 2.0
+					startY = (ys[i-1] + ys[i]) / 2.0
 					p.MoveTo(x+f*startX, y+f*startY)
 					first = false
 				}
 			} else if !prevOff {
-				if contour.OnCurve[i] {
-					p.LineTo(x+f*float64(contour.XCoordinates[i]), y+f*float64(contour.YCoordinates[i]))
+				if onCurve[i] {
+					p.LineTo(x+f*xs[i], y+f*ys[i])
 				} else {
 					prevOff = true
 				}
 			} else {
-				if contour.OnCurve[i] {
-					p.QuadTo(x+f*float64(contour.XCoordinates[i-1]), y+f*float64(contour.YCoordinates[i-1]), x+f*float64(contour.XCoordinates[i]), y+f*float64(contour.YCoordinates[i]))
+				if onCurve[i] {
+					p.QuadTo(x+f*xs[i-1], y+f*ys[i-1], x+f*xs[i], y+f*ys[i])
 					prevOff = false
 				} else {
-					midX := float64(contour.XCoordinates[i-1]+contour.XCoordinates[i]) / 2.0
-					midY := float64(contour.YCoordinates[i-1]+contour.YCoordinates[i]) / 2.0
-					p.QuadTo(x+f*float64(contour.XCoordinates[i-1]), y+f*float64(contour.YCoordinates[i-1]), x+f*midX, y+f*midY)
+					midX := (xs[i-1] + xs[i]) / 2.0
+					midY := (ys[i-1] + ys[i]) / 2.0
+					p.QuadTo(x+f*xs[i-1], y+f*ys[i-1], x+f*midX, y+f*midY)
 				}
 			}
 		}
 		if firstOff {
 			if prevOff {
-				midX := float64(contour.XCoordinates[i-1]+contour.XCoordinates[j]) / 2.0
-				midY := float64(contour.YCoordinates[i-1]+contour.YCoordinates[j]) / 2.0
-				p.QuadTo(x+f*float64(contour.XCoordinates[i-1]), y+f*float64(contour.YCoordinates[i-1]), x+f*midX, y+f*midY)
-				p.QuadTo(x+f*float64(contour.XCoordinates[j]), y+f*float64(contour.YCoordinates[j]), x+f*startX, y+f*startY)
+				midX := (xs[i-1] + xs[j]) / 2.0
+				midY := (ys[i-1] + ys[j]) / 2.0
+				p.QuadTo(x+f*xs[i-1], y+f*ys[i-1], x+f*midX, y+f*midY)
+				p.QuadTo(x+f*xs[j], y+f*ys[j], x+f*startX, y+f*startY)
 			} else {
-				p.QuadTo(x+f*float64(contour.XCoordinates[j]), y+f*float64(contour.YCoordinates[j]), x+f*startX, y+f*startY)
+				p.QuadTo(x+f*xs[j], y+f*ys[j], x+f*startX, y+f*startY)
 			}
 		} else if prevOff {
-			p.QuadTo(x+f*float64(contour.XCoordinates[i-1]), y+f*float64(contour.YCoordinates[i-1]), x+f*startX, y+f*startY)
+			p.QuadTo(x+f*xs[i-1], y+f*ys[i-1], x+f*startX, y+f*startY)
 		}
 		p.Close()
 	}
-	return nil
+}
+
+// writeGlyfContour writes a simple glyph in its most compact encoding.
+func writeGlyfContour(w *parse.BinaryWriter, contour *glyfContour) {
+	numberOfContours := int16(len(contour.EndPoints))
+	w.WriteInt16(numberOfContours)
+	w.WriteInt16(contour.XMin)
+	w.WriteInt16(contour.YMin)
+	w.WriteInt16(contour.XMax)
+	w.WriteInt16(contour.YMax)
+	for _, endPoint := range contour.EndPoints {
+		w.WriteUint16(endPoint)
+	}
+	w.WriteUint16(uint16(len(contour.Instructions)))
+	w.WriteBytes(contour.Instructions)
+
+	repeats := 0
+	xs := parse.NewBinaryWriter([]byte{})
+	ys := parse.NewBinaryWriter([]byte{})
+	numPoints := int(contour.EndPoints[numberOfContours-1]) + 1
+	for i := 0; i < numPoints; i++ {
+		dx := contour.XCoordinates[i]
+		dy := contour.YCoordinates[i]
+		if 0 < i {
+			dx -= contour.XCoordinates[i-1]
+			dy -= contour.YCoordinates[i-1]
+		}
+
+		var flag byte
+		if dx == 0 {
+			flag |= 0x10 // X_IS_SAME_OR_POSITIVE_X_SHORT_VECTOR
+		} else if -256 < dx && dx < 256 {
+			flag |= 0x02 // X_SHORT_VECTOR
+			if 0 < dx {
+				flag |= 0x10 // X_IS_SAME_OR_POSITIVE_X_SHORT_VECTOR
+				xs.WriteInt8(int8(dx))
+			} else {
+				xs.WriteInt8(int8(-dx))
+			}
+		} else {
+			xs.WriteInt16(dx)
+		}
+
+		if dy == 0 {
+			flag |= 0x20 // Y_IS_SAME_OR_POSITIVE_Y_SHORT_VECTOR
+		} else if -256 < dy && dy < 256 {
+			flag |= 0x04 // Y_SHORT_VECTOR
+			if 0 < dy {
+				flag |= 0x20 // Y_IS_SAME_OR_POSITIVE_Y_SHORT_VECTOR
+				ys.WriteByte(byte(dy))
+			} else {
+				ys.WriteByte(byte(-dy))
+			}
+		} else {
+			ys.WriteInt16(dy)
+		}
+
+		if contour.OnCurve[i] {
+			flag |= 0x01
+		}
+		if contour.OverlapSimple[i] {
+			flag |= 0x40
+		}
+
+		// handle flag repeats
+		if 0 < i && repeats < 255 && flag == w.Bytes()[w.Len()-1] {
+			repeats++
+		} else {
+			if 1 < repeats {
+				w.Bytes()[w.Len()-1] |= 0x08 // REPEAT_FLAG
+				w.WriteByte(byte(repeats))
+				repeats = 0
+			} else if repeats == 1 {
+				w.WriteByte(w.Bytes()[w.Len()-1])
+				repeats = 0
+			}
+			w.WriteByte(flag)
+		}
+	}
+	if 1 < repeats {
+		w.Bytes()[w.Len()-1] |= 0x08 // REPEAT_FLAG
+		w.WriteByte(byte(repeats))
+	} else if repeats == 1 {
+		w.WriteByte(w.Bytes()[w.Len()-1])
+	}
+	w.WriteBytes(xs.Bytes())
+	w.WriteBytes(ys.Bytes())
 }
 
 func (sfnt *SFNT) parseGlyf() error {
@@ -441,8 +591,9 @@ func (sfnt *SFNT) parseGlyf() error {
 	}
 
 	sfnt.Glyf = &glyfTable{
-		data: b,
-		loca: sfnt.Loca,
+		data:   b,
+		loca:   sfnt.Loca,
+		hinter: newTTHinter(sfnt),
 	}
 	return nil
 }
@@ -503,6 +654,30 @@ func (sfnt *SFNT) parseLoca() error {
 	return nil
 }
 
+// locaWrite returns the loca table for the glyph offsets in the glyf table, and the format used. The short format is used when all offsets are even and small enough.
+func locaWrite(glyfOffsets []uint32) ([]byte, int16) {
+	short := true
+	for _, offset := range glyfOffsets {
+		if offset%2 == 1 || math.MaxUint16 < offset/2 {
+			short = false
+			break
+		}
+	}
+
+	if short {
+		w := parse.NewBinaryWriter(make([]byte, 0, 2*len(glyfOffsets)))
+		for _, offset := range glyfOffsets {
+			w.WriteUint16(uint16(offset / 2))
+		}
+		return w.Bytes(), 0
+	}
+	w := parse.NewBinaryWriter(make([]byte, 0, 4*len(glyfOffsets)))
+	for _, offset := range glyfOffsets {
+		w.WriteUint32(offset)
+	}
+	return w.Bytes(), 1
+}
+
 ////////////////////////////////////////////////////////////////
 
 type kernPair struct {
@@ -660,3 +835,338 @@ func (kern *kernTable) Write() []byte {
 	}
 	return w.Bytes()
 }
+
+////////////////////////////////////////////////////////////////
+
+// GaspBehavior specifies the rasterization behavior for a range of ppem, see the gasp table.
+type GaspBehavior uint16
+
+// see GaspBehavior
+const (
+	GaspGridFit            GaspBehavior = 0x0001 // use hinting
+	GaspDoGray             GaspBehavior = 0x0002 // use grayscale rendering
+	GaspSymmetricGridFit   GaspBehavior = 0x0004 // use hinting with ClearType, version 1 only
+	GaspSymmetricSmoothing GaspBehavior = 0x0008 // use smoothing along multiple axes with ClearType, version 1 only
+)
+
+type gaspRange struct {
+	MaxPPEM  uint16
+	Behavior GaspBehavior
+}
+
+type gaspTable struct {
+	Version uint16
+	Ranges  []gaspRange
+}
+
+// Get returns the behavior for the given ppem. If the ppem is out of range, it returns grid-fitting and grayscale rendering.
+func (gasp *gaspTable) Get(ppem uint16) GaspBehavior {
+	for _, r := range gasp.Ranges {
+		if ppem <= r.MaxPPEM {
+			return r.Behavior
+		}
+	}
+	return GaspGridFit | GaspDoGray
+}
+
+func (sfnt *SFNT) parseGasp() error {
+	b, ok := sfnt.Tables["gasp"]
+	if !ok {
+		return fmt.Errorf("gasp: missing table")
+	} else if len(b) < 4 {
+		return fmt.Errorf("gasp: bad table")
+	}
+
+	sfnt.Gasp = &gaspTable{}
+	r := parse.NewBinaryReaderBytes(b)
+	sfnt.Gasp.Version = r.ReadUint16()
+	if 1 < sfnt.Gasp.Version {
+		return fmt.Errorf("gasp: bad version %d", sfnt.Gasp.Version)
+	}
+	numRanges := r.ReadUint16()
+	if r.Len() < 4*int64(numRanges) {
+		return fmt.Errorf("gasp: bad table")
+	}
+
+	sfnt.Gasp.Ranges = make([]gaspRange, numRanges)
+	for i := 0; i < int(numRanges); i++ {
+		sfnt.Gasp.Ranges[i].MaxPPEM = r.ReadUint16()
+		sfnt.Gasp.Ranges[i].Behavior = GaspBehavior(r.ReadUint16())
+		if 0 < i && sfnt.Gasp.Ranges[i].MaxPPEM <= sfnt.Gasp.Ranges[i-1].MaxPPEM {
+			return fmt.Errorf("gasp: ranges must be sorted by ppem")
+		}
+		if sfnt.Gasp.Version == 0 {
+			sfnt.Gasp.Ranges[i].Behavior &= GaspGridFit | GaspDoGray
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type hdmxRecord struct {
+	PixelSize uint8
+	MaxWidth  uint8
+	Widths    []uint8
+}
+
+type hdmxTable struct {
+	Records []hdmxRecord
+}
+
+// Get returns the advance width in pixels of a glyph at the given ppem.
+func (hdmx *hdmxTable) Get(glyphID, ppem uint16) (uint8, bool) {
+	for _, record := range hdmx.Records {
+		if uint16(record.PixelSize) == ppem {
+			if int(glyphID) < len(record.Widths) {
+				return record.Widths[glyphID], true
+			}
+			break
+		}
+	}
+	return 0, false
+}
+
+func (hdmx *hdmxTable) Write() []byte {
+	numGlyphs := 0
+	if 0 < len(hdmx.Records) {
+		numGlyphs = len(hdmx.Records[0].Widths)
+	}
+	sizeDeviceRecord := (2 + numGlyphs + 3) &^ 3 // 32-bit aligned
+
+	w := parse.NewBinaryWriter(make([]byte, 0, 8+len(hdmx.Records)*sizeDeviceRecord))
+	w.WriteUint16(0) // version
+	w.WriteInt16(int16(len(hdmx.Records)))
+	w.WriteInt32(int32(sizeDeviceRecord))
+	for _, record := range hdmx.Records {
+		w.WriteUint8(record.PixelSize)
+		w.WriteUint8(record.MaxWidth)
+		w.WriteBytes(record.Widths)
+		w.WriteBytes(make([]byte, sizeDeviceRecord-2-len(record.Widths)))
+	}
+	return w.Bytes()
+}
+
+func (sfnt *SFNT) parseHdmx() error {
+	b, ok := sfnt.Tables["hdmx"]
+	if !ok {
+		return fmt.Errorf("hdmx: missing table")
+	} else if len(b) < 8 {
+		return fmt.Errorf("hdmx: bad table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	version := r.ReadUint16()
+	if version != 0 {
+		return fmt.Errorf("hdmx: bad version %d", version)
+	}
+	numRecords := r.ReadInt16()
+	sizeDeviceRecord := r.ReadInt32()
+	numGlyphs := int64(sfnt.Maxp.NumGlyphs)
+	if numRecords < 0 || int64(sizeDeviceRecord) < 2+numGlyphs || r.Len()/int64(sizeDeviceRecord) < int64(numRecords) {
+		return fmt.Errorf("hdmx: bad table")
+	}
+
+	sfnt.Hdmx = &hdmxTable{}
+	sfnt.Hdmx.Records = make([]hdmxRecord, numRecords)
+	for i := 0; i < int(numRecords); i++ {
+		sfnt.Hdmx.Records[i].PixelSize = r.ReadUint8()
+		sfnt.Hdmx.Records[i].MaxWidth = r.ReadUint8()
+		sfnt.Hdmx.Records[i].Widths = r.ReadBytes(numGlyphs)
+		_ = r.ReadBytes(int64(sizeDeviceRecord) - 2 - numGlyphs) // padding
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type ltshTable struct {
+	YPels []uint8
+}
+
+// IsLinear returns true if the glyph's advance width scales linearly at the given ppem, i.e. hinting does not change the advance width.
+func (ltsh *ltshTable) IsLinear(glyphID, ppem uint16) bool {
+	if len(ltsh.YPels) <= int(glyphID) || ltsh.YPels[glyphID] == 0 {
+		return false
+	}
+	return uint16(ltsh.YPels[glyphID]) <= ppem
+}
+
+func (ltsh *ltshTable) Write() []byte {
+	w := parse.NewBinaryWriter(make([]byte, 0, 4+len(ltsh.YPels)))
+	w.WriteUint16(0) // version
+	w.WriteUint16(uint16(len(ltsh.YPels)))
+	w.WriteBytes(ltsh.YPels)
+	return w.Bytes()
+}
+
+func (sfnt *SFNT) parseLTSH() error {
+	b, ok := sfnt.Tables["LTSH"]
+	if !ok {
+		return fmt.Errorf("LTSH: missing table")
+	} else if len(b) < 4 {
+		return fmt.Errorf("LTSH: bad table")
+	}
+
+	r := parse.NewBinaryReaderBytes(b)
+	version := r.ReadUint16()
+	if version != 0 {
+		return fmt.Errorf("LTSH: bad version %d", version)
+	}
+	numGlyphs := r.ReadUint16()
+	if numGlyphs != sfnt.Maxp.NumGlyphs {
+		return fmt.Errorf("LTSH: bad numGlyphs")
+	} else if r.Len() < int64(numGlyphs) {
+		return fmt.Errorf("LTSH: bad table")
+	}
+
+	sfnt.Ltsh = &ltshTable{}
+	sfnt.Ltsh.YPels = r.ReadBytes(int64(numGlyphs))
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+type vdmxRatio struct {
+	CharSet     uint8
+	XRatio      uint8
+	YStartRatio uint8
+	YEndRatio   uint8
+	Group       int // index into Groups
+}
+
+type vdmxRecord struct {
+	YPelHeight uint16
+	YMax       int16
+	YMin       int16
+}
+
+type vdmxGroup struct {
+	StartSize uint8
+	EndSize   uint8
+	Records   []vdmxRecord
+}
+
+type vdmxTable struct {
+	Version uint16
+	Ratios  []vdmxRatio
+	Groups  []vdmxGroup
+}
+
+// Get returns the maximum and minimum y-values in pixels of all glyphs at the given ppem and pixel aspect ratio.
+func (vdmx *vdmxTable) Get(ppem uint16, xRatio, yRatio uint8) (int16, int16, bool) {
+	for _, ratio := range vdmx.Ratios {
+		if ratio.XRatio != 0 || ratio.YStartRatio != 0 || ratio.YEndRatio != 0 {
+			// compare yRatio/xRatio with the range of the record
+			if int(yRatio)*int(ratio.XRatio) < int(ratio.YStartRatio)*int(xRatio) || int(ratio.YEndRatio)*int(xRatio) < int(yRatio)*int(ratio.XRatio) {
+				continue
+			}
+		}
+
+		// use the first matching ratio only
+		group := vdmx.Groups[ratio.Group]
+		if ppem < uint16(group.StartSize) || uint16(group.EndSize) < ppem {
+			return 0, 0, false
+		}
+		for _, record := range group.Records {
+			if record.YPelHeight == ppem {
+				return record.YMax, record.YMin, true
+			}
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+func (vdmx *vdmxTable) Write() []byte {
+	offsets := make([]uint16, len(vdmx.Groups))
+	offset := 6 + 6*len(vdmx.Ratios)
+	for i, group := range vdmx.Groups {
+		offsets[i] = uint16(offset)
+		offset += 4 + 6*len(group.Records)
+	}
+
+	w := parse.NewBinaryWriter(make([]byte, 0, offset))
+	w.WriteUint16(vdmx.Version)
+	w.WriteUint16(uint16(len(vdmx.Groups))) // numRecs
+	w.WriteUint16(uint16(len(vdmx.Ratios)))
+	for _, ratio := range vdmx.Ratios {
+		w.WriteUint8(ratio.CharSet)
+		w.WriteUint8(ratio.XRatio)
+		w.WriteUint8(ratio.YStartRatio)
+		w.WriteUint8(ratio.YEndRatio)
+	}
+	for _, ratio := range vdmx.Ratios {
+		w.WriteUint16(offsets[ratio.Group])
+	}
+	for _, group := range vdmx.Groups {
+		w.WriteUint16(uint16(len(group.Records)))
+		w.WriteUint8(group.StartSize)
+		w.WriteUint8(group.EndSize)
+		for _, record := range group.Records {
+			w.WriteUint16(record.YPelHeight)
+			w.WriteInt16(record.YMax)
+			w.WriteInt16(record.YMin)
+		}
+	}
+	return w.Bytes()
+}
+
+func (sfnt *SFNT) parseVDMX() error {
+	b, ok := sfnt.Tables["VDMX"]
+	if !ok {
+		return fmt.Errorf("VDMX: missing table")
+	} else if len(b) < 6 {
+		return fmt.Errorf("VDMX: bad table")
+	}
+
+	sfnt.Vdmx = &vdmxTable{}
+	r := parse.NewBinaryReaderBytes(b)
+	sfnt.Vdmx.Version = r.ReadUint16()
+	if 1 < sfnt.Vdmx.Version {
+		return fmt.Errorf("VDMX: bad version %d", sfnt.Vdmx.Version)
+	}
+	_ = r.ReadUint16() // numRecs
+	numRatios := r.ReadUint16()
+	if r.Len() < 6*int64(numRatios) {
+		return fmt.Errorf("VDMX: bad table")
+	}
+
+	sfnt.Vdmx.Ratios = make([]vdmxRatio, numRatios)
+	for i := 0; i < int(numRatios); i++ {
+		sfnt.Vdmx.Ratios[i].CharSet = r.ReadUint8()
+		sfnt.Vdmx.Ratios[i].XRatio = r.ReadUint8()
+		sfnt.Vdmx.Ratios[i].YStartRatio = r.ReadUint8()
+		sfnt.Vdmx.Ratios[i].YEndRatio = r.ReadUint8()
+	}
+
+	groups := map[uint16]int{} // offset to group index
+	for i := 0; i < int(numRatios); i++ {
+		offset := r.ReadUint16()
+		if index, ok := groups[offset]; ok {
+			sfnt.Vdmx.Ratios[i].Group = index
+			continue
+		} else if len(b) < int(offset)+4 {
+			return fmt.Errorf("VDMX: bad offset")
+		}
+
+		rg := parse.NewBinaryReaderBytes(b[offset:])
+		numRecs := rg.ReadUint16()
+		group := vdmxGroup{}
+		group.StartSize = rg.ReadUint8()
+		group.EndSize = rg.ReadUint8()
+		if rg.Len() < 6*int64(numRecs) {
+			return fmt.Errorf("VDMX: bad group")
+		}
+		group.Records = make([]vdmxRecord, numRecs)
+		for j := 0; j < int(numRecs); j++ {
+			group.Records[j].YPelHeight = rg.ReadUint16()
+			group.Records[j].YMax = rg.ReadInt16()
+			group.Records[j].YMin = rg.ReadInt16()
+		}
+		groups[offset] = len(sfnt.Vdmx.Groups)
+		sfnt.Vdmx.Ratios[i].Group = len(sfnt.Vdmx.Groups)
+		sfnt.Vdmx.Groups = append(sfnt.Vdmx.Groups, group)
+	}
+	return nil
+}
@@ -36,3 +36,102 @@ func (p *bboxPather) CubeTo(cpx1 float64, cpy1 float64, cpx2 float64, cpy2 float
 
 func (p *bboxPather) Close() {
 }
+
+// outlineContour is a contour of a glyph outline. It holds the segment operations L, Q, or C, and the start point followed by the control points and end point of each segment.
+type outlineContour struct {
+	ops    []byte
+	points [][2]float64
+}
+
+// reverse reverses the direction of the contour while keeping its start point. Contours are closed implicitly.
+func (contour *outlineContour) reverse() {
+	if len(contour.ops) == 0 {
+		return
+	}
+	start := contour.points[0]
+	if contour.points[len(contour.points)-1] != start {
+		contour.ops = append(contour.ops, 'L')
+		contour.points = append(contour.points, start)
+	}
+
+	ops := make([]byte, 0, len(contour.ops))
+	points := make([][2]float64, 0, len(contour.points))
+	points = append(points, start)
+	end := len(contour.points)
+	for i := len(contour.ops) - 1; 0 <= i; i-- {
+		n := 1
+		if contour.ops[i] == 'Q' {
+			n = 2
+		} else if contour.ops[i] == 'C' {
+			n = 3
+		}
+		// control points in reverse order followed by the start point of the segment
+		ops = append(ops, contour.ops[i])
+		for j := end - 2; end-n-1 <= j; j-- {
+			points = append(points, contour.points[j])
+		}
+		end -= n
+	}
+	if ops[len(ops)-1] == 'L' {
+		// closing line is implicit
+		ops = ops[:len(ops)-1]
+		points = points[:len(points)-1]
+	}
+	contour.ops = ops
+	contour.points = points
+}
+
+// draw draws the contour to the pather.
+func (contour *outlineContour) draw(p Pather) {
+	points := contour.points
+	p.MoveTo(points[0][0], points[0][1])
+	points = points[1:]
+	for _, op := range contour.ops {
+		switch op {
+		case 'L':
+			p.LineTo(points[0][0], points[0][1])
+			points = points[1:]
+		case 'Q':
+			p.QuadTo(points[0][0], points[0][1], points[1][0], points[1][1])
+			points = points[2:]
+		case 'C':
+			p.CubeTo(points[0][0], points[0][1], points[1][0], points[1][1], points[2][0], points[2][1])
+			points = points[3:]
+		}
+	}
+	p.Close()
+}
+
+// outlinePather is a Pather that records a glyph outline as contours.
+type outlinePather struct {
+	contours []outlineContour
+}
+
+func (p *outlinePather) MoveTo(x, y float64) {
+	p.contours = append(p.contours, outlineContour{points: [][2]float64{{x, y}}})
+}
+
+func (p *outlinePather) add(op byte, points ...[2]float64) {
+	if len(p.contours) == 0 {
+		p.MoveTo(0.0, 0.0)
+	}
+	contour := &p.contours[len(p.contours)-1]
+	contour.ops = append(contour.ops, op)
+	contour.points = append(contour.points, points...)
+}
+
+func (p *outlinePather) LineTo(x, y float64) {
+	p.add('L', [2]float64{x, y})
+}
+
+func (p *outlinePather) QuadTo(cpx, cpy, x, y float64) {
+	p.add('Q', [2]float64{cpx, cpy}, [2]float64{x, y})
+}
+
+func (p *outlinePather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	p.add('C', [2]float64{cpx1, cpy1}, [2]float64{cpx2, cpy2}, [2]float64{x, y})
+}
+
+func (p *outlinePather) Close() {
+	// contours are closed implicitly
+}
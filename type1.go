@@ -0,0 +1,1128 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Type1 is a PostScript Type 1 font, see https://adobe-type-tools.github.io/font-tech-notes/pdfs/T1_SPEC.pdf
+type Type1 struct {
+	FontName           string
+	FullName           string
+	FamilyName         string
+	Weight             string
+	Version            string
+	Notice             string
+	ItalicAngle        float64
+	IsFixedPitch       bool
+	UnderlinePosition  float64
+	UnderlineThickness float64
+	PaintType          int
+	FontMatrix         [6]float64
+	FontBBox           [4]float64
+	Encoding           [256]string // glyph name for each character code, empty if not encoded
+	LenIV              int         // number of random bytes at the start of charstrings, -1 if charstrings are not encrypted
+
+	private     *cffPrivateDICT
+	glyphNames  []string
+	charStrings [][]byte // decrypted
+	subrs       [][]byte // decrypted
+	names       map[string]uint16
+	unicode     map[rune]uint16
+}
+
+// ParseType1 parses a PostScript Type 1 font in the PFA (ASCII) or PFB (binary) format. The encrypted portion is decrypted, as well as the charstrings and subroutines.
+func ParseType1(b []byte) (*Type1, error) {
+	cleartext, encrypted, err := type1Segments(b)
+	if err != nil {
+		return nil, fmt.Errorf("type1: %w", err)
+	}
+	if 4 <= len(encrypted) && type1IsHex(encrypted[0]) && type1IsHex(encrypted[1]) && type1IsHex(encrypted[2]) && type1IsHex(encrypted[3]) {
+		encrypted = type1DecodeHex(encrypted)
+	}
+	if len(encrypted) < 4 {
+		return nil, fmt.Errorf("type1: %w", ErrInvalidFontData)
+	}
+
+	t1 := &Type1{
+		UnderlinePosition:  -100,
+		UnderlineThickness: 50,
+		FontMatrix:         [6]float64{0.001, 0.0, 0.0, 0.001, 0.0, 0.0},
+		LenIV:              4,
+		private: &cffPrivateDICT{
+			BlueScale:       0.039625,
+			BlueShift:       7.0,
+			BlueFuzz:        1.0,
+			ExpansionFactor: 0.06,
+		},
+	}
+	if err := t1.parseCleartext(cleartext); err != nil {
+		return nil, fmt.Errorf("type1: %w", err)
+	}
+	if err := t1.parsePrivate(type1Decrypt(encrypted, 55665, 4)); err != nil {
+		return nil, fmt.Errorf("type1: %w", err)
+	}
+
+	t1.names = make(map[string]uint16, len(t1.glyphNames))
+	t1.unicode = make(map[rune]uint16, len(t1.glyphNames))
+	for glyphID, name := range t1.glyphNames {
+		t1.names[name] = uint16(glyphID)
+		if r, ok := type1GlyphNameRune(name); ok {
+			if _, ok := t1.unicode[r]; !ok {
+				t1.unicode[r] = uint16(glyphID)
+			}
+		}
+	}
+	return t1, nil
+}
+
+// type1Segments returns the cleartext and the encrypted portion of a PFB or PFA font.
+func type1Segments(b []byte) ([]byte, []byte, error) {
+	if 6 <= len(b) && b[0] == 0x80 {
+		// PFB
+		var cleartext, encrypted []byte
+		for i := 0; i < len(b); {
+			if len(b)-i < 2 || b[i] != 0x80 {
+				return nil, nil, fmt.Errorf("bad PFB segment")
+			} else if b[i+1] == 3 {
+				break // EOF
+			} else if len(b)-i < 6 {
+				return nil, nil, fmt.Errorf("bad PFB segment")
+			}
+			typ := b[i+1]
+			length := binary.LittleEndian.Uint32(b[i+2:])
+			i += 6
+			if uint32(len(b)-i) < length {
+				return nil, nil, fmt.Errorf("bad PFB segment")
+			}
+			segment := b[i : i+int(length)]
+			i += int(length)
+
+			if typ == 1 && encrypted == nil {
+				cleartext = append(cleartext, segment...)
+			} else if typ == 2 {
+				encrypted = append(encrypted, segment...)
+			} else if typ != 1 {
+				return nil, nil, fmt.Errorf("bad PFB segment type %d", typ)
+			}
+			// ASCII segments after the encrypted portion contain the trailing zeros and cleartomark
+		}
+		return cleartext, encrypted, nil
+	}
+
+	// PFA
+	if !bytes.HasPrefix(b, []byte("%!PS-AdobeFont")) && !bytes.HasPrefix(b, []byte("%!FontType1")) {
+		return nil, nil, fmt.Errorf("bad PFA or PFB header")
+	}
+	i := bytes.Index(b, []byte("eexec"))
+	if i == -1 {
+		return nil, nil, fmt.Errorf("eexec not found")
+	}
+	i += 5
+	cleartext := b[:i]
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\r' || b[i] == '\n') {
+		i++
+	}
+	return cleartext, b[i:], nil
+}
+
+func type1IsHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func type1HexValue(c byte) byte {
+	if c <= '9' {
+		return c - '0'
+	} else if c <= 'F' {
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+// type1DecodeHex decodes hexadecimal digits until the first character that is not a hexadecimal digit or whitespace.
+func type1DecodeHex(b []byte) []byte {
+	dst := make([]byte, 0, len(b)/2)
+	odd, v := false, byte(0)
+	for _, c := range b {
+		if type1IsHex(c) {
+			if odd {
+				dst = append(dst, v<<4|type1HexValue(c))
+			} else {
+				v = type1HexValue(c)
+			}
+			odd = !odd
+		} else if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			break
+		}
+	}
+	return dst
+}
+
+// type1Decrypt decrypts eexec (r=55665) and charstring (r=4330) encryption and skips the first n random bytes.
+func type1Decrypt(b []byte, r uint16, n int) []byte {
+	const c1, c2 = 52845, 22719
+	if len(b) < n {
+		return nil
+	}
+	dst := make([]byte, len(b))
+	for i, c := range b {
+		dst[i] = c ^ byte(r>>8)
+		r = (uint16(c)+r)*c1 + c2
+	}
+	return dst[n:]
+}
+
+func type1GlyphNameRune(name string) (rune, bool) {
+	if r, ok := glyphList[name]; ok {
+		return r, true
+	} else if len(name) == 7 && name[:3] == "uni" || 5 <= len(name) && len(name) <= 7 && name[0] == 'u' {
+		i := 1
+		if name[:3] == "uni" {
+			i = 3
+		}
+		if v, err := strconv.ParseUint(name[i:], 16, 32); err == nil && v <= 0x10FFFF {
+			return rune(v), true
+		}
+	}
+	return 0, false
+}
+
+// NumGlyphs returns the number of glyphs the font contains.
+func (t1 *Type1) NumGlyphs() uint16 {
+	return uint16(len(t1.glyphNames))
+}
+
+// UnitsPerEm returns the number of units per em as given by the font matrix.
+func (t1 *Type1) UnitsPerEm() uint16 {
+	if t1.FontMatrix[0] <= 0.0 || 1.0 < t1.FontMatrix[0] {
+		return 1000
+	}
+	return uint16(math.Round(1.0 / t1.FontMatrix[0]))
+}
+
+// GlyphIndex returns the glyphID for a given rune. When the rune is not defined it returns 0.
+func (t1 *Type1) GlyphIndex(r rune) uint16 {
+	return t1.unicode[r]
+}
+
+// CharCodeIndex returns the glyphID for a given character code using the font's built-in encoding. When the code is not encoded it returns 0.
+func (t1 *Type1) CharCodeIndex(code byte) uint16 {
+	if t1.Encoding[code] == "" {
+		return 0
+	}
+	return t1.names[t1.Encoding[code]]
+}
+
+// GlyphName returns the name of the glyph. It returns an empty string when no name exists.
+func (t1 *Type1) GlyphName(glyphID uint16) string {
+	if len(t1.glyphNames) <= int(glyphID) {
+		return ""
+	}
+	return t1.glyphNames[glyphID]
+}
+
+// FindGlyphName returns the glyphID for a given glyph name. When the name is not defined it returns 0.
+func (t1 *Type1) FindGlyphName(name string) uint16 {
+	return t1.names[name]
+}
+
+// GlyphAdvance returns the (horizontal) advance width of the glyph.
+func (t1 *Type1) GlyphAdvance(glyphID uint16) uint16 {
+	if len(t1.charStrings) <= int(glyphID) {
+		return 0
+	}
+	s := &type1Interpreter{
+		t1:      t1,
+		p:       &bboxPather{},
+		metrics: true,
+	}
+	if _, err := s.run(t1.charStrings[glyphID], 0); err != nil || s.wx < 0.0 || math.MaxUint16 < s.wx {
+		return 0
+	}
+	return uint16(math.Round(s.wx))
+}
+
+// GlyphPath draws the glyph's contour as a path to the pather interface. It will use the specified scale (e.g. unitsPerEm/size) and translation to draw the glyph. Hints are ignored.
+func (t1 *Type1) GlyphPath(p Pather, glyphID uint16, x, y, scale float64) error {
+	if len(t1.charStrings) <= int(glyphID) {
+		return fmt.Errorf("type1: bad glyphID %v", glyphID)
+	}
+	s := &type1Interpreter{
+		t1: t1,
+		p:  p,
+		x0: x,
+		y0: y,
+		f:  scale,
+	}
+	if done, err := s.run(t1.charStrings[glyphID], 0); err != nil {
+		return fmt.Errorf("type1: %v", err)
+	} else if !done {
+		return fmt.Errorf("type1: charstring must end with endchar operator")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// type1Lexer splits PostScript code into tokens.
+type type1Lexer struct {
+	b   []byte
+	pos int
+}
+
+func type1IsWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func type1IsDelimiter(c byte) bool {
+	return c == '(' || c == ')' || c == '<' || c == '>' || c == '[' || c == ']' || c == '{' || c == '}' || c == '/' || c == '%'
+}
+
+// next returns the next token, which is a literal name including the slash, a string including its delimiters, a delimiter, or a number or executable name. It returns nil at the end.
+func (l *type1Lexer) next() []byte {
+	for l.pos < len(l.b) {
+		if type1IsWhitespace(l.b[l.pos]) {
+			l.pos++
+		} else if l.b[l.pos] == '%' {
+			for l.pos < len(l.b) && l.b[l.pos] != '\r' && l.b[l.pos] != '\n' {
+				l.pos++
+			}
+		} else {
+			break
+		}
+	}
+	if len(l.b) <= l.pos {
+		return nil
+	}
+
+	start := l.pos
+	switch c := l.b[l.pos]; c {
+	case '(':
+		level := 0
+		for l.pos < len(l.b) {
+			if l.b[l.pos] == '\\' {
+				l.pos++
+			} else if l.b[l.pos] == '(' {
+				level++
+			} else if l.b[l.pos] == ')' {
+				level--
+				if level == 0 {
+					l.pos++
+					break
+				}
+			}
+			l.pos++
+		}
+	case '<':
+		l.pos++
+		if l.pos < len(l.b) && l.b[l.pos] == '<' {
+			l.pos++
+		} else {
+			for l.pos < len(l.b) && l.b[l.pos] != '>' {
+				l.pos++
+			}
+			if l.pos < len(l.b) {
+				l.pos++
+			}
+		}
+	case '>':
+		l.pos++
+		if l.pos < len(l.b) && l.b[l.pos] == '>' {
+			l.pos++
+		}
+	case '[', ']', '{', '}', ')':
+		l.pos++
+	default:
+		if c == '/' {
+			l.pos++
+		}
+		for l.pos < len(l.b) && !type1IsWhitespace(l.b[l.pos]) && !type1IsDelimiter(l.b[l.pos]) {
+			l.pos++
+		}
+	}
+	if len(l.b) < l.pos {
+		l.pos = len(l.b) // escape at the end
+	}
+	return l.b[start:l.pos:l.pos]
+}
+
+// binary returns the n bytes following the token that started the binary data, such as RD or -|.
+func (l *type1Lexer) binary(n int) ([]byte, error) {
+	if n < 0 || len(l.b)-l.pos-1 < n {
+		return nil, ErrInvalidFontData
+	}
+	l.pos++ // single whitespace character
+	b := l.b[l.pos : l.pos+n : l.pos+n]
+	l.pos += n
+	return b, nil
+}
+
+func (l *type1Lexer) number() (float64, bool) {
+	return type1Number(l.next())
+}
+
+func (l *type1Lexer) integer() (int, bool) {
+	f, ok := l.number()
+	if !ok || f != math.Trunc(f) || f < math.MinInt32 || math.MaxInt32 < f {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func (l *type1Lexer) boolean() bool {
+	return string(l.next()) == "true"
+}
+
+// array parses an array or procedure of numbers.
+func (l *type1Lexer) array() ([]float64, error) {
+	if tok := l.next(); len(tok) != 1 || tok[0] != '[' && tok[0] != '{' {
+		return nil, fmt.Errorf("expected array")
+	}
+	fs := []float64{}
+	for {
+		tok := l.next()
+		if tok == nil {
+			return nil, fmt.Errorf("expected array")
+		} else if len(tok) == 1 && (tok[0] == ']' || tok[0] == '}') {
+			return fs, nil
+		}
+		f, ok := type1Number(tok)
+		if !ok {
+			return nil, fmt.Errorf("expected number in array")
+		}
+		fs = append(fs, f)
+	}
+}
+
+// string parses a literal string, a hexadecimal string, or a literal name.
+func (l *type1Lexer) string() string {
+	tok := l.next()
+	if 2 <= len(tok) && tok[0] == '(' && tok[len(tok)-1] == ')' {
+		tok = tok[1 : len(tok)-1]
+		s := make([]byte, 0, len(tok))
+		for i := 0; i < len(tok); i++ {
+			if tok[i] != '\\' || i+1 == len(tok) {
+				s = append(s, tok[i])
+				continue
+			}
+			i++
+			switch c := tok[i]; c {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r', '\n':
+				if c == '\r' && i+1 < len(tok) && tok[i+1] == '\n' {
+					i++
+				}
+			default:
+				if '0' <= c && c <= '7' {
+					v := 0
+					for j := 0; j < 3 && i < len(tok) && '0' <= tok[i] && tok[i] <= '7'; j++ {
+						v = v*8 + int(tok[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(v))
+				} else {
+					s = append(s, c)
+				}
+			}
+		}
+		return string(s)
+	} else if 2 <= len(tok) && tok[0] == '<' && tok[len(tok)-1] == '>' {
+		return string(type1DecodeHex(tok[1 : len(tok)-1]))
+	} else if 1 <= len(tok) && tok[0] == '/' {
+		return string(tok[1:])
+	}
+	return ""
+}
+
+func type1Number(b []byte) (float64, bool) {
+	if len(b) == 0 {
+		return 0.0, false
+	} else if i := bytes.IndexByte(b, '#'); i != -1 {
+		// radix number
+		base, err := strconv.Atoi(string(b[:i]))
+		if err != nil || base < 2 || 36 < base {
+			return 0.0, false
+		}
+		v, err := strconv.ParseUint(string(b[i+1:]), base, 32)
+		if err != nil {
+			return 0.0, false
+		}
+		return float64(int32(uint32(v))), true
+	} else if c := b[0]; c != '+' && c != '-' && c != '.' && (c < '0' || '9' < c) {
+		return 0.0, false
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0.0, false
+	}
+	return f, true
+}
+
+func (t1 *Type1) parseCleartext(b []byte) error {
+	l := &type1Lexer{b: b}
+	for {
+		tok := l.next()
+		if tok == nil {
+			return nil
+		} else if tok[0] != '/' {
+			continue
+		}
+
+		var ok bool
+		switch string(tok[1:]) {
+		case "FontName":
+			t1.FontName = l.string()
+		case "FullName":
+			t1.FullName = l.string()
+		case "FamilyName":
+			t1.FamilyName = l.string()
+		case "Weight":
+			t1.Weight = l.string()
+		case "version":
+			t1.Version = l.string()
+		case "Notice":
+			t1.Notice = l.string()
+		case "ItalicAngle":
+			if t1.ItalicAngle, ok = l.number(); !ok {
+				return fmt.Errorf("bad ItalicAngle")
+			}
+		case "isFixedPitch":
+			t1.IsFixedPitch = l.boolean()
+		case "UnderlinePosition":
+			if t1.UnderlinePosition, ok = l.number(); !ok {
+				return fmt.Errorf("bad UnderlinePosition")
+			}
+		case "UnderlineThickness":
+			if t1.UnderlineThickness, ok = l.number(); !ok {
+				return fmt.Errorf("bad UnderlineThickness")
+			}
+		case "PaintType":
+			if t1.PaintType, ok = l.integer(); !ok {
+				return fmt.Errorf("bad PaintType")
+			}
+		case "FontType":
+			if fontType, ok := l.integer(); ok && fontType != 1 { // also appears in code such as /FontType get
+				return fmt.Errorf("unsupported FontType")
+			}
+		case "FontMatrix":
+			if fs, err := l.array(); err != nil || len(fs) != 6 {
+				return fmt.Errorf("bad FontMatrix")
+			} else {
+				copy(t1.FontMatrix[:], fs)
+			}
+		case "FontBBox":
+			if fs, err := l.array(); err != nil || len(fs) != 4 {
+				return fmt.Errorf("bad FontBBox")
+			} else {
+				copy(t1.FontBBox[:], fs)
+			}
+		case "Encoding":
+			if err := t1.parseEncoding(l); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t1 *Type1) parseEncoding(l *type1Lexer) error {
+	tok := l.next()
+	if string(tok) == "StandardEncoding" {
+		t1.Encoding = type1StandardEncoding
+		return nil
+	} else if _, ok := type1Number(tok); !ok {
+		return fmt.Errorf("bad Encoding")
+	}
+
+	// for example: 256 array 0 1 255 {1 index exch /.notdef put} for dup 32 /space put ... readonly def
+	for {
+		tok = l.next()
+		if tok == nil || string(tok) == "def" {
+			return nil
+		} else if string(tok) != "dup" {
+			continue
+		}
+
+		code, ok := l.integer()
+		if !ok {
+			continue // dup in the initialization procedure
+		}
+		name := l.next()
+		if code < 0 || 255 < code || len(name) < 2 || name[0] != '/' || string(l.next()) != "put" {
+			return fmt.Errorf("bad Encoding")
+		} else if string(name[1:]) != ".notdef" {
+			t1.Encoding[code] = string(name[1:])
+		}
+	}
+}
+
+func (t1 *Type1) parsePrivate(b []byte) error {
+	l := &type1Lexer{b: b}
+	var subrs [][]byte
+	var charStrings [][]byte
+	for {
+		tok := l.next()
+		if tok == nil {
+			break
+		} else if tok[0] != '/' {
+			continue
+		}
+
+		var ok bool
+		var err error
+		key := string(tok[1:])
+		switch key {
+		case "lenIV":
+			t1.LenIV, ok = l.integer()
+		case "BlueValues":
+			t1.private.BlueValues, err = l.array()
+		case "OtherBlues":
+			t1.private.OtherBlues, err = l.array()
+		case "FamilyBlues":
+			t1.private.FamilyBlues, err = l.array()
+		case "FamilyOtherBlues":
+			t1.private.FamilyOtherBlues, err = l.array()
+		case "StemSnapH":
+			t1.private.StemSnapH, err = l.array()
+		case "StemSnapV":
+			t1.private.StemSnapV, err = l.array()
+		case "StdHW", "StdVW":
+			var fs []float64
+			if fs, err = l.array(); err == nil && len(fs) != 1 {
+				err = fmt.Errorf("expected one number")
+			} else if err == nil && key == "StdHW" {
+				t1.private.StdHW = fs[0]
+			} else if err == nil {
+				t1.private.StdVW = fs[0]
+			}
+		case "BlueScale":
+			t1.private.BlueScale, ok = l.number()
+		case "BlueShift":
+			t1.private.BlueShift, ok = l.number()
+		case "BlueFuzz":
+			t1.private.BlueFuzz, ok = l.number()
+		case "ExpansionFactor":
+			t1.private.ExpansionFactor, ok = l.number()
+		case "LanguageGroup":
+			t1.private.LanguageGroup, ok = l.integer()
+		case "ForceBold":
+			t1.private.ForceBold = l.boolean()
+		case "Subrs":
+			subrs, err = t1.parseSubrs(l)
+		case "CharStrings":
+			charStrings, err = t1.parseCharStrings(l)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		} else if !ok && (key == "lenIV" || key == "BlueScale" || key == "BlueShift" || key == "BlueFuzz" || key == "ExpansionFactor" || key == "LanguageGroup") {
+			return fmt.Errorf("bad %v", key)
+		}
+	}
+	if charStrings == nil {
+		return fmt.Errorf("CharStrings not found")
+	}
+
+	if 0 <= t1.LenIV {
+		for i := range subrs {
+			subrs[i] = type1Decrypt(subrs[i], 4330, t1.LenIV)
+		}
+		for i := range charStrings {
+			charStrings[i] = type1Decrypt(charStrings[i], 4330, t1.LenIV)
+		}
+	}
+	t1.subrs = subrs
+	t1.charStrings = charStrings
+
+	// .notdef must be the first glyph
+	for i, name := range t1.glyphNames {
+		if name == ".notdef" {
+			if i != 0 {
+				charString := t1.charStrings[i]
+				copy(t1.glyphNames[1:i+1], t1.glyphNames[:i])
+				copy(t1.charStrings[1:i+1], t1.charStrings[:i])
+				t1.glyphNames[0] = ".notdef"
+				t1.charStrings[0] = charString
+			}
+			return nil
+		}
+	}
+	t1.glyphNames = append([]string{".notdef"}, t1.glyphNames...)
+	t1.charStrings = append([][]byte{{139, 139, 13, 14}}, t1.charStrings...) // 0 0 hsbw endchar
+	return nil
+}
+
+// parseSubrs parses for example: 5 array dup 0 15 RD <binary> NP ... ND
+func (t1 *Type1) parseSubrs(l *type1Lexer) ([][]byte, error) {
+	n, ok := l.integer()
+	if !ok || n < 0 || math.MaxUint16 < n || string(l.next()) != "array" {
+		return nil, ErrInvalidFontData
+	}
+	subrs := make([][]byte, n)
+	for {
+		pos := l.pos
+		if string(l.next()) != "dup" {
+			l.pos = pos
+			return subrs, nil
+		}
+		index, ok := l.integer()
+		if !ok || index < 0 || n <= index {
+			return nil, fmt.Errorf("bad index")
+		}
+		length, ok := l.integer()
+		if !ok {
+			return nil, ErrInvalidFontData
+		}
+		_ = l.next() // RD or -|
+		subr, err := l.binary(length)
+		if err != nil {
+			return nil, err
+		}
+		subrs[index] = subr
+		if np := l.next(); string(np) == "noaccess" {
+			_ = l.next() // put
+		}
+	}
+}
+
+// parseCharStrings parses for example: 190 dict dup begin /.notdef 9 RD <binary> ND ... end
+func (t1 *Type1) parseCharStrings(l *type1Lexer) ([][]byte, error) {
+	n, ok := l.integer()
+	if !ok || n < 0 || string(l.next()) != "dict" {
+		return nil, ErrInvalidFontData
+	}
+	charStrings := [][]byte{}
+	t1.glyphNames = []string{}
+	for {
+		tok := l.next()
+		if tok == nil {
+			return nil, ErrInvalidFontData
+		} else if string(tok) == "end" {
+			break
+		} else if tok[0] != '/' || len(tok) == 1 {
+			continue // dup begin
+		}
+
+		length, ok := l.integer()
+		if !ok {
+			return nil, ErrInvalidFontData
+		}
+		_ = l.next() // RD or -|
+		charString, err := l.binary(length)
+		if err != nil {
+			return nil, err
+		}
+		if nd := l.next(); string(nd) == "noaccess" {
+			_ = l.next() // def
+		}
+		if math.MaxUint16 <= len(charStrings) {
+			return nil, fmt.Errorf("too many glyphs")
+		}
+		t1.glyphNames = append(t1.glyphNames, string(tok[1:]))
+		charStrings = append(charStrings, charString)
+	}
+	return charStrings, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+const (
+	type1Hstem           = 1
+	type1Vstem           = 3
+	type1Vmoveto         = 4
+	type1Rlineto         = 5
+	type1Hlineto         = 6
+	type1Vlineto         = 7
+	type1Rrcurveto       = 8
+	type1Closepath       = 9
+	type1Callsubr        = 10
+	type1Return          = 11
+	type1Escape          = 12
+	type1Hsbw            = 13
+	type1Endchar         = 14
+	type1Rmoveto         = 21
+	type1Hmoveto         = 22
+	type1Vhcurveto       = 30
+	type1Hvcurveto       = 31
+	type1Dotsection      = 256 + 0
+	type1Vstem3          = 256 + 1
+	type1Hstem3          = 256 + 2
+	type1Seac            = 256 + 6
+	type1Sbw             = 256 + 7
+	type1Div             = 256 + 12
+	type1Callothersubr   = 256 + 16
+	type1Pop             = 256 + 17
+	type1Setcurrentpoint = 256 + 33
+)
+
+// type1HintSet is a set of stem hints that is active from a path operation onwards, until it is replaced.
+type type1HintSet struct {
+	op             int          // index of the first path operation for which the hints are active
+	hstems, vstems [][2]float64 // absolute position and width of the stem hints
+}
+
+// type1Interpreter runs Type 1 charstrings.
+type type1Interpreter struct {
+	t1        *Type1
+	p         Pather
+	x0, y0, f float64
+	metrics   bool // stop after the side bearing and advance width are known
+
+	x, y        float64 // current point
+	dx, dy      float64 // origin of a seac component
+	sbx, wx     float64 // side bearing and advance width
+	isComponent bool
+
+	stack   []float64
+	psStack []float64 // PostScript operand stack used by callothersubr and pop
+	isFlex  bool
+	flex    []float64
+
+	hints    bool           // collect stem hints
+	sby      float64        // vertical side bearing
+	hintSets []type1HintSet // stem hints, a new set is started by hint replacement
+	ops      int            // number of path operations
+}
+
+func (s *type1Interpreter) px(x float64) float64 {
+	return s.x0 + s.f*x
+}
+
+func (s *type1Interpreter) py(y float64) float64 {
+	return s.y0 + s.f*y
+}
+
+func (s *type1Interpreter) moveTo(dx, dy float64) {
+	s.x += dx
+	s.y += dy
+	if s.isFlex {
+		s.flex = append(s.flex, s.x, s.y)
+		return
+	}
+	s.p.Close()
+	s.p.MoveTo(s.px(s.x), s.py(s.y))
+	s.ops++
+}
+
+func (s *type1Interpreter) lineTo(dx, dy float64) {
+	s.x += dx
+	s.y += dy
+	s.p.LineTo(s.px(s.x), s.py(s.y))
+	s.ops++
+}
+
+func (s *type1Interpreter) cubeTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	cpx1, cpy1 := s.x+dx1, s.y+dy1
+	cpx2, cpy2 := cpx1+dx2, cpy1+dy2
+	s.x, s.y = cpx2+dx3, cpy2+dy3
+	s.p.CubeTo(s.px(cpx1), s.py(cpy1), s.px(cpx2), s.py(cpy2), s.px(s.x), s.py(s.y))
+	s.ops++
+}
+
+// run interprets a charstring or subroutine and returns true when endchar or seac is encountered.
+func (s *type1Interpreter) run(b []byte, depth int) (bool, error) {
+	if 10 < depth {
+		return false, fmt.Errorf("too many nested subroutines")
+	}
+	for i := 0; i < len(b); {
+		b0 := int(b[i])
+		i++
+		if 32 <= b0 {
+			var v float64
+			if b0 <= 246 {
+				v = float64(b0 - 139)
+			} else if b0 <= 254 {
+				if len(b) <= i {
+					return false, ErrInvalidFontData
+				}
+				if b0 <= 250 {
+					v = float64((b0-247)*256 + int(b[i]) + 108)
+				} else {
+					v = float64(-(b0-251)*256 - int(b[i]) - 108)
+				}
+				i++
+			} else {
+				if len(b)-i < 4 {
+					return false, ErrInvalidFontData
+				}
+				v = float64(int32(binary.BigEndian.Uint32(b[i:])))
+				i += 4
+			}
+			if 48 <= len(s.stack) {
+				return false, fmt.Errorf("too many operands for operator")
+			}
+			s.stack = append(s.stack, v)
+			continue
+		} else if b0 == type1Escape {
+			if len(b) <= i {
+				return false, ErrInvalidFontData
+			}
+			b0 = 256 + int(b[i])
+			i++
+		}
+
+		stack := s.stack
+		switch b0 {
+		case type1Hsbw, type1Sbw:
+			if b0 == type1Hsbw && len(stack) != 2 || b0 == type1Sbw && len(stack) != 4 {
+				return false, ErrBadNumOperands
+			}
+			s.x, s.y = s.dx+stack[0], s.dy
+			if b0 == type1Sbw {
+				s.y += stack[1]
+			}
+			if !s.isComponent {
+				s.sbx, s.wx = stack[0], stack[len(stack)/2]
+				if b0 == type1Sbw {
+					s.sby = stack[1]
+				}
+				if s.metrics {
+					return true, nil
+				}
+			}
+		case type1Closepath:
+			s.p.Close()
+		case type1Rmoveto:
+			if len(stack) != 2 {
+				return false, ErrBadNumOperands
+			}
+			s.moveTo(stack[0], stack[1])
+		case type1Hmoveto:
+			if len(stack) != 1 {
+				return false, ErrBadNumOperands
+			}
+			s.moveTo(stack[0], 0.0)
+		case type1Vmoveto:
+			if len(stack) != 1 {
+				return false, ErrBadNumOperands
+			}
+			s.moveTo(0.0, stack[0])
+		case type1Rlineto:
+			if len(stack) != 2 {
+				return false, ErrBadNumOperands
+			}
+			s.lineTo(stack[0], stack[1])
+		case type1Hlineto:
+			if len(stack) != 1 {
+				return false, ErrBadNumOperands
+			}
+			s.lineTo(stack[0], 0.0)
+		case type1Vlineto:
+			if len(stack) != 1 {
+				return false, ErrBadNumOperands
+			}
+			s.lineTo(0.0, stack[0])
+		case type1Rrcurveto:
+			if len(stack) != 6 {
+				return false, ErrBadNumOperands
+			}
+			s.cubeTo(stack[0], stack[1], stack[2], stack[3], stack[4], stack[5])
+		case type1Vhcurveto:
+			if len(stack) != 4 {
+				return false, ErrBadNumOperands
+			}
+			s.cubeTo(0.0, stack[0], stack[1], stack[2], stack[3], 0.0)
+		case type1Hvcurveto:
+			if len(stack) != 4 {
+				return false, ErrBadNumOperands
+			}
+			s.cubeTo(stack[0], 0.0, stack[1], stack[2], 0.0, stack[3])
+		case type1Hstem, type1Vstem, type1Hstem3, type1Vstem3:
+			// hints are only collected for the glyph itself and not for seac components
+			if s.hints && !s.isComponent && len(stack)%2 == 0 {
+				if len(s.hintSets) == 0 {
+					s.hintSets = append(s.hintSets, type1HintSet{op: s.ops})
+				}
+				hintSet := &s.hintSets[len(s.hintSets)-1]
+				for j := 0; j+1 < len(stack); j += 2 {
+					if b0 == type1Hstem || b0 == type1Hstem3 {
+						hintSet.hstems = append(hintSet.hstems, [2]float64{s.sby + stack[j], stack[j+1]})
+					} else {
+						hintSet.vstems = append(hintSet.vstems, [2]float64{s.sbx + stack[j], stack[j+1]})
+					}
+				}
+			}
+		case type1Dotsection:
+			// dot sections are ignored, Type 2 charstrings have no equivalent
+		case type1Endchar:
+			s.p.Close()
+			s.stack = s.stack[:0]
+			return true, nil
+		case type1Seac:
+			if len(stack) != 5 {
+				return false, ErrBadNumOperands
+			} else if s.isComponent {
+				return false, fmt.Errorf("seac: nested accented character")
+			}
+			asb, adx, ady := stack[0], stack[1], stack[2]
+			s.hintSets = nil // hints of the components cannot be combined
+			base, err := s.seacGlyph(stack[3])
+			if err != nil {
+				return false, err
+			}
+			accent, err := s.seacGlyph(stack[4])
+			if err != nil {
+				return false, err
+			}
+
+			// base character at the origin, accent character offset by the side bearing of the composite
+			s.isComponent = true
+			for _, component := range []struct {
+				glyphID uint16
+				dx, dy  float64
+			}{{base, 0.0, 0.0}, {accent, s.sbx + adx - asb, ady}} {
+				s.dx, s.dy = component.dx, component.dy
+				s.stack, s.psStack = s.stack[:0], s.psStack[:0]
+				if done, err := s.run(s.t1.charStrings[component.glyphID], depth+1); err != nil {
+					return false, fmt.Errorf("seac: %v", err)
+				} else if !done {
+					return false, fmt.Errorf("seac: charstring must end with endchar operator")
+				}
+			}
+			return true, nil
+		case type1Div:
+			if len(stack) < 2 {
+				return false, ErrBadNumOperands
+			} else if stack[len(stack)-1] == 0.0 {
+				return false, fmt.Errorf("div: division by zero")
+			}
+			s.stack = append(stack[:len(stack)-2], stack[len(stack)-2]/stack[len(stack)-1])
+			continue
+		case type1Callsubr:
+			if len(stack) < 1 {
+				return false, ErrBadNumOperands
+			}
+			index := int(stack[len(stack)-1])
+			if index < 0 || len(s.t1.subrs) <= index || s.t1.subrs[index] == nil {
+				return false, fmt.Errorf("subroutine: %v doesn't exist", index)
+			}
+			s.stack = stack[:len(stack)-1]
+			if done, err := s.run(s.t1.subrs[index], depth+1); err != nil || done {
+				return done, err
+			}
+			continue
+		case type1Return:
+			return false, nil
+		case type1Callothersubr:
+			if len(stack) < 2 {
+				return false, ErrBadNumOperands
+			}
+			othersubr, n := int(stack[len(stack)-1]), int(stack[len(stack)-2])
+			if n < 0 || len(stack)-2 < n {
+				return false, ErrBadNumOperands
+			}
+			args := stack[len(stack)-2-n : len(stack)-2]
+			s.stack = stack[:len(stack)-2-n]
+			s.psStack = append(s.psStack[:0], args...) // unknown othersubrs return their arguments
+			switch othersubr {
+			case 0:
+				// end flex, draw two curves through the seven collected points of which the first is the reference point
+				if !s.isFlex || len(s.flex) != 14 || n != 3 {
+					return false, fmt.Errorf("callothersubr: bad flex")
+				}
+				s.isFlex = false
+				p := s.flex[2:]
+				s.p.CubeTo(s.px(p[0]), s.py(p[1]), s.px(p[2]), s.py(p[3]), s.px(p[4]), s.py(p[5]))
+				s.p.CubeTo(s.px(p[6]), s.py(p[7]), s.px(p[8]), s.py(p[9]), s.px(p[10]), s.py(p[11]))
+				s.ops += 2
+				s.x, s.y = p[10], p[11]
+				s.psStack = append(s.psStack[:0], s.y-s.dy, s.x-s.dx) // popped by pop pop setcurrentpoint
+			case 1:
+				// start flex
+				s.isFlex = true
+				s.flex = s.flex[:0]
+			case 2:
+				// add flex point, points are collected by rmoveto
+				if !s.isFlex {
+					return false, fmt.Errorf("callothersubr: bad flex")
+				}
+			case 3:
+				// hint replacement, return the subroutine number that sets the new hints
+				if n != 1 {
+					return false, ErrBadNumOperands
+				} else if s.hints && !s.isComponent {
+					if k := len(s.hintSets); k != 0 && s.hintSets[k-1].op == s.ops {
+						s.hintSets[k-1] = type1HintSet{op: s.ops} // replaced before any path operation
+					} else {
+						s.hintSets = append(s.hintSets, type1HintSet{op: s.ops})
+					}
+				}
+			}
+			continue
+		case type1Pop:
+			if len(s.psStack) == 0 {
+				return false, fmt.Errorf("pop: empty stack")
+			}
+			s.stack = append(stack, s.psStack[len(s.psStack)-1])
+			s.psStack = s.psStack[:len(s.psStack)-1]
+			continue
+		case type1Setcurrentpoint:
+			if len(stack) != 2 {
+				return false, ErrBadNumOperands
+			}
+			s.x, s.y = s.dx+stack[0], s.dy+stack[1]
+		default:
+			if 256 <= b0 {
+				return false, fmt.Errorf("unsupported operator 12 %d", b0-256)
+			}
+			return false, fmt.Errorf("unsupported operator %d", b0)
+		}
+		s.stack = s.stack[:0]
+	}
+	return false, nil
+}
+
+// seacGlyph returns the glyphID for a character code in the standard encoding as used by seac.
+func (s *type1Interpreter) seacGlyph(code float64) (uint16, error) {
+	if code < 0.0 || 255.0 < code || type1StandardEncoding[int(code)] == "" {
+		return 0, fmt.Errorf("seac: bad character code %v", code)
+	}
+	glyphID, ok := s.t1.names[type1StandardEncoding[int(code)]]
+	if !ok {
+		return 0, fmt.Errorf("seac: glyph %v doesn't exist", type1StandardEncoding[int(code)])
+	}
+	return glyphID, nil
+}
+
+var type1StandardEncoding = [256]string{
+	32: "space", "exclam", "quotedbl", "numbersign", "dollar", "percent", "ampersand", "quoteright",
+	40: "parenleft", "parenright", "asterisk", "plus", "comma", "hyphen", "period", "slash",
+	48: "zero", "one", "two", "three", "four", "five", "six", "seven",
+	56: "eight", "nine", "colon", "semicolon", "less", "equal", "greater", "question",
+	64: "at", "A", "B", "C", "D", "E", "F", "G",
+	72: "H", "I", "J", "K", "L", "M", "N", "O",
+	80: "P", "Q", "R", "S", "T", "U", "V", "W",
+	88: "X", "Y", "Z", "bracketleft", "backslash", "bracketright", "asciicircum", "underscore",
+	96: "quoteleft", "a", "b", "c", "d", "e", "f", "g",
+	104: "h", "i", "j", "k", "l", "m", "n", "o",
+	112: "p", "q", "r", "s", "t", "u", "v", "w",
+	120: "x", "y", "z", "braceleft", "bar", "braceright", "asciitilde",
+	161: "exclamdown", "cent", "sterling", "fraction", "yen", "florin", "section",
+	168: "currency", "quotesingle", "quotedblleft", "guillemotleft", "guilsinglleft", "guilsinglright", "fi", "fl",
+	177: "endash", "dagger", "daggerdbl", "periodcentered",
+	182: "paragraph", "bullet", "quotesinglbase", "quotedblbase", "quotedblright", "guillemotright", "ellipsis", "perthousand",
+	191: "questiondown",
+	193: "grave", "acute", "circumflex", "tilde", "macron", "breve", "dotaccent",
+	200: "dieresis",
+	202: "ring", "cedilla",
+	205: "hungarumlaut", "ogonek", "caron", "emdash",
+	225: "AE",
+	227: "ordfeminine",
+	232: "Lslash", "Oslash", "OE", "ordmasculine",
+	241: "ae",
+	245: "dotlessi",
+	248: "lslash", "oslash", "oe", "germandbls",
+}
@@ -0,0 +1,443 @@
+package font
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToSFNT converts the Type 1 font to an OpenType font with CFF outlines. Charstrings are converted to Type 2 charstrings where subroutines, flex, and accented characters (seac) are flattened. Stem hints are kept and hint replacement is converted to hint masks, but stems that overlap other stems of the same hint set are dropped. The Private DICT and FontMatrix are copied. The cmap table maps the glyph names to Unicode using the Adobe Glyph List, and characters of the built-in encoding without a Unicode mapping are mapped to U+F000 plus the character code. The AFM file of the font is optional and provides the vertical metrics and kerning pairs.
+func (t1 *Type1) ToSFNT(afm *AFM) (*SFNT, error) {
+	numGlyphs := t1.NumGlyphs()
+	unitsPerEm := t1.UnitsPerEm()
+	if numGlyphs == 0 {
+		return nil, fmt.Errorf("type1: no glyphs")
+	}
+
+	fontName, fullName, familyName, weight := t1.FontName, t1.FullName, t1.FamilyName, t1.Weight
+	if afm != nil {
+		if fontName == "" {
+			fontName = afm.FontName
+		}
+		if fullName == "" {
+			fullName = afm.FullName
+		}
+		if familyName == "" {
+			familyName = afm.FamilyName
+		}
+		if weight == "" {
+			weight = afm.Weight
+		}
+	}
+	if fontName == "" {
+		return nil, fmt.Errorf("type1: missing FontName")
+	} else if fullName == "" {
+		fullName = fontName
+	}
+	if familyName == "" {
+		familyName = fullName
+	}
+
+	// obtain advance widths and stem hints
+	advances := make([]uint16, numGlyphs)
+	hstems := make([][][2]int32, numGlyphs)
+	vstems := make([][][2]int32, numGlyphs)
+	hintMasks := make([][]cffHintMask, numGlyphs)
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		s := &type1Interpreter{
+			t1:    t1,
+			p:     &bboxPather{},
+			f:     1.0,
+			hints: true,
+		}
+		if done, err := s.run(t1.charStrings[glyphID], 0); err != nil {
+			return nil, fmt.Errorf("type1: %v: %v", t1.glyphNames[glyphID], err)
+		} else if !done {
+			return nil, fmt.Errorf("type1: %v: charstring must end with endchar operator", t1.glyphNames[glyphID])
+		}
+		advances[glyphID] = uint16(max(0.0, min(math.MaxUint16, math.Round(s.wx))))
+		hstems[glyphID], vstems[glyphID], hintMasks[glyphID] = type1Hints(s.hintSets)
+	}
+
+	// the most common advance width is the default width and is omitted from the charstrings
+	defaultWidth := cffDefaultWidth(advances)
+
+	// convert charstrings
+	charStrings := &cffINDEX{}
+	lsbs := make([]int16, numGlyphs)
+	yMaxs := make([]int16, numGlyphs)
+	hasOutline := make([]bool, numGlyphs)
+	xMin, yMin, xMax, yMax := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16), int16(math.MinInt16)
+	var minRsb, xMaxExtent int16 = math.MaxInt16, math.MinInt16
+	for glyphID := uint16(0); glyphID < numGlyphs; glyphID++ {
+		w := newCFFCharStringWriter(int32(advances[glyphID]) - int32(defaultWidth))
+		w.hasWidth = advances[glyphID] != defaultWidth
+		w.WriteStems(hstems[glyphID], vstems[glyphID], hintMasks[glyphID])
+		s := &type1Interpreter{
+			t1: t1,
+			p:  w,
+			f:  1.0,
+		}
+		if _, err := s.run(t1.charStrings[glyphID], 0); err != nil {
+			return nil, fmt.Errorf("type1: %v: %v", t1.glyphNames[glyphID], err)
+		}
+		charStrings.Add(w.Bytes())
+
+		if w.XMin <= w.XMax {
+			gxMin, gyMin, gxMax, gyMax := int16(w.XMin), int16(w.YMin), int16(w.XMax), int16(w.YMax)
+			hasOutline[glyphID] = true
+			lsbs[glyphID] = gxMin
+			yMaxs[glyphID] = gyMax
+			xMin, yMin = min(xMin, gxMin), min(yMin, gyMin)
+			xMax, yMax = max(xMax, gxMax), max(yMax, gyMax)
+			minRsb = min(minRsb, int16(int32(advances[glyphID])-w.XMax))
+			xMaxExtent = max(xMaxExtent, gxMax)
+		}
+	}
+	if xMax < xMin {
+		xMin, yMin, xMax, yMax = 0, 0, 0, 0
+		minRsb, xMaxExtent = 0, 0
+	}
+
+	// vertical metrics, AFM values are in units of 1/1000 em
+	afmScale := float64(unitsPerEm) / 1000.0
+	afmValue := func(v int16) int16 {
+		return int16(math.Round(float64(v) * afmScale))
+	}
+	ascender, descender := int16(math.Round(t1.FontBBox[3])), int16(math.Round(t1.FontBBox[1]))
+	var xHeight, capHeight int16
+	if glyphID := t1.GlyphIndex('x'); glyphID != 0 && hasOutline[glyphID] {
+		xHeight = yMaxs[glyphID]
+	}
+	if glyphID := t1.GlyphIndex('H'); glyphID != 0 && hasOutline[glyphID] {
+		capHeight = yMaxs[glyphID]
+	}
+	underlinePosition, underlineThickness := t1.UnderlinePosition, t1.UnderlineThickness
+	if afm != nil {
+		if afm.Ascender != 0 || afm.Descender != 0 {
+			ascender, descender = afmValue(int16(afm.Ascender)), afmValue(int16(afm.Descender))
+		}
+		if afm.XHeight != 0 {
+			xHeight = afmValue(int16(afm.XHeight))
+		}
+		if afm.CapHeight != 0 {
+			capHeight = afmValue(int16(afm.CapHeight))
+		}
+		if afm.UnderlineThickness != 0 {
+			underlinePosition = float64(afmValue(int16(afm.UnderlinePosition)))
+			underlineThickness = float64(afmValue(int16(afm.UnderlineThickness)))
+		}
+	}
+
+	weightClass := os2WeightClass(weight)
+	isBold := 700 <= weightClass
+	isItalic := t1.ItalicAngle != 0.0
+	subfamily := "Regular"
+	if isBold && isItalic {
+		subfamily = "Bold Italic"
+	} else if isBold {
+		subfamily = "Bold"
+	} else if isItalic {
+		subfamily = "Italic"
+	}
+
+	revision := 1.0
+	if f, err := strconv.ParseFloat(strings.TrimSpace(t1.Version), 64); err == nil && 0.0 <= f && f < 32768.0 {
+		revision = f
+	}
+	version := fmt.Sprintf("%.3f", revision)
+
+	// CFF
+	private := *t1.private
+	private.Subrs = 0
+	private.DefaultWidthX = float64(defaultWidth)
+	private.NominalWidthX = float64(defaultWidth)
+	cff := &cffTable{
+		version: 1,
+		name:    fontName,
+		top: &cffTopDICT{
+			Version:            t1.Version,
+			Notice:             t1.Notice,
+			FullName:           fullName,
+			FamilyName:         familyName,
+			Weight:             weight,
+			IsFixedPitch:       t1.IsFixedPitch,
+			ItalicAngle:        t1.ItalicAngle,
+			UnderlinePosition:  t1.UnderlinePosition,
+			UnderlineThickness: t1.UnderlineThickness,
+			PaintType:          t1.PaintType,
+			CharstringType:     2,
+			FontMatrix:         t1.FontMatrix,
+			FontBBox:           t1.FontBBox,
+		},
+		globalSubrs: &cffINDEX{},
+		charset:     t1.glyphNames,
+		charStrings: charStrings,
+		fonts: &cffFontINDEX{
+			private: []*cffPrivateDICT{&private},
+		},
+	}
+	cffData, err := cff.Write()
+	if err != nil {
+		return nil, fmt.Errorf("type1: CFF: %w", err)
+	}
+
+	// cmap
+	runeMap := make(map[rune]uint16, len(t1.unicode))
+	hasRune := make([]bool, numGlyphs)
+	for r, glyphID := range t1.unicode {
+		runeMap[r] = glyphID
+		hasRune[glyphID] = true
+	}
+	for code, name := range t1.Encoding {
+		if glyphID, ok := t1.names[name]; ok && glyphID != 0 && !hasRune[glyphID] {
+			runeMap[0xF000+rune(code)] = glyphID
+		}
+	}
+	rs := make([]rune, 0, len(runeMap))
+	for r := range runeMap {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+
+	// kern
+	var kernPairs []kernPair
+	if afm != nil {
+		for key, value := range afm.KernPairs {
+			left, okLeft := t1.names[afm.GlyphName(key[0])]
+			right, okRight := t1.names[afm.GlyphName(key[1])]
+			if okLeft && okRight && value != 0 {
+				kernPairs = append(kernPairs, kernPair{uint32(left)<<16 | uint32(right), afmValue(value)})
+			}
+		}
+		sort.Slice(kernPairs, func(i, j int) bool { return kernPairs[i].Key < kernPairs[j].Key })
+		if (math.MaxUint16-14)/6 < len(kernPairs) {
+			kernPairs = kernPairs[:(math.MaxUint16-14)/6]
+		}
+	}
+
+	// head
+	now := time.Now().UTC()
+	head := &headTable{
+		FontRevision:      uint32(math.Round(revision * (1 << 16))),
+		UnitsPerEm:        unitsPerEm,
+		Created:           now,
+		Modified:          now,
+		XMin:              xMin,
+		YMin:              yMin,
+		XMax:              xMax,
+		YMax:              yMax,
+		LowestRecPPEM:     3,
+		FontDirectionHint: 2,
+	}
+	head.Flags[0] = true // baseline at y=0
+	head.Flags[3] = true // integer scaling
+	head.MacStyle[0] = isBold
+	head.MacStyle[1] = isItalic
+
+	// hhea and hmtx
+	hmtx := newHmtxTable(advances, lsbs)
+	hhea := &hheaTable{
+		Ascender:            ascender,
+		Descender:           descender,
+		MinLeftSideBearing:  math.MaxInt16,
+		MinRightSideBearing: minRsb,
+		XMaxExtent:          xMaxExtent,
+		CaretSlopeRise:      1,
+		NumberOfHMetrics:    uint16(len(hmtx.HMetrics)),
+	}
+	for glyphID, advance := range advances {
+		hhea.AdvanceWidthMax = max(hhea.AdvanceWidthMax, advance)
+		if hasOutline[glyphID] {
+			hhea.MinLeftSideBearing = min(hhea.MinLeftSideBearing, lsbs[glyphID])
+		}
+	}
+	if hhea.MinLeftSideBearing == math.MaxInt16 {
+		hhea.MinLeftSideBearing = 0
+	}
+	if isItalic {
+		hhea.CaretSlopeRise = int16(unitsPerEm)
+		hhea.CaretSlopeRun = int16(math.Round(-math.Tan(t1.ItalicAngle*math.Pi/180.0) * float64(unitsPerEm)))
+	}
+
+	// OS/2
+	os2 := newOS2Table(unitsPerEm, weightClass, isItalic, advances, rs)
+	os2.YStrikeoutSize = int16(math.Round(underlineThickness))
+	os2.STypoAscender = ascender
+	os2.STypoDescender = descender
+	os2.UsWinAscent = uint16(max(0, yMax, ascender))
+	os2.UsWinDescent = uint16(max(0, -yMin, -descender))
+	os2.SxHeight = xHeight
+	os2.SCapHeight = capHeight
+	if 0 < xHeight {
+		os2.YStrikeoutPosition = xHeight / 2
+	}
+	if 0 < len(kernPairs) {
+		os2.UsMaxContent = 2
+	}
+
+	// post, the underline position is at the top of the underline instead of at its center
+	post := &postTable{
+		ItalicAngle:        t1.ItalicAngle,
+		UnderlinePosition:  int16(math.Round(underlinePosition + underlineThickness/2.0)),
+		UnderlineThickness: int16(math.Round(underlineThickness)),
+	}
+	if t1.IsFixedPitch {
+		post.IsFixedPitch = 1
+	}
+	postData, err := post.Write()
+	if err != nil {
+		return nil, fmt.Errorf("type1: post: %w", err)
+	}
+
+	// name
+	name := &nameTable{}
+	if t1.Notice != "" {
+		name.Add(NameCopyrightNotice, t1.Notice)
+	}
+	if weightClass != 400 && weightClass != 700 && weight != "" {
+		// keep the family name to a regular/bold/italic/bold italic style group
+		preferredSubfamily := weight
+		if isItalic {
+			preferredSubfamily += " Italic"
+		}
+		name.Add(NamePreferredFamily, familyName)
+		name.Add(NamePreferredSubfamily, preferredSubfamily)
+		familyName += " " + weight
+	}
+	name.Add(NameFontFamily, familyName)
+	name.Add(NameFontSubfamily, subfamily)
+	name.Add(NameUniqueIdentifier, version+";"+fontName)
+	name.Add(NameFull, fullName)
+	name.Add(NameVersion, "Version "+version)
+	name.Add(NamePostScript, fontName)
+	nameData, err := name.Write()
+	if err != nil {
+		return nil, fmt.Errorf("type1: %w", err)
+	}
+
+	sfnt := &SFNT{
+		IsCFF: true,
+		Tables: map[string][]byte{
+			"CFF ": cffData,
+			"cmap": cmapWrite(rs, runeMap),
+			"head": head.Write(),
+			"hhea": hhea.Write(),
+			"hmtx": hmtx.Write(),
+			"maxp": (&maxpTable{Version: 0x00005000, NumGlyphs: numGlyphs}).Write(),
+			"name": nameData,
+			"OS/2": os2.Write(),
+			"post": postData,
+		},
+	}
+	if 0 < len(kernPairs) {
+		kern := &kernTable{
+			Subtables: []kernFormat0{{
+				Coverage: [8]bool{true}, // horizontal
+				Pairs:    kernPairs,
+			}},
+		}
+		sfnt.Tables["kern"] = kern.Write()
+	}
+	return ParseSFNT(sfnt.Write(), 0)
+}
+
+// type1Hints returns the rounded horizontal and vertical stem hints of all hint sets sorted by position, and a hint mask for each hint set if there is more than one. Stems that overlap a preceding stem of the same hint set are dropped, as are stems that do not fit on the Type 2 argument stack.
+func type1Hints(hintSets []type1HintSet) ([][2]int32, [][2]int32, []cffHintMask) {
+	if len(hintSets) == 0 {
+		return nil, nil, nil
+	}
+
+	hsets := make([][][2]int32, len(hintSets))
+	vsets := make([][][2]int32, len(hintSets))
+	for i, hintSet := range hintSets {
+		hsets[i] = type1Stems(hintSet.hstems)
+		vsets[i] = type1Stems(hintSet.vstems)
+	}
+	hstems, vstems := type1StemsUnion(hsets), type1StemsUnion(vsets)
+	if len(hintSets) == 1 || len(hstems) == 0 && len(vstems) == 0 {
+		return hstems, vstems, nil
+	}
+
+	index := func(stems [][2]int32, stem [2]int32) int {
+		i := sort.Search(len(stems), func(i int) bool { return !type1StemLess(stems[i], stem) })
+		if i < len(stems) && stems[i] == stem {
+			return i
+		}
+		return -1
+	}
+	masks := make([]cffHintMask, len(hintSets))
+	for i, hintSet := range hintSets {
+		masks[i].Op = hintSet.op
+		masks[i].Mask = make([]byte, (len(hstems)+len(vstems)+7)/8)
+		for _, stem := range hsets[i] {
+			if j := index(hstems, stem); j != -1 {
+				masks[i].Mask[j/8] |= 0x80 >> (j % 8)
+			}
+		}
+		for _, stem := range vsets[i] {
+			if j := index(vstems, stem); j != -1 {
+				j += len(hstems)
+				masks[i].Mask[j/8] |= 0x80 >> (j % 8)
+			}
+		}
+	}
+	return hstems, vstems, masks
+}
+
+func type1StemLess(a, b [2]int32) bool {
+	if a[0] == b[0] {
+		return a[1] < b[1]
+	}
+	return a[0] < b[0]
+}
+
+// type1Stems returns the rounded stem hints sorted by position, where stems that overlap a preceding stem are dropped.
+func type1Stems(stems [][2]float64) [][2]int32 {
+	if len(stems) == 0 {
+		return nil
+	}
+
+	istems := make([][2]int32, 0, len(stems))
+	for _, stem := range stems {
+		istems = append(istems, [2]int32{int32(math.Round(stem[0])), int32(math.Round(stem[1]))})
+	}
+	sort.Slice(istems, func(i, j int) bool { return type1StemLess(istems[i], istems[j]) })
+
+	j := 0
+	for i, stem := range istems {
+		if 0 < i && stem == istems[j-1] {
+			continue // duplicate
+		} else if 0 < i && min(stem[0], stem[0]+stem[1]) < max(istems[j-1][0], istems[j-1][0]+istems[j-1][1]) {
+			continue // overlap
+		}
+		istems[j] = stem
+		j++
+	}
+	return istems[:j]
+}
+
+// type1StemsUnion returns the sorted union of the stems of all hint sets, limited to the stems that fit on the Type 2 argument stack.
+func type1StemsUnion(sets [][][2]int32) [][2]int32 {
+	stems := [][2]int32{}
+	for _, set := range sets {
+		stems = append(stems, set...)
+	}
+	if len(stems) == 0 {
+		return nil
+	}
+	sort.Slice(stems, func(i, j int) bool { return type1StemLess(stems[i], stems[j]) })
+
+	j := 0
+	for i, stem := range stems {
+		if 0 < i && stem == stems[j-1] {
+			continue // duplicate
+		}
+		stems[j] = stem
+		j++
+	}
+	return stems[:min(j, 23)] // 48 arguments including the width
+}
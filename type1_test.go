@@ -0,0 +1,350 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+type type1TestPather struct {
+	strings.Builder
+}
+
+func (p *type1TestPather) MoveTo(x, y float64) {
+	fmt.Fprintf(p, "M%g %g", x, y)
+}
+
+func (p *type1TestPather) LineTo(x, y float64) {
+	fmt.Fprintf(p, "L%g %g", x, y)
+}
+
+func (p *type1TestPather) QuadTo(cpx, cpy, x, y float64) {
+	fmt.Fprintf(p, "Q%g %g %g %g", cpx, cpy, x, y)
+}
+
+func (p *type1TestPather) CubeTo(cpx1, cpy1, cpx2, cpy2, x, y float64) {
+	fmt.Fprintf(p, "C%g %g %g %g %g %g", cpx1, cpy1, cpx2, cpy2, x, y)
+}
+
+func (p *type1TestPather) Close() {
+	if s := p.String(); s != "" && s[len(s)-1] != 'z' {
+		p.WriteString("z")
+	}
+}
+
+// type1Encrypt is the inverse of type1Decrypt with four zero bytes prepended.
+func type1Encrypt(b []byte, r uint16) []byte {
+	const c1, c2 = 52845, 22719
+	dst := make([]byte, 4+len(b))
+	for i, c := range append(make([]byte, 4), b...) {
+		dst[i] = c ^ byte(r>>8)
+		r = (uint16(dst[i])+r)*c1 + c2
+	}
+	return dst
+}
+
+// type1CharString encodes integers as numbers and strings as operators.
+func type1CharString(vals ...any) []byte {
+	ops := map[string][]byte{
+		"hsbw":            {13},
+		"hstem":           {1},
+		"vstem":           {3},
+		"rmoveto":         {21},
+		"rlineto":         {5},
+		"closepath":       {9},
+		"callsubr":        {10},
+		"return":          {11},
+		"endchar":         {14},
+		"seac":            {12, 6},
+		"callothersubr":   {12, 16},
+		"pop":             {12, 17},
+		"setcurrentpoint": {12, 33},
+	}
+	b := []byte{}
+	for _, val := range vals {
+		switch v := val.(type) {
+		case int:
+			if -107 <= v && v <= 107 {
+				b = append(b, byte(v+139))
+			} else if 108 <= v && v <= 1131 {
+				b = append(b, byte((v-108)/256+247), byte((v-108)%256))
+			} else {
+				b = append(b, 255, 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(b[len(b)-4:], uint32(int32(v)))
+			}
+		case string:
+			b = append(b, ops[v]...)
+		}
+	}
+	return type1Encrypt(b, 4330)
+}
+
+func TestParseType1(t *testing.T) {
+	b, err := os.ReadFile("resources/cmr10.pfb")
+	test.Error(t, err)
+
+	t1, err := ParseType1(b)
+	test.Error(t, err)
+	test.T(t, t1.FontName, "CMR10")
+	test.T(t, t1.FamilyName, "Computer Modern")
+	test.T(t, t1.Notice, "Copyright (c) 1997, 2009 American Mathematical Society (<http://www.ams.org>), with Reserved Font Name CMR10.")
+	test.T(t, t1.FontBBox, [4]float64{-40, -250, 1009, 750})
+	test.T(t, t1.UnitsPerEm(), uint16(1000))
+	test.T(t, t1.NumGlyphs(), uint16(132))
+	test.T(t, t1.GlyphName(0), ".notdef")
+
+	glyphID := t1.GlyphIndex('A')
+	test.T(t, t1.GlyphName(glyphID), "A")
+	test.T(t, t1.FindGlyphName("A"), glyphID)
+	test.T(t, t1.CharCodeIndex(65), glyphID)
+	test.T(t, t1.CharCodeIndex(11), t1.FindGlyphName("ff"))
+	test.T(t, t1.GlyphAdvance(glyphID), uint16(750))
+
+	// bounds from the AFM file
+	p := &bboxPather{XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+	test.Error(t, t1.GlyphPath(p, glyphID, 0.0, 0.0, 1.0))
+	test.T(t, *p, bboxPather{32, 717, 0, 716})
+
+	// convert to PFA
+	pfa := []byte{}
+	for i := 0; b[i+1] != 3; {
+		length := int(binary.LittleEndian.Uint32(b[i+2:]))
+		segment := b[i+6 : i+6+length]
+		if b[i+1] == 2 {
+			segment = []byte(hex.EncodeToString(segment))
+		}
+		pfa = append(pfa, segment...)
+		i += 6 + length
+	}
+	t1PFA, err := ParseType1(pfa)
+	test.Error(t, err)
+	test.T(t, t1PFA.NumGlyphs(), t1.NumGlyphs())
+	for glyphID := uint16(0); glyphID < t1.NumGlyphs(); glyphID++ {
+		p, pPFA := &type1TestPather{}, &type1TestPather{}
+		test.Error(t, t1.GlyphPath(p, glyphID, 0.0, 0.0, 1.0))
+		test.Error(t, t1PFA.GlyphPath(pPFA, glyphID, 0.0, 0.0, 1.0))
+		test.T(t, pPFA.String(), p.String(), t1.GlyphName(glyphID))
+	}
+}
+
+func TestType1CharStrings(t *testing.T) {
+	subrs := [][]byte{
+		type1CharString(0, 700, "rlineto", "return"),
+		type1CharString(50, 100, "hstem", 20, 30, "vstem", "return"),
+	}
+	charStrings := []struct {
+		name       string
+		charString []byte
+	}{
+		{"A", type1CharString(50, 600, "hsbw", 0, 0, "rmoveto", 500, 0, "rlineto", 0, "callsubr", "closepath", "endchar")},
+		{"acute", type1CharString(20, 300, "hsbw", 0, 800, "rmoveto", 100, 100, "rlineto", "closepath", "endchar")},
+		{"Aacute", type1CharString(50, 600, "hsbw", 20, 250, 0, 65, 194, "seac")},
+		{"B", type1CharString(0, 500, "hsbw", 0, 0, "rmoveto",
+			0, 1, "callothersubr",
+			100, 0, "rmoveto", 0, 2, "callothersubr", // reference point
+			-70, 20, "rmoveto", 0, 2, "callothersubr",
+			40, 0, "rmoveto", 0, 2, "callothersubr",
+			30, -10, "rmoveto", 0, 2, "callothersubr",
+			30, -10, "rmoveto", 0, 2, "callothersubr",
+			40, 0, "rmoveto", 0, 2, "callothersubr",
+			30, 0, "rmoveto", 0, 2, "callothersubr",
+			50, 200, 0, 3, 0, "callothersubr", "pop", "pop", "setcurrentpoint",
+			0, -100, "rlineto", "closepath", "endchar")},
+		{"C", type1CharString(0, 500, "hsbw", 0, 100, "hstem", 0, 0, "rmoveto", 100, 0, "rlineto",
+			1, 1, 3, "callothersubr", "pop", "callsubr", // hint replacement with an overlapping stem
+			0, 100, "rlineto", "closepath", "endchar")},
+		{".notdef", type1CharString(0, 500, "hsbw", "endchar")},
+	}
+
+	private := &strings.Builder{}
+	private.WriteString("dup /Private 8 dict dup begin\n/RD{string currentfile exch readstring pop}executeonly def\n/ND{noaccess def}executeonly def\n/NP{noaccess put}executeonly def\n/lenIV 4 def\n")
+	fmt.Fprintf(private, "/BlueValues [-10 0 700 710] def\n/Subrs %d array\n", len(subrs))
+	for i, subr := range subrs {
+		fmt.Fprintf(private, "dup %d %d RD %s NP\n", i, len(subr), subr)
+	}
+	fmt.Fprintf(private, "ND\n2 index /CharStrings %d dict dup begin\n", len(charStrings))
+	for _, charString := range charStrings {
+		fmt.Fprintf(private, "/%s %d RD %s ND\n", charString.name, len(charString.charString), charString.charString)
+	}
+	private.WriteString("end\nend\nreadonly put\nput\ndup /FontName get exch definefont pop\nmark currentfile closefile\n")
+
+	pfa := "%!PS-AdobeFont-1.0: Test 001.000\n/FontName /Test def\n/FontType 1 def\n/FontMatrix [0.0005 0 0 0.0005 0 0] readonly def\n/Encoding 256 array\n0 1 255 {1 index exch /.notdef put} for\ndup 65 /A put\ndup 66 /B put\nreadonly def\ncurrentfile eexec\n"
+	pfa += hex.EncodeToString(type1Encrypt([]byte(private.String()), 55665))
+	pfa += "\n" + strings.Repeat("0", 512) + "\ncleartomark\n"
+
+	t1, err := ParseType1([]byte(pfa))
+	test.Error(t, err)
+	test.T(t, t1.FontName, "Test")
+	test.T(t, t1.UnitsPerEm(), uint16(2000))
+	test.T(t, t1.NumGlyphs(), uint16(6))
+	test.T(t, t1.GlyphName(0), ".notdef")
+	test.T(t, t1.CharCodeIndex(65), t1.FindGlyphName("A"))
+	test.T(t, t1.CharCodeIndex(66), t1.FindGlyphName("B"))
+	test.T(t, t1.CharCodeIndex(67), uint16(0))
+	test.T(t, t1.GlyphIndex('Á'), t1.FindGlyphName("Aacute"))
+	test.T(t, t1.GlyphAdvance(t1.FindGlyphName("Aacute")), uint16(600))
+	test.T(t, t1.private.BlueValues, []float64{-10, 0, 700, 710})
+
+	var tests = []struct {
+		name string
+		path string
+	}{
+		{"A", "M50 0L550 0L550 700z"},
+		{"Aacute", "M50 0L550 0L550 700zM300 800L400 900z"},
+		{"B", "M0 0C30 20 70 20 100 10C130 0 170 0 200 0L200 -100z"},
+		{"C", "M0 0L100 0L100 100z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &type1TestPather{}
+			test.Error(t, t1.GlyphPath(p, t1.FindGlyphName(tt.name), 0.0, 0.0, 1.0))
+			test.T(t, p.String(), tt.path)
+		})
+	}
+
+	// the overlapping stems of C are declared together and selected by hint masks
+	sfnt, err := t1.ToSFNT(nil)
+	test.Error(t, err)
+	charString := sfnt.CFF.charStrings.Get(t1.FindGlyphName("C"))
+	test.That(t, bytes.Contains(charString, []byte{139, 239, 89, 239, 18, 159, 169, 23}), "stems") // 0 100 -50 100 hstemhm 20 30 vstemhm
+	test.That(t, bytes.Contains(charString, []byte{19, 0x80, 139, 139, 21}), "first hint mask")    // hintmask 0 0 rmoveto
+	test.That(t, bytes.Contains(charString, []byte{19, 0x60, 139, 239, 5}), "second hint mask")    // hintmask 0 100 rlineto
+	p, pCFF := &type1TestPather{}, &type1TestPather{}
+	test.Error(t, t1.GlyphPath(p, t1.FindGlyphName("C"), 0.0, 0.0, 1.0))
+	test.Error(t, sfnt.GlyphPath(pCFF, t1.FindGlyphName("C"), 0, 0.0, 0.0, 1.0, NoHinting))
+	test.T(t, pCFF.String(), p.String())
+	test.Error(t, sfnt.GlyphPath(&type1TestPather{}, t1.FindGlyphName("C"), 12, 0.0, 0.0, 1.0, FullHinting))
+}
+
+func TestParseType1Error(t *testing.T) {
+	b, err := os.ReadFile("resources/cmr10.pfb")
+	test.Error(t, err)
+
+	var tests = []struct {
+		b   []byte
+		err string
+	}{
+		{[]byte("%!PS-AdobeFont-1.0"), "type1: eexec not found"},
+		{[]byte("%!PS-AdobeFont-1.0 currentfile eexec\n"), "type1: invalid font data"},
+		{[]byte("OTTO"), "type1: bad PFA or PFB header"},
+		{b[:100], "type1: bad PFB segment"},
+		{[]byte{0x80, 1, 1, 0, 0, 0, ' ', 0x80, 4, 0, 0, 0, 0}, "type1: bad PFB segment type 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := ParseType1(tt.b)
+			test.T(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestType1ToSFNT(t *testing.T) {
+	b, err := os.ReadFile("resources/cmr10.pfb")
+	test.Error(t, err)
+	t1, err := ParseType1(b)
+	test.Error(t, err)
+
+	afm, err := ParseAFM([]byte(`StartFontMetrics 4.1
+FontName CMR10
+Ascender 694
+Descender -194
+CapHeight 683
+XHeight 431
+UnderlinePosition -100
+UnderlineThickness 50
+StartCharMetrics 2
+C 65 ; WX 750 ; N A ; B 32 0 717 716 ;
+C 86 ; WX 750 ; N V ; B 14 -22 736 683 ;
+EndCharMetrics
+StartKernData
+StartKernPairs 1
+KPX A V -111
+EndKernPairs
+EndKernData
+EndFontMetrics
+`))
+	test.Error(t, err)
+
+	sfnt, err := t1.ToSFNT(afm)
+	test.Error(t, err)
+	test.That(t, sfnt.IsCFF)
+	test.T(t, sfnt.NumGlyphs(), t1.NumGlyphs())
+	test.T(t, sfnt.Head.UnitsPerEm, uint16(1000))
+	test.T(t, sfnt.Name.Find(NameFontFamily), "Computer Modern")
+	test.T(t, sfnt.Name.Find(NameFontSubfamily), "Regular")
+	test.T(t, sfnt.Name.Find(NamePostScript), "CMR10")
+	test.T(t, sfnt.Hhea.Ascender, int16(694))
+	test.T(t, sfnt.Hhea.Descender, int16(-194))
+	test.T(t, sfnt.OS2.SCapHeight, int16(683))
+	test.T(t, sfnt.OS2.UsWeightClass, uint16(400))
+	test.T(t, sfnt.Post.UnderlinePosition, int16(-75))
+
+	glyphID := sfnt.GlyphIndex('A')
+	test.T(t, glyphID, t1.GlyphIndex('A'))
+	test.T(t, sfnt.GlyphName(glyphID), "A")
+	test.T(t, sfnt.GlyphAdvance(glyphID), uint16(750))
+	test.T(t, sfnt.Kerning(glyphID, sfnt.GlyphIndex('V')), int16(-111))
+	test.T(t, sfnt.GlyphIndex(0xF000+32), t1.FindGlyphName("suppress")) // encoded glyph without Unicode mapping
+
+	privateDICT, err := sfnt.CFF.PrivateDICT(glyphID)
+	test.Error(t, err)
+	test.T(t, privateDICT.BlueValues, t1.private.BlueValues)
+	test.T(t, privateDICT.StdVW, t1.private.StdVW)
+
+	for glyphID := uint16(0); glyphID < t1.NumGlyphs(); glyphID++ {
+		p, pCFF := &type1TestPather{}, &type1TestPather{}
+		test.Error(t, t1.GlyphPath(p, glyphID, 0.0, 0.0, 1.0))
+		test.Error(t, sfnt.GlyphPath(pCFF, glyphID, 0, 0.0, 0.0, 1.0, NoHinting))
+		test.T(t, pCFF.String(), p.String(), t1.GlyphName(glyphID))
+		test.T(t, sfnt.GlyphAdvance(glyphID), t1.GlyphAdvance(glyphID), t1.GlyphName(glyphID))
+	}
+
+	subset, err := sfnt.Subset([]uint16{0, glyphID}, SubsetOptions{Tables: KeepAllTables})
+	test.Error(t, err)
+	test.T(t, subset.GlyphIndex('A'), uint16(1))
+
+	woff2, err := sfnt.WriteWOFF2()
+	test.Error(t, err)
+	sfnt2, err := ParseSFNT(woff2, 0)
+	test.Error(t, err)
+	test.T(t, sfnt2.GlyphIndex('A'), glyphID)
+}
+
+func TestType1Stems(t *testing.T) {
+	var tests = []struct {
+		stems [][2]float64
+		ref   [][2]int32
+	}{
+		{nil, nil},
+		{[][2]float64{{400, 30}, {0, 20.4}, {400, 30}}, [][2]int32{{0, 20}, {400, 30}}},
+		{[][2]float64{{0, 40}, {30, 40}, {70, 10}}, [][2]int32{{0, 40}, {70, 10}}},               // overlap
+		{[][2]float64{{700, -20}, {0, 40}, {40, 20}}, [][2]int32{{0, 40}, {40, 20}, {700, -20}}}, // ghost stem
+	}
+	for _, tt := range tests {
+		test.T(t, type1Stems(tt.stems), tt.ref)
+	}
+}
+
+func TestType1Hints(t *testing.T) {
+	// the second hint set replaces the first, its overlapping stems are added with a hint mask
+	hstems, vstems, masks := type1Hints([]type1HintSet{
+		{0, [][2]float64{{0, 40}, {100, 20}}, [][2]float64{{10, 20}}},
+		{3, [][2]float64{{30, 40}, {100, 20}}, nil},
+	})
+	test.T(t, hstems, [][2]int32{{0, 40}, {30, 40}, {100, 20}})
+	test.T(t, vstems, [][2]int32{{10, 20}})
+	test.T(t, masks, []cffHintMask{{0, []byte{0xB0}}, {3, []byte{0x60}}})
+
+	// a single hint set needs no hint mask
+	hstems, vstems, masks = type1Hints([]type1HintSet{{0, [][2]float64{{0, 40}, {30, 40}}, nil}})
+	test.T(t, hstems, [][2]int32{{0, 40}})
+	test.T(t, vstems, [][2]int32(nil))
+	test.T(t, masks, []cffHintMask(nil))
+}
@@ -3,6 +3,9 @@ package font
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
+
+	"github.com/tdewolff/parse/v2"
 )
 
 // MaxMemory is the maximum memory that can be allocated by a font.
@@ -14,6 +17,37 @@ var ErrExceedsMemory = fmt.Errorf("memory limit exceded")
 // ErrInvalidFontData is returned if the font is malformed.
 var ErrInvalidFontData = fmt.Errorf("invalid font data")
 
+// newBinaryReader returns a binary reader where Err returns io.EOF only after reading past the end of the data. Such reads return nothing, as do all reads that follow.
+func newBinaryReader(b []byte) *parse.BinaryReader {
+	return parse.NewBinaryReader(&binaryReaderBytes{data: b})
+}
+
+type binaryReaderBytes struct {
+	data []byte
+	eof  bool
+}
+
+func (r *binaryReaderBytes) Bytes(b []byte, n, off int64) ([]byte, error) {
+	if r.eof || off < 0 || n < 0 || int64(len(r.data)) < off || int64(len(r.data))-off < n {
+		r.eof = true
+		return nil, io.EOF
+	}
+	data := r.data[off : off+n : off+n]
+	if b == nil {
+		return data, nil
+	}
+	copy(b, data)
+	return b[:n], nil
+}
+
+func (r *binaryReaderBytes) Len() int64 {
+	return int64(len(r.data))
+}
+
+func (r *binaryReaderBytes) Close() error {
+	return nil
+}
+
 func calcChecksum(b []byte) uint32 {
 	if len(b)%4 != 0 {
 		panic("data not multiple of four bytes")
@@ -50,6 +84,15 @@ func flagsToUint8(flags [8]bool) (v uint8) {
 	return
 }
 
+func flagsToUint16(flags [16]bool) (v uint16) {
+	for i := 0; i < 16; i++ {
+		if flags[i] {
+			v |= 1 << i
+		}
+	}
+	return
+}
+
 func uint32ToString(v uint32) string {
 	b := make([]byte, 4)
 	binary.BigEndian.PutUint32(b, v)
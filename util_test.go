@@ -0,0 +1,26 @@
+package font
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tdewolff/test"
+)
+
+func TestBinaryReader(t *testing.T) {
+	r := newBinaryReader([]byte{0x01, 0x02, 0x03})
+	test.T(t, r.ReadUint16(), uint16(0x0102))
+	test.T(t, r.ReadUint8(), uint8(0x03))
+	test.Error(t, r.Err()) // reading up to the end is not an error
+	test.T(t, r.Len(), int64(0))
+
+	test.T(t, r.ReadUint8(), uint8(0))
+	test.T(t, r.Err(), io.EOF)
+
+	// reads after reading past the end keep failing
+	r = newBinaryReader([]byte{0x01, 0x02, 0x03})
+	test.T(t, r.ReadUint32(), uint32(0))
+	test.T(t, r.ReadUint8(), uint8(0))
+	test.T(t, r.Err(), io.EOF)
+	test.T(t, r.Len(), int64(3))
+}
@@ -127,6 +127,16 @@ func ParseWOFF(b []byte) ([]byte, error) {
 		return nil, fmt.Errorf("tables can not overlap")
 	}
 
+	var tableEnd uint32
+	for _, table := range tables {
+		if tableEnd < table.offset+table.length {
+			tableEnd = table.offset + table.length
+		}
+	}
+	if _, _, err := readWOFFBlocks(b, tableEnd, b[24:]); err != nil {
+		return nil, err
+	}
+
 	var searchRange uint16 = 1
 	var entrySelector uint16
 	var rangeShift uint16
@@ -225,3 +235,111 @@ func ParseWOFF(b []byte) ([]byte, error) {
 	binary.BigEndian.PutUint32(buf[checksumAdjustmentPos:], checksumAdjustment)
 	return buf, nil
 }
+
+// WOFFOptions are the options for writing WOFF fonts.
+type WOFFOptions struct {
+	Metadata    *WOFFMetadata // extended metadata block, compressed using zlib
+	PrivateData []byte        // private data block
+}
+
+// WriteWOFF writes the font to the WOFF format. Each table is compressed using zlib, unless that doesn't reduce its size in which case it is stored uncompressed. See https://www.w3.org/TR/WOFF/
+func (sfnt *SFNT) WriteWOFF() ([]byte, error) {
+	return sfnt.WriteWOFFOptions(WOFFOptions{})
+}
+
+// WriteWOFFOptions writes the font to the WOFF format including the extended metadata and private data blocks if given.
+func (sfnt *SFNT) WriteWOFFOptions(options WOFFOptions) ([]byte, error) {
+	// write SFNT to obtain table checksums and the checksum adjustment in head
+	b := sfnt.Write()
+	r := parse.NewBinaryReaderBytes(b)
+	flavor := r.ReadString(4)
+	numTables := r.ReadUint16()
+	_ = r.ReadUint16() // searchRange
+	_ = r.ReadUint16() // entrySelector
+	_ = r.ReadUint16() // rangeShift
+
+	tables := make([]woffTable, numTables)
+	for i := range tables {
+		tables[i].tag = r.ReadString(4)
+		tables[i].origChecksum = r.ReadUint32()
+		tables[i].offset = r.ReadUint32()
+		tables[i].origLength = r.ReadUint32()
+	}
+
+	// compress tables
+	datas := make([][]byte, numTables)
+	offset := 44 + 20*uint32(numTables)
+	for i, table := range tables {
+		data := b[table.offset : table.offset+table.origLength]
+
+		var buf bytes.Buffer
+		zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+		if err != nil {
+			return nil, err
+		} else if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", table.tag, err)
+		} else if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("%s: %v", table.tag, err)
+		}
+		if buf.Len() < len(data) {
+			data = buf.Bytes()
+		}
+
+		datas[i] = data
+		tables[i].offset = offset
+		tables[i].length = uint32(len(data))
+		offset += (tables[i].length + 3) & 0xFFFFFFFC // add padding
+	}
+
+	var metadata, compMetadata []byte
+	if options.Metadata != nil {
+		var err error
+		if metadata, err = writeWOFFMetadata(options.Metadata); err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+		if err != nil {
+			return nil, err
+		} else if _, err := zw.Write(metadata); err != nil {
+			return nil, fmt.Errorf("metadata: %v", err)
+		} else if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("metadata: %v", err)
+		}
+		compMetadata = buf.Bytes()
+	}
+
+	w := parse.NewBinaryWriter(make([]byte, 0, offset))
+	w.WriteString("wOFF")         // signature
+	w.WriteString(flavor)         // flavor
+	w.WriteUint32(0)              // length (set later)
+	w.WriteUint16(numTables)      // numTables
+	w.WriteUint16(0)              // reserved
+	w.WriteUint32(uint32(len(b))) // totalSfntSize
+	w.WriteUint16(1)              // majorVersion
+	w.WriteUint16(0)              // minorVersion
+	w.WriteUint32(0)              // metaOffset
+	w.WriteUint32(0)              // metaLength
+	w.WriteUint32(0)              // metaOrigLength
+	w.WriteUint32(0)              // privOffset
+	w.WriteUint32(0)              // privLength
+	for _, table := range tables {
+		w.WriteString(table.tag)
+		w.WriteUint32(table.offset)
+		w.WriteUint32(table.length)
+		w.WriteUint32(table.origLength)
+		w.WriteUint32(table.origChecksum)
+	}
+	for _, data := range datas {
+		w.WriteBytes(data)
+		for i := 0; i < (4-len(data)&3)&3; i++ {
+			w.WriteByte(0) // padding
+		}
+	}
+	writeWOFFBlocks(w, 24, compMetadata, uint32(len(metadata)), options.PrivateData)
+
+	b = w.Bytes()
+	binary.BigEndian.PutUint32(b[8:], uint32(len(b))) // length
+	return b, nil
+}
@@ -32,6 +32,11 @@ type woff2Table struct {
 	data             []byte
 }
 
+type woff2Font struct {
+	flavor        uint32
+	tagTableIndex map[string]int
+}
+
 var woff2TableTags = []string{
 	"cmap", "head", "hhea", "hmtx",
 	"maxp", "name", "OS/2", "post",
@@ -51,21 +56,19 @@ var woff2TableTags = []string{
 	"Gloc", "Feat", "Sill",
 }
 
-// ParseWOFF2 parses the WOFF2 font format and returns its contained SFNT font format (TTF or OTF). See https://www.w3.org/TR/WOFF2/
+// ParseWOFF2 parses the WOFF2 font format and returns its contained SFNT font format (TTF or OTF). Font collections are returned as a TTC, where tables shared between fonts are written only once. See https://www.w3.org/TR/WOFF2/
 func ParseWOFF2(b []byte) ([]byte, error) {
 	if len(b) < 48 {
 		return nil, ErrInvalidFontData
 	}
 
-	r := parse.NewBinaryReaderBytes(b)
+	r := newBinaryReader(b)
 	signature := r.ReadString(4)
 	if signature != "wOF2" {
 		return nil, fmt.Errorf("bad signature")
 	}
 	flavor := r.ReadUint32()
-	if uint32ToString(flavor) == "ttcf" {
-		return nil, fmt.Errorf("collections are unsupported")
-	}
+	isCollection := uint32ToString(flavor) == "ttcf"
 	length := r.ReadUint32()              // length
 	numTables := r.ReadUint16()           // numTables
 	reserved := r.ReadUint16()            // reserved
@@ -88,7 +91,6 @@ func ParseWOFF2(b []byte) ([]byte, error) {
 		return nil, fmt.Errorf("reserved in header must be zero")
 	}
 
-	tags := []string{}
 	tagTableIndex := map[string]int{}
 	tables := []woff2Table{}
 	var uncompressedSize uint32
@@ -129,19 +131,19 @@ func ParseWOFF2(b []byte) ([]byte, error) {
 		}
 
 		if tag == "loca" {
-			iGlyf, hasGlyf := tagTableIndex["glyf"]
-			if uint32ToString(flavor) == "ttcf" && (!hasGlyf || i-1 != iGlyf) {
-				// TODO: should find last glyf table, map lookup probably doesn't work here
-				return nil, fmt.Errorf("loca: must come directly after glyf table")
-			} else if !hasGlyf {
+			if isCollection {
+				// each font in the collection may have its own glyf and loca tables
+				if i == 0 || tables[i-1].tag != "glyf" {
+					return nil, fmt.Errorf("loca: must come directly after glyf table")
+				}
+			} else if _, hasGlyf := tagTableIndex["glyf"]; !hasGlyf {
 				return nil, fmt.Errorf("loca: must come after glyf table")
 			}
 		}
-		if _, ok := tagTableIndex[tag]; ok {
+		if _, ok := tagTableIndex[tag]; ok && !isCollection {
 			return nil, fmt.Errorf("%s: table defined more than once", tag)
 		}
 
-		tags = append(tags, tag)
 		tagTableIndex[tag] = len(tables)
 		tables = append(tables, woff2Table{
 			tag:              tag,
@@ -151,16 +153,64 @@ func ParseWOFF2(b []byte) ([]byte, error) {
 		})
 	}
 
-	iGlyf, hasGlyf := tagTableIndex["glyf"]
-	iLoca, hasLoca := tagTableIndex["loca"]
-	if hasGlyf != hasLoca || hasGlyf && tables[iGlyf].transformVersion != tables[iLoca].transformVersion {
-		return nil, fmt.Errorf("glyf and loca tables must be both present and either be both transformed or untransformed")
-	}
-	if hasLoca && tables[iLoca].transformLength != 0 {
-		return nil, fmt.Errorf("loca: transformLength must be zero")
+	// parse collection directory
+	var collectionVersion uint32
+	fonts := []woff2Font{}
+	if isCollection {
+		collectionVersion = r.ReadUint32()
+		numFonts := read255Uint16(r)
+		if r.Err() != nil {
+			return nil, ErrInvalidFontData
+		} else if collectionVersion != 0x00010000 && collectionVersion != 0x00020000 {
+			return nil, fmt.Errorf("collection: bad version")
+		} else if numFonts == 0 {
+			return nil, fmt.Errorf("collection: numFonts must not be zero")
+		}
+		for i := 0; i < int(numFonts); i++ {
+			numFontTables := read255Uint16(r)
+			font := woff2Font{
+				flavor:        r.ReadUint32(),
+				tagTableIndex: make(map[string]int, numFontTables),
+			}
+			if r.Err() != nil {
+				return nil, ErrInvalidFontData
+			} else if numFontTables == 0 {
+				return nil, fmt.Errorf("collection: numTables must not be zero")
+			}
+			for j := 0; j < int(numFontTables); j++ {
+				index := read255Uint16(r)
+				if r.Err() != nil {
+					return nil, ErrInvalidFontData
+				} else if numTables <= index {
+					return nil, fmt.Errorf("collection: bad table index")
+				}
+				tag := tables[index].tag
+				if _, ok := font.tagTableIndex[tag]; ok {
+					return nil, fmt.Errorf("%s: table defined more than once", tag)
+				}
+				font.tagTableIndex[tag] = int(index)
+			}
+			fonts = append(fonts, font)
+		}
+	} else {
+		fonts = append(fonts, woff2Font{
+			flavor:        flavor,
+			tagTableIndex: tagTableIndex,
+		})
 	}
 
-	// TODO: (WOFF2) parse collection directory format
+	for _, font := range fonts {
+		iGlyf, hasGlyf := font.tagTableIndex["glyf"]
+		iLoca, hasLoca := font.tagTableIndex["loca"]
+		if hasGlyf != hasLoca || hasGlyf && tables[iGlyf].transformVersion != tables[iLoca].transformVersion {
+			return nil, fmt.Errorf("glyf and loca tables must be both present and either be both transformed or untransformed")
+		} else if isCollection && hasGlyf && iGlyf+1 != iLoca {
+			return nil, fmt.Errorf("loca: must come directly after glyf table")
+		}
+		if hasLoca && tables[iLoca].transformLength != 0 {
+			return nil, fmt.Errorf("loca: transformLength must be zero")
+		}
+	}
 
 	// decompress font data using Brotli
 	compData := r.ReadBytes(int64(totalCompressedSize))
@@ -168,6 +218,8 @@ func ParseWOFF2(b []byte) ([]byte, error) {
 		return nil, ErrInvalidFontData
 	} else if MaxMemory < uncompressedSize {
 		return nil, ErrExceedsMemory
+	} else if _, _, err := readWOFFBlocks(b, uint32(r.Pos()), b[28:]); err != nil {
+		return nil, err
 	}
 	rBrotli := brotli.NewReader(bytes.NewReader(compData)) // err is always nil
 	dataBuf := bytes.NewBuffer(make([]byte, 0, uncompressedSize))
@@ -197,134 +249,203 @@ func ParseWOFF2(b []byte) ([]byte, error) {
 		offset += n
 	}
 
-	// detransform font data tables
-	if hasGlyf {
-		if tables[iGlyf].transformVersion == 0 {
-			var err error
-			tables[iGlyf].data, tables[iLoca].data, err = reconstructGlyfLoca(tables[iGlyf].data, tables[iLoca].origLength)
-			if err != nil {
-				return nil, err
+	// detransform font data tables, tables shared between fonts are detransformed only once
+	detransformed := make([]bool, len(tables))
+	for _, font := range fonts {
+		iGlyf, hasGlyf := font.tagTableIndex["glyf"]
+		iLoca, hasLoca := font.tagTableIndex["loca"]
+		if hasGlyf && !detransformed[iGlyf] {
+			if tables[iGlyf].transformVersion == 0 {
+				var err error
+				tables[iGlyf].data, tables[iLoca].data, err = reconstructGlyfLoca(tables[iGlyf].data, tables[iLoca].origLength)
+				if err != nil {
+					return nil, err
+				}
+				if tables[iLoca].origLength != uint32(len(tables[iLoca].data)) {
+					return nil, fmt.Errorf("loca: invalid value for origLength")
+				}
+			} else {
+				rGlyf := newBinaryReader(tables[iGlyf].data)
+				_ = rGlyf.ReadUint32() // version
+				numGlyphs := uint32(rGlyf.ReadUint16())
+				indexFormat := rGlyf.ReadUint16()
+				if rGlyf.Err() == io.EOF {
+					return nil, ErrInvalidFontData
+				}
+				if indexFormat == 0 && tables[iLoca].origLength != (numGlyphs+1)*2 || indexFormat == 1 && tables[iLoca].origLength != (numGlyphs+1)*4 {
+					return nil, fmt.Errorf("loca: invalid value for origLength")
+				}
 			}
-			if tables[iLoca].origLength != uint32(len(tables[iLoca].data)) {
-				return nil, fmt.Errorf("loca: invalid value for origLength")
+			detransformed[iGlyf] = true
+		}
+
+		if iHmtx, hasHmtx := font.tagTableIndex["hmtx"]; hasHmtx && tables[iHmtx].transformVersion == 1 && !detransformed[iHmtx] {
+			iHead, ok := font.tagTableIndex["head"]
+			if !ok {
+				return nil, fmt.Errorf("hmtx: head table must be defined in order to rebuild hmtx table")
 			}
-		} else {
-			rGlyf := parse.NewBinaryReaderBytes(tables[iGlyf].data)
-			_ = rGlyf.ReadUint32() // version
-			numGlyphs := uint32(rGlyf.ReadUint16())
-			indexFormat := rGlyf.ReadUint16()
-			if rGlyf.Err() == io.EOF {
-				return nil, ErrInvalidFontData
+			if !hasGlyf {
+				return nil, fmt.Errorf("hmtx: glyf table must be defined in order to rebuild hmtx table")
 			}
-			if indexFormat == 0 && tables[iLoca].origLength != (numGlyphs+1)*2 || indexFormat == 1 && tables[iLoca].origLength != (numGlyphs+1)*4 {
-				return nil, fmt.Errorf("loca: invalid value for origLength")
+			if !hasLoca {
+				return nil, fmt.Errorf("hmtx: loca table must be defined in order to rebuild hmtx table")
 			}
+			iMaxp, ok := font.tagTableIndex["maxp"]
+			if !ok {
+				return nil, fmt.Errorf("hmtx: maxp table must be defined in order to rebuild hmtx table")
+			}
+			iHhea, ok := font.tagTableIndex["hhea"]
+			if !ok {
+				return nil, fmt.Errorf("hmtx: hhea table must be defined in order to rebuild hmtx table")
+			}
+			var err error
+			tables[iHmtx].data, err = reconstructHmtx(tables[iHmtx].data, tables[iHead].data, tables[iGlyf].data, tables[iLoca].data, tables[iMaxp].data, tables[iHhea].data)
+			if err != nil {
+				return nil, err
+			}
+			detransformed[iHmtx] = true
 		}
-	}
 
-	if iHmtx, hasHmtx := tagTableIndex["hmtx"]; hasHmtx && tables[iHmtx].transformVersion == 1 {
-		iHead, ok := tagTableIndex["head"]
-		if !ok {
-			return nil, fmt.Errorf("hmtx: head table must be defined in order to rebuild hmtx table")
-		}
-		if !hasGlyf {
-			return nil, fmt.Errorf("hmtx: glyf table must be defined in order to rebuild hmtx table")
-		}
-		if !hasLoca {
-			return nil, fmt.Errorf("hmtx: loca table must be defined in order to rebuild hmtx table")
-		}
-		iMaxp, ok := tagTableIndex["maxp"]
-		if !ok {
-			return nil, fmt.Errorf("hmtx: maxp table must be defined in order to rebuild hmtx table")
+		// set checkSumAdjustment to zero to enable calculation of table checksum and overal checksum
+		// also clear 11th bit in flags field
+		iHead, hasHead := font.tagTableIndex["head"]
+		if !hasHead || len(tables[iHead].data) < 18 {
+			return nil, fmt.Errorf("head: must be present")
 		}
-		iHhea, ok := tagTableIndex["hhea"]
-		if !ok {
-			return nil, fmt.Errorf("hmtx: hhea table must be defined in order to rebuild hmtx table")
+		binary.BigEndian.PutUint32(tables[iHead].data[8:], 0x00000000) // clear checkSumAdjustment
+		if flags := binary.BigEndian.Uint16(tables[iHead].data[16:]); flags&0x0800 == 0 {
+			return nil, fmt.Errorf("head: bit 11 in flags must be set")
 		}
-		var err error
-		tables[iHmtx].data, err = reconstructHmtx(tables[iHmtx].data, tables[iHead].data, tables[iGlyf].data, tables[iLoca].data, tables[iMaxp].data, tables[iHhea].data)
-		if err != nil {
-			return nil, err
+
+		if _, hasDSIG := font.tagTableIndex["DSIG"]; hasDSIG {
+			return nil, fmt.Errorf("DSIG: must be removed")
 		}
 	}
 
-	// set checkSumAdjustment to zero to enable calculation of table checksum and overal checksum
-	// also clear 11th bit in flags field
-	iHead, hasHead := tagTableIndex["head"]
-	if !hasHead || len(tables[iHead].data) < 18 {
-		return nil, fmt.Errorf("head: must be present")
-	}
-	binary.BigEndian.PutUint32(tables[iHead].data[8:], 0x00000000) // clear checkSumAdjustment
-	if flags := binary.BigEndian.Uint16(tables[iHead].data[16:]); flags&0x0800 == 0 {
-		return nil, fmt.Errorf("head: bit 11 in flags must be set")
+	// find offsets of table directories
+	sfntOffset := uint32(0)
+	if isCollection {
+		sfntOffset = 12 + 4*uint32(len(fonts))
+		if collectionVersion == 0x00020000 {
+			sfntOffset += 12
+		}
 	}
+	fontTags := make([][]string, len(fonts))
+	fontOffsets := make([]uint32, len(fonts))
+	for i, font := range fonts {
+		tags := make([]string, 0, len(font.tagTableIndex))
+		for tag := range font.tagTableIndex {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		fontTags[i] = tags
+		fontOffsets[i] = sfntOffset
+		if math.MaxUint32-sfntOffset < 12+16*uint32(len(tags)) {
+			return nil, ErrInvalidFontData
+		}
+		sfntOffset += 12 + 16*uint32(len(tags))
+	}
+
+	// find offsets of tables in the order of the table record entries and add padding
+	tableOrder := []int{}
+	tableOffsets := make([]uint32, len(tables)) // zero if not yet written
+	tableLengths := make([]uint32, len(tables))
+	tableChecksums := make([]uint32, len(tables))
+	for i, tags := range fontTags {
+		for _, tag := range tags {
+			j := fonts[i].tagTableIndex[tag]
+			if tableOffsets[j] != 0 {
+				continue // shared table
+			}
+			actualLength := uint32(len(tables[j].data))
 
-	if _, hasDSIG := tagTableIndex["DSIG"]; hasDSIG {
-		return nil, fmt.Errorf("DSIG: must be removed")
-	}
+			// add padding
+			nPadding := (4 - actualLength&3) & 3
+			if math.MaxUint32-actualLength < nPadding || math.MaxUint32-actualLength-nPadding < sfntOffset {
+				// both actualLength and sfntOffset can overflow, check for both
+				return nil, ErrInvalidFontData
+			}
+			for k := 0; k < int(nPadding); k++ {
+				tables[j].data = append(tables[j].data, 0x00)
+			}
 
-	// find values for offset table
-	var searchRange uint16 = 1
-	var entrySelector uint16
-	var rangeShift uint16
-	for {
-		if searchRange*2 > numTables {
-			break
+			tableOrder = append(tableOrder, j)
+			tableOffsets[j] = sfntOffset
+			tableLengths[j] = actualLength
+			tableChecksums[j] = calcChecksum(tables[j].data)
+			sfntOffset += uint32(len(tables[j].data))
 		}
-		searchRange *= 2
-		entrySelector++
 	}
-	searchRange *= 16
-	rangeShift = numTables*16 - searchRange
 
-	// write offset table
-	if MaxMemory < totalSfntSize {
+	// write collection header
+	if MaxMemory < totalSfntSize || MaxMemory < sfntOffset {
 		return nil, ErrExceedsMemory
 	}
-	w := parse.NewBinaryWriter(make([]byte, 0, totalSfntSize)) // initial guess, will be bigger
-	w.WriteUint32(flavor)
-	w.WriteUint16(numTables)
-	w.WriteUint16(searchRange)
-	w.WriteUint16(entrySelector)
-	w.WriteUint16(rangeShift)
-
-	// write table record entries, sorted alphabetically
-	sort.Strings(tags)
-	sfntOffset := 12 + 16*uint32(numTables) // can never exceed uint32 as numTables is uint16
-	for _, tag := range tags {
-		i := tagTableIndex[tag]
-		actualLength := uint32(len(tables[i].data))
-
-		// add padding
-		nPadding := (4 - actualLength&3) & 3
-		if math.MaxUint32-actualLength < nPadding || math.MaxUint32-actualLength-nPadding < sfntOffset {
-			// both actualLength and sfntOffset can overflow, check for both
-			return nil, ErrInvalidFontData
+	w := parse.NewBinaryWriter(make([]byte, 0, sfntOffset))
+	if isCollection {
+		w.WriteUint32(flavor)
+		w.WriteUint32(collectionVersion)
+		w.WriteUint32(uint32(len(fonts)))
+		for _, fontOffset := range fontOffsets {
+			w.WriteUint32(fontOffset)
 		}
-		for j := 0; j < int(nPadding); j++ {
-			tables[i].data = append(tables[i].data, 0x00)
+		if collectionVersion == 0x00020000 {
+			w.WriteUint32(0) // dsigTag
+			w.WriteUint32(0) // dsigLength
+			w.WriteUint32(0) // dsigOffset
 		}
+	}
 
-		w.WriteUint32(binary.BigEndian.Uint32([]byte(tables[i].tag)))
-		w.WriteUint32(calcChecksum(tables[i].data))
-		w.WriteUint32(sfntOffset)
-		w.WriteUint32(actualLength)
-		sfntOffset += uint32(len(tables[i].data))
+	checkSumAdjustments := make([]uint32, len(fonts))
+	for i, tags := range fontTags {
+		// find values for offset table
+		numFontTables := uint16(len(tags))
+		var searchRange uint16 = 1
+		var entrySelector uint16
+		var rangeShift uint16
+		for {
+			if searchRange*2 > numFontTables {
+				break
+			}
+			searchRange *= 2
+			entrySelector++
+		}
+		searchRange *= 16
+		rangeShift = numFontTables*16 - searchRange
+
+		// write offset table
+		start := w.Len()
+		w.WriteUint32(fonts[i].flavor)
+		w.WriteUint16(numFontTables)
+		w.WriteUint16(searchRange)
+		w.WriteUint16(entrySelector)
+		w.WriteUint16(rangeShift)
+
+		// write table record entries, sorted alphabetically
+		var checksum uint32
+		for _, tag := range tags {
+			j := fonts[i].tagTableIndex[tag]
+			w.WriteUint32(binary.BigEndian.Uint32([]byte(tag)))
+			w.WriteUint32(tableChecksums[j])
+			w.WriteUint32(tableOffsets[j])
+			w.WriteUint32(tableLengths[j])
+			checksum += tableChecksums[j]
+		}
+		checksum += calcChecksum(w.Bytes()[start:])
+		checkSumAdjustments[i] = 0xB1B0AFBA - checksum
 	}
 
 	// write tables
-	var iCheckSumAdjustment uint32
-	for _, tag := range tags {
-		if tag == "head" {
-			iCheckSumAdjustment = uint32(w.Len()) + 8
-		}
-		table := tables[tagTableIndex[tag]]
-		w.WriteBytes(table.data)
+	for _, i := range tableOrder {
+		w.WriteBytes(tables[i].data)
 	}
 
+	// set checkSumAdjustment, for a head table shared between fonts this uses the first font
 	buf := w.Bytes()
-	checkSumAdjustment := 0xB1B0AFBA - calcChecksum(buf)
-	binary.BigEndian.PutUint32(buf[iCheckSumAdjustment:], checkSumAdjustment)
+	for i := len(fonts) - 1; 0 <= i; i-- {
+		iHead := fonts[i].tagTableIndex["head"]
+		binary.BigEndian.PutUint32(buf[tableOffsets[iHead]+8:], checkSumAdjustments[i])
+	}
 	return buf, nil
 }
 
@@ -338,7 +459,7 @@ func signInt16(flag byte, pos uint) int16 {
 // Remarkable! This code was written on a Sunday evening, and after fixing the compiler errors it worked flawlessly!
 // Edit: oops, there was actually a subtle bug fixed in dx of flag < 120 of simple glyphs.
 func reconstructGlyfLoca(b []byte, origLocaLength uint32) ([]byte, []byte, error) {
-	r := parse.NewBinaryReaderBytes(b)
+	r := newBinaryReader(b)
 	_ = r.ReadUint16() // version
 	optionFlags := r.ReadUint16()
 	numGlyphs := r.ReadUint16()
@@ -355,14 +476,14 @@ func reconstructGlyfLoca(b []byte, origLocaLength uint32) ([]byte, []byte, error
 	}
 
 	bitmapSize := ((uint32(numGlyphs) + 31) >> 5) << 2
-	nContourStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(nContourStreamSize)))
-	nPointsStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(nPointsStreamSize)))
-	flagStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(flagStreamSize)))
-	glyphStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(glyphStreamSize)))
-	compositeStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(compositeStreamSize)))
+	nContourStream := newBinaryReader(r.ReadBytes(int64(nContourStreamSize)))
+	nPointsStream := newBinaryReader(r.ReadBytes(int64(nPointsStreamSize)))
+	flagStream := newBinaryReader(r.ReadBytes(int64(flagStreamSize)))
+	glyphStream := newBinaryReader(r.ReadBytes(int64(glyphStreamSize)))
+	compositeStream := newBinaryReader(r.ReadBytes(int64(compositeStreamSize)))
 	bboxBitmap := parse.NewBitmapReader(r.ReadBytes(int64(bitmapSize)))
-	bboxStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(bboxStreamSize - bitmapSize)))
-	instructionStream := parse.NewBinaryReaderBytes(r.ReadBytes(int64(instructionStreamSize)))
+	bboxStream := newBinaryReader(r.ReadBytes(int64(bboxStreamSize - bitmapSize)))
+	instructionStream := newBinaryReader(r.ReadBytes(int64(instructionStreamSize)))
 	var overlapSimpleBitmap *parse.BitmapReader
 	if optionFlags&0x0001 != 0 { // overlapSimpleBitmap present
 		overlapSimpleBitmap = parse.NewBitmapReader(r.ReadBytes(int64(bitmapSize)))
@@ -430,38 +551,7 @@ func reconstructGlyfLoca(b []byte, origLocaLength uint32) ([]byte, []byte, error
 				onCurve := (flag & 0x80) == 0
 				flag &= 0x7f
 
-				// used for reference: https://github.com/fonttools/fonttools/blob/master/Lib/fontTools/ttLib/woff2.py
-				// as well as: https://github.com/google/woff2/blob/master/src/woff2_dec.cc
-				var dx, dy int16
-				if flag < 10 {
-					coord0 := int16(glyphStream.ReadUint8())
-					dy = signInt16(flag, 0) * (int16(flag&0x0E)<<7 + coord0)
-				} else if flag < 20 {
-					coord0 := int16(glyphStream.ReadUint8())
-					dx = signInt16(flag, 0) * (int16((flag-10)&0x0E)<<7 + coord0)
-				} else if flag < 84 {
-					coord0 := int16(glyphStream.ReadUint8())
-					dx = signInt16(flag, 0) * (1 + int16((flag-20)&0x30) + coord0>>4)
-					dy = signInt16(flag, 1) * (1 + int16((flag-20)&0x0C)<<2 + (coord0 & 0x0F))
-				} else if flag < 120 {
-					coord0 := int16(glyphStream.ReadUint8())
-					coord1 := int16(glyphStream.ReadUint8())
-					dx = signInt16(flag, 0) * (1 + int16((flag-84)/12)<<8 + coord0)
-					dy = signInt16(flag, 1) * (1 + (int16((flag-84)%12)>>2)<<8 + coord1)
-				} else if flag < 124 {
-					coord0 := int16(glyphStream.ReadUint8())
-					coord1 := int16(glyphStream.ReadUint8())
-					coord2 := int16(glyphStream.ReadUint8())
-					dx = signInt16(flag, 0) * (coord0<<4 + coord1>>4)
-					dy = signInt16(flag, 1) * ((coord1&0x0F)<<8 + coord2)
-				} else {
-					coord0 := int16(glyphStream.ReadUint8())
-					coord1 := int16(glyphStream.ReadUint8())
-					coord2 := int16(glyphStream.ReadUint8())
-					coord3 := int16(glyphStream.ReadUint8())
-					dx = signInt16(flag, 0) * (coord0<<8 + coord1)
-					dy = signInt16(flag, 1) * (coord2<<8 + coord3)
-				}
+				dx, dy := readTriplet(glyphStream, flag)
 				xCoordinates = append(xCoordinates, dx)
 				yCoordinates = append(yCoordinates, dy)
 
@@ -619,7 +709,7 @@ func reconstructGlyfLoca(b []byte, origLocaLength uint32) ([]byte, []byte, error
 
 func reconstructHmtx(b, head, glyf, loca, maxp, hhea []byte) ([]byte, error) {
 	// get indexFormat
-	rHead := parse.NewBinaryReaderBytes(head)
+	rHead := newBinaryReader(head)
 	_ = rHead.ReadBytes(50) // skip
 	indexFormat := rHead.ReadInt16()
 	if rHead.Err() == io.EOF {
@@ -627,7 +717,7 @@ func reconstructHmtx(b, head, glyf, loca, maxp, hhea []byte) ([]byte, error) {
 	}
 
 	// get numGlyphs
-	rMaxp := parse.NewBinaryReaderBytes(maxp)
+	rMaxp := newBinaryReader(maxp)
 	_ = rMaxp.ReadUint32() // version
 	numGlyphs := rMaxp.ReadUint16()
 	if rMaxp.Err() == io.EOF {
@@ -635,7 +725,7 @@ func reconstructHmtx(b, head, glyf, loca, maxp, hhea []byte) ([]byte, error) {
 	}
 
 	// get numHMetrics
-	rHhea := parse.NewBinaryReaderBytes(hhea)
+	rHhea := newBinaryReader(hhea)
 	_ = rHhea.ReadBytes(34) // skip all but the last header field
 	numHMetrics := rHhea.ReadUint16()
 	if rHhea.Err() == io.EOF {
@@ -654,9 +744,9 @@ func reconstructHmtx(b, head, glyf, loca, maxp, hhea []byte) ([]byte, error) {
 	if locaLength != uint32(len(loca)) {
 		return nil, ErrInvalidFontData
 	}
-	rLoca := parse.NewBinaryReaderBytes(loca)
+	rLoca := newBinaryReader(loca)
 
-	r := parse.NewBinaryReaderBytes(b)
+	r := newBinaryReader(b)
 	flags := r.ReadUint8() // flags
 	reconstructProportional := flags&0x01 != 0
 	reconstructMonospaced := flags&0x02 != 0
@@ -693,7 +783,7 @@ func reconstructHmtx(b, head, glyf, loca, maxp, hhea []byte) ([]byte, error) {
 	}
 
 	// extract xMin values from glyf table using loca indices
-	rGlyf := parse.NewBinaryReaderBytes(glyf)
+	rGlyf := newBinaryReader(glyf)
 	iGlyphMin := uint16(0)
 	iGlyphMax := numGlyphs
 	if !reconstructProportional {
@@ -780,98 +870,253 @@ func read255Uint16(r *parse.BinaryReader) uint16 {
 	}
 }
 
+// WOFF2Options are the options for writing WOFF2 fonts.
+type WOFF2Options struct {
+	Metadata        *WOFFMetadata // extended metadata block, compressed using Brotli
+	PrivateData     []byte        // private data block
+	Quality         int           // Brotli compression quality from 1 to 11, default is 6
+	Window          int           // Brotli window size as base 2 logarithm from 10 to 24, default depends on the quality
+	NoGlyfTransform bool          // store the glyf and loca tables without transformation, which also disables the hmtx transformation
+	NoHmtxTransform bool          // store the hmtx table without transformation
+	TableOrder      []string      // order of the tables in the font data, other tables follow in alphabetical order which is the default
+}
+
+// WOFF2TableSize is the size of a table in a WOFF2 font.
+type WOFF2TableSize struct {
+	Tag              string
+	Length           uint32 // length in the SFNT font
+	TransformLength  uint32 // length after transformation, equal to Length when not transformed
+	CompressedLength uint32 // length when compressed on its own, since all tables are compressed together this estimates its share of the compressed font data
+}
+
+// WOFF2Report is the size breakdown of a WOFF2 font.
+type WOFF2Report struct {
+	Tables           []WOFF2TableSize // in the order of the font data
+	SfntLength       uint32           // length of the decoded font
+	CompressedLength uint32           // length of the compressed font data
+	Length           uint32           // length of the WOFF2 font
+}
+
+// WriteWOFF2 writes the font to the WOFF2 format. See https://www.w3.org/TR/WOFF2/
 func (sfnt *SFNT) WriteWOFF2() ([]byte, error) {
-	tags := make([]string, 0, len(sfnt.Tables))
-	for tag := range sfnt.Tables {
-		if tag == "DSIG" {
-			continue // exclude DSIG table
-		}
-		tags = append(tags, tag)
-	}
-	// TODO: (WOFF2) loca must follow glyf for TTC
-	sort.Strings(tags)
-
-	w := parse.NewBinaryWriter(make([]byte, 0, sfnt.Length*6/10)) // estimated size
-	w.WriteString("wOF2")                                         // signature
-	w.WriteString(sfnt.Version)                                   // flavor
-	w.WriteUint32(0)                                              // length (set later)
-	w.WriteUint16(uint16(len(tags)))                              // numTables
-	w.WriteUint16(0)                                              // reserved
-	w.WriteUint32(sfnt.Length)                                    // totalSfntSize
-	w.WriteUint32(0)                                              // totalCompressedSize (set later)
-	w.WriteUint16(1)                                              // majorVersion
-	w.WriteUint16(0)                                              // minorVersion
-	w.WriteUint32(0)                                              // metaOffset
-	w.WriteUint32(0)                                              // metaLength
-	w.WriteUint32(0)                                              // metaOrigLength
-	w.WriteUint32(0)                                              // privOffset
-	w.WriteUint32(0)                                              // privLength
-
-	var glyf, hmtx []byte
-	_, hasGlyf := sfnt.Tables["glyf"]
-	_, hasLoca := sfnt.Tables["loca"]
-	_, hasHmtx := sfnt.Tables["hmtx"]
-	if hasGlyf && hasLoca {
-		var xMins []int16
-		glyf, xMins = transformGlyf(sfnt.NumGlyphs(), sfnt.Glyf, sfnt.Loca)
-		if glyf != nil && hasHmtx {
-			hmtx = transformHmtx(sfnt.Hmtx, xMins)
-		}
-	}
-	for _, tag := range tags {
+	return sfnt.WriteWOFF2Options(WOFF2Options{})
+}
+
+// WriteWOFF2Options writes the font to the WOFF2 format including the extended metadata and private data blocks if given. The DSIG table is always removed since the font data is modified.
+func (sfnt *SFNT) WriteWOFF2Options(options WOFF2Options) ([]byte, error) {
+	return writeWOFF2([]*SFNT{sfnt}, false, options, nil)
+}
+
+// WriteWOFF2Report is like WriteWOFF2Options but also returns the size of each table before and after compression.
+func (sfnt *SFNT) WriteWOFF2Report(options WOFF2Options) ([]byte, *WOFF2Report, error) {
+	report := &WOFF2Report{}
+	b, err := writeWOFF2([]*SFNT{sfnt}, false, options, report)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, report, nil
+}
+
+// WriteWOFF2Collection writes the fonts to a WOFF2 font collection, which decodes to a TTC. Tables that are identical between fonts, such as shared glyf and loca tables, are stored only once. The fonts of a TTC can be obtained by parsing each font with ParseSFNT. See https://www.w3.org/TR/WOFF2/#collection_dir_format
+func WriteWOFF2Collection(sfnts []*SFNT, options WOFF2Options) ([]byte, error) {
+	if len(sfnts) == 0 {
+		return nil, fmt.Errorf("collection: must have at least one font")
+	} else if math.MaxUint16 < len(sfnts) {
+		return nil, fmt.Errorf("collection: too many fonts")
+	}
+	return writeWOFF2(sfnts, true, options, nil)
+}
+
+func writeWOFF2(sfnts []*SFNT, isCollection bool, options WOFF2Options, report *WOFF2Report) ([]byte, error) {
+	quality := options.Quality
+	if quality == 0 {
+		quality = brotli.DefaultCompression
+	} else if quality < brotli.BestSpeed || brotli.BestCompression < quality {
+		return nil, fmt.Errorf("invalid Brotli quality %d", quality)
+	}
+	if options.Window != 0 && (options.Window < 10 || 24 < options.Window) {
+		return nil, fmt.Errorf("invalid Brotli window %d", options.Window)
+	}
+
+	// list tables of all fonts, tables are stored once when both the original and the transformed data are identical
+	tables := []woff2Table{}
+	origTables := [][]byte{}
+	fontTables := make([][]int, len(sfnts))
+	addTable := func(tag string, orig, data []byte, transformVersion int) int {
+		for i := range tables {
+			if tables[i].tag == tag && tables[i].transformVersion == transformVersion && bytes.Equal(origTables[i], orig) && bytes.Equal(tables[i].data, data) {
+				return i
+			}
+		}
+		var transformLength uint32
+		if (tag == "glyf" || tag == "loca") && transformVersion == 0 || tag == "hmtx" && transformVersion == 1 {
+			transformLength = uint32(len(data))
+		}
+		tables = append(tables, woff2Table{
+			tag:              tag,
+			origLength:       uint32(len(orig)),
+			transformVersion: transformVersion,
+			transformLength:  transformLength,
+			data:             data,
+		})
+		origTables = append(origTables, orig)
+		return len(tables) - 1
+	}
+	for i, sfnt := range sfnts {
+		tags := make([]string, 0, len(sfnt.Tables))
+		for tag := range sfnt.Tables {
+			if tag == "DSIG" {
+				continue // exclude DSIG table
+			}
+			tags = append(tags, tag)
+		}
+		sortWOFF2Tags(tags, options.TableOrder)
+
+		var glyf, hmtx []byte
+		_, hasGlyf := sfnt.Tables["glyf"]
+		_, hasLoca := sfnt.Tables["loca"]
+		_, hasHmtx := sfnt.Tables["hmtx"]
+		if hasGlyf && hasLoca && !options.NoGlyfTransform {
+			var xMins []int16
+			glyf, xMins = transformGlyf(sfnt.NumGlyphs(), sfnt.Glyf, sfnt.Loca)
+			if glyf != nil && hasHmtx && !options.NoHmtxTransform {
+				hmtx = transformHmtx(sfnt.Hmtx, xMins)
+			}
+		}
+
+		glyfTransformVersion := 0
+		if glyf == nil {
+			glyfTransformVersion = 3
+		}
+		for _, tag := range tags {
+			table := sfnt.Tables[tag]
+			if isCollection && hasGlyf && hasLoca && (tag == "glyf" || tag == "loca") {
+				// loca must directly follow glyf for collections, and they can only be shared together
+				if tag == "glyf" {
+					loca := sfnt.Tables["loca"]
+					iGlyf := len(tables)
+					for j := 0; j+1 < len(tables); j++ {
+						if tables[j].tag == "glyf" && tables[j].transformVersion == glyfTransformVersion && bytes.Equal(origTables[j], table) && bytes.Equal(origTables[j+1], loca) {
+							iGlyf = j
+							break
+						}
+					}
+					if iGlyf == len(tables) {
+						glyfTable := woff2Table{tag: "glyf", origLength: uint32(len(table)), transformVersion: 3, data: table}
+						locaTable := woff2Table{tag: "loca", origLength: uint32(len(loca)), transformVersion: 3, data: loca}
+						if glyf != nil {
+							glyfTable.transformVersion, glyfTable.transformLength, glyfTable.data = 0, uint32(len(glyf)), glyf
+							locaTable.transformVersion, locaTable.data = 0, nil
+						}
+						tables = append(tables, glyfTable, locaTable)
+						origTables = append(origTables, table, loca)
+					}
+					fontTables[i] = append(fontTables[i], iGlyf, iGlyf+1)
+				}
+				continue
+			}
+
+			transformVersion := 0
+			data := table
+			if tag == "head" {
+				data = make([]byte, len(table))
+				copy(data, table)
+				flags := binary.BigEndian.Uint16(data[16:])
+				flags |= 0x0800 // set bit 11, font is compressed
+				binary.BigEndian.PutUint16(data[16:], flags)
+			} else if tag == "glyf" || tag == "loca" {
+				transformVersion = glyfTransformVersion
+				if glyf != nil && tag == "glyf" {
+					data = glyf
+				} else if glyf != nil && tag == "loca" {
+					data = nil
+				}
+			} else if hmtx != nil && tag == "hmtx" {
+				transformVersion = 1
+				data = hmtx
+			}
+			fontTables[i] = append(fontTables[i], addTable(tag, table, data, transformVersion))
+		}
+	}
+	if math.MaxUint16 < len(tables) {
+		return nil, fmt.Errorf("too many tables")
+	}
+
+	// calculate size of decoded font
+	totalSfntSize := sfnts[0].Length
+	if isCollection {
+		size := 12 + 4*uint64(len(sfnts))
+		for i := range sfnts {
+			size += 12 + 16*uint64(len(fontTables[i]))
+		}
+		for i := range tables {
+			size += (uint64(tables[i].origLength) + 3) &^ 3
+		}
+		if math.MaxUint32 < size {
+			return nil, fmt.Errorf("collection: too large")
+		}
+		totalSfntSize = uint32(size)
+	}
+
+	flavor := sfnts[0].Version
+	if isCollection {
+		flavor = "ttcf"
+	}
+	w := parse.NewBinaryWriter(make([]byte, 0, totalSfntSize*6/10)) // estimated size
+	w.WriteString("wOF2")                                           // signature
+	w.WriteString(flavor)                                           // flavor
+	w.WriteUint32(0)                                                // length (set later)
+	w.WriteUint16(uint16(len(tables)))                              // numTables
+	w.WriteUint16(0)                                                // reserved
+	w.WriteUint32(totalSfntSize)                                    // totalSfntSize
+	w.WriteUint32(0)                                                // totalCompressedSize (set later)
+	w.WriteUint16(1)                                                // majorVersion
+	w.WriteUint16(0)                                                // minorVersion
+	w.WriteUint32(0)                                                // metaOffset
+	w.WriteUint32(0)                                                // metaLength
+	w.WriteUint32(0)                                                // metaOrigLength
+	w.WriteUint32(0)                                                // privOffset
+	w.WriteUint32(0)                                                // privLength
+
+	for _, table := range tables {
 		tagIndex := -1
 		for index, woff2Tag := range woff2TableTags {
-			if woff2Tag == tag {
+			if woff2Tag == table.tag {
 				tagIndex = index
 				break
 			}
 		}
 
-		transformVersion := 0
-		if glyf == nil && (tag == "glyf" || tag == "loca") {
-			transformVersion = 3
-		} else if hmtx != nil && tag == "hmtx" {
-			transformVersion = 1
-		}
-		w.WriteUint8(byte(transformVersion)<<6 | byte(tagIndex)&0x3F) // flags
+		w.WriteUint8(byte(table.transformVersion)<<6 | byte(tagIndex)&0x3F) // flags
 		if tagIndex == -1 {
-			w.WriteString(tag) // tag
+			w.WriteString(table.tag) // tag
 		}
-		writeUintBase128(w, uint32(len(sfnt.Tables[tag])))
-		if glyf != nil && tag == "glyf" {
-			writeUintBase128(w, uint32(len(glyf)))
-		} else if glyf != nil && tag == "loca" {
-			writeUintBase128(w, 0)
-		} else if hmtx != nil && tag == "hmtx" {
-			writeUintBase128(w, uint32(len(hmtx)))
+		writeUintBase128(w, table.origLength)
+		if (table.tag == "glyf" || table.tag == "loca") && table.transformVersion == 0 || table.tag == "hmtx" && table.transformVersion == 1 {
+			writeUintBase128(w, table.transformLength)
 		}
 	}
 
-	if sfnt.Version == "ttcf" {
-		// TODO: (WOFF2) support TTC
-		w.WriteUint32(0)     // version
-		write255Uint16(w, 0) // numFonts
+	if isCollection {
+		w.WriteUint32(0x00010000) // version
+		write255Uint16(w, uint16(len(sfnts)))
+		for i, sfnt := range sfnts {
+			write255Uint16(w, uint16(len(fontTables[i])))
+			w.WriteString(sfnt.Version) // flavor
+			for _, index := range fontTables[i] {
+				write255Uint16(w, uint16(index))
+			}
+		}
 	}
 
+	brotliOptions := brotli.WriterOptions{
+		Quality: quality,
+		LGWin:   options.Window,
+	}
 	headerLength := w.Len()
-	wBrotli := brotli.NewWriter(w)
-	for _, tag := range tags {
-		table := sfnt.Tables[tag]
-		if tag == "head" {
-			head := make([]byte, len(table))
-			copy(head, table)
-			flags := binary.BigEndian.Uint16(head[16:])
-			flags |= 0x0800 // set bit 11, font is compressed
-			binary.BigEndian.PutUint16(head[16:], flags)
-			table = head
-		} else if glyf != nil && tag == "glyf" {
-			table = glyf
-		} else if glyf != nil && tag == "loca" {
-			continue
-		} else if hmtx != nil && tag == "hmtx" {
-			table = hmtx
-		}
-		if _, err := wBrotli.Write(table); err != nil {
+	wBrotli := brotli.NewWriterOptions(w, brotliOptions)
+	for _, table := range tables {
+		if _, err := wBrotli.Write(table.data); err != nil {
 			return nil, err
 		}
 	}
@@ -887,12 +1132,87 @@ func (sfnt *SFNT) WriteWOFF2() ([]byte, error) {
 		w.WriteByte(0)
 	}
 
+	if options.Metadata != nil {
+		metadata, err := writeWOFFMetadata(options.Metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		wBrotli := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		if _, err := wBrotli.Write(metadata); err != nil {
+			return nil, fmt.Errorf("metadata: %v", err)
+		} else if err := wBrotli.Close(); err != nil {
+			return nil, fmt.Errorf("metadata: %v", err)
+		}
+		writeWOFFBlocks(w, 28, buf.Bytes(), uint32(len(metadata)), options.PrivateData)
+	} else {
+		writeWOFFBlocks(w, 28, nil, 0, options.PrivateData)
+	}
+
 	b := w.Bytes()
 	binary.BigEndian.PutUint32(b[8:], uint32(len(b)))               // length
 	binary.BigEndian.PutUint32(b[20:], uint32(totalCompressedSize)) // totalCompressedSize
+
+	if report != nil {
+		report.Tables = make([]WOFF2TableSize, 0, len(tables))
+		for _, table := range tables {
+			var buf bytes.Buffer
+			if len(table.data) != 0 { // transformed loca is reconstructed from glyf
+				wBrotli := brotli.NewWriterOptions(&buf, brotliOptions)
+				if _, err := wBrotli.Write(table.data); err != nil {
+					return nil, err
+				} else if err := wBrotli.Close(); err != nil {
+					return nil, err
+				}
+			}
+			report.Tables = append(report.Tables, WOFF2TableSize{
+				Tag:              table.tag,
+				Length:           table.origLength,
+				TransformLength:  uint32(len(table.data)),
+				CompressedLength: uint32(buf.Len()),
+			})
+		}
+		report.SfntLength = totalSfntSize
+		report.CompressedLength = uint32(totalCompressedSize)
+		report.Length = uint32(len(b))
+	}
 	return b, nil
 }
 
+// sortWOFF2Tags sorts the tags in the given order, followed by the remaining tags in alphabetical order. The loca table is moved directly after the glyf table if it came before.
+func sortWOFF2Tags(tags, order []string) {
+	index := make(map[string]int, len(order))
+	for i, tag := range order {
+		if _, ok := index[tag]; !ok {
+			index[tag] = i
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		iOrder, iOk := index[tags[i]]
+		jOrder, jOk := index[tags[j]]
+		if iOk && jOk {
+			return iOrder < jOrder
+		} else if iOk != jOk {
+			return iOk
+		}
+		return tags[i] < tags[j]
+	})
+
+	iGlyf, iLoca := -1, -1
+	for i, tag := range tags {
+		if tag == "glyf" {
+			iGlyf = i
+		} else if tag == "loca" {
+			iLoca = i
+		}
+	}
+	if iLoca != -1 && iLoca < iGlyf {
+		copy(tags[iLoca:], tags[iLoca+1:iGlyf+1])
+		tags[iGlyf] = "loca"
+	}
+}
+
 func transformGlyf(numGlyphs uint16, glyf *glyfTable, loca *locaTable) ([]byte, []int16) {
 	bitmapSize := ((uint32(numGlyphs) + 31) >> 5) << 2
 	nContourStream := parse.NewBinaryWriter([]byte{})
@@ -943,54 +1263,7 @@ func transformGlyf(numGlyphs uint16, glyf *glyfTable, loca *locaTable) ([]byte,
 					dx -= contour.XCoordinates[i-1]
 					dy -= contour.YCoordinates[i-1]
 				}
-				dxSign, dySign := byte(1), byte(1)
-				if dx < 0 {
-					dxSign = 0
-					dx = -dx
-				}
-				if dy < 0 {
-					dySign = 0
-					dy = -dy
-				}
-
-				var flag byte
-				if dx == 0 && dy < 1280 {
-					// also dx==0 and dy==0
-					delta := dy >> 8
-					flag = byte(delta<<1) + dySign
-					glyphStream.WriteByte(byte(dy - (delta << 8)))
-				} else if dx < 1280 && dy == 0 {
-					delta := dx >> 8
-					flag = 10 + byte(delta<<1) + dxSign
-					glyphStream.WriteByte(byte(dx - (delta << 8)))
-				} else if dx < 65 && dy < 65 {
-					deltax := (dx - 1) >> 4
-					deltay := (dy - 1) >> 4
-					flag = 20 + byte(deltax<<4) + byte(deltay<<2) + (dySign << 1) + dxSign
-					glyphStream.WriteByte(byte(dx-1-(deltax<<4))<<4 | byte(dy-1-(deltay<<4)))
-				} else if dx < 769 && dy < 769 {
-					deltax := (dx - 1) >> 8
-					deltay := (dy - 1) >> 8
-					flag = 84 + byte(deltax<<2)*3 + byte(deltay<<2) + (dySign << 1) + dxSign
-					glyphStream.WriteByte(byte(dx - 1 - (deltax << 8)))
-					glyphStream.WriteByte(byte(dy - 1 - (deltay << 8)))
-				} else if dx < 4096 && dy < 4096 {
-					flag = 120 + (dySign << 1) + dxSign
-					glyphStream.WriteByte(byte(dx & 0x0FF0 >> 4))
-					glyphStream.WriteByte(byte(dx&0x000F)<<4 | byte(dy&0x0F00>>8))
-					glyphStream.WriteByte(byte(dy & 0x00FF))
-				} else {
-					flag = 124 + (dySign << 1) + dxSign
-					glyphStream.WriteInt16(dx)
-					glyphStream.WriteInt16(dy)
-				}
-				if dxSign == 0 {
-					dx = -dx
-				}
-				if dySign == 0 {
-					dy = -dy
-				}
-
+				flag := writeTriplet(glyphStream, dx, dy)
 				if !contour.OnCurve[i] {
 					flag |= 0x80
 				}
@@ -1012,29 +1285,27 @@ func transformGlyf(numGlyphs uint16, glyf *glyfTable, loca *locaTable) ([]byte,
 					xMax = x
 				}
 			}
-			if xMin == contour.XMin && xMax == contour.XMax {
-				for _, y := range contour.YCoordinates[1:] {
-					if y < yMin {
-						yMin = y
-					}
-					if yMax < y {
-						yMax = y
-					}
+			for _, y := range contour.YCoordinates[1:] {
+				if y < yMin {
+					yMin = y
 				}
-				if yMin == contour.YMin && yMax == contour.YMax {
-					bboxEqual = true
-				} else {
-					xMin, xMax = contour.XMin, contour.XMax
-					yMin, yMax = contour.YMin, contour.YMax
+				if yMax < y {
+					yMax = y
 				}
 			}
+			if xMin == contour.XMin && xMax == contour.XMax && yMin == contour.YMin && yMax == contour.YMax {
+				bboxEqual = true
+			} else {
+				xMin, xMax = contour.XMin, contour.XMax
+				yMin, yMax = contour.YMin, contour.YMax
+			}
 
 			// instruction stream
 			write255Uint16(glyphStream, uint16(len(contour.Instructions)))
 			instructionStream.WriteBytes(contour.Instructions)
 		} else {
 			// composite glyph
-			r := parse.NewBinaryReaderBytes(glyf.Get(uint16(glyphID)))
+			r := newBinaryReader(glyf.Get(uint16(glyphID)))
 			_ = r.ReadInt16() // numberOfContours
 			xMin = r.ReadInt16()
 			yMin = r.ReadInt16()
@@ -1060,7 +1331,7 @@ func transformGlyf(numGlyphs uint16, glyf *glyfTable, loca *locaTable) ([]byte,
 			if hasInstructions {
 				instructionLength := r.ReadUint16()
 				write255Uint16(glyphStream, instructionLength)
-				glyphStream.WriteBytes(r.ReadBytes(int64(instructionLength)))
+				instructionStream.WriteBytes(r.ReadBytes(int64(instructionLength)))
 			}
 		}
 
@@ -1161,6 +1432,89 @@ func transformHmtx(hmtx *hmtxTable, xMins []int16) []byte {
 	return w.Bytes()
 }
 
+// readTriplet reads the coordinate deltas of a point using the triplet encoding, where flag has the on-curve bit cleared. See https://www.w3.org/TR/WOFF2/#triplet_decoding
+func readTriplet(r *parse.BinaryReader, flag byte) (int16, int16) {
+	// used for reference: https://github.com/fonttools/fonttools/blob/master/Lib/fontTools/ttLib/woff2.py
+	// as well as: https://github.com/google/woff2/blob/master/src/woff2_dec.cc
+	var dx, dy int16
+	if flag < 10 {
+		coord0 := int16(r.ReadUint8())
+		dy = signInt16(flag, 0) * (int16(flag&0x0E)<<7 + coord0)
+	} else if flag < 20 {
+		coord0 := int16(r.ReadUint8())
+		dx = signInt16(flag, 0) * (int16((flag-10)&0x0E)<<7 + coord0)
+	} else if flag < 84 {
+		coord0 := int16(r.ReadUint8())
+		dx = signInt16(flag, 0) * (1 + int16((flag-20)&0x30) + coord0>>4)
+		dy = signInt16(flag, 1) * (1 + int16((flag-20)&0x0C)<<2 + (coord0 & 0x0F))
+	} else if flag < 120 {
+		coord0 := int16(r.ReadUint8())
+		coord1 := int16(r.ReadUint8())
+		dx = signInt16(flag, 0) * (1 + int16((flag-84)/12)<<8 + coord0)
+		dy = signInt16(flag, 1) * (1 + (int16((flag-84)%12)>>2)<<8 + coord1)
+	} else if flag < 124 {
+		coord0 := int16(r.ReadUint8())
+		coord1 := int16(r.ReadUint8())
+		coord2 := int16(r.ReadUint8())
+		dx = signInt16(flag, 0) * (coord0<<4 + coord1>>4)
+		dy = signInt16(flag, 1) * ((coord1&0x0F)<<8 + coord2)
+	} else {
+		coord0 := int16(r.ReadUint8())
+		coord1 := int16(r.ReadUint8())
+		coord2 := int16(r.ReadUint8())
+		coord3 := int16(r.ReadUint8())
+		dx = signInt16(flag, 0) * (coord0<<8 + coord1)
+		dy = signInt16(flag, 1) * (coord2<<8 + coord3)
+	}
+	return dx, dy
+}
+
+// writeTriplet writes the coordinate deltas of a point using the triplet encoding and returns the flag without the on-curve bit. See https://www.w3.org/TR/WOFF2/#triplet_decoding
+func writeTriplet(w *parse.BinaryWriter, dx, dy int16) byte {
+	dxSign, dySign := byte(1), byte(1)
+	if dx < 0 {
+		dxSign = 0
+		dx = -dx
+	}
+	if dy < 0 {
+		dySign = 0
+		dy = -dy
+	}
+
+	var flag byte
+	if dx == 0 && dy < 1280 {
+		// also dx==0 and dy==0
+		delta := dy >> 8
+		flag = byte(delta<<1) + dySign
+		w.WriteByte(byte(dy - (delta << 8)))
+	} else if dx < 1280 && dy == 0 {
+		delta := dx >> 8
+		flag = 10 + byte(delta<<1) + dxSign
+		w.WriteByte(byte(dx - (delta << 8)))
+	} else if dx < 65 && dy < 65 {
+		deltax := (dx - 1) >> 4
+		deltay := (dy - 1) >> 4
+		flag = 20 + byte(deltax<<4) + byte(deltay<<2) + (dySign << 1) + dxSign
+		w.WriteByte(byte(dx-1-(deltax<<4))<<4 | byte(dy-1-(deltay<<4)))
+	} else if dx < 769 && dy < 769 {
+		deltax := (dx - 1) >> 8
+		deltay := (dy - 1) >> 8
+		flag = 84 + byte(deltax<<2)*3 + byte(deltay<<2) + (dySign << 1) + dxSign
+		w.WriteByte(byte(dx - 1 - (deltax << 8)))
+		w.WriteByte(byte(dy - 1 - (deltay << 8)))
+	} else if dx < 4096 && dy < 4096 {
+		flag = 120 + (dySign << 1) + dxSign
+		w.WriteByte(byte(dx & 0x0FF0 >> 4))
+		w.WriteByte(byte(dx&0x000F)<<4 | byte(dy&0x0F00>>8))
+		w.WriteByte(byte(dy & 0x00FF))
+	} else {
+		flag = 124 + (dySign << 1) + dxSign
+		w.WriteInt16(dx)
+		w.WriteInt16(dy)
+	}
+	return flag
+}
+
 func writeUintBase128(w *parse.BinaryWriter, accum uint32) {
 	// see https://www.w3.org/TR/WOFF2/#DataTypes
 	if accum == 0 {
@@ -1,7 +1,7 @@
 package font
 
 import (
-	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"testing"
@@ -101,10 +101,10 @@ func TestWOFF2ValidationDecoder(t *testing.T) {
 
 func TestWOFF2ValidationDecoderRoundtrip(t *testing.T) {
 	filenames := []string{
-		//"roundtrip-collection-dsig-001",
-		//"roundtrip-collection-order-001",
+		"roundtrip-collection-dsig-001",
+		"roundtrip-collection-order-001",
 		//"roundtrip-hmtx-lsb-001", // the woff2 test file seems to be broken, advanceWidth is in reverse order
-		//"roundtrip-offset-tables-001",
+		"roundtrip-offset-tables-001",
 	}
 	for _, filename := range filenames {
 		t.Run(filename, func(t *testing.T) {
@@ -114,11 +114,165 @@ func TestWOFF2ValidationDecoderRoundtrip(t *testing.T) {
 			test.Error(t, err)
 			b, err = ParseWOFF2(b)
 			test.Error(t, err)
-			if !bytes.Equal(a, b) {
-				test.Fail(t, "decoded WOFF2 unequal to TTF")
+
+			// glyf is reconstructed without compacting the coordinates, compare fonts by their tables and glyphs
+			numFonts := 1
+			if string(a[:4]) == "ttcf" {
+				numFonts = int(binary.BigEndian.Uint32(a[8:]))
+				test.T(t, binary.BigEndian.Uint32(b[8:]), uint32(numFonts), "numFonts")
+			}
+			for index := 0; index < numFonts; index++ {
+				sfntA, err := ParseSFNT(a, index)
+				test.Error(t, err)
+				sfntB, err := ParseSFNT(b, index)
+				test.Error(t, err)
+				testWOFF2Equal(t, sfntB, sfntA, "hmtx") // hmtx has reversed advance widths in all test files
+			}
+		})
+	}
+}
+
+func TestWOFF2GlyfTransform(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// composite glyph with instructions
+	glyphID := sfnt.GlyphIndex('\u0132') // IJ
+	test.That(t, sfnt.Glyf.IsComposite(glyphID))
+	contour, err := sfnt.Glyf.Contour(glyphID)
+	test.Error(t, err)
+	test.That(t, 0 < len(contour.Instructions))
+
+	// simple glyph with an explicit bounding box that differs only vertically from the computed one
+	glyphID2 := sfnt.GlyphIndex('I')
+	glyph := sfnt.Glyf.Get(glyphID2)
+	binary.BigEndian.PutUint16(glyph[8:], binary.BigEndian.Uint16(glyph[8:])+10) // yMax
+
+	b, err = sfnt.WriteWOFF2()
+	test.Error(t, err)
+	b, err = ParseWOFF2(b)
+	test.Error(t, err)
+	sfnt2, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+	testWOFF2Equal(t, sfnt2, sfnt)
+}
+
+func TestWOFF2Collection(t *testing.T) {
+	sfnts := []*SFNT{}
+	b, err := ioutil.ReadFile("testdata/woff2_decoder/roundtrip-collection-order-001.ttf")
+	test.Error(t, err)
+	for index := 0; index < 3; index++ {
+		sfnt, err := ParseSFNT(b, index)
+		test.Error(t, err)
+		sfnts = append(sfnts, sfnt)
+	}
+	b, err = ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+	sfnts = append(sfnts, sfnt)
+
+	b, err = WriteWOFF2Collection(sfnts, WOFF2Options{})
+	test.Error(t, err)
+	test.T(t, string(b[4:8]), "ttcf")
+	test.T(t, binary.BigEndian.Uint16(b[12:]), uint16(13+len(sfnt.Tables))) // the fonts in the test file only have different name tables
+
+	b, err = ParseWOFF2(b)
+	test.Error(t, err)
+	for index, sfnt := range sfnts {
+		sfnt2, err := ParseSFNT(b, index)
+		test.Error(t, err)
+		testWOFF2Equal(t, sfnt2, sfnt)
+	}
+}
+
+func TestWOFF2Options(t *testing.T) {
+	b, err := ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	var tests = []struct {
+		name    string
+		options WOFF2Options
+		tags    []string
+	}{
+		{"default", WOFF2Options{}, []string{"FFTM", "GDEF", "GPOS"}},
+		{"quality", WOFF2Options{Quality: 1, Window: 10}, []string{"FFTM", "GDEF", "GPOS"}},
+		{"no glyf transform", WOFF2Options{NoGlyfTransform: true}, []string{"FFTM", "GDEF", "GPOS"}},
+		{"no hmtx transform", WOFF2Options{NoHmtxTransform: true}, []string{"FFTM", "GDEF", "GPOS"}},
+		{"order", WOFF2Options{TableOrder: []string{"loca", "cmap", "head"}}, []string{"cmap", "head", "FFTM"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, report, err := sfnt.WriteWOFF2Report(tt.options)
+			test.Error(t, err)
+			test.T(t, report.Length, uint32(len(b)))
+			test.T(t, report.SfntLength, binary.BigEndian.Uint32(b[16:]))
+			test.T(t, report.CompressedLength, binary.BigEndian.Uint32(b[20:]))
+			test.T(t, len(report.Tables), len(sfnt.Tables))
+			for i, tag := range tt.tags {
+				test.T(t, report.Tables[i].Tag, tag)
 			}
+
+			iGlyf := -1
+			for i, table := range report.Tables {
+				test.T(t, table.Length, uint32(len(sfnt.Tables[table.Tag])), table.Tag)
+				if table.Tag == "glyf" {
+					iGlyf = i
+					test.T(t, table.TransformLength != table.Length, !tt.options.NoGlyfTransform, "glyf transformed")
+				} else if table.Tag == "loca" {
+					test.That(t, iGlyf != -1 && iGlyf < i, "loca after glyf")
+				} else if table.Tag == "hmtx" {
+					test.T(t, table.TransformLength != table.Length, !tt.options.NoGlyfTransform && !tt.options.NoHmtxTransform, "hmtx transformed")
+				}
+			}
+
+			b, err = ParseWOFF2(b)
+			test.Error(t, err)
+			sfnt2, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+			testWOFF2Equal(t, sfnt2, sfnt)
 		})
 	}
+
+	_, err = sfnt.WriteWOFF2Options(WOFF2Options{Quality: 12})
+	test.That(t, err != nil, "bad quality")
+	_, err = sfnt.WriteWOFF2Options(WOFF2Options{Window: 25})
+	test.That(t, err != nil, "bad window")
+}
+
+func testWOFF2Equal(t *testing.T, sfnt, sfntOrig *SFNT, skip ...string) {
+	test.T(t, len(sfnt.Tables), len(sfntOrig.Tables), "number of tables")
+	for tag, table := range sfntOrig.Tables {
+		if tag == "glyf" || tag == "loca" {
+			continue // compared per glyph below
+		} else if tag == "head" {
+			// ignore checkSumAdjustment and bit 11 in flags that is set by WOFF2 encoders
+			table = append([]byte{}, table...)
+			binary.BigEndian.PutUint32(table[8:], 0)
+			binary.BigEndian.PutUint32(sfnt.Tables[tag][8:], 0)
+			table[16] |= 0x08
+		}
+		for _, skipTag := range skip {
+			if tag == skipTag {
+				table = sfnt.Tables[tag]
+			}
+		}
+		test.T(t, sfnt.Tables[tag], table, "table", tag)
+	}
+	test.T(t, sfnt.NumGlyphs(), sfntOrig.NumGlyphs())
+	if sfntOrig.Glyf != nil {
+		for glyphID := uint16(0); glyphID < sfntOrig.NumGlyphs(); glyphID++ {
+			contour, err := sfnt.Glyf.Contour(glyphID)
+			test.Error(t, err)
+			contourOrig, err := sfntOrig.Glyf.Contour(glyphID)
+			test.Error(t, err)
+			test.T(t, contour, contourOrig, "glyph", glyphID)
+		}
+	}
 }
 
 func TestWOFF2ValidationFormat(t *testing.T) {
@@ -141,22 +295,22 @@ func TestWOFF2ValidationFormat(t *testing.T) {
 		{"header-length-002", "length in header must match file size"},
 		{"header-numTables-001", "numTables in header must not be zero"},
 		{"header-reserved-001", "reserved in header must be zero"},
-		//{"blocks-extraneous-data-001", "err"},
-		//{"blocks-extraneous-data-002", "err"},
-		//{"blocks-extraneous-data-003", "err"},
-		//{"blocks-extraneous-data-004", "err"},
-		//{"blocks-extraneous-data-005", "err"},
-		//{"blocks-extraneous-data-006", "err"},
-		//{"blocks-extraneous-data-007", "err"},
-		//{"blocks-metadata-absent-002", "err"},
-		//{"blocks-metadata-padding-001", "err"},
-		//{"blocks-metadata-padding-002", "err"},
-		//{"blocks-metadata-padding-003", "err"},
-		//{"blocks-metadata-padding-004", "err"},
-		//{"blocks-ordering-003", "err"},
-		//{"blocks-ordering-004", "err"},
-		//{"blocks-private-001", "err"},
-		//{"blocks-private-002", "err"},
+		{"blocks-extraneous-data-001", "extraneous data at the end of the file"},
+		{"blocks-extraneous-data-002", "extraneous data at the end of the file"},
+		{"blocks-extraneous-data-003", "extraneous data before metadata"},
+		{"blocks-extraneous-data-004", "extraneous data before private data"},
+		{"blocks-extraneous-data-005", "extraneous data before private data"},
+		{"blocks-extraneous-data-006", "extraneous data at the end of the file"},
+		{"blocks-extraneous-data-007", "extraneous data at the end of the file"},
+		{"blocks-metadata-absent-002", "metaOffset and metaLength must both be zero or non-zero"},
+		{"blocks-metadata-padding-001", "extraneous data at the end of the file"},
+		{"blocks-metadata-padding-002", ""},
+		{"blocks-metadata-padding-003", ""},
+		{"blocks-metadata-padding-004", "length in header must match file size"},
+		{"blocks-ordering-003", "extraneous data before metadata"},
+		{"blocks-ordering-004", "extraneous data before metadata"},
+		{"blocks-private-001", "private data must start on a 4-byte boundary"},
+		{"blocks-private-002", "extraneous data at the end of the file"},
 		{"directory-table-order-001", ""},
 		{"directory-table-order-002", "loca: must come after glyf table"},
 		{"tabledata-extraneous-data-001", "sum of table lengths must match decompressed font data size"},
@@ -0,0 +1,486 @@
+package font
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// WOFFMetadata is the extended metadata block of WOFF and WOFF2 fonts. See https://www.w3.org/TR/WOFF/#Metadata
+type WOFFMetadata struct {
+	XMLName     xml.Name         `xml:"metadata"`
+	Version     string           `xml:"version,attr"`
+	UniqueID    *WOFFUniqueID    `xml:"uniqueid"`
+	Vendor      *WOFFVendor      `xml:"vendor"`
+	Credits     *WOFFCredits     `xml:"credits"`
+	Description *WOFFDescription `xml:"description"`
+	License     *WOFFLicense     `xml:"license"`
+	Copyright   *WOFFTexts       `xml:"copyright"`
+	Trademark   *WOFFTexts       `xml:"trademark"`
+	Licensee    *WOFFLicensee    `xml:"licensee"`
+	Extensions  []WOFFExtension  `xml:"extension"`
+}
+
+// WOFFUniqueID is a unique identifier for the font.
+type WOFFUniqueID struct {
+	ID string `xml:"id,attr"`
+}
+
+// WOFFVendor is the font vendor.
+type WOFFVendor struct {
+	Name  string `xml:"name,attr"`
+	URL   string `xml:"url,attr,omitempty"`
+	Dir   string `xml:"dir,attr,omitempty"`
+	Class string `xml:"class,attr,omitempty"`
+}
+
+// WOFFCredits is the list of contributors to the font.
+type WOFFCredits struct {
+	Credits []WOFFCredit `xml:"credit"`
+}
+
+// WOFFCredit is a contributor to the font.
+type WOFFCredit struct {
+	Name  string `xml:"name,attr"`
+	URL   string `xml:"url,attr,omitempty"`
+	Role  string `xml:"role,attr,omitempty"`
+	Dir   string `xml:"dir,attr,omitempty"`
+	Class string `xml:"class,attr,omitempty"`
+}
+
+// WOFFDescription is a description of the font.
+type WOFFDescription struct {
+	URL   string     `xml:"url,attr,omitempty"`
+	Texts []WOFFText `xml:"text"`
+}
+
+// WOFFLicense is the license of the font, URL usually points to the full license text.
+type WOFFLicense struct {
+	URL   string     `xml:"url,attr,omitempty"`
+	ID    string     `xml:"id,attr,omitempty"`
+	Texts []WOFFText `xml:"text"`
+}
+
+// WOFFTexts is a text in one or more languages, used for the copyright and trademark.
+type WOFFTexts struct {
+	Texts []WOFFText `xml:"text"`
+}
+
+// WOFFLicensee is the name of the licensee of the font.
+type WOFFLicensee struct {
+	Name  string `xml:"name,attr"`
+	Dir   string `xml:"dir,attr,omitempty"`
+	Class string `xml:"class,attr,omitempty"`
+}
+
+// WOFFExtension holds vendor specific metadata as name-value items.
+type WOFFExtension struct {
+	ID    string              `xml:"id,attr,omitempty"`
+	Names []WOFFText          `xml:"name"`
+	Items []WOFFExtensionItem `xml:"item"`
+}
+
+// WOFFExtensionItem is a single name-value item of an extension.
+type WOFFExtensionItem struct {
+	ID     string     `xml:"id,attr,omitempty"`
+	Names  []WOFFText `xml:"name"`
+	Values []WOFFText `xml:"value"`
+}
+
+// WOFFText is a text in a specific language. Content is the raw XML content, which for text elements may contain div and span elements. Lang is parsed from either the xml:lang or the lang attribute.
+type WOFFText struct {
+	Lang    string `xml:"lang,attr,omitempty"`
+	Dir     string `xml:"dir,attr,omitempty"`
+	Class   string `xml:"class,attr,omitempty"`
+	Content string `xml:",innerxml"`
+}
+
+// String returns the text content without markup.
+func (text WOFFText) String() string {
+	sb := strings.Builder{}
+	dec := xml.NewDecoder(strings.NewReader(text.Content))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		} else if data, ok := tok.(xml.CharData); ok {
+			sb.Write(data)
+		}
+	}
+	return sb.String()
+}
+
+// ParseWOFFMetadata parses the extended metadata block and the private data block of the WOFF or WOFF2 font format. Both return values are nil when the block is absent. The metadata is validated against the schema of the specification. See https://www.w3.org/TR/WOFF/#Metadata
+func ParseWOFFMetadata(b []byte) (*WOFFMetadata, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, ErrInvalidFontData
+	}
+
+	var err error
+	var tableEnd uint32
+	var compressed, private []byte
+	var metaOrigLength uint32
+	signature := string(b[:4])
+	if signature == "wOFF" {
+		if len(b) < 44 {
+			return nil, nil, ErrInvalidFontData
+		}
+		numTables := binary.BigEndian.Uint16(b[12:])
+		if uint32(len(b)) < 44+20*uint32(numTables) {
+			return nil, nil, ErrInvalidFontData
+		}
+		for i := 0; i < int(numTables); i++ {
+			entry := b[44+20*i:]
+			end := uint64(binary.BigEndian.Uint32(entry[4:])) + uint64(binary.BigEndian.Uint32(entry[8:]))
+			if uint64(len(b)) < end {
+				return nil, nil, fmt.Errorf("table extends beyond file size")
+			} else if tableEnd < uint32(end) {
+				tableEnd = uint32(end)
+			}
+		}
+		metaOrigLength = binary.BigEndian.Uint32(b[32:])
+		compressed, private, err = readWOFFBlocks(b, tableEnd, b[24:])
+	} else if signature == "wOF2" {
+		if len(b) < 48 {
+			return nil, nil, ErrInvalidFontData
+		}
+		if tableEnd, _, err = woff2Directory(b); err != nil {
+			return nil, nil, err
+		}
+		metaOrigLength = binary.BigEndian.Uint32(b[36:])
+		compressed, private, err = readWOFFBlocks(b, tableEnd, b[28:])
+	} else {
+		return nil, nil, fmt.Errorf("bad signature")
+	}
+	if err != nil {
+		return nil, nil, err
+	} else if compressed == nil {
+		return nil, private, nil
+	} else if MaxMemory < metaOrigLength {
+		return nil, nil, ErrExceedsMemory
+	}
+
+	// decompress metadata using zlib for WOFF and Brotli for WOFF2
+	var r io.Reader
+	if signature == "wOFF" {
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, nil, fmt.Errorf("metadata: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = brotli.NewReader(bytes.NewReader(compressed))
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(r, int64(metaOrigLength)+1)); err != nil {
+		return nil, nil, fmt.Errorf("metadata: %v", err)
+	} else if uint32(buf.Len()) != metaOrigLength {
+		return nil, nil, fmt.Errorf("metadata: decompressed length must be equal to metaOrigLength")
+	}
+
+	data := buf.Bytes()
+	if err := validateWOFFMetadata(data); err != nil {
+		return nil, nil, err
+	}
+	metadata := &WOFFMetadata{}
+	if err := xml.Unmarshal(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")), metadata); err != nil {
+		return nil, nil, fmt.Errorf("metadata: %v", err)
+	}
+	return metadata, private, nil
+}
+
+// readWOFFBlocks returns the compressed metadata block and the private data block from the header fields at h, which must follow the table data block ending at tableEnd in order and each start on a 4-byte boundary. Nothing may follow the last block.
+func readWOFFBlocks(b []byte, tableEnd uint32, h []byte) ([]byte, []byte, error) {
+	metaOffset := binary.BigEndian.Uint32(h[0:])
+	metaLength := binary.BigEndian.Uint32(h[4:])
+	privOffset := binary.BigEndian.Uint32(h[12:])
+	privLength := binary.BigEndian.Uint32(h[16:])
+	if (metaOffset == 0) != (metaLength == 0) {
+		return nil, nil, fmt.Errorf("metaOffset and metaLength must both be zero or non-zero")
+	} else if (privOffset == 0) != (privLength == 0) {
+		return nil, nil, fmt.Errorf("privOffset and privLength must both be zero or non-zero")
+	}
+
+	length := uint64(len(b))
+	end := uint64(tableEnd)
+	var meta, priv []byte
+	if metaOffset != 0 {
+		if uint64(metaOffset) < end {
+			return nil, nil, fmt.Errorf("metadata must follow table data block")
+		} else if metaOffset&3 != 0 {
+			return nil, nil, fmt.Errorf("metadata must start on a 4-byte boundary")
+		} else if (end+3)&^3 != uint64(metaOffset) {
+			return nil, nil, fmt.Errorf("extraneous data before metadata")
+		} else if length < uint64(metaOffset)+uint64(metaLength) {
+			return nil, nil, fmt.Errorf("metadata extends beyond file size")
+		}
+		meta = b[metaOffset : metaOffset+metaLength : metaOffset+metaLength]
+		end = uint64(metaOffset) + uint64(metaLength)
+	}
+	if privOffset != 0 {
+		if uint64(privOffset) < end {
+			if metaOffset != 0 {
+				return nil, nil, fmt.Errorf("private data must follow metadata")
+			}
+			return nil, nil, fmt.Errorf("private data must follow table data block")
+		} else if privOffset&3 != 0 {
+			return nil, nil, fmt.Errorf("private data must start on a 4-byte boundary")
+		} else if (end+3)&^3 != uint64(privOffset) {
+			return nil, nil, fmt.Errorf("extraneous data before private data")
+		} else if length < uint64(privOffset)+uint64(privLength) {
+			return nil, nil, fmt.Errorf("private data extends beyond file size")
+		}
+		for _, c := range b[end:privOffset] {
+			if c != 0 {
+				return nil, nil, fmt.Errorf("padding must be zero")
+			}
+		}
+		priv = b[privOffset : privOffset+privLength : privOffset+privLength]
+		end = uint64(privOffset) + uint64(privLength)
+	}
+
+	// the table data block may be padded when it is last
+	if metaOffset == 0 && privOffset == 0 && length == (end+3)&^3 {
+		end = length
+	}
+	if end != length {
+		return nil, nil, fmt.Errorf("extraneous data at the end of the file")
+	}
+	return meta, priv, nil
+}
+
+// woff2Directory reads the table and collection directories of a WOFF2 font and returns the end of the compressed font data and the number of fonts.
+func woff2Directory(b []byte) (uint32, int, error) {
+	r := newBinaryReader(b)
+	_ = r.ReadBytes(4) // signature
+	flavor := r.ReadString(4)
+	_ = r.ReadBytes(4) // length
+	numTables := r.ReadUint16()
+	_ = r.ReadBytes(6) // reserved and totalSfntSize
+	totalCompressedSize := r.ReadUint32()
+	_ = r.ReadBytes(24) // majorVersion to privLength
+	for i := 0; i < int(numTables); i++ {
+		flags := r.ReadUint8()
+		if flags&0x3F == 63 {
+			_ = r.ReadUint32() // tag
+		}
+		if _, err := readUintBase128(r); err != nil {
+			return 0, 0, err
+		}
+		tagIndex := flags & 0x3F
+		transformVersion := flags >> 6
+		if (tagIndex == 10 || tagIndex == 11) && transformVersion == 0 || tagIndex == 3 && transformVersion == 1 {
+			if _, err := readUintBase128(r); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	numFonts := 1
+	if flavor == "ttcf" {
+		_ = r.ReadUint32() // version
+		numFonts = int(read255Uint16(r))
+		for i := 0; i < numFonts; i++ {
+			n := read255Uint16(r)
+			_ = r.ReadUint32() // flavor
+			for j := 0; j < int(n); j++ {
+				_ = read255Uint16(r) // index
+			}
+		}
+	}
+	end := uint64(r.Pos()) + uint64(totalCompressedSize)
+	if r.Err() != nil || uint64(len(b)) < end {
+		return 0, 0, ErrInvalidFontData
+	}
+	return uint32(end), numFonts, nil
+}
+
+type woffMetadataSchema struct {
+	attrs    map[string]bool // attribute names, true if required
+	children []woffMetadataChild
+	text     bool // character data is allowed
+}
+
+type woffMetadataChild struct {
+	name     string
+	min, max int // max is -1 for unbounded
+	schema   *woffMetadataSchema
+}
+
+func newWOFFMetadataSchema() *woffMetadataSchema {
+	textAttrs := map[string]bool{"lang": false, "dir": false, "class": false}
+	plain := &woffMetadataSchema{attrs: textAttrs, text: true}
+	block := &woffMetadataSchema{attrs: map[string]bool{"dir": false, "class": false}, text: true}
+	block.children = []woffMetadataChild{{"div", 0, -1, block}, {"span", 0, -1, block}}
+	text := &woffMetadataSchema{attrs: textAttrs, children: block.children, text: true}
+	texts := func(attrs map[string]bool, min int) *woffMetadataSchema {
+		return &woffMetadataSchema{attrs: attrs, children: []woffMetadataChild{{"text", min, -1, text}}}
+	}
+	return &woffMetadataSchema{
+		attrs: map[string]bool{"version": true},
+		children: []woffMetadataChild{
+			{"uniqueid", 0, 1, &woffMetadataSchema{attrs: map[string]bool{"id": true}}},
+			{"vendor", 0, 1, &woffMetadataSchema{attrs: map[string]bool{"name": true, "url": false, "dir": false, "class": false}}},
+			{"credits", 0, 1, &woffMetadataSchema{children: []woffMetadataChild{
+				{"credit", 1, -1, &woffMetadataSchema{attrs: map[string]bool{"name": true, "url": false, "role": false, "dir": false, "class": false}}},
+			}}},
+			{"description", 0, 1, texts(map[string]bool{"url": false}, 1)},
+			{"license", 0, 1, texts(map[string]bool{"url": false, "id": false}, 0)},
+			{"copyright", 0, 1, texts(nil, 1)},
+			{"trademark", 0, 1, texts(nil, 1)},
+			{"licensee", 0, 1, &woffMetadataSchema{attrs: map[string]bool{"name": true, "dir": false, "class": false}}},
+			{"extension", 0, -1, &woffMetadataSchema{
+				attrs: map[string]bool{"id": false},
+				children: []woffMetadataChild{
+					{"name", 0, -1, plain},
+					{"item", 1, -1, &woffMetadataSchema{
+						attrs: map[string]bool{"id": false},
+						children: []woffMetadataChild{
+							{"name", 1, -1, plain},
+							{"value", 1, -1, plain},
+						},
+					}},
+				},
+			}},
+		},
+	}
+}
+
+// validateWOFFMetadata checks that the metadata is well-formed UTF-8 encoded XML that conforms to the metadata schema.
+func validateWOFFMetadata(b []byte) error {
+	b = bytes.TrimPrefix(b, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(b) {
+		return fmt.Errorf("metadata: must be UTF-8 encoded")
+	}
+
+	type element struct {
+		schema *woffMetadataSchema
+		counts []int
+	}
+	root := newWOFFMetadataSchema()
+	stack := []element{}
+	hasRoot := false
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("metadata: %v", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var schema *woffMetadataSchema
+			if len(stack) == 0 {
+				if hasRoot {
+					return fmt.Errorf("metadata: root element must occur once")
+				} else if tok.Name.Local != "metadata" {
+					return fmt.Errorf("metadata: root element must be metadata")
+				}
+				schema = root
+				hasRoot = true
+			} else {
+				parent := stack[len(stack)-1]
+				for i, child := range parent.schema.children {
+					if child.name == tok.Name.Local {
+						parent.counts[i]++
+						if child.max != -1 && child.max < parent.counts[i] {
+							return fmt.Errorf("metadata: %s element occurs too often", tok.Name.Local)
+						}
+						schema = child.schema
+						break
+					}
+				}
+				if schema == nil {
+					return fmt.Errorf("metadata: unknown %s element", tok.Name.Local)
+				}
+			}
+
+			attrs := map[string]bool{}
+			for _, attr := range tok.Attr {
+				if attr.Name.Space != "" && (attr.Name.Space != "http://www.w3.org/XML/1998/namespace" || attr.Name.Local != "lang") {
+					return fmt.Errorf("metadata: unknown %s attribute in %s element", attr.Name.Local, tok.Name.Local)
+				} else if _, ok := schema.attrs[attr.Name.Local]; !ok {
+					return fmt.Errorf("metadata: unknown %s attribute in %s element", attr.Name.Local, tok.Name.Local)
+				} else if attr.Name.Local == "dir" && attr.Value != "ltr" && attr.Value != "rtl" {
+					return fmt.Errorf("metadata: dir attribute must be ltr or rtl")
+				} else if attr.Name.Local == "version" && attr.Value != "1.0" {
+					return fmt.Errorf("metadata: version attribute must be 1.0")
+				}
+				attrs[attr.Name.Local] = true
+			}
+			for name, required := range schema.attrs {
+				if required && !attrs[name] {
+					return fmt.Errorf("metadata: %s element must have %s attribute", tok.Name.Local, name)
+				}
+			}
+			stack = append(stack, element{schema, make([]int, len(schema.children))})
+		case xml.EndElement:
+			elem := stack[len(stack)-1]
+			for i, child := range elem.schema.children {
+				if elem.counts[i] < child.min {
+					return fmt.Errorf("metadata: %s element must have %s element", tok.Name.Local, child.name)
+				}
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if (len(stack) == 0 || !stack[len(stack)-1].schema.text) && len(bytes.TrimSpace(tok)) != 0 {
+				return fmt.Errorf("metadata: unexpected content")
+			}
+		}
+	}
+	if !hasRoot {
+		return fmt.Errorf("metadata: root element must be metadata")
+	}
+	return nil
+}
+
+// writeWOFFMetadata encodes the metadata as UTF-8 XML.
+func writeWOFFMetadata(metadata *WOFFMetadata) ([]byte, error) {
+	m := *metadata
+	if m.Version == "" {
+		m.Version = "1.0"
+	}
+	b, err := xml.MarshalIndent(&m, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %v", err)
+	}
+	b = append([]byte(xml.Header), b...)
+	if err := validateWOFFMetadata(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// writeWOFFBlocks appends the compressed metadata and the private data blocks and sets their location in the header fields at offset h. The table data block must already be padded to a 4-byte boundary.
+func writeWOFFBlocks(w *parse.BinaryWriter, h int, compMetadata []byte, metaOrigLength uint32, private []byte) {
+	var metaOffset, privOffset uint32
+	if 0 < len(compMetadata) {
+		metaOffset = uint32(w.Len())
+		w.WriteBytes(compMetadata)
+	}
+	if 0 < len(private) {
+		for w.Len()&3 != 0 {
+			w.WriteByte(0) // padding
+		}
+		privOffset = uint32(w.Len())
+		w.WriteBytes(private)
+	}
+
+	b := w.Bytes()
+	binary.BigEndian.PutUint32(b[h:], metaOffset)
+	binary.BigEndian.PutUint32(b[h+4:], uint32(len(compMetadata)))
+	binary.BigEndian.PutUint32(b[h+8:], metaOrigLength)
+	binary.BigEndian.PutUint32(b[h+12:], privOffset)
+	binary.BigEndian.PutUint32(b[h+16:], uint32(len(private)))
+}
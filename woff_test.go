@@ -1,8 +1,12 @@
 package font
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/tdewolff/test"
@@ -24,6 +28,39 @@ func TestWOFFError(t *testing.T) {
 	}
 }
 
+func TestWOFFWrite(t *testing.T) {
+	for _, filename := range []string{"DejaVuSerif.ttf", "EBGaramond12-Regular.otf"} {
+		t.Run(filename, func(t *testing.T) {
+			b, err := ioutil.ReadFile("resources/" + filename)
+			test.Error(t, err)
+
+			sfnt, err := ParseSFNT(b, 0)
+			test.Error(t, err)
+
+			woff, err := sfnt.WriteWOFF()
+			test.Error(t, err)
+			test.T(t, len(woff)%4, 0)
+			test.That(t, len(woff) < len(b))
+
+			// tables are never larger than their uncompressed size
+			numTables := int(binary.BigEndian.Uint16(woff[12:]))
+			test.T(t, numTables, len(sfnt.Tables))
+			for i := 0; i < numTables; i++ {
+				entry := woff[44+20*i:]
+				test.That(t, binary.BigEndian.Uint32(entry[8:]) <= binary.BigEndian.Uint32(entry[12:]), string(entry[:4]))
+			}
+
+			b2, err := ParseWOFF(woff)
+			test.Error(t, err)
+			sfnt2, err := ParseSFNT(b2, 0)
+			test.Error(t, err)
+			test.T(t, sfnt2.NumGlyphs(), sfnt.NumGlyphs())
+			test.T(t, sfnt2.GlyphIndex('A'), sfnt.GlyphIndex('A'))
+			test.T(t, sfnt2.GlyphAdvance(sfnt.GlyphIndex('A')), sfnt.GlyphAdvance(sfnt.GlyphIndex('A')))
+		})
+	}
+}
+
 func TestWOFFValidationFormat(t *testing.T) {
 	var tts = []struct {
 		filename string
@@ -48,19 +85,25 @@ func TestWOFFValidationFormat(t *testing.T) {
 		{"header-totalSfntSize-002", "totalSfntSize is incorrect"},
 		{"header-totalSfntSize-003", "totalSfntSize is incorrect"},
 		//{"blocks-extraneous-data-001", "err"}, // bad test?
-		//{"blocks-extraneous-data-002", "err"}, // bad test?
-		//{"blocks-extraneous-data-003", "err"},
-		//{"blocks-extraneous-data-004", "err"},
-		//{"blocks-extraneous-data-005", "err"},
-		//{"blocks-extraneous-data-006", "err"},
-		//{"blocks-extraneous-data-007", "err"},
-		//{"blocks-ordering-001", "metadata must follow table data block"},
-		//{"blocks-ordering-002", "private data without metadata must follow table data block"},
-		//{"blocks-ordering-003", "metadata must follow table data block"},
-		//{"blocks-ordering-004", "metadata must follow table data block"},
-		//{"blocks-overlap-001", "tables can not overlap"},
-		//{"blocks-overlap-002", "tables can not overlap"},
-		//{"blocks-overlap-003", "tables can not overlap"},
+		{"blocks-extraneous-data-002", "extraneous data at the end of the file"},
+		{"blocks-extraneous-data-003", "extraneous data before metadata"},
+		{"blocks-extraneous-data-004", "extraneous data before private data"},
+		{"blocks-extraneous-data-005", "extraneous data before private data"},
+		{"blocks-extraneous-data-006", "extraneous data at the end of the file"},
+		{"blocks-extraneous-data-007", "extraneous data at the end of the file"},
+		{"blocks-ordering-001", "metadata must follow table data block"},
+		{"blocks-ordering-002", "private data must follow table data block"},
+		{"blocks-ordering-003", "extraneous data before metadata"},
+		{"blocks-ordering-004", "extraneous data before metadata"},
+		{"blocks-overlap-001", "metadata must follow table data block"},
+		{"blocks-overlap-002", "private data must follow table data block"},
+		{"blocks-overlap-003", "private data must follow metadata"},
+		{"blocks-metadata-absent-001", "metaOffset and metaLength must both be zero or non-zero"},
+		{"blocks-metadata-absent-002", "metaOffset and metaLength must both be zero or non-zero"},
+		{"blocks-private-absent-001", "privOffset and privLength must both be zero or non-zero"},
+		{"blocks-private-absent-002", "privOffset and privLength must both be zero or non-zero"},
+		{"blocks-metadata-padding-001", "extraneous data at the end of the file"},
+		{"blocks-private-001", "private data must start on a 4-byte boundary"},
 		{"directory-4-byte-001", "totalSfntSize is incorrect"},
 		//{"directory-4-byte-002", "err"}, // bad test?
 		//{"directory-4-byte-003", "err"}, // we do not test this
@@ -97,3 +140,89 @@ func TestWOFFValidationFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestWOFFMetadataValidationFormat(t *testing.T) {
+	validityRe := regexp.MustCompile(`id="([a-zA-Z0-9-]+)-validity">(Yes|No)<`)
+	for _, dir := range []string{"woff_format", "woff2_format"} {
+		index, err := ioutil.ReadFile("testdata/" + dir + "/testcaseindex.xht")
+		test.Error(t, err)
+
+		for _, match := range validityRe.FindAllSubmatch(index, -1) {
+			name, valid := string(match[1]), string(match[2]) == "Yes"
+			if !strings.HasPrefix(name, "metadata-") && !strings.HasPrefix(name, "blocks-") && !strings.HasPrefix(name, "valid-") {
+				continue
+			} else if name == "blocks-extraneous-data-001" {
+				continue // data between the table directory and table data is not checked
+			}
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				filenames, err := filepath.Glob("testdata/" + dir + "/" + name + ".woff*")
+				test.Error(t, err)
+				test.T(t, len(filenames), 1)
+				b, err := ioutil.ReadFile(filenames[0])
+				test.Error(t, err)
+				_, _, err = ParseWOFFMetadata(b)
+				if valid {
+					test.Error(t, err)
+				} else if err == nil {
+					test.Fail(t, "must give error")
+				}
+			})
+		}
+	}
+}
+
+func TestWOFFMetadata(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/woff_format/valid-008.woff")
+	test.Error(t, err)
+	metadata, private, err := ParseWOFFMetadata(b)
+	test.Error(t, err)
+	test.T(t, metadata.Version, "1.0")
+	test.T(t, metadata.Vendor.Name, "Test Vendor")
+	test.T(t, metadata.License.URL, "http://w3c.org/Fonts")
+	test.That(t, 0 < len(metadata.Credits.Credits))
+	test.That(t, 0 < len(private))
+
+	b, err = ioutil.ReadFile("resources/DejaVuSerif.ttf")
+	test.Error(t, err)
+	sfnt, err := ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	metadata = &WOFFMetadata{
+		UniqueID: &WOFFUniqueID{"org.dejavu-fonts.serif"},
+		Vendor:   &WOFFVendor{Name: "DejaVu", URL: "https://dejavu-fonts.github.io/"},
+		Credits:  &WOFFCredits{[]WOFFCredit{{Name: "Bitstream", Role: "Design"}}},
+		License: &WOFFLicense{
+			URL:   "https://dejavu-fonts.github.io/License.html",
+			Texts: []WOFFText{{Lang: "en", Content: "Free &amp; open <span>license</span>"}},
+		},
+		Copyright: &WOFFTexts{[]WOFFText{{Content: "Copyright (c) 2003 by Bitstream, Inc."}}},
+	}
+	private = []byte("private")
+
+	woff, err := sfnt.WriteWOFFOptions(WOFFOptions{Metadata: metadata, PrivateData: private})
+	test.Error(t, err)
+	woff2, err := sfnt.WriteWOFF2Options(WOFF2Options{Metadata: metadata, PrivateData: private})
+	test.Error(t, err)
+	for _, b := range [][]byte{woff, woff2} {
+		metadata2, private2, err := ParseWOFFMetadata(b)
+		test.Error(t, err)
+		test.T(t, metadata2.Version, "1.0")
+		test.T(t, *metadata2.UniqueID, *metadata.UniqueID)
+		test.T(t, *metadata2.Vendor, *metadata.Vendor)
+		test.T(t, *metadata2.Credits, *metadata.Credits)
+		test.T(t, metadata2.License.URL, metadata.License.URL)
+		test.T(t, metadata2.License.Texts[0].Lang, "en")
+		test.T(t, metadata2.License.Texts[0].String(), "Free & open license")
+		test.T(t, *metadata2.Copyright, *metadata.Copyright)
+		test.T(t, private2, private)
+	}
+
+	b, err = ParseWOFF(woff)
+	test.Error(t, err)
+	_, err = ParseSFNT(b, 0)
+	test.Error(t, err)
+
+	// credits without credit elements
+	_, err = sfnt.WriteWOFFOptions(WOFFOptions{Metadata: &WOFFMetadata{Credits: &WOFFCredits{}}})
+	test.T(t, err.Error(), "metadata: credits element must have credit element")
+}